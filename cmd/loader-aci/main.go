@@ -0,0 +1,101 @@
+// Package main содержит загрузчик НКД облигаций
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package main
+
+import (
+	"context"
+	"log"
+	"market-loader/internal/app"
+	"market-loader/pkg/config"
+	"market-loader/pkg/logs"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run выполняет загрузку и возвращает код завершения
+func run() int {
+	// Определяем путь к конфигурации
+	configPath := config.GetConfigPath()
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+	}
+
+	// Настраиваем логирование
+	logger := logs.SetupLogger(cfg)
+
+	logger.Info("Запуск загрузчика НКД облигаций")
+
+	// Проверяем валидность даты начала загрузки
+	startDate := cfg.GetStartDate()
+	if config.IsFutureDate(startDate) {
+		logger.Fatalf("Дата начала загрузки (%s) не может быть в будущем", startDate.Format("2006-01-02"))
+	}
+
+	// Создаем контекст
+	ctx := context.Background()
+
+	// Подключение и получение исходных данных
+	instance, err := app.Initialize(ctx, cfg, startDate, logger, app.LoaderACI)
+	if err != nil {
+		logger.Errorf("Ошибка инициализации: %v", err)
+		return app.ExitCode(app.RunStats{}, err)
+	}
+	defer instance.Close()
+
+	// Пропускаем запуск, если предыдущий завершился недавно
+	skip, err := app.ShouldSkipRun(ctx, instance.DBPool, app.LoaderACI, "", cfg, logger)
+	if err != nil {
+		logger.Warnf("Ошибка проверки предыдущего запуска: %v", err)
+	}
+	if skip {
+		return app.ExitNothingToDo
+	}
+	runID := app.StartRun(ctx, instance.DBPool, app.LoaderACI, "", logger)
+
+	bondCount := 0
+	failedCount := 0
+	// Обрабатываем только облигации (instance.Instruments содержит только enabled=true)
+	for _, instrument := range instance.Instruments {
+		if instrument.InstrumentType != config.InstrumentTypeBond {
+			continue
+		}
+
+		if err := app.ProcessInstrumentACI(ctx, instance.Client, instance.DBPool, instrument, cfg, logger); err != nil {
+			logger.WithFields(logrus.Fields{
+				"figi":   instrument.Figi,
+				"ticker": instrument.Ticker,
+				"name":   instrument.Name,
+				"error":  err,
+			}).Error("Ошибка обработки НКД облигации")
+			failedCount++
+			continue
+		}
+
+		// Пауза между запросами
+		time.Sleep(cfg.GetRateLimitPause())
+
+		bondCount++
+	}
+	logger.Debugf("Обработано облигаций %d", bondCount)
+
+	app.FinishRun(ctx, instance.DBPool, runID, bondCount+failedCount, failedCount, nil, logger)
+
+	logger.Info("Загрузка НКД завершена")
+
+	return app.ExitCode(app.RunStats{Total: bondCount + failedCount, Failed: failedCount}, nil)
+}
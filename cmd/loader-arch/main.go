@@ -12,17 +12,117 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"market-loader/internal/app"
 	"market-loader/internal/arch"
+	"market-loader/internal/data"
 	"market-loader/internal/storage"
 	"market-loader/pkg/config"
 	"market-loader/pkg/logs"
 	"os"
+	"sort"
 	"time"
+
+	"github.com/spf13/cobra"
 )
 
+var (
+	// Флаги командной строки
+	archiveFile      string
+	decimalSeparator string
+	tempDirFlag      string
+
+	// Корневая команда: загрузка архивов по всем инструментам
+	rootCmd = &cobra.Command{
+		Use:   "loader-arch",
+		Short: "Загрузка минутных свечей через архивы",
+		Run: func(_ *cobra.Command, _ []string) {
+			os.Exit(runLoad())
+		},
+	}
+
+	// Проверка архива без загрузки в БД
+	validateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Проверить архив без загрузки в БД",
+		Long: `Разбирает все CSV файлы ZIP архива так же, как при загрузке,
+и выводит статистику: файлы, строки, свечи, отклонённые строки с причинами, период.
+Подключение к БД и API не требуется.
+
+Пример использования:
+  loader-arch validate --file BBG004730N88_2024.zip`,
+		RunE: runValidate,
+	}
+)
+
+func init() {
+	rootCmd.Flags().StringVar(&tempDirFlag, "temp-dir", "", "Директория для скачиваемых архивов (по умолчанию archive.temp_dir или системная временная)")
+
+	validateCmd.Flags().StringVarP(&archiveFile, "file", "f", "", "Путь к ZIP архиву")
+	if err := validateCmd.MarkFlagRequired("file"); err != nil {
+		log.Fatalf("Ошибка настройки флагов: %v", err)
+	}
+	validateCmd.Flags().StringVar(&decimalSeparator, "decimal-separator", config.DecimalSeparatorDot, "Десятичный разделитель чисел в CSV (\".\" или \",\")")
+	rootCmd.AddCommand(validateCmd)
+}
+
 func main() {
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(app.ExitCode(app.RunStats{}, err))
+	}
+}
+
+// runValidate проверяет архив и выводит статистику
+func runValidate(_ *cobra.Command, _ []string) error {
+	cfg := &config.Config{}
+	cfg.Archive.DecimalSeparator = decimalSeparator
+	separator, err := cfg.GetDecimalSeparator()
+	if err != nil {
+		return fmt.Errorf("ошибка параметра --decimal-separator: %w", err)
+	}
+	arch.SetDecimalSeparator(separator)
+
+	stats, err := arch.ValidateArchive(archiveFile)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки архива: %w", err)
+	}
+
+	fmt.Printf("Архив: %s\n", archiveFile)
+	fmt.Printf("Файлов CSV: %d\n", stats.Files)
+	fmt.Printf("Строк: %d\n", stats.Rows)
+	fmt.Printf("Свечей: %d\n", stats.Candles)
+	fmt.Printf("Дублей времени: %d\n", stats.Duplicates)
+	fmt.Printf("Отклонено строк: %d\n", stats.Rejected)
+	if stats.Candles > 0 {
+		fmt.Printf("Период: %s - %s\n", stats.First.Format(time.RFC3339), stats.Last.Format(time.RFC3339))
+	}
+
+	if stats.Rejected > 0 {
+		reasons := make([]string, 0, len(stats.RejectReasons))
+		for reason := range stats.RejectReasons {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+
+		fmt.Println("Причины отклонения:")
+		for _, reason := range reasons {
+			fmt.Printf("  %s: %d\n", reason, stats.RejectReasons[reason])
+		}
+
+		fmt.Println("Примеры отклонённых строк:")
+		for _, row := range stats.Samples {
+			fmt.Printf("  %s:%d %s: %s\n", row.File, row.Line, row.Reason, row.Detail)
+		}
+	}
+
+	return nil
+}
+
+// runLoad загружает архивы свечей по всем активным инструментам и возвращает код завершения
+func runLoad() int {
 	// Определяем путь к конфигурации
 	configPath := config.GetConfigPath()
 
@@ -32,14 +132,19 @@ func main() {
 		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
 	}
 
+	// Директория для архивов из командной строки важнее конфигурации
+	if tempDirFlag != "" {
+		cfg.Archive.TempDir = tempDirFlag
+	}
+
 	// Настраиваем логирование
 	logger := logs.SetupLogger(cfg)
 
 	logger.Info("Запуск загрузчика минутных данных через архивы")
 
 	// Логируем настройки лимитов
-	if cfg.Loading.RateLimitPause > 0 {
-		logger.Debugf("Установлена пауза между запросами: %d секунд (API limit)", cfg.Loading.RateLimitPause)
+	if pause := cfg.GetRateLimitPause(); pause > 0 {
+		logger.Debugf("Установлена пауза между запросами: %s (API limit)", pause)
 	} else {
 		logger.Debug("Пауза между запросами не установлена (API limit)")
 	}
@@ -64,40 +169,40 @@ func main() {
 	// Подключение и получение исходных данных
 	instance, err := app.Initialize(ctx, cfg, startDate, logger, "instruments")
 	if err != nil {
-		logger.Fatalf("Ошибка инициализации: %v", err)
+		logger.Errorf("Ошибка инициализации: %v", err)
+		return app.ExitCode(app.RunStats{}, err)
 	}
-	defer instance.DBPool.Close()
+	defer instance.Close()
 
 	logger.WithField("count", len(instance.Instruments)).Debug("Количество активных (enabled=true) инструментов в БД")
 
-	// Определяем временную директорию для архивов
-	var tempDir string
-	if cfg.Archive.TempDir != "" {
-		// Используем настроенную директорию
-		tempDir = cfg.Archive.TempDir
-		// Создаем директорию, если она не существует
-		if err := os.MkdirAll(tempDir, config.DefaultDirPerm); err != nil {
-			logger.Fatalf("Ошибка создания временной директории %s: %v", tempDir, err)
-		}
-	} else {
-		// Используем системную временную директорию
-		var err error
-		tempDir, err = os.MkdirTemp("", "tinvest_archives")
-		if err != nil {
-			logger.Fatalf("Ошибка создания временной директории: %v", err)
-		}
-		defer func() {
-			if err := os.RemoveAll(tempDir); err != nil {
-				logger.Errorf("Ошибка удаления временной директории: %v", err)
-			}
-		}()
+	// Определяем временную директорию для архивов (--temp-dir, archive.temp_dir или системная),
+	// созданная загрузчиком директория удаляется по политике archive.cleanup
+	tempDir, err := arch.OpenTempDir(cfg.Archive.TempDir)
+	if err != nil {
+		logger.Errorf("Ошибка подготовки временной директории: %v", err)
+		return app.ExitCode(app.RunStats{}, err)
 	}
+	failed := 0
+	defer func() {
+		tempDir.Close(failed > 0, logger)
+	}()
+
+	// Ограничение времени загрузки (loading.max_run_duration)
+	runCtx, cancelRun := app.WithRunDeadline(ctx, cfg)
+	defer cancelRun()
 
 	// Загружаем данные по каждому инструменту
+	tokens := cfg.GetTokens()
 	totalCandles := 0
 	requestCount := 0
+	budgetExhausted := false
+	var runErr error
 
-	for _, instrument := range instance.Instruments {
+	for i, instrument := range instance.Instruments {
+		if budgetExhausted || runErr != nil {
+			break
+		}
 		logger.Infof("Загрузка данных для %s (%s)", instrument.Ticker, instrument.Figi)
 
 		start := startYear
@@ -107,23 +212,46 @@ func main() {
 		}
 
 		instrumentCandles := 0
+		instrumentFailed := false
 		for year := start; year <= currentYear; year++ {
+			// Время загрузки истекло: загруженные годы сохранены
+			if runCtx.Err() != nil {
+				runErr = data.RunStopError(runCtx)
+				logger.Warnf("Время загрузки истекло (loading.max_run_duration): обработано %d из %d инструментов", i, len(instance.Instruments))
+				break
+			}
+
+			// Бюджет запросов на запуск (loading.max_requests_per_run)
+			if !data.TakeRequest() {
+				logger.Warnf("Бюджет запросов к API исчерпан (запросов: %d), продолжение в следующем запуске", requestCount)
+				budgetExhausted = true
+				break
+			}
+
 			// Создаем партиции для года заранее
 			logger.Infof("Создание партиций для %d года...", year)
-			if err := storage.CreateYearPartitions(instance.DBPool, year); err != nil {
+			if err := storage.CreateYearPartitions(instance.DBPool, config.CandleInterval1Min, year); err != nil {
 				logger.Warnf("Ошибка создания партиций за %d год для %s: %v", year, instrument.Ticker, err)
+				instrumentFailed = true
 				continue
 			}
 
 			// Проверяем лимиты API
-			if cfg.Loading.RateLimitPause > 0 {
-				logger.Infof("Пауза %d секунд для соблюдения лимитов API...", cfg.Loading.RateLimitPause)
-				time.Sleep(time.Duration(cfg.Loading.RateLimitPause) * time.Second)
+			if pause := cfg.GetRateLimitPause(); pause > 0 {
+				logger.Infof("Пауза %s для соблюдения лимитов API...", pause)
+				time.Sleep(pause)
 			}
 
-			candles, err := arch.DownloadYearArchive(ctx, cfg.Tinvest.Token, instrument.Figi, year, tempDir, instance.DBPool, logger)
+			// Архивы скачиваются по очереди с каждым токеном (tinvest.tokens)
+			token := tokens[requestCount%len(tokens)]
+			candles, err := arch.DownloadYearArchive(ctx, token, instrument.Figi, year, tempDir.Path, cfg.GetArchiveMaxSize(), instance.DBPool, logger)
+			if errors.Is(err, arch.ErrArchiveUnchanged) {
+				requestCount++
+				continue
+			}
 			if err != nil {
 				logger.Warnf("Ошибка загрузки архива за %d год для %s: %v", year, instrument.Ticker, err)
+				instrumentFailed = true
 				continue
 			}
 
@@ -134,8 +262,14 @@ func main() {
 		}
 
 		totalCandles += instrumentCandles
+		if instrumentFailed {
+			failed++
+		}
 		logger.Infof("Всего загружено %d свечей для %s", instrumentCandles, instrument.Ticker)
 	}
 
+	storage.LogSaveSummary(logger)
 	logger.Infof("Загрузка завершена. Всего загружено %d свечей", totalCandles)
+
+	return app.ExitCode(app.RunStats{Total: len(instance.Instruments), Failed: failed}, runErr)
 }
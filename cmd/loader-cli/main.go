@@ -10,25 +10,54 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"market-loader/internal/app"
+	"market-loader/internal/data"
 	"market-loader/internal/storage"
 	"market-loader/pkg/config"
 	"market-loader/pkg/logs"
 	"os"
+	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var (
 	// Флаги командной строки
-	interval   string
-	figi       string
+	intervals  []string
+	figis      []string
 	startDate  string
 	configPath string
+	profile    string
+	newOnly    bool
+	retryFail  bool
+	eventDates []string
+	window     string
+	group      string
+	asOf       string
+
+	// Флаги list-instruments
+	listType        string
+	listTicker      string
+	listEnabledOnly bool
+
+	// Флаги tail
+	tailFigi     string
+	tailInterval string
+	tailCount    int
+
+	// Флаги coverage
+	coverageInterval string
+	coverageAll      bool
+
+	// Код завершения по итогам загрузки
+	exitCode int
 
 	// Корневая команда
 	rootCmd = &cobra.Command{
@@ -38,20 +67,102 @@ var (
 
 Примеры использования:
   t-loader_cli --figi BBG000B9XRY4 --interval 1min
+  t-loader_cli --figi BBG000B9XRY4,BBG004730N88 --interval 1day
+  t-loader_cli -f BBG000B9XRY4 -f BBG004730N88 --interval 1day
   t-loader_cli --figi BBG000B9XRY4 --interval 1hour --start-date 2024-01-01
-  t-loader_cli --figi BBG000B9XRY4 --interval 1day --start-date 2024-01-01 --debug`,
+  t-loader_cli --figi BBG000B9XRY4 --interval 1day --start-date 2024-01-01 --debug
+  t-loader_cli --figi BBG000B9XRY4 --interval 1min,1hour,1day
+  t-loader_cli --interval 1min --dates 2024-02-15,2024-05-10 --window 3d
+  t-loader_cli --interval 1min --retry-failed
+  t-loader_cli --interval 1day --group tech
+  t-loader_cli --interval 1day --start-date 2020-01-01 --as-of 2024-06-01T00:00:00Z`,
 		RunE: runLoader,
 	}
+
+	// Команда вывода списка инструментов
+	listInstrumentsCmd = &cobra.Command{
+		Use:   "list-instruments",
+		Short: "Список инструментов из БД",
+		Long: `Вывод списка инструментов из представления instrument_view с названием источника данных.
+
+Примеры использования:
+  t-loader_cli list-instruments
+  t-loader_cli list-instruments --type share --enabled
+  t-loader_cli list-instruments --ticker SBER`,
+		RunE: runListInstruments,
+	}
+
+	// Команда вывода последних свечей инструмента
+	tailCmd = &cobra.Command{
+		Use:   "tail",
+		Short: "Последние свечи инструмента из БД",
+		Long: `Вывод последних N свечей инструмента по интервалу (по возрастанию времени),
+например, чтобы проверить, что последняя загрузка дошла до БД.
+
+Примеры использования:
+  t-loader_cli tail --figi BBG004730N88
+  t-loader_cli tail --figi BBG004730N88 --interval 1day -n 5`,
+		RunE: runTail,
+	}
+
+	// Команда вывода полноты загрузки
+	coverageCmd = &cobra.Command{
+		Use:   "coverage",
+		Short: "Недогруженные инструменты по торговому календарю",
+		Long: `Вывод полноты загрузки включённых инструментов из таблицы load_coverage:
+загружено свечей, ожидаемое количество по торговому календарю и их отношение.
+Полнота пересчитывается после каждой загрузки при loading.coverage.enabled.
+По умолчанию выводятся только инструменты, не считающиеся загруженными.
+
+Примеры использования:
+  t-loader_cli coverage --interval 1day
+  t-loader_cli coverage --interval 1day --all`,
+		RunE: runCoverage,
+	}
+
+	// Команды управления группами инструментов
+	groupCmd = &cobra.Command{
+		Use:   "group",
+		Short: "Группы инструментов (портфели, секторы)",
+		Long: `Управление группами инструментов в БД (watchlist_groups, watchlist_members).
+Группа используется для загрузки (t-loader_cli --group) и выгрузки (loader-export --group).
+Инструменты задаются FIGI или тикером через запятую или отдельными аргументами.
+
+Примеры использования:
+  t-loader_cli group add tech SBER,YNDX
+  t-loader_cli group remove tech YNDX
+  t-loader_cli group remove tech
+  t-loader_cli group list
+  t-loader_cli group list tech`,
+	}
+	groupAddCmd = &cobra.Command{
+		Use:   "add <группа> <инструменты>",
+		Short: "Добавить инструменты в группу (группа создаётся при необходимости)",
+		Args:  cobra.MinimumNArgs(2),
+		RunE:  runGroupAdd,
+	}
+	groupRemoveCmd = &cobra.Command{
+		Use:   "remove <группа> [инструменты]",
+		Short: "Удалить инструменты из группы, без инструментов - удалить группу",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runGroupRemove,
+	}
+	groupListCmd = &cobra.Command{
+		Use:   "list [группа]",
+		Short: "Список групп или инструментов группы",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  runGroupList,
+	}
 )
 
 func runLoader(cmd *cobra.Command, _ []string) error {
 	// Определяем путь к конфигурации
-	if !cmd.Flags().Changed("config") {
+	if !cmd.Flags().Changed("conf") {
 		configPath = config.GetConfigPath()
 	}
 
 	// Загружаем конфигурацию
-	cfg, err := config.LoadConfig(configPath)
+	cfg, err := config.LoadConfigProfile(configPath, profile)
 	if err != nil {
 		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
 	}
@@ -61,11 +172,32 @@ func runLoader(cmd *cobra.Command, _ []string) error {
 
 	logger.Info("Запуск CLI загрузчика свечей")
 
-	// Определяем интервал
-	// Выходим если не задан
-	intervalType, err := config.ParseInterval(interval)
-	if err != nil {
-		logger.Fatalf("Ошибка парсинга интервала: %v", err)
+	// Фиксируем текущий момент запуска: данные загружаются такими, какими были на эту дату
+	if asOf != "" {
+		pinned, err := config.ParseAsOf(asOf)
+		if err != nil {
+			logger.Fatalf("Ошибка парсинга --as-of: %v", err)
+		}
+		if pinned.After(time.Now()) {
+			logger.Fatalf("Момент --as-of (%s) не может быть в будущем", pinned.Format(time.RFC3339))
+		}
+		config.SetAsOf(pinned)
+		logger.WithField("asOf", pinned.Format(time.RFC3339)).Info("Загрузка на зафиксированный момент, свечи позже него не загружаются")
+	}
+
+	// Определяем интервалы
+	// Выходим если не заданы
+	var intervalTypes, intervalNames []string
+	for _, interval := range intervals {
+		intervalType, err := config.ParseInterval(interval)
+		if err != nil {
+			logger.Fatalf("Ошибка парсинга интервала: %v", err)
+		}
+		intervalTypes = append(intervalTypes, intervalType)
+		intervalNames = append(intervalNames, config.Interval2text(intervalType))
+	}
+	if len(intervalTypes) == 0 {
+		logger.Fatal("Не задан интервал")
 	}
 
 	// Читаем дату из конфига если нет параметра
@@ -73,19 +205,47 @@ func runLoader(cmd *cobra.Command, _ []string) error {
 		startDate = cfg.Loading.StartDate
 	}
 	// Проверяем валидность даты начала загрузки
-	parsedTime, err := time.Parse("2006-01-02", startDate)
+	parsedTime, err := config.ParseDate(startDate)
 	if err != nil {
-		logger.Fatalf("Ошибка парсинга интервала: %v", err)
+		logger.Fatalf("Ошибка парсинга даты начала загрузки: %v", err)
 	}
-	if parsedTime.After(time.Now()) {
+	if config.IsFutureDate(parsedTime) {
 		logger.Fatalf("Дата начала загрузки (%s) не может быть в будущем", startDate)
 	} else {
 		cfg.Loading.StartDate = parsedTime.Format("2006-01-02")
 	}
 
+	// Окна вокруг дат событий: загружаются только они, без всей истории
+	var windows []data.DateWindow
+	if len(eventDates) > 0 {
+		windowSize, err := config.ParseDays(window)
+		if err != nil {
+			logger.Fatalf("Ошибка парсинга --window: %v", err)
+		}
+		dates := make([]time.Time, 0, len(eventDates))
+		for _, value := range eventDates {
+			date, err := config.ParseDate(strings.TrimSpace(value))
+			if err != nil {
+				logger.Fatalf("Ошибка парсинга --dates: %v", err)
+			}
+			dates = append(dates, date)
+		}
+		windows = data.EventWindows(dates, windowSize)
+		if len(windows) == 0 {
+			logger.Fatal("Все даты событий в будущем, загружать нечего")
+		}
+		logger.WithFields(logrus.Fields{
+			"dates":   len(dates),
+			"window":  window,
+			"windows": len(windows),
+		}).Info("Загрузка окон вокруг дат событий")
+	} else if cmd.Flags().Changed("window") {
+		logger.Fatal("--window задаётся вместе с --dates")
+	}
+
 	// Логируем настройки лимитов
-	if cfg.Loading.RateLimitPause > 0 {
-		logger.Debugf("Установлена пауза между запросами: %d секунд (API limit)", cfg.Loading.RateLimitPause)
+	if pause := cfg.GetRateLimitPause(); pause > 0 {
+		logger.Debugf("Установлена пауза между запросами: %s (API limit)", pause)
 	} else {
 		logger.Debug("Пауза между запросами не установлена (API limit)")
 	}
@@ -94,75 +254,362 @@ func runLoader(cmd *cobra.Command, _ []string) error {
 	ctx := context.Background()
 
 	// Подключение и получение исходных данных
-	instance, err := app.Initialize(ctx, cfg, parsedTime, logger, config.Interval2text(intervalType))
+	instance, err := app.Initialize(ctx, cfg, parsedTime, logger, strings.Join(intervalNames, ","))
 	if err != nil {
-		logger.Fatalf("Ошибка инициализации: %v", err)
+		return fmt.Errorf("ошибка инициализации: %w", err)
 	}
-	defer instance.DBPool.Close()
+	defer instance.Close()
 
 	logger.WithField("count", len(instance.Instruments)).Debug("Количество инструментов в БД")
 
 	var instruments []storage.Instrument
-	if cmd.Flags().Changed("figi") {
-		// Получаем инструмент из базы данных или API
-		instr, err := getInstrument(ctx, instance, figi, logger)
+	// Инструменты с ошибками последнего запуска (--retry-failed)
+	var retryRuns []app.FailedRun
+	if retryFail && (cmd.Flags().Changed("figi") || newOnly) {
+		logger.Fatal("--retry-failed не задаётся вместе с --figi и --new-only")
+	}
+	if group != "" && (cmd.Flags().Changed("figi") || newOnly || retryFail) {
+		logger.Fatal("--group не задаётся вместе с --figi, --new-only и --retry-failed")
+	}
+	if group != "" {
+		// Инструменты группы (loader-cli group add)
+		instruments, err = storage.GetInstrumentsByGroup(ctx, instance.DBPool, group)
+		if err != nil {
+			logger.Fatalf("Ошибка получения инструментов группы: %v", err)
+		}
+		if len(instruments) == 0 {
+			logger.Infof("В группе %s нет инструментов, загружать нечего", group)
+			exitCode = app.ExitNothingToDo
+			return nil
+		}
+	} else if retryFail {
+		retryRuns, instruments, err = getFailedInstruments(ctx, instance, intervalTypes, logger)
+		if err != nil {
+			logger.Fatalf("Ошибка получения инструментов с ошибками: %v", err)
+		}
+		if len(instruments) == 0 {
+			logger.Info("В последнем запуске нет инструментов с ошибками, загружать нечего")
+			exitCode = app.ExitNothingToDo
+			return nil
+		}
+	} else if cmd.Flags().Changed("figi") {
+		// Получаем инструменты из базы данных или API
+		for _, figi := range figis {
+			instr, err := getInstrument(ctx, instance, figi, cfg, logger)
+			if err != nil {
+				logger.Fatalf("Ошибка получения инструмента: %v", err)
+			}
+			instruments = append(instruments, *instr)
+		}
+	} else if newOnly {
+		// Только инструменты, включённые после последнего завершённого запуска загрузчика свечей
+		instruments, err = getNewInstruments(ctx, instance, intervalTypes, logger)
 		if err != nil {
-			logger.Fatalf("Ошибка получения инструмента: %v", err)
+			logger.Fatalf("Ошибка получения новых инструментов: %v", err)
 		}
-		instruments = append(instruments, *instr)
 	} else {
 		instruments = instance.Instruments
 	}
 
-	logger.Infof("Запуск загрузчика данных на интервал %s", config.Interval2text(intervalType))
+	logger.Infof("Запуск загрузчика данных на интервал %s", strings.Join(intervalNames, ", "))
 
 	// Логируем настройки загрузки
-	logger.WithFields(logrus.Fields{
-		"startDate":      cfg.GetStartDate().Format("2006-01-02"),
-		"rateLimitPause": cfg.Loading.RateLimitPause,
-		"apiLimit":       cfg.GetIntervalLimit(config.Interval2text(intervalType)),
-	}).Info("Настройки загрузки")
+	for _, intervalName := range intervalNames {
+		logger.WithFields(logrus.Fields{
+			"interval":       intervalName,
+			"startDate":      cfg.GetStartDate().Format("2006-01-02"),
+			"rateLimitPause": cfg.GetRateLimitPause(),
+			"apiLimit":       cfg.GetIntervalLimit(intervalName),
+		}).Info("Настройки загрузки")
+	}
 
-	// Обрабатываем инструменты
-	for _, instrument := range instruments {
-		if err := app.ProcessInstrument(ctx, instance.Client, instance.DBPool, intervalType, instrument, cfg, logger); err != nil {
-			logger.WithFields(logrus.Fields{
+	// Загрузка не идёт одновременно с синхронизацией инструментов (loading.sync_lock)
+	releaseLock, skip := app.AcquireSyncLock(ctx, instance.DBPool, false, cfg, logger)
+	if skip {
+		exitCode = app.ExitNothingToDo
+		return nil
+	}
+	defer releaseLock()
+
+	// Ограничение времени загрузки (loading.max_run_duration)
+	runCtx, cancelRun := app.WithRunDeadline(ctx, cfg)
+	defer cancelRun()
+
+	// Обрабатываем инструменты (интервалы инструмента - параллельно до loading.interval_workers)
+	failed, runErr := app.RunInstruments(runCtx, instruments, cfg, logger, func(instrument storage.Instrument) error {
+		if len(windows) > 0 {
+			return app.ProcessInstrumentWindows(runCtx, instance.Client, instance.DBPool, intervalTypes, instrument, windows, cfg, logger)
+		}
+		return app.ProcessInstrumentIntervals(runCtx, instance.Client, instance.DBPool, intervalTypes, instrument, cfg, logger)
+	})
+
+	// Сохраняем остаток буфера отложенной записи: несохранённые свечи - ошибка запуска
+	if err := storage.FlushCandles(instance.DBPool, logger); err != nil {
+		logger.Errorf("Ошибка сброса буфера отложенной записи: %v", err)
+		runErr = errors.Join(runErr, fmt.Errorf("ошибка сброса буфера отложенной записи: %w", err))
+	}
+
+	// Успешно повторённые инструменты удаляются из списка ошибок последнего запуска
+	if retryFail {
+		app.ClearRetriedFailures(ctx, instance.DBPool, retryRuns, failed, logger)
+	}
+
+	// Итог по каждому запрошенному FIGI
+	if cmd.Flags().Changed("figi") {
+		for _, instrument := range instruments {
+			fields := logrus.Fields{
 				"figi":   instrument.Figi,
 				"ticker": instrument.Ticker,
-				"error":  err,
-			}).Error("Ошибка обработки инструмента")
-			continue
+			}
+			if err, exists := failed[instrument.Figi]; exists {
+				fields["error"] = err
+				logger.WithFields(fields).Warn("Итог: ошибка")
+			} else {
+				logger.WithFields(fields).Info("Итог: загружено")
+			}
 		}
-
-		// Пауза между запросами
-		time.Sleep(time.Duration(cfg.Loading.RateLimitPause) * time.Second)
 	}
+	logger.WithFields(logrus.Fields{
+		"total":  len(instruments),
+		"failed": len(failed),
+	}).Info("Обработано инструментов")
 
+	storage.LogSaveSummary(logger)
+	data.LogFetchSummary(logger)
+	app.LogInaccessibleSummary(logger)
+	app.LogSkippedEmptySummary(logger)
+	app.LogVerifySummary(logger)
 	logger.Info("Загрузка завершена")
 
+	exitCode = app.ExitCode(app.RunStats{Total: len(instruments), Failed: len(failed)}, runErr)
+	return nil
+}
+
+func runListInstruments(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	// Тип инструмента в любом регистре и числе: Share, SHARE, shares
+	var instrumentType config.InstrumentType
+	if listType != "" {
+		if instrumentType, err = config.ParseInstrumentType(listType); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+
+	dbpool, err := storage.ConnectToDatabase(ctx, &cfg.Database)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer dbpool.Close()
+
+	instruments, err := storage.GetInstrumentView(ctx, dbpool, storage.InstrumentViewFilter{
+		InstrumentType: instrumentType,
+		Ticker:         listTicker,
+		EnabledOnly:    listEnabledOnly,
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TICKER\tFIGI\tTYPE\tCURRENCY\tSOURCE\tENABLED\tLAST LOADED\tNAME")
+	for _, instrument := range instruments {
+		lastLoaded := "-"
+		if instrument.LastLoadedTime != nil {
+			lastLoaded = instrument.LastLoadedTime.Format("2006-01-02 15:04")
+		}
+		dataSource := instrument.DataSourceName
+		if dataSource == "" {
+			dataSource = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\t%s\t%s\n",
+			instrument.Ticker,
+			instrument.Figi,
+			instrument.InstrumentType,
+			instrument.Currency,
+			dataSource,
+			instrument.Enabled,
+			lastLoaded,
+			instrument.Name,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("ошибка вывода списка инструментов: %w", err)
+	}
+	fmt.Printf("Всего инструментов: %d\n", len(instruments))
+
 	return nil
 }
 
-func getInstrument(ctx context.Context, instance *app.Result, figi string, logger *logrus.Logger) (*storage.Instrument, error) {
-	// Ищем инструмент по FIGI
+// getNewInstruments возвращает инструменты, включённые после начала последнего
+// завершённого запуска загрузчика свечей по интервалу (run_log)
+func getNewInstruments(ctx context.Context, instance *app.Result, intervalTypes []string, logger *logrus.Logger) ([]storage.Instrument, error) {
+	// При нескольких интервалах - с самого раннего из последних запусков
+	var since time.Time
+	for i, intervalType := range intervalTypes {
+		lastRun, err := storage.GetLastCompletedRun(ctx, instance.DBPool, app.LoaderCandles, intervalType)
+		if err != nil {
+			return nil, err
+		}
+		if lastRun == nil {
+			since = time.Time{}
+			break
+		}
+		if i == 0 || lastRun.StartedAt.Before(since) {
+			since = lastRun.StartedAt
+		}
+	}
+
+	instruments, err := storage.GetInstrumentsEnabledSince(ctx, instance.DBPool, since)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.WithFields(logrus.Fields{
+		"since": since.Format(time.RFC3339),
+		"count": len(instruments),
+	}).Info("Инструменты, включённые после последнего запуска")
+	return instruments, nil
+}
+
+func runCoverage(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	intervalType, err := config.ParseInterval(coverageInterval)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка парсинга интервала: %w", err)}
+	}
+
+	ctx := context.Background()
+
+	dbpool, err := storage.ConnectToDatabase(ctx, &cfg.Database)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer dbpool.Close()
+
+	coverages, err := storage.GetLoadCoverage(ctx, dbpool, intervalType)
+	if err != nil {
+		return err
+	}
+	if len(coverages) == 0 {
+		fmt.Printf("Полнота загрузки по интервалу %s не рассчитана (loading.coverage.enabled)\n", config.Interval2text(intervalType))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TICKER\tFIGI\tCANDLES\tEXPECTED\tCOMPLETENESS\tLOADED\tFROM\tCHECKED")
+	underLoaded := 0
+	for _, coverage := range coverages {
+		if !coverage.Loaded {
+			underLoaded++
+		} else if !coverageAll {
+			continue
+		}
+		expected, ratio := "-", "-"
+		if coverage.Expected > 0 {
+			expected = fmt.Sprintf("%d", coverage.Expected)
+			ratio = fmt.Sprintf("%.1f%%", coverage.Ratio*100)
+		}
+		loaded := "нет"
+		if coverage.Loaded {
+			loaded = "да"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			coverage.Ticker,
+			coverage.Figi,
+			coverage.Candles,
+			expected,
+			ratio,
+			loaded,
+			coverage.From.Format("2006-01-02"),
+			coverage.CheckedAt.Local().Format("2006-01-02 15:04"),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("ошибка вывода полноты загрузки: %w", err)
+	}
+	fmt.Printf("Недогружено инструментов: %d из %d\n", underLoaded, len(coverages))
+
+	return nil
+}
+
+// getFailedInstruments возвращает инструменты с ошибками последнего завершённого запуска загрузчика свечей
+// по каждому из интервалов (объединение). Инструменты, выключенные после запуска, пропускаются
+func getFailedInstruments(ctx context.Context, instance *app.Result, intervalTypes []string, logger *logrus.Logger) ([]app.FailedRun, []storage.Instrument, error) {
+	runs, err := app.LastFailedRuns(ctx, instance.DBPool, app.LoaderCandles, intervalTypes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	retry := make(map[string]bool)
+	for _, run := range runs {
+		for _, figi := range run.Figis {
+			retry[figi] = true
+		}
+	}
+
+	var instruments []storage.Instrument
+	for _, instrument := range instance.Instruments {
+		if retry[instrument.Figi] {
+			instruments = append(instruments, instrument)
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"failed":  len(retry),
+		"enabled": len(instruments),
+	}).Info("Повтор инструментов с ошибками последнего запуска")
+	return runs, instruments, nil
+}
+
+func getInstrument(ctx context.Context, instance *app.Result, figi string, cfg *config.Config, logger *logrus.Logger) (*storage.Instrument, error) {
+	// Ищем инструмент по FIGI или тикеру
 	for _, instrument := range instance.Instruments {
-		if instrument.Figi == figi {
+		if instrument.Figi == figi || strings.EqualFold(instrument.Ticker, figi) {
 			logger.Infof("Инструмент найден в базе данных: %s (%s)", instrument.Name, instrument.Figi)
 			return &instrument, nil
 		}
 	}
 
-	// Если не найден в базе, получаем из API
+	// Если не найден в базе, загружаем из API только этот инструмент
 	logger.Infof("Инструмент не найден в базе данных, получаем из API: %s", figi)
-	if err := app.LoadAllInstruments(ctx, instance.Client, instance.DBPool, logger); err != nil {
-		logger.Fatalf("Ошибка загрузки инструментов из API: %v", err)
+	instrument, err := data.ResolveInstrument(ctx, instance.Client, instance.DBPool, figi, logger)
+	if err == nil {
+		return instrument, nil
+	}
+	logger.Warnf("Точечная загрузка инструмента не удалась, загружаем весь справочник: %v", err)
+
+	// Запасной вариант - полная загрузка справочника
+	if _, err := app.LoadAllInstruments(ctx, instance.Client, instance.DBPool, cfg, logger); err != nil {
+		return nil, fmt.Errorf("ошибка загрузки инструментов из API: %w", err)
 	}
 	newInstruments, err := storage.GetInstruments(ctx, instance.DBPool, "")
 	if err != nil {
 		logger.Errorf("Ошибка загрузки инструментов из API: %v", err)
 	} else {
 		for _, instrument := range newInstruments {
-			if instrument.Figi == figi {
+			if instrument.Figi == figi || strings.EqualFold(instrument.Ticker, figi) {
 				logger.Infof("Инструмент найден в базе данных: %s (%s)", instrument.Name, instrument.Figi)
 				return &instrument, nil
 			}
@@ -172,12 +619,252 @@ func getInstrument(ctx context.Context, instance *app.Result, figi string, logge
 	return nil, fmt.Errorf("инструмент с FIGI %s не найден", figi)
 }
 
+func runTail(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	intervalType, err := config.ParseInterval(tailInterval)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка парсинга интервала: %w", err)}
+	}
+
+	ctx := context.Background()
+
+	dbpool, err := storage.ConnectToDatabase(ctx, &cfg.Database)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer dbpool.Close()
+
+	candles, err := storage.GetRecentCandles(ctx, dbpool, tailFigi, intervalType, tailCount)
+	if err != nil {
+		return err
+	}
+	if len(candles) == 0 {
+		fmt.Printf("Свечей %s по интервалу %s нет\n", tailFigi, config.Interval2text(intervalType))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tOPEN\tHIGH\tLOW\tCLOSE\tVOLUME")
+	for _, candle := range candles {
+		fmt.Fprintf(w, "%s\t%g\t%g\t%g\t%g\t%d\n",
+			candle.Time.UTC().Format(time.RFC3339),
+			candle.OpenPrice,
+			candle.HighPrice,
+			candle.LowPrice,
+			candle.ClosePrice,
+			candle.Volume,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("ошибка вывода свечей: %w", err)
+	}
+
+	return nil
+}
+
+// connectGroupDB загружает конфигурацию и подключается к БД для команд group
+func connectGroupDB(cmd *cobra.Command) (*pgxpool.Pool, error) {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return nil, &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	dbpool, err := storage.ConnectToDatabase(context.Background(), &cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	return dbpool, nil
+}
+
+// resolveGroupMembers находит FIGI инструментов по FIGI или тикеру (значения через запятую или аргументами)
+func resolveGroupMembers(ctx context.Context, dbpool *pgxpool.Pool, args []string) ([]string, error) {
+	instruments, err := storage.GetInstrumentView(ctx, dbpool, storage.InstrumentViewFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	var resolved []string
+	for _, arg := range args {
+		for _, value := range strings.Split(arg, ",") {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			found := false
+			for _, instrument := range instruments {
+				if instrument.Figi == value || strings.EqualFold(instrument.Ticker, value) {
+					resolved = append(resolved, instrument.Figi)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("инструмент %s не найден в базе данных", value)
+			}
+		}
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("не заданы инструменты")
+	}
+	return resolved, nil
+}
+
+func runGroupAdd(cmd *cobra.Command, args []string) error {
+	dbpool, err := connectGroupDB(cmd)
+	if err != nil {
+		return err
+	}
+	defer dbpool.Close()
+
+	ctx := context.Background()
+	members, err := resolveGroupMembers(ctx, dbpool, args[1:])
+	if err != nil {
+		return err
+	}
+	added, err := storage.AddGroupMembers(ctx, dbpool, args[0], members)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Группа %s: добавлено инструментов %d из %d\n", args[0], added, len(members))
+	return nil
+}
+
+func runGroupRemove(cmd *cobra.Command, args []string) error {
+	dbpool, err := connectGroupDB(cmd)
+	if err != nil {
+		return err
+	}
+	defer dbpool.Close()
+
+	ctx := context.Background()
+	if len(args) == 1 {
+		if err := storage.DeleteGroup(ctx, dbpool, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Группа %s удалена\n", args[0])
+		return nil
+	}
+
+	members, err := resolveGroupMembers(ctx, dbpool, args[1:])
+	if err != nil {
+		return err
+	}
+	removed, err := storage.RemoveGroupMembers(ctx, dbpool, args[0], members)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Группа %s: удалено инструментов %d\n", args[0], removed)
+	return nil
+}
+
+func runGroupList(cmd *cobra.Command, args []string) error {
+	dbpool, err := connectGroupDB(cmd)
+	if err != nil {
+		return err
+	}
+	defer dbpool.Close()
+
+	ctx := context.Background()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	if len(args) == 0 {
+		groups, err := storage.GetGroups(ctx, dbpool)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "GROUP\tINSTRUMENTS\tCREATED")
+		for _, group := range groups {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", group.Name, group.Members, group.CreatedAt.Format("2006-01-02 15:04"))
+		}
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("ошибка вывода списка групп: %w", err)
+		}
+		fmt.Printf("Всего групп: %d\n", len(groups))
+		return nil
+	}
+
+	instruments, err := storage.GetInstrumentsByGroup(ctx, dbpool, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "TICKER\tFIGI\tTYPE\tENABLED\tNAME")
+	for _, instrument := range instruments {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n",
+			instrument.Ticker,
+			instrument.Figi,
+			instrument.InstrumentType,
+			instrument.Enabled,
+			instrument.Name,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("ошибка вывода инструментов группы: %w", err)
+	}
+	fmt.Printf("Инструментов в группе %s: %d\n", args[0], len(instruments))
+	return nil
+}
+
 func main() {
 	// Добавляем флаги
-	rootCmd.Flags().StringVarP(&interval, "interval", "i", "1min", "Интервал свечей (1min, 2min, 3min, 5min, 10min, 15min, 30min, 1hour, 2hour, 4hour, 1day, 1week, 1month)")
-	rootCmd.Flags().StringVarP(&figi, "figi", "f", "", "FIGI инструмента (по умолчанию enabled=true из БД)")
+	rootCmd.Flags().StringSliceVarP(&intervals, "interval", "i", []string{"1min"}, "Интервалы свечей через запятую (1min, 2min, 3min, 5min, 10min, 15min, 30min, 1hour, 2hour, 4hour, 1day, 1week, 1month)")
+	rootCmd.Flags().StringSliceVarP(&figis, "figi", "f", nil, "FIGI инструментов через запятую или повтором флага (по умолчанию enabled=true из БД)")
+	rootCmd.Flags().BoolVar(&newOnly, "new-only", false, "Только инструменты, включённые после последнего завершённого запуска загрузчика")
+	rootCmd.Flags().BoolVar(&retryFail, "retry-failed", false, "Только инструменты с ошибками последнего запуска загрузчика по интервалу")
 	rootCmd.Flags().StringVarP(&startDate, "start-date", "s", "", "Дата начала загрузки в формате YYYY-MM-DD (по умолчанию из конфига)")
-	rootCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации (опционально)")
+	rootCmd.Flags().StringSliceVar(&eventDates, "dates", nil, "Даты событий YYYY-MM-DD через запятую: загружаются только окна вокруг них")
+	rootCmd.Flags().StringVarP(&group, "group", "g", "", "Группа инструментов (t-loader_cli group add) вместо --figi")
+	rootCmd.Flags().StringVar(&asOf, "as-of", "", "Зафиксированный текущий момент запуска (RFC 3339 или YYYY-MM-DD): свечи позже него не загружаются")
+	rootCmd.Flags().StringVar(&window, "window", "1d", "Окно вокруг каждой даты из --dates: дни (3d) или Go duration (12h)")
+	rootCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+
+	// Подкоманда list-instruments
+	listInstrumentsCmd.Flags().StringVarP(&listType, "type", "t", "", "Тип инструмента (share, bond, etf, currency, future)")
+	listInstrumentsCmd.Flags().StringVar(&listTicker, "ticker", "", "Тикер инструмента")
+	listInstrumentsCmd.Flags().BoolVar(&listEnabledOnly, "enabled", false, "Только включённые (enabled=true) инструменты")
+	listInstrumentsCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	listInstrumentsCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+	rootCmd.AddCommand(listInstrumentsCmd)
+
+	// Подкоманда tail
+	tailCmd.Flags().StringVarP(&tailFigi, "figi", "f", "", "FIGI инструмента")
+	tailCmd.Flags().StringVarP(&tailInterval, "interval", "i", "1min", "Интервал свечей")
+	tailCmd.Flags().IntVarP(&tailCount, "count", "n", 20, "Количество последних свечей")
+	tailCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	tailCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+	if err := tailCmd.MarkFlagRequired("figi"); err != nil {
+		log.Fatalf("%v", err)
+	}
+	rootCmd.AddCommand(tailCmd)
+
+	// Подкоманда coverage
+	coverageCmd.Flags().StringVarP(&coverageInterval, "interval", "i", "1day", "Интервал свечей")
+	coverageCmd.Flags().BoolVar(&coverageAll, "all", false, "Выводить и загруженные инструменты")
+	coverageCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	coverageCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+	rootCmd.AddCommand(coverageCmd)
+
+	// Подкоманда group (флаги конфигурации - общие для add, remove, list)
+	groupCmd.PersistentFlags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	groupCmd.PersistentFlags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+	groupCmd.AddCommand(groupAddCmd, groupRemoveCmd, groupListCmd)
+	rootCmd.AddCommand(groupCmd)
 
 	// Делаем --interval обязательным
 	if err := rootCmd.MarkFlagRequired("interval"); err != nil {
@@ -185,8 +872,10 @@ func main() {
 	}
 
 	// Выполняем команду
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Ошибка выполнения команды: %v\n", err)
-		os.Exit(1)
+		os.Exit(app.ExitCode(app.RunStats{}, err))
 	}
+	os.Exit(exitCode)
 }
@@ -14,12 +14,19 @@ import (
 	"market-loader/internal/app"
 	"market-loader/pkg/config"
 	"market-loader/pkg/logs"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run выполняет загрузку и возвращает код завершения
+func run() int {
 	// Определяем путь к конфигурации
 	configPath := config.GetConfigPath()
 
@@ -36,13 +43,13 @@ func main() {
 
 	// Проверяем валидность даты начала загрузки
 	startDate := cfg.GetStartDate()
-	if startDate.After(time.Now()) {
+	if config.IsFutureDate(startDate) {
 		logger.Fatalf("Дата начала загрузки (%s) не может быть в будущем", startDate.Format("2006-01-02"))
 	}
 
 	// Логируем настройки лимитов
-	if cfg.Loading.RateLimitPause > 0 {
-		logger.Debugf("Установлена пауза между запросами: %d секунд (API limit)", cfg.Loading.RateLimitPause)
+	if pause := cfg.GetRateLimitPause(); pause > 0 {
+		logger.Debugf("Установлена пауза между запросами: %s (API limit)", pause)
 	} else {
 		logger.Debug("Пауза между запросами не установлена (API limit)")
 	}
@@ -53,17 +60,31 @@ func main() {
 	// Подключение и получение исходных данных
 	instance, err := app.Initialize(ctx, cfg, startDate, logger, "instruments")
 	if err != nil {
-		logger.Fatalf("Ошибка инициализации: %v", err)
+		logger.Errorf("Ошибка инициализации: %v", err)
+		return app.ExitCode(app.RunStats{}, err)
 	}
-	defer instance.DBPool.Close()
+	defer instance.Close()
 
 	logger.WithField("count", len(instance.Instruments)).Debug("Количество инструментов в БД")
 
+	// Пропускаем запуск, если предыдущий завершился недавно
+	skip, err := app.ShouldSkipRun(ctx, instance.DBPool, app.LoaderDividends, "", cfg, logger)
+	if err != nil {
+		logger.Warnf("Ошибка проверки предыдущего запуска: %v", err)
+	}
+	if skip {
+		return app.ExitNothingToDo
+	}
+	runID := app.StartRun(ctx, instance.DBPool, app.LoaderDividends, "", logger)
+
 	var shareCount = 0
+	var failedCount = 0
+	// FIGI, по которым дивиденды не загружены и после повторов
+	var failedFigis []string
 	// Обрабатываем каждый инструмент
 	for _, instrument := range instance.Instruments {
-		// Обрабатываем только активные (enabled=true) акции
-		if instrument.InstrumentType == config.Shares && instrument.Enabled {
+		// Обрабатываем только акции (instance.Instruments содержит только enabled=true)
+		if instrument.InstrumentType == config.InstrumentTypeShare {
 			logger.WithFields(logrus.Fields{
 				"figi":   instrument.Figi,
 				"ticker": instrument.Ticker,
@@ -76,16 +97,29 @@ func main() {
 					"name":   instrument.Name,
 					"error":  err,
 				}).Error("Ошибка обработки дивидендов инструмента")
+				failedCount++
+				failedFigis = append(failedFigis, instrument.Figi)
 				continue
 			}
 
 			// Пауза между запросами
-			time.Sleep(time.Duration(cfg.Loading.RateLimitPause) * time.Second)
+			time.Sleep(cfg.GetRateLimitPause())
 
 			shareCount++
 		}
 	}
 	logger.Debugf("Обработано акций %d", shareCount)
+	if len(failedFigis) > 0 {
+		logger.WithFields(logrus.Fields{
+			"failed": len(failedFigis),
+			"figis":  strings.Join(failedFigis, ","),
+		}).Warn("Итог: дивиденды не загружены")
+	}
+
+	app.RecordFailedInstruments(ctx, instance.DBPool, runID, failedFigis, logger)
+	app.FinishRun(ctx, instance.DBPool, runID, shareCount+failedCount, failedCount, nil, logger)
 
 	logger.Info("Загрузка дивидендов завершена")
+
+	return app.ExitCode(app.RunStats{Total: shareCount + failedCount, Failed: failedCount}, nil)
 }
@@ -0,0 +1,139 @@
+// Package main содержит проверку целостности данных в БД
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"market-loader/internal/app"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+	"market-loader/pkg/logs"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Флаги командной строки
+	fix        bool
+	configPath string
+	profile    string
+
+	// Корневая команда
+	rootCmd = &cobra.Command{
+		Use:   "loader-doctor",
+		Short: "Проверка целостности данных в БД",
+		Long: `Проверка ссылочной целостности данных:
+  - свечи и дивиденды инструментов, которых нет в instruments
+  - инструменты с несуществующим data_source_id
+  - партиции свечей, не подключённые к родительской таблице
+
+С флагом --fix безопасные проблемы исправляются: свечи и дивиденды без инструмента
+удаляются, несуществующий data_source_id сбрасывается в NULL.
+Отключённые партиции только выводятся, их нужно проверить вручную.
+
+Примеры использования:
+  loader-doctor
+  loader-doctor --fix`,
+		RunE: runDoctor,
+	}
+)
+
+func runDoctor(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	// Настраиваем логирование
+	logger := logs.SetupLogger(cfg)
+
+	ctx := context.Background()
+
+	dbpool, err := storage.ConnectToDatabase(ctx, &cfg.Database)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer dbpool.Close()
+
+	report, err := storage.CheckIntegrity(ctx, dbpool)
+	if err != nil {
+		return err
+	}
+
+	if err := printReport(report); err != nil {
+		return err
+	}
+
+	if !fix || report.OK() {
+		return nil
+	}
+
+	fixed, err := storage.FixIntegrity(ctx, dbpool, report)
+	if err != nil {
+		return err
+	}
+	for kind, count := range fixed {
+		logger.WithFields(logrus.Fields{
+			"issue": kind,
+			"rows":  count,
+		}).Info("Проблема исправлена")
+	}
+
+	return nil
+}
+
+// printReport выводит отчёт о целостности таблицей
+func printReport(report *storage.IntegrityReport) error {
+	if report.OK() {
+		fmt.Println("Проблем целостности не найдено")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ISSUE\tTABLE\tCOUNT\tFIXABLE\tSAMPLES")
+	for _, issue := range report.Issues {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%t\t%s\n",
+			issue.Kind,
+			issue.Table,
+			issue.Count,
+			issue.Fixable,
+			strings.Join(issue.Samples, ", "),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("ошибка вывода отчёта: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	// Добавляем флаги
+	rootCmd.Flags().BoolVar(&fix, "fix", false, "Исправить безопасные проблемы")
+	rootCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+
+	// Выполняем команду
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка выполнения команды: %v\n", err)
+		os.Exit(app.ExitCode(app.RunStats{}, err))
+	}
+}
@@ -0,0 +1,245 @@
+// Package main содержит выгрузку загруженных данных из БД в CSV/JSON
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"market-loader/internal/app"
+	"market-loader/internal/export"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+	"market-loader/pkg/logs"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	typeCandles   = "candles"
+	typeDividends = "dividends"
+)
+
+var (
+	// Флаги командной строки
+	dataType    string
+	interval    string
+	figi        string
+	group       string
+	currency    string
+	fromDate    string
+	toDate      string
+	format      string
+	outputPath  string
+	columnsSpec string
+	configPath  string
+	profile     string
+
+	// Корневая команда
+	rootCmd = &cobra.Command{
+		Use:   "loader-export",
+		Short: "Выгрузка свечей и дивидендов из БД",
+		Long: `Выгрузка загруженных данных из БД в CSV или JSON.
+
+Примеры использования:
+  loader-export --figi BBG004730N88 --interval 1day --from 2024-01-01 > sber.csv
+  loader-export -t candles -f BBG004730N88 -i 1hour --format json -o sber.json
+  loader-export -f BBG004730N88 -i 1day --columns time,close,typical
+  loader-export --group tech -i 1day --from 2024-01-01 -o tech.csv
+  loader-export -t dividends --from 2020-01-01 --format json
+  loader-export -f BBG004730N88 -i 1hour --from -7d --to now
+  loader-export -t dividends -f BBG004730N88
+  loader-export -t dividends --currency usd`,
+		RunE: runExport,
+	}
+)
+
+func runExport(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	// Настраиваем логирование (логи пишутся в stderr)
+	logger := logs.SetupLogger(cfg)
+
+	if err := export.ValidateFormat(format); err != nil {
+		return err
+	}
+
+	// Колонки свечей (nil - все колонки)
+	var columns []string
+	if columnsSpec != "" {
+		columns, err = export.ParseCandleColumns(columnsSpec)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Относительные границы (-7d, now) считаются от одного момента
+	now := time.Now()
+	from, err := parseTime(fromDate, now)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка парсинга --from: %w", err)}
+	}
+	to, err := parseTime(toDate, now)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка парсинга --to: %w", err)}
+	}
+	// Дата окончания YYYY-MM-DD включает весь день
+	if _, dateErr := config.ParseDate(toDate); dateErr == nil {
+		to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	// Предпочтение источников, если за одно время есть несколько свечей
+	sources, err := cfg.GetSourcePreference()
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка конфигурации storage.source_preference: %w", err)}
+	}
+	storage.SetCandleSourcePreference(sources)
+
+	ctx := context.Background()
+
+	dbpool, err := storage.ConnectToDatabase(ctx, &cfg.Database)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer dbpool.Close()
+
+	// Инструменты выгрузки: --figi или состав группы --group
+	var figis []string
+	switch {
+	case figi != "" && group != "":
+		return &app.UsageError{Err: fmt.Errorf("--figi и --group не задаются вместе")}
+	case figi != "":
+		figis = []string{figi}
+	case group != "":
+		instruments, err := storage.GetInstrumentsByGroup(ctx, dbpool, group)
+		if err != nil {
+			return err
+		}
+		if len(instruments) == 0 {
+			return fmt.Errorf("в группе %s нет инструментов", group)
+		}
+		for _, instrument := range instruments {
+			figis = append(figis, instrument.Figi)
+		}
+	}
+
+	// Куда пишем результат
+	var out io.Writer = os.Stdout
+	if outputPath != "" {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return fmt.Errorf("ошибка создания файла %s: %w", outputPath, err)
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				logger.Errorf("Ошибка закрытия файла: %v", err)
+			}
+		}()
+		out = file
+	}
+
+	var count int
+	switch dataType {
+	case typeCandles:
+		if len(figis) == 0 {
+			return &app.UsageError{Err: fmt.Errorf("для выгрузки свечей необходимо указать --figi или --group")}
+		}
+		intervalType, err := config.ParseInterval(interval)
+		if err != nil {
+			return &app.UsageError{Err: fmt.Errorf("ошибка парсинга интервала: %w", err)}
+		}
+		count, err = export.Candles(ctx, dbpool, out, format, figis, intervalType, from, to, columns)
+		if err != nil {
+			return err
+		}
+	case typeDividends:
+		dividends, err := storage.GetDividends(ctx, dbpool, figi, currency, from, to)
+		if err != nil {
+			return err
+		}
+		if group != "" {
+			dividends = groupDividends(dividends, figis)
+		}
+		count, err = export.Dividends(out, format, dividends)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("неподдерживаемый тип данных: %s", dataType)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"type":   dataType,
+		"figi":   figi,
+		"group":  group,
+		"format": format,
+		"count":  count,
+	}).Info("Выгрузка завершена")
+
+	return nil
+}
+
+// groupDividends оставляет дивиденды инструментов группы
+func groupDividends(dividends []storage.Dividend, figis []string) []storage.Dividend {
+	members := make(map[string]bool, len(figis))
+	for _, figi := range figis {
+		members[figi] = true
+	}
+	filtered := dividends[:0]
+	for _, dividend := range dividends {
+		if members[dividend.Figi] {
+			filtered = append(filtered, dividend)
+		}
+	}
+	return filtered
+}
+
+// parseTime парсит дату YYYY-MM-DD или относительный момент (config.ParseFlexibleTime), пустая строка - без ограничения
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return config.ParseFlexibleTime(value, now)
+}
+
+func main() {
+	// Добавляем флаги
+	rootCmd.Flags().StringVarP(&dataType, "type", "t", typeCandles, "Тип данных (candles, dividends)")
+	rootCmd.Flags().StringVarP(&figi, "figi", "f", "", "FIGI инструмента (для дивидендов - опционально)")
+	rootCmd.Flags().StringVarP(&group, "group", "g", "", "Группа инструментов вместо --figi (loader-cli group)")
+	rootCmd.Flags().StringVar(&currency, "currency", "", "Валюта дивидендов, например rub, usd (по умолчанию все валюты)")
+	rootCmd.Flags().StringVarP(&interval, "interval", "i", "1min", "Интервал свечей (1min, 2min, 3min, 5min, 10min, 15min, 30min, 1hour, 2hour, 4hour, 1day, 1week, 1month или CANDLE_INTERVAL_*)")
+	rootCmd.Flags().StringVar(&fromDate, "from", "", "Начало: YYYY-MM-DD, RFC 3339, now, today или смещение назад (-7d, -12h, -1mo) (по умолчанию без ограничения)")
+	rootCmd.Flags().StringVar(&toDate, "to", "", "Окончание: YYYY-MM-DD включительно, RFC 3339, now, today или смещение назад (-1d) (по умолчанию без ограничения)")
+	rootCmd.Flags().StringVar(&format, "format", export.FormatCSV, "Формат выгрузки (csv, json)")
+	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Файл для записи (по умолчанию stdout)")
+	rootCmd.Flags().StringVar(&columnsSpec, "columns", "", "Колонки свечей через запятую: figi, time, open, high, low, close, volume, interval_type, typical ((h+l+c)/3)")
+	rootCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+
+	// Выполняем команду
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка выполнения команды: %v\n", err)
+		os.Exit(app.ExitCode(app.RunStats{}, err))
+	}
+}
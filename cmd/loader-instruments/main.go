@@ -14,10 +14,15 @@ import (
 	"market-loader/internal/app"
 	"market-loader/pkg/config"
 	"market-loader/pkg/logs"
-	"time"
+	"os"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run выполняет загрузку и возвращает код завершения
+func run() int {
 	// Определяем путь к конфигурации
 	configPath := config.GetConfigPath()
 
@@ -34,13 +39,13 @@ func main() {
 
 	// Проверяем валидность даты начала загрузки
 	startDate := cfg.GetStartDate()
-	if startDate.After(time.Now()) {
+	if config.IsFutureDate(startDate) {
 		logger.Fatalf("Дата начала загрузки (%s) не может быть в будущем", startDate.Format("2006-01-02"))
 	}
 
 	// Логируем настройки лимитов
-	if cfg.Loading.RateLimitPause > 0 {
-		logger.Debugf("Установлена пауза между запросами: %d секунд (API limit)", cfg.Loading.RateLimitPause)
+	if pause := cfg.GetRateLimitPause(); pause > 0 {
+		logger.Debugf("Установлена пауза между запросами: %s (API limit)", pause)
 	} else {
 		logger.Debug("Пауза между запросами не установлена (API limit)")
 	}
@@ -51,15 +56,41 @@ func main() {
 	// Подключение и получение исходных данных
 	instance, err := app.Initialize(ctx, cfg, startDate, logger, "instruments")
 	if err != nil {
-		logger.Fatalf("Ошибка инициализации: %v", err)
+		logger.Errorf("Ошибка инициализации: %v", err)
+		return app.ExitCode(app.RunStats{}, err)
 	}
-	defer instance.DBPool.Close()
+	defer instance.Close()
 
 	logger.WithField("count", len(instance.Instruments)).Debug("Количество активных (enabled=true) инструментов в БД")
 
+	// Пропускаем запуск, если предыдущий завершился недавно
+	skip, err := app.ShouldSkipRun(ctx, instance.DBPool, app.LoaderInstruments, "", cfg, logger)
+	if err != nil {
+		logger.Warnf("Ошибка проверки предыдущего запуска: %v", err)
+	}
+	if skip {
+		return app.ExitNothingToDo
+	}
+
+	// Синхронизация не идёт одновременно с загрузкой свечей (loading.sync_lock)
+	releaseLock, skip := app.AcquireSyncLock(ctx, instance.DBPool, true, cfg, logger)
+	if skip {
+		return app.ExitNothingToDo
+	}
+	defer releaseLock()
+
+	runID := app.StartRun(ctx, instance.DBPool, app.LoaderInstruments, "", logger)
+
 	// Загружаем все типы инструментов из API
 	logger.Debug("Загружаем все инструменты из API и обновляем в БД")
-	if err := app.LoadAllInstruments(ctx, instance.Client, instance.DBPool, logger); err != nil {
-		logger.Fatalf("Ошибка загрузки инструментов из API: %v", err)
+	stats, err := app.LoadAllInstruments(ctx, instance.Client, instance.DBPool, cfg, logger)
+	// В run_log - количество типов инструментов и типов с ошибкой
+	app.FinishRunWithFailures(ctx, instance.DBPool, runID, stats.Total, stats.Failed, err, logger)
+	if err != nil {
+		logger.Errorf("Ошибка загрузки инструментов из API: %v", err)
+		// Успешно загруженные типы сохранены: ошибка части типов - частичный успех, всех - сбой
+		return app.ExitCode(stats, err)
 	}
+
+	return app.ExitCode(stats, nil)
 }
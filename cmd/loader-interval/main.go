@@ -13,10 +13,14 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"time"
+	"os"
 
 	"market-loader/internal/app"
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
 	"market-loader/pkg/config"
 	"market-loader/pkg/logs"
 
@@ -26,12 +30,20 @@ import (
 var MAININTERVAL string
 
 func main() {
+	os.Exit(run())
+}
+
+// run выполняет загрузку и возвращает код завершения
+func run() int {
 	if MAININTERVAL == "" {
 		log.Println("MAININTERVAL не задан при сборке (или произошла ошибка)")
 		log.Println("Используйте Makefile для корректной сборки")
 		log.Println("По умолчанию используется интервал 1 минута")
 		MAININTERVAL = config.CandleInterval1Min
 	}
+	if _, err := config.GetCandleIntervalChecked(MAININTERVAL); err != nil {
+		log.Fatalf("Некорректный MAININTERVAL при сборке: %v", err)
+	}
 
 	// Определяем путь к конфигурации
 	configPath := config.GetConfigPath()
@@ -44,7 +56,7 @@ func main() {
 
 	// Проверяем валидность даты начала загрузки
 	startDate := cfg.GetStartDate()
-	if startDate.After(time.Now()) {
+	if config.IsFutureDate(startDate) {
 		log.Fatalf("Дата начала загрузки (%s) не может быть в будущем", startDate)
 	}
 
@@ -56,7 +68,7 @@ func main() {
 	// Логируем настройки загрузки
 	logger.WithFields(logrus.Fields{
 		"startDate":      cfg.GetStartDate().Format("2006-01-02"),
-		"rateLimitPause": cfg.Loading.RateLimitPause,
+		"rateLimitPause": cfg.GetRateLimitPause(),
 		"apiLimit":       cfg.GetIntervalLimit(config.Interval2text(MAININTERVAL)),
 	}).Info("Настройки загрузки")
 
@@ -66,26 +78,55 @@ func main() {
 	// Подключение и получение исходных данных
 	instance, err := app.Initialize(ctx, cfg, startDate, logger, config.Interval2text(MAININTERVAL))
 	if err != nil {
-		logger.Fatalf("Ошибка инициализации: %v", err)
+		logger.Errorf("Ошибка инициализации: %v", err)
+		return app.ExitCode(app.RunStats{}, err)
 	}
-	defer instance.DBPool.Close()
+	defer instance.Close()
 
 	logger.WithField("count", len(instance.Instruments)).Debug("Количество инструментов в БД")
 
+	// Пропускаем запуск, если предыдущий завершился недавно
+	skip, err := app.ShouldSkipRun(ctx, instance.DBPool, app.LoaderCandles, MAININTERVAL, cfg, logger)
+	if err != nil {
+		logger.Warnf("Ошибка проверки предыдущего запуска: %v", err)
+	}
+	if skip {
+		return app.ExitNothingToDo
+	}
+
+	// Загрузка не идёт одновременно с синхронизацией инструментов (loading.sync_lock)
+	releaseLock, skip := app.AcquireSyncLock(ctx, instance.DBPool, false, cfg, logger)
+	if skip {
+		return app.ExitNothingToDo
+	}
+	defer releaseLock()
+
+	runID := app.StartRun(ctx, instance.DBPool, app.LoaderCandles, MAININTERVAL, logger)
+
+	// Ограничение времени загрузки (loading.max_run_duration)
+	runCtx, cancelRun := app.WithRunDeadline(ctx, cfg)
+	defer cancelRun()
+
 	// Обрабатываем каждый инструмент
-	for _, instrument := range instance.Instruments {
-		if err := app.ProcessInstrument(ctx, instance.Client, instance.DBPool, MAININTERVAL, instrument, cfg, logger); err != nil {
-			logger.WithFields(logrus.Fields{
-				"figi":   instrument.Figi,
-				"ticker": instrument.Ticker,
-				"error":  err,
-			}).Error("Ошибка обработки инструмента")
-			continue
-		}
-
-		// Пауза между запросами
-		time.Sleep(time.Duration(cfg.Loading.RateLimitPause) * time.Second)
+	failed, runErr := app.RunInstruments(runCtx, instance.Instruments, cfg, logger, func(instrument storage.Instrument) error {
+		return app.ProcessInstrument(runCtx, instance.Client, instance.DBPool, MAININTERVAL, instrument, cfg, logger)
+	})
+
+	// Сохраняем остаток буфера отложенной записи до фиксации запуска: его свечи учитываются в run_log
+	if err := storage.FlushCandles(instance.DBPool, logger); err != nil {
+		logger.Errorf("Ошибка сброса буфера отложенной записи: %v", err)
+		runErr = errors.Join(runErr, fmt.Errorf("ошибка сброса буфера отложенной записи: %w", err))
 	}
 
+	app.RecordFailedInstruments(ctx, instance.DBPool, runID, app.FailedFigis(failed), logger)
+	app.FinishRun(ctx, instance.DBPool, runID, len(instance.Instruments), len(failed), runErr, logger)
+
+	storage.LogSaveSummary(logger)
+	data.LogFetchSummary(logger)
+	app.LogInaccessibleSummary(logger)
+	app.LogSkippedEmptySummary(logger)
+	app.LogVerifySummary(logger)
 	logger.Info("Загрузка завершена")
+
+	return app.ExitCode(app.RunStats{Total: len(instance.Instruments), Failed: len(failed)}, runErr)
 }
@@ -0,0 +1,187 @@
+// Package main содержит отчёт об отставании свечей инструментов
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"market-loader/internal/app"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+	"market-loader/pkg/logs"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	formatTable      = "table"
+	formatPrometheus = "prometheus"
+)
+
+var (
+	// Флаги командной строки
+	interval   string
+	limit      int
+	format     string
+	configPath string
+	profile    string
+
+	// Корневая команда
+	rootCmd = &cobra.Command{
+		Use:   "loader-lag",
+		Short: "Отставание свечей включённых инструментов",
+		Long: `Отчёт об отставании свечей: now - MAX(time) по каждому включённому инструменту.
+Сначала выводятся инструменты без свечей, затем по убыванию отставания.
+Формат prometheus выводит метрики для textfile collector node_exporter.
+
+Примеры использования:
+  loader-lag
+  loader-lag --interval 1day --limit 20
+  loader-lag --format prometheus > /var/lib/node_exporter/market_loader_lag.prom`,
+		RunE: runLag,
+	}
+)
+
+func runLag(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	// Настраиваем логирование (логи пишутся в stderr)
+	logs.SetupLogger(cfg)
+
+	intervalType, err := config.ParseInterval(interval)
+	if err != nil {
+		return err
+	}
+	if format != formatTable && format != formatPrometheus {
+		return &app.UsageError{Err: fmt.Errorf("неизвестный формат %q (доступны: %s, %s)", format, formatTable, formatPrometheus)}
+	}
+
+	ctx := context.Background()
+
+	dbpool, err := storage.ConnectToDatabase(ctx, &cfg.Database)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer dbpool.Close()
+
+	lags, err := storage.InstrumentLag(ctx, dbpool, intervalType)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && len(lags) > limit {
+		lags = lags[:limit]
+	}
+
+	if format == formatPrometheus {
+		// Полнота загрузки (loading.coverage) - по всем инструментам, без учёта --limit
+		coverages, err := storage.GetLoadCoverage(ctx, dbpool, intervalType)
+		if err != nil {
+			return err
+		}
+		return writePrometheus(os.Stdout, config.Interval2text(intervalType), lags, coverages)
+	}
+	return writeTable(os.Stdout, lags)
+}
+
+// writeTable выводит отставание таблицей
+func writeTable(out io.Writer, lags []storage.CandleLag) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TICKER\tFIGI\tLAST CANDLE\tLAG\tNAME")
+	for _, lag := range lags {
+		lastCandle, lagText := "-", "нет свечей"
+		if !lag.LastTime.IsZero() {
+			lastCandle = lag.LastTime.Format("2006-01-02 15:04")
+			lagText = formatLag(lag.Lag)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", lag.Ticker, lag.Figi, lastCandle, lagText, lag.Name)
+	}
+	return w.Flush()
+}
+
+// writePrometheus выводит отставание в текстовом формате Prometheus;
+// инструменты без свечей учитываются отдельной метрикой. Полнота загрузки выводится,
+// если она рассчитана (loading.coverage.enabled)
+func writePrometheus(out io.Writer, intervalText string, lags []storage.CandleLag, coverages []storage.LoadCoverage) error {
+	var b []byte
+	b = append(b, "# HELP market_loader_candle_lag_seconds Отставание последней свечи инструмента от текущего момента\n"...)
+	b = append(b, "# TYPE market_loader_candle_lag_seconds gauge\n"...)
+	withoutCandles := 0
+	for _, lag := range lags {
+		if lag.LastTime.IsZero() {
+			withoutCandles++
+			continue
+		}
+		b = fmt.Appendf(b, "market_loader_candle_lag_seconds{figi=%q,ticker=%q,interval=%q} %.0f\n",
+			lag.Figi, lag.Ticker, intervalText, lag.Lag.Seconds())
+	}
+	b = append(b, "# HELP market_loader_instruments_without_candles Включённые инструменты без свечей интервала\n"...)
+	b = append(b, "# TYPE market_loader_instruments_without_candles gauge\n"...)
+	b = fmt.Appendf(b, "market_loader_instruments_without_candles{interval=%q} %d\n", intervalText, withoutCandles)
+
+	if len(coverages) > 0 {
+		b = append(b, "# HELP market_loader_coverage_ratio Доля загруженных свечей от ожидаемых по торговому календарю\n"...)
+		b = append(b, "# TYPE market_loader_coverage_ratio gauge\n"...)
+		underLoaded := 0
+		for _, coverage := range coverages {
+			if !coverage.Loaded {
+				underLoaded++
+			}
+			if coverage.Expected == 0 {
+				continue
+			}
+			b = fmt.Appendf(b, "market_loader_coverage_ratio{figi=%q,ticker=%q,interval=%q} %.4f\n",
+				coverage.Figi, coverage.Ticker, intervalText, coverage.Ratio)
+		}
+		b = append(b, "# HELP market_loader_instruments_underloaded Включённые инструменты, не считающиеся загруженными\n"...)
+		b = append(b, "# TYPE market_loader_instruments_underloaded gauge\n"...)
+		b = fmt.Appendf(b, "market_loader_instruments_underloaded{interval=%q} %d\n", intervalText, underLoaded)
+	}
+
+	_, err := out.Write(b)
+	return err
+}
+
+// formatLag форматирует отставание: дни и часы, для малых значений - часы и минуты
+func formatLag(lag time.Duration) string {
+	days := int(lag.Hours()) / config.HoursInDay
+	if days > 0 {
+		return fmt.Sprintf("%dд %dч", days, int(lag.Hours())%config.HoursInDay)
+	}
+	return fmt.Sprintf("%dч %dм", int(lag.Hours()), int(lag.Minutes())%60)
+}
+
+func main() {
+	// Добавляем флаги
+	rootCmd.Flags().StringVarP(&interval, "interval", "i", "1min", "Интервал свечей (1min, 1hour, 1day, ...)")
+	rootCmd.Flags().IntVarP(&limit, "limit", "n", 0, "Показать только N самых отстающих инструментов (0 - все)")
+	rootCmd.Flags().StringVar(&format, "format", formatTable, "Формат вывода: table, prometheus")
+	rootCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+
+	// Выполняем команду
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка выполнения команды: %v\n", err)
+		os.Exit(app.ExitCode(app.RunStats{}, err))
+	}
+}
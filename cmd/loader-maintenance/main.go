@@ -0,0 +1,219 @@
+// Package main содержит служебные операции обслуживания БД
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"market-loader/internal/app"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+	"market-loader/pkg/logs"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Флаги командной строки
+	dedupe      bool
+	partitions  bool
+	split       bool
+	retention   bool
+	monthsAhead int
+	dryRun      bool
+	figi        string
+	configPath  string
+	profile     string
+
+	// Корневая команда
+	rootCmd = &cobra.Command{
+		Use:   "loader-maintenance",
+		Short: "Обслуживание БД",
+		Long: `Служебные операции обслуживания БД.
+
+Примеры использования:
+  loader-maintenance --dedupe --dry-run
+  loader-maintenance --dedupe
+  loader-maintenance --dedupe --figi BBG004730N88
+  loader-maintenance --partitions
+  loader-maintenance --partitions --months 6
+  loader-maintenance --split-intervals
+  loader-maintenance --retention --dry-run
+  loader-maintenance --retention`,
+		RunE: runMaintenance,
+	}
+)
+
+func runMaintenance(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	// Настраиваем логирование
+	logger := logs.SetupLogger(cfg)
+
+	if !dedupe && !partitions && !split && !retention {
+		return &app.UsageError{Err: fmt.Errorf("не указана операция (--dedupe, --partitions, --split-intervals, --retention)")}
+	}
+
+	policy, err := cfg.GetRetention()
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка конфигурации: %w", err)}
+	}
+	if retention && len(policy) == 0 {
+		return &app.UsageError{Err: fmt.Errorf("--retention требует storage.retention в конфигурации")}
+	}
+	if split && !cfg.Database.TablePerInterval {
+		return &app.UsageError{Err: fmt.Errorf("--split-intervals требует database.table_per_interval: true")}
+	}
+
+	ctx := context.Background()
+
+	dbpool, err := storage.ConnectToDatabase(ctx, &cfg.Database)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer dbpool.Close()
+
+	if split {
+		moved, err := storage.SplitCandlesByInterval(ctx, dbpool, logger)
+		if err != nil {
+			return err
+		}
+		logger.WithField("moved", moved).Info("Свечи перенесены в таблицы по интервалам")
+	}
+
+	if partitions {
+		if !cmd.Flags().Changed("months") {
+			monthsAhead = cfg.Database.GetPartitionsAhead()
+		}
+		created, err := storage.EnsureFuturePartitions(ctx, dbpool, monthsAhead)
+		if err != nil {
+			return err
+		}
+		logger.WithFields(logrus.Fields{
+			"monthsAhead": monthsAhead,
+			"created":     created,
+		}).Info("Партиции свечей на будущие месяцы созданы")
+	}
+
+	if dedupe {
+		if err := runDedupe(ctx, dbpool, logger); err != nil {
+			return err
+		}
+	}
+
+	if retention {
+		if err := runRetention(ctx, dbpool, policy, logger); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runDedupe находит и удаляет дубли свечей
+func runDedupe(ctx context.Context, dbpool *pgxpool.Pool, logger *logrus.Logger) error {
+	duplicates, err := storage.FindDuplicateCandles(ctx, dbpool, figi)
+	if err != nil {
+		return err
+	}
+
+	extra := 0
+	for _, duplicate := range duplicates {
+		extra += duplicate.Count - 1
+		logger.WithFields(logrus.Fields{
+			"figi":         duplicate.FIGI,
+			"time":         duplicate.Time.Format("2006-01-02 15:04:05"),
+			"intervalType": duplicate.IntervalType,
+			"count":        duplicate.Count,
+		}).Debug("Дубль свечи")
+	}
+
+	logger.WithFields(logrus.Fields{
+		"groups": len(duplicates),
+		"extra":  extra,
+	}).Info("Найдено дублей свечей")
+
+	if dryRun || len(duplicates) == 0 {
+		return nil
+	}
+
+	deleted, err := storage.RemoveDuplicateCandles(ctx, dbpool, figi)
+	if err != nil {
+		return err
+	}
+
+	logger.WithField("deleted", deleted).Info("Дубли свечей удалены")
+	return nil
+}
+
+// runRetention удаляет свечи старше срока хранения интервала (storage.retention)
+func runRetention(ctx context.Context, dbpool *pgxpool.Pool, policy map[string]time.Duration, logger *logrus.Logger) error {
+	now := time.Now().UTC()
+	for intervalType, retention := range policy {
+		logger.WithFields(logrus.Fields{
+			"interval": config.Interval2text(intervalType),
+			"before":   now.Add(-retention).Format("2006-01-02 15:04:05"),
+		}).Info("Срок хранения свечей")
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	summary, err := storage.EnforceRetention(ctx, dbpool, policy)
+	if err != nil {
+		return err
+	}
+
+	for intervalType, deleted := range summary.Deleted {
+		logger.WithFields(logrus.Fields{
+			"interval": config.Interval2text(intervalType),
+			"deleted":  deleted,
+		}).Info("Устаревшие свечи удалены")
+	}
+	logger.WithFields(logrus.Fields{
+		"count":      len(summary.Dropped),
+		"partitions": summary.Dropped,
+	}).Info("Пустые партиции удалены")
+	return nil
+}
+
+func main() {
+	// Добавляем флаги
+	rootCmd.Flags().BoolVar(&dedupe, "dedupe", false, "Удалить дубли свечей (figi, time, interval_type), оставив запись с последним created_at")
+	rootCmd.Flags().BoolVar(&partitions, "partitions", false, "Создать партиции свечей на будущие месяцы")
+	rootCmd.Flags().BoolVar(&split, "split-intervals", false, "Перенести свечи из candles в отдельные таблицы интервалов (database.table_per_interval)")
+	rootCmd.Flags().BoolVar(&retention, "retention", false, "Удалить свечи старше срока хранения интервала (storage.retention) и опустевшие партиции")
+	rootCmd.Flags().IntVar(&monthsAhead, "months", config.DefaultPartitionsAhead, "На сколько месяцев вперёд создавать партиции (по умолчанию database.partitions_ahead)")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Только показать найденное, без изменений")
+	rootCmd.Flags().StringVarP(&figi, "figi", "f", "", "FIGI инструмента (по умолчанию все)")
+	rootCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+
+	// Выполняем команду
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка выполнения команды: %v\n", err)
+		os.Exit(app.ExitCode(app.RunStats{}, err))
+	}
+}
@@ -0,0 +1,132 @@
+// Package main содержит последовательный запуск нескольких загрузчиков по плану
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"market-loader/internal/app"
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+	"market-loader/pkg/logs"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Флаги командной строки
+	jobs       []string
+	configPath string
+	profile    string
+
+	// Код завершения по итогам плана
+	exitCode int
+
+	// Корневая команда
+	rootCmd = &cobra.Command{
+		Use:   "loader-plan",
+		Short: "Запуск загрузчиков по плану",
+		Long: `Последовательный запуск заданий в одном процессе с общим подключением к БД и API.
+
+Задания: instruments, candles:<интервал>, dividends.
+По умолчанию план берётся из run_plan в конфигурации.
+
+Примеры использования:
+  loader-plan
+  loader-plan --jobs instruments,candles:1min,candles:1day,dividends`,
+		RunE: runPlan,
+	}
+)
+
+func runPlan(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	// Настраиваем логирование
+	logger := logs.SetupLogger(cfg)
+
+	// План из флага имеет приоритет над конфигурацией
+	if !cmd.Flags().Changed("jobs") {
+		jobs = cfg.RunPlan
+	}
+	// Проверяем план до подключения
+	if _, err := app.ParseJobs(jobs); err != nil {
+		return err
+	}
+
+	logger.WithField("jobs", jobs).Info("Запуск загрузчиков по плану")
+
+	// Проверяем валидность даты начала загрузки
+	startDate := cfg.GetStartDate()
+	if config.IsFutureDate(startDate) {
+		return &app.UsageError{Err: fmt.Errorf("дата начала загрузки (%s) не может быть в будущем", startDate.Format("2006-01-02"))}
+	}
+
+	// Создаем контекст
+	ctx := context.Background()
+
+	// Подключение и получение исходных данных (общие для всех заданий)
+	instance, err := app.Initialize(ctx, cfg, startDate, logger, "plan")
+	if err != nil {
+		return fmt.Errorf("ошибка инициализации: %w", err)
+	}
+	defer instance.Close()
+
+	// Перечитывание конфигурации по SIGHUP (уровень логов, пауза, лимиты)
+	reloadCtx, stopReload := context.WithCancel(ctx)
+	defer stopReload()
+	app.WatchConfigReload(reloadCtx, cfg, configPath, logger)
+
+	// Ограничение времени загрузки (loading.max_run_duration) - на весь план
+	runCtx, cancelRun := app.WithRunDeadline(ctx, cfg)
+	defer cancelRun()
+
+	stats, planErr := app.RunPlan(runCtx, instance, jobs, cfg, logger)
+	exitCode = app.ExitCode(stats, planErr)
+
+	storage.LogSaveSummary(logger)
+	data.LogFetchSummary(logger)
+	app.LogInaccessibleSummary(logger)
+	app.LogSkippedEmptySummary(logger)
+	app.LogVerifySummary(logger)
+
+	if planErr != nil {
+		return planErr
+	}
+	logger.Info("План выполнен")
+	return nil
+}
+
+func main() {
+	// Добавляем флаги
+	rootCmd.Flags().StringSliceVar(&jobs, "jobs", nil, "Задания через запятую (instruments, candles:<интервал>, dividends), по умолчанию run_plan из конфига")
+	rootCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+
+	// Выполняем команду
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка выполнения команды: %v\n", err)
+		if exitCode == app.ExitOK {
+			exitCode = app.ExitCode(app.RunStats{}, err)
+		}
+	}
+	os.Exit(exitCode)
+}
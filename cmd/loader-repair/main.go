@@ -0,0 +1,238 @@
+// Package main содержит восстановление свечей инструмента: поиск пропусков и подозрительных свечей
+// и повторную загрузку этих периодов
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"market-loader/internal/app"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+	"market-loader/pkg/logs"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Флаги командной строки
+	figi       string
+	interval   string
+	fromDate   string
+	toDate     string
+	useArchive bool
+	dryRun     bool
+	configPath string
+	profile    string
+
+	// Код завершения по итогам восстановления
+	exitCode int
+
+	// Корневая команда
+	rootCmd = &cobra.Command{
+		Use:   "loader-repair",
+		Short: "Восстановление свечей инструмента",
+		Long: `Восстановление свечей одного инструмента по одному интервалу:
+находит торговые дни (недели, месяцы) без свечей между первой и последней свечой
+и свечи с несогласованными OHLCV (цена <= 0, high < low, open/close вне [low, high], объём < 0),
+затем загружает эти периоды заново. Полученные свечи перезаписывают имеющиеся,
+подозрительные свечи, которых загрузка не вернула, удаляются только после успешной загрузки. В итоге выводится количество свечей,
+пропусков и подозрительных свечей до и после восстановления.
+
+С --archive минутные свечи прошлых лет загружаются из годовых архивов, остальное - через API.
+С --dry-run проблемы только выводятся, данные не меняются и API не используется.
+
+Примеры использования:
+  loader-repair --figi BBG004730N88 --dry-run
+  loader-repair --figi SBER --interval 1min --from 2024-01-01 --to 2024-03-31
+  loader-repair --figi BBG004730N88 --interval 1min --archive`,
+		RunE: runRepair,
+	}
+)
+
+func runRepair(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	// Настраиваем логирование
+	logger := logs.SetupLogger(cfg)
+
+	intervalType, err := config.ParseInterval(interval)
+	if err != nil {
+		return err
+	}
+
+	// Период: по умолчанию вся история инструмента
+	var from time.Time
+	to := config.Now().UTC()
+	if fromDate != "" {
+		if from, err = config.ParseDate(fromDate); err != nil {
+			return &app.UsageError{Err: fmt.Errorf("ошибка парсинга --from: %w", err)}
+		}
+	}
+	if toDate != "" {
+		if to, err = config.ParseDate(toDate); err != nil {
+			return &app.UsageError{Err: fmt.Errorf("ошибка парсинга --to: %w", err)}
+		}
+		if to.Before(from) {
+			return &app.UsageError{Err: fmt.Errorf("--to (%s) раньше --from (%s)", to.Format("2006-01-02"), from.Format("2006-01-02"))}
+		}
+		// Дата окончания включает весь день
+		to = to.AddDate(0, 0, 1)
+	} else if !from.Before(to) {
+		return &app.UsageError{Err: fmt.Errorf("--from (%s) в будущем", from.Format("2006-01-02"))}
+	}
+	if useArchive && intervalType != config.CandleInterval1Min {
+		return &app.UsageError{Err: fmt.Errorf("--archive поддерживается только для интервала %s", config.CandleIntervalText1Min)}
+	}
+
+	ctx := context.Background()
+
+	// Без --dry-run нужен клиент API, для поиска проблем достаточно БД
+	var client *investgo.Client
+	var dbpool *pgxpool.Pool
+	if dryRun {
+		if dbpool, err = storage.ConnectToDatabase(ctx, &cfg.Database); err != nil {
+			return fmt.Errorf("ошибка подключения к БД: %w", err)
+		}
+	} else {
+		instance, err := app.Initialize(ctx, cfg, cfg.GetStartDate(), logger, "repair")
+		if err != nil {
+			return fmt.Errorf("ошибка инициализации: %w", err)
+		}
+		client, dbpool = instance.Client, instance.DBPool
+	}
+	defer dbpool.Close()
+
+	instrument, err := findInstrument(ctx, dbpool, figi)
+	if err != nil {
+		return err
+	}
+
+	fields := logrus.Fields{
+		"figi":     instrument.Figi,
+		"ticker":   instrument.Ticker,
+		"interval": config.Interval2text(intervalType),
+	}
+
+	report, repairErr := app.RepairCandles(ctx, client, dbpool, *instrument, intervalType, from, to, useArchive, dryRun, cfg, logger)
+
+	for _, gap := range report.Gaps {
+		logger.WithFields(fields).WithFields(logrus.Fields{
+			"from": gap.From.Format("2006-01-02"),
+			"to":   gap.To.Format("2006-01-02"),
+		}).Info("Пропуск свечей")
+	}
+	for _, suspect := range report.Suspect {
+		logger.WithFields(fields).WithFields(logrus.Fields{
+			"time":   suspect.Time.Format(time.RFC3339),
+			"reason": suspect.Reason,
+		}).Info("Подозрительная свеча")
+	}
+	if !dryRun {
+		for _, window := range report.Refetched {
+			logger.WithFields(fields).WithFields(logrus.Fields{
+				"from": window.From.Format(time.RFC3339),
+				"to":   window.To.Format(time.RFC3339),
+			}).Debug("Период загружен заново")
+		}
+	}
+
+	summary := logger.WithFields(fields).WithFields(logrus.Fields{
+		"gaps":      len(report.Gaps),
+		"suspect":   len(report.Suspect),
+		"refetched": len(report.Refetched),
+		"before":    report.Before,
+	})
+	switch {
+	case len(report.Refetched) == 0:
+		summary.Info("Пропусков и подозрительных свечей не найдено")
+		exitCode = app.ExitNothingToDo
+		return nil
+	case dryRun:
+		summary.Info("Найдены проблемы (--dry-run, данные не изменены)")
+		return nil
+	}
+
+	summary = summary.WithFields(logrus.Fields{
+		"deleted":      report.Deleted,
+		"after":        report.After,
+		"gapsAfter":    report.GapsAfter,
+		"suspectAfter": report.SuspectAfter,
+	})
+	if len(report.Archived) > 0 {
+		years := make([]string, 0, len(report.Archived))
+		for _, year := range report.Archived {
+			years = append(years, fmt.Sprint(year))
+		}
+		summary = summary.WithField("archives", strings.Join(years, ","))
+	}
+	if repairErr != nil {
+		summary.WithField("error", repairErr).Error("Восстановление свечей завершено с ошибкой")
+		exitCode = app.ExitCode(app.RunStats{Total: 1, Failed: 1}, repairErr)
+		return nil
+	}
+	summary.Info("Восстановление свечей завершено")
+
+	exitCode = app.ExitCode(app.RunStats{Total: 1}, nil)
+	return nil
+}
+
+// findInstrument ищет инструмент в БД по FIGI или тикеру
+func findInstrument(ctx context.Context, dbpool *pgxpool.Pool, value string) (*storage.Instrument, error) {
+	instruments, err := storage.GetInstruments(ctx, dbpool, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, instrument := range instruments {
+		if instrument.Figi == value || strings.EqualFold(instrument.Ticker, value) {
+			return &instrument, nil
+		}
+	}
+	return nil, fmt.Errorf("инструмент %s не найден в базе данных", value)
+}
+
+func main() {
+	// Добавляем флаги
+	rootCmd.Flags().StringVarP(&figi, "figi", "f", "", "FIGI или тикер инструмента (обязательно)")
+	rootCmd.Flags().StringVarP(&interval, "interval", "i", config.CandleIntervalTextDay, "Интервал свечей (1min, 1hour, 1day, ...)")
+	rootCmd.Flags().StringVar(&fromDate, "from", "", "Дата начала в формате YYYY-MM-DD (по умолчанию с первой свечи)")
+	rootCmd.Flags().StringVar(&toDate, "to", "", "Дата окончания в формате YYYY-MM-DD включительно (по умолчанию сегодня)")
+	rootCmd.Flags().BoolVar(&useArchive, "archive", false, "Минутные свечи прошлых лет загружать из годовых архивов")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Только вывести найденные проблемы, данные не изменять")
+	rootCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+	if err := rootCmd.MarkFlagRequired("figi"); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Выполняем команду
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка выполнения команды: %v\n", err)
+		os.Exit(app.ExitCode(app.RunStats{}, err))
+	}
+	os.Exit(exitCode)
+}
@@ -0,0 +1,175 @@
+// Package main содержит расчёт доходности по сохранённым свечам
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"market-loader/internal/app"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+	"market-loader/pkg/logs"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// defaultDays период расчёта по умолчанию (дней до сегодняшнего)
+const defaultDays = 30
+
+var (
+	// Флаги командной строки
+	figi        string
+	interval    string
+	method      string
+	totalReturn bool
+	fromDate    string
+	toDate      string
+	configPath  string
+	profile     string
+
+	// Код завершения по итогам расчёта
+	exitCode int
+
+	// Корневая команда
+	rootCmd = &cobra.Command{
+		Use:   "loader-returns",
+		Short: "Расчёт доходности по сохранённым свечам",
+		Long: `Расчёт доходности инструментов по ценам закрытия уже сохранённых свечей:
+логарифмической ln(close / prevClose) или простой close / prevClose - 1.
+Результат записывается в таблицу returns, запросы к API не выполняются.
+С --total к цене закрытия прибавляются дивиденды, выплаченные в периоде свечи (полная доходность).
+
+Примеры использования:
+  loader-returns
+  loader-returns --method simple --from 2024-01-01 --to 2024-12-31
+  loader-returns --figi BBG004730N88 --total --from 2020-01-01`,
+		RunE: runReturns,
+	}
+)
+
+func runReturns(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	// Настраиваем логирование
+	logger := logs.SetupLogger(cfg)
+
+	intervalType, err := config.ParseInterval(interval)
+	if err != nil {
+		return err
+	}
+	if err := storage.ValidateReturnMethod(method); err != nil {
+		return err
+	}
+
+	// Период расчёта: по умолчанию последние defaultDays дней, включая сегодня
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -defaultDays)
+	to := today
+	if fromDate != "" {
+		if from, err = config.ParseDate(fromDate); err != nil {
+			return &app.UsageError{Err: fmt.Errorf("ошибка парсинга --from: %w", err)}
+		}
+	}
+	if toDate != "" {
+		if to, err = config.ParseDate(toDate); err != nil {
+			return &app.UsageError{Err: fmt.Errorf("ошибка парсинга --to: %w", err)}
+		}
+	}
+	if to.Before(from) {
+		return &app.UsageError{Err: fmt.Errorf("--to (%s) раньше --from (%s)", to.Format("2006-01-02"), from.Format("2006-01-02"))}
+	}
+	// Дата окончания включает весь день
+	to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+
+	ctx := context.Background()
+
+	dbpool, err := storage.ConnectToDatabase(ctx, &cfg.Database)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer dbpool.Close()
+
+	// Отбор инструментов по allowlist/denylist
+	storage.SetInstrumentFilterSource(cfg.GetInstrumentFilter)
+	instruments, err := storage.GetEnabledInstruments(ctx, dbpool, "")
+	if err != nil {
+		return err
+	}
+
+	stats := app.RunStats{}
+	for _, instrument := range instruments {
+		if figi != "" && instrument.Figi != figi {
+			continue
+		}
+
+		written, err := storage.ComputeReturns(ctx, dbpool, instrument.Figi, intervalType, from, to, method, totalReturn)
+		stats.Total++
+		if err != nil {
+			stats.Failed++
+			logger.WithFields(logrus.Fields{
+				"figi":   instrument.Figi,
+				"ticker": instrument.Ticker,
+				"error":  err,
+			}).Error("Ошибка расчёта доходности")
+			continue
+		}
+
+		logger.WithFields(logrus.Fields{
+			"figi":   instrument.Figi,
+			"ticker": instrument.Ticker,
+			"values": written,
+		}).Info("Доходность рассчитана")
+	}
+
+	logger.WithFields(logrus.Fields{
+		"instruments": stats.Total,
+		"failed":      stats.Failed,
+		"interval":    interval,
+		"method":      method,
+		"total":       totalReturn,
+		"from":        from.Format("2006-01-02"),
+		"to":          to.Format("2006-01-02"),
+	}).Info("Расчёт доходности завершён")
+
+	exitCode = app.ExitCode(stats, nil)
+	return nil
+}
+
+func main() {
+	// Добавляем флаги
+	rootCmd.Flags().StringVarP(&figi, "figi", "f", "", "FIGI инструмента (по умолчанию все включённые)")
+	rootCmd.Flags().StringVarP(&interval, "interval", "i", config.CandleIntervalTextDay, "Интервал свечей (1min, 1hour, 1day, ...)")
+	rootCmd.Flags().StringVarP(&method, "method", "m", storage.ReturnMethodLog, "Способ расчёта: log (логарифмическая) или simple (простая)")
+	rootCmd.Flags().BoolVar(&totalReturn, "total", false, "Полная доходность с учётом дивидендов (по дате выплаты)")
+	rootCmd.Flags().StringVar(&fromDate, "from", "", "Дата начала в формате YYYY-MM-DD (по умолчанию 30 дней назад)")
+	rootCmd.Flags().StringVar(&toDate, "to", "", "Дата окончания в формате YYYY-MM-DD включительно (по умолчанию сегодня)")
+	rootCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+
+	// Выполняем команду
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка выполнения команды: %v\n", err)
+		os.Exit(app.ExitCode(app.RunStats{}, err))
+	}
+	os.Exit(exitCode)
+}
@@ -0,0 +1,146 @@
+// Package main содержит выгрузку DDL схемы БД загрузчика
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"market-loader/internal/app"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Флаги командной строки
+	withPartitions bool
+	configPath     string
+	profile        string
+
+	// Корневая команда
+	rootCmd = &cobra.Command{
+		Use:   "loader-schema",
+		Short: "Схема БД загрузчика",
+	}
+
+	// Вывод DDL схемы
+	dumpCmd = &cobra.Command{
+		Use:   "dump",
+		Short: "Вывести DDL схемы БД",
+		Long: `Вывод DDL таблиц, индексов, ограничений, триггера и представления instrument_view,
+которые создаёт загрузчик, из тех же определений, что используются при инициализации БД.
+Учитываются настройки database.schema и database.partition_by_interval из конфигурации.
+Подключение к БД не требуется.
+
+С флагом --partitions к выводу добавляются существующие в БД таблицы свечей интервалов
+и партиции свечей (нужно подключение к БД).
+
+Примеры использования:
+  loader-schema dump > schema.sql
+  loader-schema dump --partitions --conf config/config.yaml`,
+		RunE: runDump,
+	}
+)
+
+func runDump(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	confChanged := cmd.Flags().Changed("conf")
+	if !confChanged {
+		configPath = config.GetConfigPath()
+	}
+
+	// Конфигурация нужна только для подключения к БД и настроек схемы:
+	// без неё выводится схема по умолчанию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		if confChanged || withPartitions || profile != "" {
+			return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+		}
+		cfg = &config.Config{}
+	}
+	storage.SetPartitionByInterval(cfg.Database.PartitionByInterval)
+
+	var statements []string
+	if schema := cfg.Database.GetSchema(); schema != config.DefaultSchema {
+		statements = append(statements,
+			fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", schema),
+			fmt.Sprintf("SET search_path TO %s", schema))
+	}
+	statements = append(statements, storage.SchemaDDL()...)
+
+	if withPartitions {
+		ctx := context.Background()
+
+		dbpool, err := storage.ConnectToDatabase(ctx, &cfg.Database)
+		if err != nil {
+			return fmt.Errorf("ошибка подключения к БД: %w", err)
+		}
+		defer dbpool.Close()
+
+		partitions, err := storage.PartitionsDDL(ctx, dbpool)
+		if err != nil {
+			return err
+		}
+		statements = append(statements, partitions...)
+	}
+
+	for _, statement := range statements {
+		fmt.Println(formatStatement(statement))
+		fmt.Println()
+	}
+	return nil
+}
+
+// formatStatement убирает общий отступ строк DDL (из исходного кода) и добавляет завершающую ";"
+func formatStatement(statement string) string {
+	lines := strings.Split(strings.Trim(statement, "\n"), "\n")
+
+	// Наименьший отступ непустых строк
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		width := len(line) - len(strings.TrimLeft(line, "\t "))
+		if indent < 0 || width < indent {
+			indent = width
+		}
+	}
+
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			lines[i] = line[indent:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+
+	result := strings.TrimSpace(strings.Join(lines, "\n"))
+	if !strings.HasSuffix(result, ";") {
+		result += ";"
+	}
+	return result
+}
+
+func main() {
+	dumpCmd.Flags().BoolVar(&withPartitions, "partitions", false, "Добавить существующие в БД таблицы интервалов и партиции свечей")
+	dumpCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	dumpCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+	rootCmd.AddCommand(dumpCmd)
+
+	// Выполняем команду
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка выполнения команды: %v\n", err)
+		os.Exit(app.ExitCode(app.RunStats{}, err))
+	}
+}
@@ -0,0 +1,103 @@
+// Package main содержит загрузку минутных свечей в реальном времени через стрим MarketData
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"market-loader/internal/app"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+	"market-loader/pkg/logs"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Флаги командной строки
+	configPath string
+	profile    string
+
+	// Корневая команда
+	rootCmd = &cobra.Command{
+		Use:   "loader-stream",
+		Short: "Загрузка минутных свечей в реальном времени",
+		Long: `Подписка на стрим минутных свечей для включённых инструментов.
+
+Свечи сохраняются по мере поступления, при обрыве стрим переподключается.
+Пропуски за время обрыва догружает обычный loader-1min.
+Остановка по SIGINT/SIGTERM, при остановке сохраняется время последней свечи.
+SIGHUP - перечитать конфигурацию (уровень логов, пауза, лимиты).
+
+Примеры использования:
+  loader-stream
+  loader-stream --conf config/config.yaml`,
+		RunE: runStream,
+	}
+)
+
+func runStream(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	// Настраиваем логирование
+	logger := logs.SetupLogger(cfg)
+
+	// Проверяем валидность даты начала загрузки
+	startDate := cfg.GetStartDate()
+	if config.IsFutureDate(startDate) {
+		return &app.UsageError{Err: fmt.Errorf("дата начала загрузки (%s) не может быть в будущем", startDate.Format("2006-01-02"))}
+	}
+
+	// Подключение и получение исходных данных
+	instance, err := app.Initialize(context.Background(), cfg, startDate, logger, "stream")
+	if err != nil {
+		return fmt.Errorf("ошибка инициализации: %w", err)
+	}
+	defer instance.Close()
+
+	// Стрим работает до сигнала остановки
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Перечитывание конфигурации по SIGHUP (уровень логов, пауза, лимиты)
+	app.WatchConfigReload(ctx, cfg, configPath, logger)
+
+	if err := app.RunCandleStream(ctx, instance, logger); err != nil {
+		return err
+	}
+
+	storage.LogSaveSummary(logger)
+	return nil
+}
+
+func main() {
+	// Добавляем флаги
+	rootCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+
+	// Выполняем команду
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка выполнения команды: %v\n", err)
+		os.Exit(app.ExitCode(app.RunStats{}, err))
+	}
+}
@@ -0,0 +1,172 @@
+// Package main содержит расчёт дневного VWAP по сохранённым минутным свечам
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"market-loader/internal/app"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+	"market-loader/pkg/logs"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// defaultDays период расчёта по умолчанию (дней до сегодняшнего)
+const defaultDays = 7
+
+var (
+	// Флаги командной строки
+	figi       string
+	fromDate   string
+	toDate     string
+	configPath string
+	profile    string
+
+	// Код завершения по итогам расчёта
+	exitCode int
+
+	// Корневая команда
+	rootCmd = &cobra.Command{
+		Use:   "loader-vwap",
+		Short: "Расчёт дневного VWAP по минутным свечам",
+		Long: `Расчёт средневзвешенной по объёму цены (VWAP) за каждый торговый день
+по уже сохранённым минутным свечам: sum(close * volume) / sum(volume).
+Результат записывается в таблицу session_vwap, запросы к API не выполняются.
+Дни без свечей пропускаются, дни с нулевым объёмом записываются с vwap = NULL.
+
+Примеры использования:
+  loader-vwap
+  loader-vwap --from 2024-01-01 --to 2024-12-31
+  loader-vwap --figi BBG004730N88 --from 2024-06-01`,
+		RunE: runVWAP,
+	}
+)
+
+func runVWAP(cmd *cobra.Command, _ []string) error {
+	// Определяем путь к конфигурации
+	if !cmd.Flags().Changed("conf") {
+		configPath = config.GetConfigPath()
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfigProfile(configPath, profile)
+	if err != nil {
+		return &app.UsageError{Err: fmt.Errorf("ошибка загрузки конфигурации: %w", err)}
+	}
+
+	// Настраиваем логирование
+	logger := logs.SetupLogger(cfg)
+
+	// Период расчёта: по умолчанию последние defaultDays дней, включая сегодня
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -defaultDays)
+	to := today
+	if fromDate != "" {
+		if from, err = config.ParseDate(fromDate); err != nil {
+			return &app.UsageError{Err: fmt.Errorf("ошибка парсинга --from: %w", err)}
+		}
+	}
+	if toDate != "" {
+		if to, err = config.ParseDate(toDate); err != nil {
+			return &app.UsageError{Err: fmt.Errorf("ошибка парсинга --to: %w", err)}
+		}
+	}
+	if to.Before(from) {
+		return &app.UsageError{Err: fmt.Errorf("--to (%s) раньше --from (%s)", to.Format("2006-01-02"), from.Format("2006-01-02"))}
+	}
+
+	ctx := context.Background()
+
+	dbpool, err := storage.ConnectToDatabase(ctx, &cfg.Database)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer dbpool.Close()
+
+	// Отбор инструментов по allowlist/denylist
+	storage.SetInstrumentFilterSource(cfg.GetInstrumentFilter)
+	instruments, err := storage.GetEnabledInstruments(ctx, dbpool, "")
+	if err != nil {
+		return err
+	}
+
+	stats := app.RunStats{}
+	for _, instrument := range instruments {
+		if figi != "" && instrument.Figi != figi {
+			continue
+		}
+
+		days, err := computeInstrumentVWAP(ctx, dbpool, instrument.Figi, from, to)
+		stats.Total++
+		if err != nil {
+			stats.Failed++
+			logger.WithFields(logrus.Fields{
+				"figi":   instrument.Figi,
+				"ticker": instrument.Ticker,
+				"error":  err,
+			}).Error("Ошибка расчёта VWAP")
+			continue
+		}
+
+		logger.WithFields(logrus.Fields{
+			"figi":   instrument.Figi,
+			"ticker": instrument.Ticker,
+			"days":   days,
+		}).Info("VWAP рассчитан")
+	}
+
+	logger.WithFields(logrus.Fields{
+		"instruments": stats.Total,
+		"failed":      stats.Failed,
+		"from":        from.Format("2006-01-02"),
+		"to":          to.Format("2006-01-02"),
+	}).Info("Расчёт VWAP завершён")
+
+	exitCode = app.ExitCode(stats, nil)
+	return nil
+}
+
+// computeInstrumentVWAP считает VWAP инструмента за каждый день периода, возвращает количество записанных дней
+func computeInstrumentVWAP(ctx context.Context, dbpool *pgxpool.Pool, figi string, from, to time.Time) (int, error) {
+	days := 0
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		result, err := storage.ComputeSessionVWAP(ctx, dbpool, figi, day)
+		if err != nil {
+			return days, err
+		}
+		if result.Candles > 0 {
+			days++
+		}
+	}
+	return days, nil
+}
+
+func main() {
+	// Добавляем флаги
+	rootCmd.Flags().StringVarP(&figi, "figi", "f", "", "FIGI инструмента (по умолчанию все включённые)")
+	rootCmd.Flags().StringVar(&fromDate, "from", "", "Дата начала в формате YYYY-MM-DD (по умолчанию 7 дней назад)")
+	rootCmd.Flags().StringVar(&toDate, "to", "", "Дата окончания в формате YYYY-MM-DD включительно (по умолчанию сегодня)")
+	rootCmd.Flags().StringVarP(&configPath, "conf", "c", "config/config.yaml", "Путь к файлу конфигурации, \"-\" - stdin, http(s):// - URL (опционально)")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "Профиль конфигурации из секции profiles (по умолчанию $MARKET_LOADER_PROFILE или default)")
+
+	// Выполняем команду
+	rootCmd.SetFlagErrorFunc(app.FlagErrorFunc)
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка выполнения команды: %v\n", err)
+		os.Exit(app.ExitCode(app.RunStats{}, err))
+	}
+	os.Exit(exitCode)
+}
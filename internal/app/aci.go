@@ -0,0 +1,83 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+	"github.com/sirupsen/logrus"
+)
+
+// ProcessInstrumentACI загружает НКД одной облигации с дня после последней сохранённой даты
+func ProcessInstrumentACI(ctx context.Context, client *investgo.Client, dbpool *pgxpool.Pool, instrument storage.Instrument, cfg *config.Config, logger *logrus.Logger) error {
+	// Проверяем последнюю сохранённую дату НКД
+	lastDate, err := storage.GetLastAccruedInterestDate(ctx, dbpool, instrument.Figi)
+	if err != nil {
+		return err
+	}
+
+	// Определяем период загрузки
+	endTime := config.Now()
+	startTime := cfg.GetStartDate()
+
+	// Если НКД уже загружался, продолжаем со следующего дня
+	if !lastDate.IsZero() {
+		startTime = lastDate.AddDate(0, 0, 1)
+	}
+
+	// Проверяем, нужно ли загружать данные
+	if startTime.After(endTime) {
+		logger.WithFields(logrus.Fields{
+			"figi":   instrument.Figi,
+			"ticker": instrument.Ticker,
+		}).Debug("НКД актуален, пропускаем")
+		return nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"figi":      instrument.Figi,
+		"ticker":    instrument.Ticker,
+		"startTime": startTime.Format("2006-01-02"),
+		"endTime":   endTime.Format("2006-01-02"),
+	}).Info("Загружаем НКД")
+
+	// Загружаем НКД
+	values, err := data.LoadAccruedInterests(client, instrument.Figi, startTime, endTime)
+	if err != nil {
+		return err
+	}
+
+	// Сохраняем НКД
+	if len(values) == 0 {
+		logger.WithFields(logrus.Fields{
+			"figi":   instrument.Figi,
+			"ticker": instrument.Ticker,
+		}).Debug("Новых значений НКД нет")
+		return nil
+	}
+
+	for _, value := range values {
+		if err := storage.SaveAccruedInterest(ctx, dbpool, value); err != nil {
+			return err
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"figi":   instrument.Figi,
+		"ticker": instrument.Ticker,
+		"count":  len(values),
+	}).Info("НКД сохранён")
+
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	"context"
 	"time"
 
+	"market-loader/internal/arch"
 	"market-loader/internal/data"
 	"market-loader/internal/storage"
 	"market-loader/pkg/config"
@@ -26,11 +27,25 @@ type Result struct {
 	Ctx         context.Context
 	DBPool      *pgxpool.Pool
 	Client      *investgo.Client
+	Clients     *data.ClientPool // клиенты всех токенов, закрываются в Close
+	Sink        data.CandleSink  // публикация свечей, закрывается в Close
 	Instruments []storage.Instrument
 	StartDate   time.Time
 	Logger      *logrus.Entry
 }
 
+// Close закрывает публикацию свечей, клиенты API и подключение к БД; вызывается при завершении загрузчика
+func (r *Result) Close() {
+	data.SetCandleSink(nil, r.Logger.Logger)
+	if err := r.Sink.Close(); err != nil {
+		r.Logger.Warnf("Ошибка закрытия публикации свечей: %v", err)
+	}
+	data.SetClientPool(nil)
+	r.Clients.Stop()
+	_ = r.Client.Stop()
+	r.DBPool.Close()
+}
+
 // Initialize — централизованная инициализация для загрузчиков
 // loaderName используется как имя и интервал
 func Initialize(
@@ -43,32 +58,229 @@ func Initialize(
 	log := logger.WithField("loader", loaderName)
 	log.Debug("Начало инициализации компонентов")
 
-	// Подключение к БД
-	dbpool, err := storage.ConnectToDatabase(ctx, &cfg.Database)
+	// Подавление повторяющихся логов сохранения свечей
+	storage.SetLogSampling(!cfg.Logging.DisableSampling)
+
+	// Повторы записи при потере соединения с БД
+	if cfg.Database.MaxRetries != nil {
+		storage.SetWriteRetryPolicy(*cfg.Database.MaxRetries, storage.DefaultWriteRetryDelay)
+	}
+	// Повторы запросов к API при временных ошибках
+	if cfg.Tinvest.MaxRetries != nil {
+		data.SetAPIRetryPolicy(*cfg.Tinvest.MaxRetries, data.DefaultAPIRetryDelay)
+	}
+	// Повторы выдерживают паузу rate_limit_pause, как и первые запросы
+	data.SetAPIRateLimitPause(cfg.GetRateLimitPause)
+
+	// Подсказка о повторной загрузке уже имеющихся свечей
+	if cfg.Loading.ConflictAlertRatio != nil {
+		storage.SetConflictAlertRatio(*cfg.Loading.ConflictAlertRatio)
+	}
+
+	// Файл-источник свечей из архивов
+	storage.SetTrackSourceFile(cfg.Archive.TrackSourceFile)
+
+	// Предпочтение источников свечей при чтении
+	sources, err := cfg.GetSourcePreference()
+	if err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "storage.source_preference"}
+	}
+	storage.SetCandleSourcePreference(sources)
+
+	// Проверка шага сохраняемых свечей
+	intervalCheck, err := cfg.GetIntervalCheck()
+	if err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "storage.interval_check"}
+	}
+	storage.SetIntervalCheck(intervalCheck)
+
+	// Десятичный разделитель чисел в CSV архивов
+	separator, err := cfg.GetDecimalSeparator()
+	if err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "archive.decimal_separator"}
+	}
+	arch.SetDecimalSeparator(separator)
+
+	// Пропуск архивов, не изменившихся с прошлой обработки
+	arch.SetSkipUnchanged(cfg.Archive.SkipUnchanged)
+
+	// Удаление скачанных архивов
+	cleanup, err := cfg.GetArchiveCleanup()
+	if err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "archive.cleanup"}
+	}
+	arch.SetCleanupPolicy(cleanup)
+
+	// Канонические секторы инструментов (loading.sector_mapping поверх соответствия по умолчанию)
+	data.SetSectorMapping(cfg.GetSectorMapping())
+
+	// Отбор инструментов по allowlist/denylist (файлы проверяются сразу, чтобы ошибка была при запуске)
+	if _, err := cfg.GetInstrumentFilter(); err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err}
+	}
+	storage.SetInstrumentFilterSource(cfg.GetInstrumentFilter)
+
+	// Инструменты только для квалифицированных инвесторов (недоступны токену неквалифицированного инвестора)
+	storage.SetExcludeQualOnly(cfg.Loading.ExcludeQualOnly)
+
+	// Буфер отложенной записи свечей
+	flushInterval, err := cfg.GetWriteBufferFlushInterval()
+	if err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "loading.write_buffer.flush_interval"}
+	}
+	storage.SetWriteBuffer(cfg.Loading.WriteBuffer.Size, flushInterval)
+
+	// Бюджет запросов к API на запуск
+	data.SetRequestBudget(cfg.Loading.MaxRequestsPerRun)
+
+	// Максимальная длительность загрузки: ошибка в значении не должна молча снимать ограничение
+	if _, err := cfg.GetMaxRunDuration(); err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "loading.max_run_duration"}
+	}
+	// Минимальный интервал между запусками
+	if _, err := cfg.GetMinRunInterval(); err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "loading.min_run_interval"}
+	}
+	// Ожидание блокировки синхронизации инструментов
+	if _, err := cfg.GetSyncLockWait(); err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "loading.sync_lock.wait"}
+	}
+
+	// Поведение при start_date раньше первой свечи инструмента
+	if _, err := cfg.GetBeforeListing(); err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "loading.before_listing"}
+	}
+
+	// Статус инструментов для запроса списка в API
+	if _, err := cfg.GetInstrumentStatus(); err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "loading.instrument_status"}
+	}
+
+	// Порядок загрузки истории
+	if _, err := cfg.GetLoadingOrder(); err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "loading.order"}
+	}
+
+	// Минимальный объём сохраняемых свечей по интервалам
+	minVolume, err := cfg.GetMinVolume()
+	if err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "loading.min_volume"}
+	}
+	storage.SetMinVolume(minVolume)
+
+	// Свечи только основной сессии (loading.sessions: regular)
+	sessions, err := cfg.GetSessions()
+	if err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "loading.sessions"}
+	}
+	if sessions == config.SessionsRegular {
+		openAt, closeAt, err := cfg.RegularSession()
+		if err != nil {
+			return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "calendar.regular_open"}
+		}
+		storage.SetRegularSession(openAt, closeAt)
+	}
+
+	// Преобразования свечей перед сохранением
+	transformer, err := data.NewCandleTransformer(cfg.Loading.Transforms, cfg)
+	if err != nil {
+		return nil, &InitializationError{Msg: "ошибка настройки преобразований свечей", Err: err}
+	}
+	data.SetCandleTransformer(transformer)
+
+	// Проверка лимитов загрузки: неизвестный интервал - ошибка, остальное - предупреждения
+	if err := cfg.Validate(); err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "loading.limits"}
+	}
+	for _, warning := range cfg.ValidateLimits() {
+		log.Warn(warning)
+	}
+
+	// Хотя бы один токен API
+	if len(cfg.GetTokens()) == 0 {
+		return nil, &InitializationError{Msg: "не задан токен API", Field: "tinvest.token"}
+	}
+
+	// Ожидание доступности БД и API при запуске
+	connectTimeout, err := cfg.GetConnectTimeout()
+	if err != nil {
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "startup.connect_timeout"}
+	}
+
+	// Подключение к БД (с ожиданием доступности, если задан startup.connect_timeout)
+	dbpool, err := connectDatabase(ctx, cfg, connectTimeout, log)
+	if err != nil {
+		return nil, &InitializationError{Msg: "ошибка подключения к БД", Err: err, Connection: true}
+	}
+
+	// Прокси для запросов к API (tinvest.proxy или HTTPS_PROXY)
+	proxy, err := data.ConfigureProxy(cfg)
 	if err != nil {
-		return nil, &InitializationError{Msg: "ошибка подключения к БД", Err: err}
+		dbpool.Close()
+		return nil, &InitializationError{Msg: "ошибка конфигурации", Err: err, Field: "tinvest.proxy"}
+	}
+	if proxy != "" {
+		log.WithField("proxy", proxy).Info("Подключение к API через прокси")
 	}
 
 	// Клиент API
-	client, err := data.CreateTinvestClient(ctx, cfg)
+	client, err := connectAPI(ctx, cfg, connectTimeout, proxy != "", log)
 	if err != nil {
 		dbpool.Close()
-		return nil, &InitializationError{Msg: "ошибка создания клиента API", Err: err}
+		return nil, &InitializationError{Msg: "ошибка создания клиента API", Err: err, Connection: true}
+	}
+	log.WithFields(logrus.Fields{
+		"appName": data.AppName(cfg),
+		"runID":   data.RunID(),
+	}).Debug("Клиент API создан")
+
+	// Клиенты дополнительных токенов: загрузка свечей распределяется между токенами
+	clients, err := data.NewClientPool(ctx, cfg, client)
+	if err != nil {
+		_ = client.Stop()
+		dbpool.Close()
+		return nil, &InitializationError{Msg: "ошибка создания клиентов API", Err: err, Connection: true}
+	}
+	data.SetClientPool(clients)
+	data.SetSharedRateLimit(cfg.GetIntervalWorkers() > 1)
+	if clients.Len() > 1 {
+		log.WithField("tokens", clients.Len()).Info("Запросы распределяются между токенами API")
 	}
 
 	// Загрузка инструментов
 	instruments, err := storage.LoadInstruments(ctx, dbpool, logger)
 	if err != nil {
+		data.SetClientPool(nil)
+		clients.Stop()
+		_ = client.Stop()
 		dbpool.Close()
 		return nil, &InitializationError{Msg: "ошибка загрузки инструментов", Err: err}
 	}
 
 	log.WithField("count", len(instruments)).Debug("Инструменты загружены")
 
+	// Публикация сохранённых свечей (sink.kafka): подключается последней, после БД и API,
+	// чтобы не оставлять открытое подключение при ошибке инициализации
+	sink, err := data.NewCandleSink(cfg)
+	if err != nil {
+		data.SetClientPool(nil)
+		clients.Stop()
+		_ = client.Stop()
+		dbpool.Close()
+		return nil, &InitializationError{Msg: "ошибка настройки публикации свечей", Err: err, Field: "sink.kafka.brokers"}
+	}
+	data.SetCandleSink(sink, logger)
+
+	// Профилирование (только если задан debug.pprof_addr): сервер запускается после успешной
+	// инициализации и работает до завершения процесса
+	StartPprof(cfg.Debug.PprofAddr, logger)
+
 	return &Result{
 		Ctx:         ctx,
 		DBPool:      dbpool,
 		Client:      client,
+		Clients:     clients,
+		Sink:        sink,
 		Instruments: instruments,
 		StartDate:   startDate,
 		Logger:      log,
@@ -80,6 +292,13 @@ type InitializationError struct {
 	Msg   string
 	Field string
 	Err   error
+	// Ошибка подключения к БД или API (код завершения ExitConnectionError)
+	Connection bool
+}
+
+// Unwrap возвращает исходную ошибку (errors.Is(err, data.ErrInvalidToken))
+func (e *InitializationError) Unwrap() error {
+	return e.Err
 }
 
 func (e *InitializationError) Error() string {
@@ -10,10 +10,13 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"market-loader/internal/data"
 	"market-loader/internal/storage"
 	"market-loader/pkg/config"
+	"sync"
+	"sync/atomic"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/russianinvestments/invest-api-go-sdk/investgo"
@@ -28,6 +31,19 @@ func ProcessLoadResult(
 	loadError error,
 	logger *logrus.Logger,
 ) error {
+	// Сохраняем буфер до обновления прогресса: last_loaded_time не должен опережать данные в БД
+	if err := storage.FlushCandlesFor(dbpool, figi, intervalType, logger); err != nil {
+		logger.WithFields(logrus.Fields{
+			"figi":         figi,
+			"intervalType": intervalType,
+			"error":        err,
+		}).Error("Ошибка сброса буфера отложенной записи")
+		if loadError == nil {
+			loadError = err
+		}
+		return loadError
+	}
+
 	// Получаем время последней загруженной свечи из БД
 	lastCandleTime, err := storage.GetLastCandleTime(ctx, dbpool, figi, intervalType)
 	if err != nil {
@@ -73,15 +89,192 @@ func ProcessInstrument(
 	cfg *config.Config,
 	logger *logrus.Logger,
 ) error {
+	// Время загрузки истекло - инструмент не начинаем
+	if ctx.Err() != nil {
+		return data.RunStopError(ctx)
+	}
+	// Прогресс сохраняется и после истечения времени загрузки
+	dbCtx := context.WithoutCancel(ctx)
+
 	// Проверяем статус загрузки по реально загруженным данным
 	lastLoadedTime, err := storage.GetLastLoadedTime(ctx, dbpool, instrument.Figi, interval)
 	if err != nil {
 		return fmt.Errorf("ошибка получения времени последней загрузки: %w", err)
 	}
 
+	// Инструмент без свечей, не возвращавший их несколько запусков подряд, запрашивается только при повторной проверке
+	hadCandles := !lastLoadedTime.IsZero()
+	if !hadCandles && shouldSkipEmpty(ctx, dbpool, instrument, interval, cfg, logger) {
+		return nil
+	}
+
+	// Новый инструмент на минутном интервале - история через архивы (archive.first_run),
+	// затем API продолжает с последней загруженной свечи
+	if lastLoadedTime.IsZero() && interval == config.CandleInterval1Min && cfg.Archive.FirstRun {
+		if err := loadFirstRunArchives(ctx, dbpool, instrument, cfg, logger); err != nil {
+			if errors.Is(err, data.ErrBudgetExhausted) {
+				return err
+			}
+			// Загруженные годы сохраняются в прогрессе, остальное - в следующем запуске
+			if errors.Is(err, data.ErrRunDeadline) {
+				return data.ProcessLoadResult(dbCtx, dbpool, instrument.Figi, interval, err, logger)
+			}
+			logger.WithFields(logrus.Fields{
+				"figi":   instrument.Figi,
+				"ticker": instrument.Ticker,
+				"error":  err,
+			}).Warn("Ошибка загрузки архивов, продолжаем через API")
+		}
+		if lastLoadedTime, err = storage.GetLastCandleTime(dbCtx, dbpool, instrument.Figi, interval); err != nil {
+			return fmt.Errorf("ошибка получения времени последней свечи: %w", err)
+		}
+	}
+
 	// Загружаем данные с помощью универсальной функции
 	loadError := data.LoadCandleData(ctx, client, dbpool, instrument, lastLoadedTime, interval, cfg, logger)
 
 	// Обрабатываем результат загрузки и обновляем прогресс
-	return data.ProcessLoadResult(ctx, dbpool, instrument.Figi, interval, loadError, logger)
+	err = data.ProcessLoadResult(dbCtx, dbpool, instrument.Figi, interval, loadError, logger)
+
+	// Инструмент без доступа для токена - не ошибка запуска
+	if data.IsInstrumentInaccessible(err) {
+		handleInaccessibleInstrument(dbCtx, dbpool, instrument, err, cfg, logger)
+		return nil
+	}
+
+	// Проверяем количество свечей после успешной загрузки
+	if err == nil {
+		if !hadCandles {
+			trackEmptyLoad(dbCtx, dbpool, instrument, interval, cfg, logger)
+		}
+		VerifyCandleCount(dbCtx, dbpool, instrument, interval, cfg, logger)
+		VerifyPriceScale(dbCtx, dbpool, instrument, interval, cfg, logger)
+		UpdateLoadCoverage(dbCtx, dbpool, instrument, interval, cfg, logger)
+	}
+	return err
+}
+
+// ProcessInstrumentIntervals обрабатывает инструмент по нескольким интервалам: параллельно
+// не более loading.interval_workers интервалов, пауза rate_limit_pause общая. Ошибки интервалов собираются вместе
+func ProcessInstrumentIntervals(
+	ctx context.Context,
+	client *investgo.Client,
+	dbpool *pgxpool.Pool,
+	intervals []string,
+	instrument storage.Instrument,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) error {
+	if len(intervals) == 1 {
+		return ProcessInstrument(ctx, client, dbpool, intervals[0], instrument, cfg, logger)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	slots := make(chan struct{}, cfg.GetIntervalWorkers())
+	for _, interval := range intervals {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(interval string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := ProcessInstrument(ctx, client, dbpool, interval, instrument, cfg, logger); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", config.Interval2text(interval), err))
+				mu.Unlock()
+			}
+		}(interval)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// ProcessInstrumentWindows загружает свечи инструмента только за окна вокруг дат событий по каждому интервалу.
+// Прогресс инструмента не обновляется: окна событий не считаются загрузкой истории
+func ProcessInstrumentWindows(
+	ctx context.Context,
+	client *investgo.Client,
+	dbpool *pgxpool.Pool,
+	intervals []string,
+	instrument storage.Instrument,
+	windows []data.DateWindow,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) error {
+	var errs []error
+	for _, interval := range intervals {
+		if err := data.RunStopError(ctx); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		loadErr := data.LoadCandleWindows(ctx, client, dbpool, instrument, windows, interval, cfg, logger)
+		// Загруженные чанки сохраняются и при ошибке загрузки
+		if err := storage.FlushCandlesFor(dbpool, instrument.Figi, interval, logger); err != nil && loadErr == nil {
+			loadErr = err
+		}
+		if loadErr != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", config.Interval2text(interval), loadErr))
+			if errors.Is(loadErr, data.ErrBudgetExhausted) || errors.Is(loadErr, data.ErrRunDeadline) {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IsBudgetExhausted проверяет, что загрузка остановлена исчерпанием бюджета запросов (не ошибка запуска)
+func IsBudgetExhausted(err error, logger *logrus.Logger) bool {
+	if !errors.Is(err, data.ErrBudgetExhausted) {
+		return false
+	}
+	logger.WithField("requests", data.RequestsUsed()).
+		Warn("Бюджет запросов к API исчерпан (loading.max_requests_per_run), продолжение в следующем запуске")
+	return true
+}
+
+// inaccessibleCount количество инструментов без доступа за запуск
+var inaccessibleCount atomic.Int64
+
+// handleInaccessibleInstrument логирует недоступный инструмент одной строкой
+// и выключает его, если задано loading.disable_inaccessible
+func handleInaccessibleInstrument(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	err error,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) {
+	inaccessibleCount.Add(1)
+
+	fields := logrus.Fields{
+		"figi":         instrument.Figi,
+		"ticker":       instrument.Ticker,
+		"forQualified": instrument.ForQualInvestorFlag,
+	}
+
+	if !cfg.Loading.DisableInaccessible {
+		logger.WithFields(fields).Infof("Инструмент недоступен для токена, пропускаем: %v", err)
+		return
+	}
+
+	if disableErr := storage.DisableInstrument(ctx, dbpool, instrument.Figi); disableErr != nil {
+		fields["error"] = disableErr
+		logger.WithFields(fields).Warn("Инструмент недоступен для токена, не удалось выключить")
+		return
+	}
+	logger.WithFields(fields).Infof("Инструмент недоступен для токена, выключен (enabled = false): %v", err)
+}
+
+// LogInaccessibleSummary выводит количество недоступных инструментов за запуск
+func LogInaccessibleSummary(logger *logrus.Logger) {
+	if count := inaccessibleCount.Load(); count > 0 {
+		logger.WithField("count", count).Info("Пропущено инструментов без доступа")
+	}
 }
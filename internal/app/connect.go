@@ -0,0 +1,129 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// connectWithRetry повторяет подключение с нарастающей задержкой, пока не истечёт timeout.
+// retryable решает, имеет ли смысл повторять после ошибки (например, ошибки миграций не повторяются)
+func connectWithRetry(
+	ctx context.Context,
+	timeout time.Duration,
+	log *logrus.Entry,
+	target string,
+	connect func() error,
+	retryable func(error) bool,
+) error {
+	deadline := time.Now().Add(timeout)
+	delay := config.StartupConnectDelay
+
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			if attempt > 1 {
+				log.WithFields(logrus.Fields{
+					"target":  target,
+					"attempt": attempt,
+				}).Info("Подключение установлено")
+			}
+			return nil
+		}
+
+		if timeout <= 0 || !retryable(err) || time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		log.WithFields(logrus.Fields{
+			"target":  target,
+			"attempt": attempt,
+			"delay":   delay,
+			"error":   err,
+		}).Warn("Ошибка подключения, повтор")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > config.StartupMaxConnectDelay {
+			delay = config.StartupMaxConnectDelay
+		}
+	}
+}
+
+// connectDatabase подключается к БД, ожидая её доступности до timeout (startup.connect_timeout)
+func connectDatabase(ctx context.Context, cfg *config.Config, timeout time.Duration, log *logrus.Entry) (*pgxpool.Pool, error) {
+	var dbpool *pgxpool.Pool
+	err := connectWithRetry(ctx, timeout, log, "database", func() error {
+		var err error
+		dbpool, err = storage.ConnectToDatabase(ctx, &cfg.Database)
+		return err
+	}, storage.IsRecoverableError)
+	return dbpool, err
+}
+
+// connectAPI создает клиент API и проверяет токен и доступность API запросом информации о пользователе,
+// ожидая доступности до timeout (startup.connect_timeout). Недействительный токен - сразу ErrInvalidToken.
+// Недоступность API без ожидания (и без прокси) не прерывает запуск: её обработает загрузка инструментов.
+// При подключении через прокси (viaProxy) недоступность API - ошибка, чтобы проблема прокси была видна при запуске
+func connectAPI(ctx context.Context, cfg *config.Config, timeout time.Duration, viaProxy bool, log *logrus.Entry) (*investgo.Client, error) {
+	verify := timeout > 0 || viaProxy
+
+	var client *investgo.Client
+	err := connectWithRetry(ctx, timeout, log, "api", func() error {
+		var err error
+		client, err = data.CreateTinvestClient(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		// Клиент подключается лениво, поэтому токен и доступность API проверяются запросом
+		_, err = client.NewUsersServiceClient().GetInfo()
+		if err = data.WrapAccountError(err); err == nil {
+			return nil
+		}
+		if !verify && !errors.Is(err, data.ErrInvalidToken) {
+			log.Warnf("Не удалось проверить доступность API: %v", err)
+			return nil
+		}
+		_ = client.Stop()
+		if viaProxy && !errors.Is(err, data.ErrInvalidToken) {
+			return fmt.Errorf("API недоступен через прокси: %w", err)
+		}
+		return err
+	}, isRecoverableAPIError)
+	return client, err
+}
+
+// isRecoverableAPIError проверяет, что ошибка API связана с недоступностью, а не с токеном или правами
+func isRecoverableAPIError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,87 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"math"
+
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateLoadCoverage пересчитывает полноту загрузки инструмента по интервалу (loading.coverage):
+// количество свечей сравнивается с ожидаемым по торговому календарю от start_date (не раньше первой свечи
+// инструмента) до текущего момента. Инструмент считается загруженным, если доля не меньше
+// loading.coverage.min_ratio и свечей не меньше loading.coverage.min_candles. Результат - в load_coverage
+func UpdateLoadCoverage(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	intervalType string,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) {
+	if !cfg.Loading.Coverage.Enabled {
+		return
+	}
+
+	stats, err := storage.GetCandleStats(ctx, dbpool, instrument.Figi, intervalType)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"figi":  instrument.Figi,
+			"error": err,
+		}).Warn("Не удалось рассчитать полноту загрузки")
+		return
+	}
+
+	// Без даты первой свечи в справочнике период отсчитывается от первой загруженной свечи
+	from := cfg.GetStartDate()
+	if listing := data.ListingDate(instrument, intervalType); listing.After(from) {
+		from = listing
+	} else if listing.IsZero() && stats.First.After(from) {
+		from = stats.First
+	}
+
+	coverage := storage.LoadCoverage{
+		Figi:         instrument.Figi,
+		IntervalType: intervalType,
+		Candles:      stats.Count,
+		From:         from,
+	}
+	if expected, ok := cfg.ExpectedCandles(intervalType, from, config.Now()); ok && expected > 0 {
+		coverage.Expected = expected
+		coverage.Ratio = float64(stats.Count) / float64(expected)
+	}
+	coverage.Loaded = stats.Count > 0 && stats.Count >= cfg.Loading.Coverage.MinCandles &&
+		(coverage.Expected == 0 || coverage.Ratio >= cfg.GetCoverageMinRatio())
+
+	if err := storage.SaveLoadCoverage(ctx, dbpool, coverage); err != nil {
+		logger.WithFields(logrus.Fields{
+			"figi":  instrument.Figi,
+			"error": err,
+		}).Warn("Не удалось сохранить полноту загрузки")
+		return
+	}
+
+	if !coverage.Loaded {
+		logger.WithFields(logrus.Fields{
+			"figi":         instrument.Figi,
+			"ticker":       instrument.Ticker,
+			"intervalType": intervalType,
+			"candles":      stats.Count,
+			"expected":     coverage.Expected,
+			"completeness": math.Round(coverage.Ratio*100) / 100,
+		}).Debug("Инструмент загружен не полностью")
+	}
+}
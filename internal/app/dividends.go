@@ -14,7 +14,6 @@ import (
 	"market-loader/internal/data"
 	"market-loader/internal/storage"
 	"market-loader/pkg/config"
-	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/russianinvestments/invest-api-go-sdk/investgo"
@@ -24,10 +23,13 @@ import (
 // ProcessInstrumentDividends обрабатывает дивиденды одного инструмента
 func ProcessInstrumentDividends(ctx context.Context, client *investgo.Client, dbpool *pgxpool.Pool, instrument storage.Instrument, cfg *config.Config, logger *logrus.Logger) error {
 	// Проверяем последнюю дату выплаты дивидендов
-	lastDividendDate, _ := storage.GetLastDividendDate(ctx, dbpool, instrument.Figi)
+	lastDividendDate, err := storage.GetLastDividendDate(ctx, dbpool, instrument.Figi)
+	if err != nil {
+		return err
+	}
 
 	// Определяем период загрузки
-	endTime := time.Now()
+	endTime := config.Now()
 	startTime := cfg.GetStartDate()
 
 	// Если есть последняя выплата, начинаем с неё
@@ -52,17 +54,16 @@ func ProcessInstrumentDividends(ctx context.Context, client *investgo.Client, db
 	}).Info("Загружаем дивиденды")
 
 	// Загружаем дивиденды
-	dividends, err := data.LoadDividends(client, instrument.Figi, startTime, endTime)
+	dividends, err := data.LoadDividends(ctx, client, instrument.Figi, startTime, endTime)
 	if err != nil {
 		return fmt.Errorf("ошибка загрузки дивидендов: %w", err)
 	}
 
-	// Сохраняем дивиденды
+	// Сохраняем дивиденды инструмента одной транзакцией: при ошибке не сохраняется ни один,
+	// инструмент попадает в ошибки запуска и загружается повторно целиком
 	if len(dividends) > 0 {
-		for _, dividend := range dividends {
-			if err := storage.SaveDividend(ctx, dbpool, dividend); err != nil {
-				return fmt.Errorf("ошибка сохранения дивиденда: %w", err)
-			}
+		if err := storage.SaveDividends(ctx, dbpool, dividends); err != nil {
+			return err
 		}
 
 		logger.WithFields(logrus.Fields{
@@ -0,0 +1,120 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// skippedEmptyCount количество инструментов без свечей, пропущенных за запуск (loading.skip_empty)
+var skippedEmptyCount atomic.Int64
+
+// shouldSkipEmpty проверяет, что инструмент без свечей не вернул свечей loading.skip_empty.runs запусков подряд
+// и повторная проверка (loading.skip_empty.recheck) ещё не наступила
+func shouldSkipEmpty(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	interval string,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) bool {
+	if cfg.Loading.SkipEmpty.Runs <= 0 {
+		return false
+	}
+
+	state, err := storage.GetEmptyLoad(ctx, dbpool, instrument.Figi, interval)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"figi":  instrument.Figi,
+			"error": err,
+		}).Warn("Не удалось проверить пустые загрузки инструмента")
+		return false
+	}
+	if state.Runs < cfg.Loading.SkipEmpty.Runs {
+		return false
+	}
+
+	recheckAt := state.CheckedAt.Add(cfg.GetSkipEmptyRecheck())
+	if !time.Now().Before(recheckAt) {
+		return false
+	}
+
+	skippedEmptyCount.Add(1)
+	logger.WithFields(logrus.Fields{
+		"figi":      instrument.Figi,
+		"ticker":    instrument.Ticker,
+		"interval":  config.Interval2text(interval),
+		"emptyRuns": state.Runs,
+		"recheckAt": recheckAt.Format("2006-01-02 15:04"),
+	}).Debug("Инструмент без свечей, пропускаем до повторной проверки")
+	return true
+}
+
+// trackEmptyLoad учитывает успешную загрузку инструмента, у которого не было свечей:
+// свечей по-прежнему нет - ещё один пустой запуск, появились - учёт сбрасывается
+func trackEmptyLoad(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	interval string,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) {
+	if cfg.Loading.SkipEmpty.Runs <= 0 {
+		return
+	}
+
+	fields := logrus.Fields{
+		"figi":     instrument.Figi,
+		"ticker":   instrument.Ticker,
+		"interval": config.Interval2text(interval),
+	}
+
+	lastCandleTime, err := storage.GetLastCandleTime(ctx, dbpool, instrument.Figi, interval)
+	if err != nil {
+		fields["error"] = err
+		logger.WithFields(fields).Warn("Не удалось учесть пустую загрузку инструмента")
+		return
+	}
+	if !lastCandleTime.IsZero() {
+		if err := storage.ClearEmptyLoad(ctx, dbpool, instrument.Figi, interval); err != nil {
+			fields["error"] = err
+			logger.WithFields(fields).Warn("Не удалось сбросить учёт пустых загрузок инструмента")
+		}
+		return
+	}
+
+	runs, err := storage.RecordEmptyLoad(ctx, dbpool, instrument.Figi, interval)
+	if err != nil {
+		fields["error"] = err
+		logger.WithFields(fields).Warn("Не удалось учесть пустую загрузку инструмента")
+		return
+	}
+	if runs == cfg.Loading.SkipEmpty.Runs {
+		fields["emptyRuns"] = runs
+		fields["recheck"] = cfg.GetSkipEmptyRecheck()
+		logger.WithFields(fields).Info("Инструмент не вернул свечей несколько запусков подряд, далее запрашивается только при повторной проверке")
+	}
+}
+
+// LogSkippedEmptySummary выводит количество инструментов без свечей, пропущенных за запуск
+func LogSkippedEmptySummary(logger *logrus.Logger) {
+	if count := skippedEmptyCount.Load(); count > 0 {
+		logger.WithField("count", count).Info("Пропущено инструментов без свечей (loading.skip_empty)")
+	}
+}
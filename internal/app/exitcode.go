@@ -0,0 +1,116 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"errors"
+
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
+
+	"github.com/spf13/cobra"
+)
+
+// Коды завершения загрузчиков (для cron и мониторинга)
+const (
+	// ExitOK успешное завершение
+	ExitOK = 0
+	// ExitConfigError ошибка конфигурации или параметров запуска
+	ExitConfigError = 1
+	// ExitConnectionError ошибка подключения к БД или API
+	ExitConnectionError = 2
+	// ExitPartialFailure часть инструментов (заданий) завершилась с ошибкой
+	ExitPartialFailure = 3
+	// ExitNothingToDo нечего загружать (запуск пропущен или нет инструментов)
+	ExitNothingToDo = 4
+	// ExitDeadline истекло время загрузки (loading.max_run_duration), прогресс сохранён
+	ExitDeadline = 5
+	// ExitAuthError недействительный или просроченный токен T-Invest
+	ExitAuthError = 6
+	// ExitRunFailed загрузка не выполнена: все инструменты (задания) завершились с ошибкой
+	// или запуск прерван ошибкой
+	ExitRunFailed = 7
+)
+
+// UsageError ошибка конфигурации или параметров запуска (код завершения ExitConfigError)
+type UsageError struct {
+	Err error
+}
+
+// Unwrap возвращает исходную ошибку
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+// FlagErrorFunc помечает ошибки разбора флагов как ошибки параметров запуска (cobra.Command.SetFlagErrorFunc)
+func FlagErrorFunc(_ *cobra.Command, err error) error {
+	return &UsageError{Err: err}
+}
+
+// RunStats итог запуска загрузчика для кода завершения
+type RunStats struct {
+	Total  int // обработано инструментов (заданий)
+	Failed int // из них с ошибкой
+}
+
+// Add учитывает результат задания; задание без инструментов (справочник)
+// или прерванное ошибкой до обработки инструментов считается одной единицей
+func (s *RunStats) Add(total, failed int, err error) {
+	if total == 0 {
+		total = 1
+	}
+	if err != nil && failed == 0 {
+		failed = 1
+	}
+	s.Total += total
+	s.Failed += failed
+}
+
+// ExitCode возвращает код завершения по итогам запуска и ошибке, прервавшей запуск
+func ExitCode(stats RunStats, err error) int {
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidToken) {
+			return ExitAuthError
+		}
+		if errors.Is(err, data.ErrRunDeadline) {
+			return ExitDeadline
+		}
+		var initErr *InitializationError
+		if errors.As(err, &initErr) && initErr.Connection {
+			return ExitConnectionError
+		}
+		if errors.Is(err, ErrProviderDown) || storage.IsRecoverableError(err) {
+			return ExitConnectionError
+		}
+		// Ошибки конфигурации и параметров запуска: при запуске (Initialize) и разборе флагов
+		var usageErr *UsageError
+		if errors.As(err, &initErr) || errors.As(err, &usageErr) {
+			return ExitConfigError
+		}
+		if stats.Failed > 0 && stats.Failed < stats.Total {
+			return ExitPartialFailure
+		}
+		return ExitRunFailed
+	}
+
+	switch {
+	case stats.Total == 0:
+		return ExitNothingToDo
+	case stats.Failed >= stats.Total:
+		return ExitRunFailed
+	case stats.Failed > 0:
+		return ExitPartialFailure
+	default:
+		return ExitOK
+	}
+}
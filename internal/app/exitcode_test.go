@@ -0,0 +1,49 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"market-loader/internal/data"
+)
+
+func TestExitCode(t *testing.T) {
+	runErr := errors.New("ошибка загрузки")
+	tests := []struct {
+		name  string
+		stats RunStats
+		err   error
+		want  int
+	}{
+		{"success", RunStats{Total: 4}, nil, ExitOK},
+		{"nothing to do", RunStats{}, nil, ExitNothingToDo},
+		{"partial", RunStats{Total: 4, Failed: 1}, nil, ExitPartialFailure},
+		{"all failed", RunStats{Total: 4, Failed: 4}, nil, ExitRunFailed},
+		{"partial with error", RunStats{Total: 4, Failed: 3}, runErr, ExitPartialFailure},
+		{"all failed with error", RunStats{Total: 4, Failed: 4}, runErr, ExitRunFailed},
+		{"runtime error", RunStats{}, runErr, ExitRunFailed},
+		{"usage error", RunStats{}, &UsageError{Err: runErr}, ExitConfigError},
+		{"initialization error", RunStats{}, &InitializationError{Msg: "ошибка конфигурации", Err: runErr}, ExitConfigError},
+		{"connection error", RunStats{}, &InitializationError{Msg: "ошибка подключения к БД", Err: runErr, Connection: true}, ExitConnectionError},
+		{"provider down", RunStats{Total: 4, Failed: 2}, ErrProviderDown, ExitConnectionError},
+		{"invalid token", RunStats{}, fmt.Errorf("запрос: %w", data.ErrInvalidToken), ExitAuthError},
+		{"deadline", RunStats{Total: 4, Failed: 1}, data.ErrRunDeadline, ExitDeadline},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.stats, tt.err); got != tt.want {
+				t.Errorf("ExitCode(%+v, %v) = %d, ожидался %d", tt.stats, tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"market-loader/internal/arch"
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// loadFirstRunArchives загружает минутную историю нового инструмента через годовые архивы:
+// с года начала загрузки до прошлого года. Текущий год догружается через API.
+// Годы до первого архива с данными пропускаются, ошибка после него останавливает загрузку архивов,
+// чтобы API продолжил с последней загруженной свечи без пропусков
+func loadFirstRunArchives(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) error {
+	startYear := cfg.GetStartDate().Year()
+	if instrument.IpoDate.Year() > startYear {
+		startYear = instrument.IpoDate.Year()
+	}
+	lastYear := config.Now().UTC().Year() - 1
+	if startYear > lastYear {
+		return nil
+	}
+
+	// Временная директория для архивов (удаляется по политике archive.cleanup)
+	tempDir, err := arch.OpenTempDir(cfg.Archive.TempDir)
+	if err != nil {
+		return err
+	}
+	failed := false
+	defer func() {
+		tempDir.Close(failed, logger)
+	}()
+
+	logger.WithFields(logrus.Fields{
+		"figi":     instrument.Figi,
+		"ticker":   instrument.Ticker,
+		"fromYear": startYear,
+		"toYear":   lastYear,
+	}).Info("Первая загрузка: история через архивы, текущий год - через API")
+
+	// Свечей инструмента в БД нет - хеши прошлых обработок устарели, архивы разбираются заново
+	if err := storage.ClearArchiveHashes(ctx, dbpool, instrument.Figi); err != nil {
+		return err
+	}
+
+	token := cfg.GetTokens()[0]
+	loaded := 0
+	for year := startYear; year <= lastYear; year++ {
+		// Время загрузки истекло - загруженные годы сохранены, остальное продолжит API в следующем запуске
+		if ctx.Err() != nil {
+			return data.RunStopError(ctx)
+		}
+		if !data.TakeRequest() {
+			return data.ErrBudgetExhausted
+		}
+
+		if err := storage.CreateYearPartitions(dbpool, config.CandleInterval1Min, year); err != nil {
+			return fmt.Errorf("ошибка создания партиций за %d год: %w", year, err)
+		}
+
+		candles, err := arch.DownloadYearArchive(context.WithoutCancel(ctx), token, instrument.Figi, year, tempDir.Path, cfg.GetArchiveMaxSize(), dbpool, logger)
+		if err != nil {
+			if loaded == 0 {
+				logger.Debugf("Архив за %d год для %s недоступен, пропускаем: %v", year, instrument.Ticker, err)
+				continue
+			}
+			failed = true
+			return fmt.Errorf("ошибка загрузки архива за %d год: %w", year, err)
+		}
+		loaded += len(candles)
+		logger.Infof("Загружено %d свечей за %d год для %s", len(candles), year, instrument.Ticker)
+
+		// Пауза между запросами
+		time.Sleep(cfg.GetRateLimitPause())
+	}
+
+	return nil
+}
@@ -10,46 +10,132 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"market-loader/internal/data"
+	"market-loader/pkg/config"
+	"sync"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/russianinvestments/invest-api-go-sdk/investgo"
 	"github.com/sirupsen/logrus"
 )
 
-// LoadAllInstruments загружает все типы инструментов
+// instrumentTypes типы инструментов для загрузки справочника (валюты - вместе с валютными парами)
+var instrumentTypes = []struct {
+	name  config.InstrumentType
+	title string
+}{
+	{config.InstrumentTypeShare, "акции"},
+	{config.InstrumentTypeBond, "облигации"},
+	{config.InstrumentTypeEtf, "ETF"},
+	{config.InstrumentTypeCurrency, "валюты"},
+}
+
+// LoadAllInstruments загружает все типы инструментов, параллельно до loading.instrument_workers типов;
+// запросы списков к API разносятся на rate_limit_pause.
+// Ошибки отдельных типов объединяются, успешно загруженные типы сохраняются.
+// Возвращает количество типов инструментов и типов, загрузка которых завершилась с ошибкой
 func LoadAllInstruments(
 	ctx context.Context,
 	client *investgo.Client,
 	dbpool *pgxpool.Pool,
+	cfg *config.Config,
 	logger *logrus.Logger,
-) error {
+) (RunStats, error) {
 	// Получаем или создаем источник данных T-Invest
 	dataSourceID, err := data.GetOrCreateTInvestDataSource(ctx, dbpool)
 	if err != nil {
-		return fmt.Errorf("ошибка получения источника данных T-Invest: %w", err)
+		return RunStats{}, fmt.Errorf("ошибка получения источника данных T-Invest: %w", err)
 	}
 
-	// Загружаем акции
-	logger.Debug("Загружаем акции...")
-	if err := data.LoadInstrumentsByType(ctx, client, dbpool, "share", dataSourceID, logger); err != nil {
-		return fmt.Errorf("ошибка загрузки share: %w", err)
+	// Статус инструментов для запроса списка
+	status, err := cfg.GetInstrumentStatus()
+	if err != nil {
+		return RunStats{}, err
 	}
+	logger.WithField("status", status.String()).Debug("Статус загружаемых инструментов")
 
-	// Загружаем облигации
-	logger.Debug("Загружаем облигации...")
-	if err := data.LoadInstrumentsByType(ctx, client, dbpool, "bond", dataSourceID, logger); err != nil {
-		return fmt.Errorf("ошибка загрузки bond: %w", err)
+	// Загружаем все типы, ошибка одного типа не прерывает загрузку остальных
+	batchSize := cfg.GetInstrumentBatchSize()
+	waitTurn := requestSpacer(cfg.GetRateLimitPause())
+	results := make([]error, len(instrumentTypes))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, cfg.GetInstrumentWorkers())
+	for i, instrumentType := range instrumentTypes {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, name config.InstrumentType, title string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			logger.Debugf("Загружаем %s...", title)
+			if err := waitTurn(ctx); err != nil {
+				results[i] = err
+				return
+			}
+			results[i] = data.LoadInstrumentsByType(ctx, client, dbpool, name, status, dataSourceID, batchSize, logger)
+		}(i, instrumentType.name, instrumentType.title)
 	}
+	wg.Wait()
 
-	// Загружаем ETF
-	logger.Debug("Загружаем ETF...")
-	if err := data.LoadInstrumentsByType(ctx, client, dbpool, "etf", dataSourceID, logger); err != nil {
-		return fmt.Errorf("ошибка загрузки etf: %w", err)
+	// Итог - в порядке типов, независимо от порядка завершения
+	var errs []error
+	var loaded, failed []config.InstrumentType
+	for i, instrumentType := range instrumentTypes {
+		if err := results[i]; err != nil {
+			logger.WithFields(logrus.Fields{
+				"type":  instrumentType.name,
+				"error": err,
+			}).Error("Ошибка загрузки инструментов")
+			errs = append(errs, fmt.Errorf("ошибка загрузки %s: %w", instrumentType.name, err))
+			failed = append(failed, instrumentType.name)
+			continue
+		}
+		loaded = append(loaded, instrumentType.name)
 	}
 
-	logger.Info("Все инструменты (share, bond, etf) загружены с расширенными данными")
+	logger.WithFields(logrus.Fields{
+		"loaded":      loaded,
+		"failed":      failed,
+		"loadedCount": len(loaded),
+		"failedCount": len(failed),
+	}).Info("Загрузка инструментов по типам завершена")
+
+	stats := RunStats{Total: len(instrumentTypes), Failed: len(failed)}
+	if len(errs) > 0 {
+		return stats, errors.Join(errs...)
+	}
 
-	return nil
+	logger.Info("Все инструменты (share, bond, etf, currency) загружены с расширенными данными")
+
+	return stats, nil
+}
+
+// requestSpacer возвращает функцию ожидания очереди запроса: параллельные запросы к API
+// выполняются не чаще одного за pause
+func requestSpacer(pause time.Duration) func(ctx context.Context) error {
+	var (
+		mu     sync.Mutex
+		nextAt time.Time
+	)
+	return func(ctx context.Context) error {
+		mu.Lock()
+		readyAt := nextAt
+		if now := time.Now(); readyAt.Before(now) {
+			readyAt = now
+		}
+		nextAt = readyAt.Add(pause)
+		mu.Unlock()
+
+		if wait := time.Until(readyAt); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		return nil
+	}
 }
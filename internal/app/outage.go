@@ -0,0 +1,140 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrProviderDown API не отвечает по нескольким инструментам подряд и после пауз (вероятно, технические работы)
+var ErrProviderDown = errors.New("API провайдера недоступен, вероятно идут технические работы")
+
+// RunInstruments обрабатывает инструменты по очереди с паузой rate_limit_pause между ними.
+// Останавливается при исчерпании бюджета запросов и по истечении времени загрузки
+// (контекст из WithRunDeadline, возвращается data.ErrRunDeadline). При серии подряд идущих ошибок API
+// (loading.outage.threshold) ставит запуск на паузу и повторяет инструменты серии;
+// если API не восстановился после loading.outage.max_pauses пауз - возвращает ErrProviderDown.
+// Недействительный токен (data.ErrInvalidToken) сразу прерывает запуск.
+// Возвращает ошибки по FIGI
+func RunInstruments(
+	ctx context.Context,
+	instruments []storage.Instrument,
+	cfg *config.Config,
+	logger *logrus.Logger,
+	process func(instrument storage.Instrument) error,
+) (map[string]error, error) {
+	failed := make(map[string]error)
+	threshold := cfg.Loading.Outage.Threshold
+	streak, pauses := 0, 0
+
+	for i := 0; i < len(instruments); i++ {
+		instrument := instruments[i]
+
+		if ctx.Err() != nil {
+			return failed, runDeadlineReached(ctx, i, len(instruments), logger)
+		}
+
+		err := process(instrument)
+		if err == nil {
+			delete(failed, instrument.Figi)
+			streak, pauses = 0, 0
+
+			// Пауза между запросами
+			time.Sleep(cfg.GetRateLimitPause())
+			continue
+		}
+
+		if IsBudgetExhausted(err, logger) {
+			break
+		}
+		if errors.Is(err, data.ErrRunDeadline) {
+			return failed, runDeadlineReached(ctx, i, len(instruments), logger)
+		}
+		if errors.Is(err, data.ErrInvalidToken) {
+			logger.WithFields(logrus.Fields{
+				"figi":  instrument.Figi,
+				"error": err,
+			}).Error("Токен T-Invest недействителен или просрочен, завершаем запуск")
+			return failed, err
+		}
+		logger.WithFields(logrus.Fields{
+			"figi":   instrument.Figi,
+			"ticker": instrument.Ticker,
+			"error":  err,
+		}).Error("Ошибка обработки инструмента")
+		failed[instrument.Figi] = err
+
+		// Серию составляют только ошибки доступности API
+		if !isRecoverableAPIError(err) {
+			streak = 0
+			continue
+		}
+		streak++
+		if threshold <= 0 || streak < threshold {
+			continue
+		}
+
+		if pauses >= cfg.GetOutageMaxPauses() {
+			logger.WithFields(logrus.Fields{
+				"streak": streak,
+				"pauses": pauses,
+			}).Error("API провайдера недоступен, завершаем запуск")
+			return failed, ErrProviderDown
+		}
+		pauses++
+
+		pause := cfg.GetOutagePause()
+		logger.WithFields(logrus.Fields{
+			"streak": streak,
+			"pause":  pause,
+			"step":   pauses,
+		}).Warn("Ошибки API по нескольким инструментам подряд, вероятно технические работы. Пауза")
+		select {
+		case <-ctx.Done():
+			return failed, runDeadlineReached(ctx, i+1-streak, len(instruments), logger)
+		case <-time.After(pause):
+		}
+
+		// Повторяем инструменты серии
+		i -= streak
+		streak = 0
+	}
+
+	return failed, nil
+}
+
+// WithRunDeadline ограничивает загрузку временем loading.max_run_duration (0 - без ограничения).
+// По истечении начатые чанки дозагружаются, новые чанки и инструменты не начинаются.
+// Значение проверяется в Initialize
+func WithRunDeadline(ctx context.Context, cfg *config.Config) (context.Context, context.CancelFunc) {
+	if duration, err := cfg.GetMaxRunDuration(); err == nil && duration > 0 {
+		return context.WithTimeout(ctx, duration)
+	}
+	return context.WithCancel(ctx)
+}
+
+// runDeadlineReached логирует остановку по истечении времени загрузки и возвращает причину остановки
+func runDeadlineReached(ctx context.Context, done, total int, logger *logrus.Logger) error {
+	err := data.RunStopError(ctx)
+	if errors.Is(err, data.ErrRunDeadline) {
+		logger.WithFields(logrus.Fields{
+			"done":  done,
+			"total": total,
+		}).Warnf("Время загрузки истекло (loading.max_run_duration): обработано %d из %d инструментов", done, total)
+	}
+	return err
+}
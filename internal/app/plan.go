@@ -0,0 +1,208 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// JobInstruments задание обновления справочника инструментов
+	JobInstruments = "instruments"
+	// JobCandlesPrefix префикс задания загрузки свечей (candles:1min)
+	JobCandlesPrefix = "candles:"
+	// JobDividends задание загрузки дивидендов
+	JobDividends = "dividends"
+)
+
+// Job задание плана запуска
+type Job struct {
+	Name         string // Исходное имя задания из конфигурации
+	Loader       string // Имя загрузчика в run_log
+	IntervalType string // Интервал свечей (только для candles)
+}
+
+// ParseJobs разбирает и проверяет список заданий плана запуска
+func ParseJobs(specs []string) ([]Job, error) {
+	jobs := make([]Job, 0, len(specs))
+	for _, spec := range specs {
+		name := strings.TrimSpace(spec)
+		switch {
+		case name == JobInstruments:
+			jobs = append(jobs, Job{Name: name, Loader: LoaderInstruments})
+		case name == JobDividends:
+			jobs = append(jobs, Job{Name: name, Loader: LoaderDividends})
+		case strings.HasPrefix(name, JobCandlesPrefix):
+			intervalType, err := config.ParseInterval(strings.TrimPrefix(name, JobCandlesPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("задание %q: %w", name, err)
+			}
+			jobs = append(jobs, Job{Name: name, Loader: LoaderCandles, IntervalType: intervalType})
+		default:
+			return nil, fmt.Errorf("неизвестное задание плана: %q (доступны: instruments, candles:<интервал>, dividends)", name)
+		}
+	}
+	return jobs, nil
+}
+
+// RunPlan выполняет задания плана последовательно в одном процессе,
+// используя общее подключение к БД и API из instance.
+// Ошибка одного задания не прерывает план, в конце возвращается сводная ошибка.
+// По истечении времени загрузки (WithRunDeadline) план останавливается с data.ErrRunDeadline,
+// при недействительном токене - с data.ErrInvalidToken.
+// Итог по всем заданиям возвращается для кода завершения.
+func RunPlan(ctx context.Context, instance *Result, jobs []string, cfg *config.Config, logger *logrus.Logger) (RunStats, error) {
+	var stats RunStats
+
+	parsed, err := ParseJobs(jobs)
+	if err != nil {
+		return stats, err
+	}
+	if len(parsed) == 0 {
+		return stats, fmt.Errorf("план запуска пуст")
+	}
+
+	// Журнал запусков ведётся и после истечения времени загрузки
+	dbCtx := context.WithoutCancel(ctx)
+
+	var failedJobs []string
+	for i, job := range parsed {
+		log := logger.WithFields(logrus.Fields{
+			"job":  job.Name,
+			"step": fmt.Sprintf("%d/%d", i+1, len(parsed)),
+		})
+
+		if ctx.Err() != nil {
+			log.Warnf("Время загрузки истекло (loading.max_run_duration): выполнено %d из %d заданий", i, len(parsed))
+			return stats, data.RunStopError(ctx)
+		}
+		log.Info("Запуск задания")
+
+		// Пропускаем задание, если оно завершалось недавно
+		skip, err := ShouldSkipRun(dbCtx, instance.DBPool, job.Loader, job.IntervalType, cfg, logger)
+		if err != nil {
+			log.Warnf("Ошибка проверки предыдущего запуска: %v", err)
+		}
+		if skip {
+			continue
+		}
+
+		// Синхронизация инструментов и загрузка свечей не идут одновременно с другими процессами (loading.sync_lock)
+		releaseLock := func() {}
+		if job.Loader == LoaderInstruments || job.Loader == LoaderCandles {
+			if releaseLock, skip = AcquireSyncLock(ctx, instance.DBPool, job.Loader == LoaderInstruments, cfg, logger); skip {
+				continue
+			}
+		}
+
+		started := time.Now()
+		runID := StartRun(dbCtx, instance.DBPool, job.Loader, job.IntervalType, logger)
+		total, failedFigis, jobErr := runJob(ctx, instance, job, cfg, logger)
+		releaseLock()
+		failed := len(failedFigis)
+		RecordFailedInstruments(dbCtx, instance.DBPool, runID, failedFigis, logger)
+		FinishRun(dbCtx, instance.DBPool, runID, total, failed, jobErr, logger)
+
+		// Задание остановлено по истечении времени загрузки или из-за токена - остальные не начинаем
+		if errors.Is(jobErr, data.ErrRunDeadline) || errors.Is(jobErr, data.ErrInvalidToken) {
+			stats.Add(total, failed, nil)
+			return stats, jobErr
+		}
+		stats.Add(total, failed, jobErr)
+
+		if jobErr != nil {
+			log.Errorf("Ошибка задания: %v", jobErr)
+			failedJobs = append(failedJobs, job.Name)
+			continue
+		}
+		log.WithFields(logrus.Fields{
+			"total":    total,
+			"failed":   failed,
+			"duration": time.Since(started).Round(time.Second),
+		}).Info("Задание завершено")
+	}
+
+	if len(failedJobs) > 0 {
+		return stats, fmt.Errorf("задания завершились с ошибкой: %s", strings.Join(failedJobs, ", "))
+	}
+	return stats, nil
+}
+
+// runJob выполняет одно задание, возвращает количество обработанных инструментов и FIGI инструментов с ошибками
+func runJob(ctx context.Context, instance *Result, job Job, cfg *config.Config, logger *logrus.Logger) (int, []string, error) {
+	switch job.Loader {
+	case LoaderInstruments:
+		if _, err := LoadAllInstruments(ctx, instance.Client, instance.DBPool, cfg, logger); err != nil {
+			return 0, nil, fmt.Errorf("ошибка загрузки инструментов из API: %w", err)
+		}
+		// Следующие задания работают с обновлённым списком инструментов
+		instruments, err := storage.LoadInstruments(ctx, instance.DBPool, logger)
+		if err != nil {
+			return 0, nil, fmt.Errorf("ошибка загрузки инструментов: %w", err)
+		}
+		instance.Instruments = instruments
+		return 0, nil, nil
+
+	case LoaderCandles:
+		failed, runErr := RunInstruments(ctx, instance.Instruments, cfg, logger, func(instrument storage.Instrument) error {
+			return ProcessInstrument(ctx, instance.Client, instance.DBPool, job.IntervalType, instrument, cfg, logger)
+		})
+		if err := storage.FlushCandles(instance.DBPool, logger); err != nil {
+			return len(instance.Instruments), FailedFigis(failed), fmt.Errorf("ошибка сброса буфера отложенной записи: %w", err)
+		}
+		return len(instance.Instruments), FailedFigis(failed), runErr
+
+	case LoaderDividends:
+		total := 0
+		// FIGI, по которым дивиденды не загружены и после повторов
+		var failedFigis []string
+		for _, instrument := range instance.Instruments {
+			// Обрабатываем только акции (instance.Instruments содержит только enabled=true)
+			if instrument.InstrumentType != config.InstrumentTypeShare {
+				continue
+			}
+			if ctx.Err() != nil {
+				return total, failedFigis, data.RunStopError(ctx)
+			}
+			total++
+			if err := ProcessInstrumentDividends(ctx, instance.Client, instance.DBPool, instrument, cfg, logger); err != nil {
+				logger.WithFields(logrus.Fields{
+					"figi":   instrument.Figi,
+					"ticker": instrument.Ticker,
+					"error":  err,
+				}).Error("Ошибка обработки дивидендов инструмента")
+				failedFigis = append(failedFigis, instrument.Figi)
+				continue
+			}
+
+			// Пауза между запросами
+			time.Sleep(cfg.GetRateLimitPause())
+		}
+		if len(failedFigis) > 0 {
+			logger.WithFields(logrus.Fields{
+				"failed": len(failedFigis),
+				"figis":  strings.Join(failedFigis, ","),
+			}).Warn("Итог: дивиденды не загружены")
+		}
+		return total, failedFigis, nil
+
+	default:
+		return 0, nil, fmt.Errorf("неизвестный загрузчик: %s", job.Loader)
+	}
+}
@@ -0,0 +1,55 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"market-loader/pkg/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StartPprof запускает HTTP-сервер pprof на addr (debug.pprof_addr)
+// пустой addr - профилирование выключено
+func StartPprof(addr string, logger *logrus.Logger) {
+	if addr == "" {
+		return
+	}
+
+	// Отдельный mux, чтобы обработчики pprof не попадали в http.DefaultServeMux
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if ip := net.ParseIP(host); host == "" || (ip != nil && !ip.IsLoopback()) {
+			logger.Warnf("pprof слушает не только localhost (%s), не используйте в продакшене", addr)
+		}
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: config.DefaultHTTPTimeout,
+	}
+
+	go func() {
+		logger.Infof("pprof доступен на http://%s/debug/pprof/", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("Ошибка сервера pprof: %v", err)
+		}
+	}()
+}
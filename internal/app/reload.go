@@ -0,0 +1,66 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"market-loader/pkg/config"
+	"market-loader/pkg/logs"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WatchConfigReload перечитывает конфигурацию по SIGHUP до завершения ctx.
+// Применяются только настройки, которые можно менять на лету (см. config.Reload)
+func WatchConfigReload(ctx context.Context, cfg *config.Config, path string, logger *logrus.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				reloadConfig(cfg, path, logger)
+			}
+		}
+	}()
+}
+
+// reloadConfig применяет изменения конфигурации и логирует результат
+func reloadConfig(cfg *config.Config, path string, logger *logrus.Logger) {
+	changed, ignored, warnings, err := cfg.Reload(path)
+	if err != nil {
+		logger.WithError(err).Error("Ошибка перечитывания конфигурации, продолжаем с текущей")
+		return
+	}
+
+	logger.SetLevel(logs.ParseLevel(cfg.GetLogLevel()))
+
+	for _, warning := range warnings {
+		logger.Warn(warning)
+	}
+
+	for _, change := range changed {
+		logger.WithField("change", change).Info("Настройка изменена")
+	}
+	if len(ignored) > 0 {
+		logger.WithField("sections", ignored).Warn("Изменения требуют перезапуска и не применены")
+	}
+	logger.WithFields(logrus.Fields{
+		"path":    path,
+		"changed": len(changed),
+	}).Info("Конфигурация перечитана (SIGHUP)")
+}
@@ -0,0 +1,322 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"market-loader/internal/arch"
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+	"github.com/sirupsen/logrus"
+)
+
+// RepairReport итог восстановления свечей инструмента за период
+type RepairReport struct {
+	Gaps         []data.DateWindow       // периоды торговых дней без свечей
+	Suspect      []storage.SuspectCandle // свечи с несогласованными OHLCV
+	Refetched    []data.DateWindow       // периоды, загруженные заново
+	Archived     []int                   // годы, загруженные заново из архивов
+	Deleted      int64                   // удалено подозрительных свечей, не полученных при загрузке
+	Before       int64                   // свечей в периоде до восстановления
+	After        int64                   // свечей в периоде после восстановления
+	GapsAfter    int                     // периодов без свечей после восстановления
+	SuspectAfter int                     // подозрительных свечей после восстановления
+}
+
+// RepairCandles находит пропуски торговых дней и подозрительные свечи инструмента за период [from, to)
+// и загружает эти периоды заново из API (минутные свечи прошлых лет при useArchive - из годовых архивов).
+// Полученные свечи перезаписывают имеющиеся; подозрительные свечи, которых загрузка не вернула,
+// удаляются только после успешной загрузки. При dryRun только находит проблемы
+func RepairCandles(
+	ctx context.Context,
+	client *investgo.Client,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	intervalType string,
+	from, to time.Time,
+	useArchive, dryRun bool,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) (*RepairReport, error) {
+	report := &RepairReport{}
+	if err := inspectCandles(ctx, dbpool, instrument.Figi, intervalType, from, to, cfg, report); err != nil {
+		return report, err
+	}
+
+	windows := append([]data.DateWindow(nil), report.Gaps...)
+	times := make([]time.Time, 0, len(report.Suspect))
+	for _, suspect := range report.Suspect {
+		times = append(times, suspect.Time)
+		windows = append(windows, data.DateWindow{From: suspect.Time, To: config.IntervalPeriodEnd(intervalType, suspect.Time)})
+	}
+	report.Refetched = mergeWindows(windows)
+
+	if dryRun || len(report.Refetched) == 0 {
+		report.After = report.Before
+		report.GapsAfter = len(report.Gaps)
+		report.SuspectAfter = len(report.Suspect)
+		return report, nil
+	}
+
+	// Загрузка идёт до конца, даже если одна из частей завершилась с ошибкой, - итог пересчитывается по БД
+	dbCtx := context.WithoutCancel(ctx)
+
+	// Версии подозрительных строк до загрузки: перезаписанные загрузкой строки получат новую версию
+	versions, err := storage.GetCandleVersions(dbCtx, dbpool, instrument.Figi, intervalType, times)
+	if err != nil {
+		return report, err
+	}
+
+	apiWindows := report.Refetched
+	var loadErr error
+	if useArchive && intervalType == config.CandleInterval1Min {
+		apiWindows, loadErr = repairFromArchives(ctx, dbpool, instrument, report.Refetched, cfg, report, logger)
+	}
+	if loadErr == nil && len(apiWindows) > 0 {
+		loadErr = data.LoadCandleWindows(ctx, client, dbpool, instrument, apiWindows, intervalType, cfg, logger)
+		if err := storage.FlushCandlesFor(dbpool, instrument.Figi, intervalType, logger); err != nil && loadErr == nil {
+			loadErr = err
+		}
+	}
+
+	// При ошибке загрузки подозрительные свечи остаются: лучше несогласованные данные, чем никаких
+	if loadErr == nil {
+		deleted, err := storage.DeleteUnchangedCandles(dbCtx, dbpool, instrument.Figi, intervalType, versions)
+		if err != nil {
+			loadErr = err
+		}
+		report.Deleted = deleted
+	}
+
+	after := &RepairReport{}
+	if err := inspectCandles(dbCtx, dbpool, instrument.Figi, intervalType, from, to, cfg, after); err != nil {
+		return report, errors.Join(loadErr, err)
+	}
+	report.After = after.Before
+	report.GapsAfter = len(after.Gaps)
+	report.SuspectAfter = len(after.Suspect)
+
+	return report, loadErr
+}
+
+// inspectCandles заполняет количество свечей, пропуски и подозрительные свечи за период
+func inspectCandles(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	figi, intervalType string,
+	from, to time.Time,
+	cfg *config.Config,
+	report *RepairReport,
+) error {
+	var err error
+	if report.Before, err = storage.CountCandles(ctx, dbpool, figi, intervalType, from, to); err != nil {
+		return err
+	}
+	if report.Gaps, err = FindCandleGaps(ctx, dbpool, figi, intervalType, from, to, cfg); err != nil {
+		return err
+	}
+	if report.Suspect, err = storage.FindSuspectCandles(ctx, dbpool, figi, intervalType, from, to); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindCandleGaps возвращает периоды без свечей, в которых по торговому календарю были торги:
+// торговые дни (для внутридневных и дневных интервалов), недели или месяцы.
+// Пропуски ищутся только между первой и последней свечой инструмента
+func FindCandleGaps(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	figi, intervalType string,
+	from, to time.Time,
+	cfg *config.Config,
+) ([]data.DateWindow, error) {
+	stats, err := storage.GetCandleStats(ctx, dbpool, figi, intervalType)
+	if err != nil {
+		return nil, err
+	}
+	if stats.Count == 0 {
+		return nil, nil
+	}
+
+	// До первой свечи инструмент мог ещё не торговаться, после последней - свечи ещё не загружены
+	if from.Before(stats.First) {
+		from = stats.First
+	}
+	if last := config.IntervalPeriodEnd(intervalType, stats.Last); to.After(last) {
+		to = last
+	}
+	if !from.Before(to) {
+		return nil, nil
+	}
+
+	first := gapPeriodStart(intervalType, from)
+	days, err := storage.GetCandleDays(ctx, dbpool, figi, intervalType, first, to)
+	if err != nil {
+		return nil, err
+	}
+	covered := make(map[time.Time]bool, len(days))
+	for day := range days {
+		date, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора дня %s: %w", day, err)
+		}
+		covered[gapPeriodStart(intervalType, date)] = true
+	}
+
+	var gaps []data.DateWindow
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !cfg.IsTradingDay(day) {
+			continue
+		}
+		start := gapPeriodStart(intervalType, day)
+		if covered[start] {
+			continue
+		}
+		end := start.AddDate(0, 0, 1)
+		if intervalType == config.CandleIntervalWeek || intervalType == config.CandleIntervalMonth {
+			end = config.IntervalPeriodEnd(intervalType, start)
+		}
+		gaps = append(gaps, data.DateWindow{From: start, To: end})
+	}
+
+	return mergeWindows(gaps), nil
+}
+
+// gapPeriodStart возвращает начало периода, в котором ищутся свечи: неделя (с понедельника),
+// месяц или день для остальных интервалов
+func gapPeriodStart(intervalType string, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch intervalType {
+	case config.CandleIntervalWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case config.CandleIntervalMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// mergeWindows сортирует периоды и объединяет пересекающиеся и соседние
+func mergeWindows(windows []data.DateWindow) []data.DateWindow {
+	if len(windows) == 0 {
+		return nil
+	}
+	sorted := append([]data.DateWindow(nil), windows...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].From.Before(sorted[j].From) })
+
+	merged := []data.DateWindow{sorted[0]}
+	for _, window := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if !window.From.After(last.To) {
+			if window.To.After(last.To) {
+				last.To = window.To
+			}
+			continue
+		}
+		merged = append(merged, window)
+	}
+	return merged
+}
+
+// repairFromArchives загружает заново годовые архивы минутных свечей за прошлые годы, в которые попадают периоды.
+// Возвращает периоды, которые не покрыты архивами и загружаются через API (текущий год, недоступные архивы)
+func repairFromArchives(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	windows []data.DateWindow,
+	cfg *config.Config,
+	report *RepairReport,
+	logger *logrus.Logger,
+) ([]data.DateWindow, error) {
+	currentYear := config.Now().UTC().Year()
+	var years []int
+	for _, window := range windows {
+		for year := window.From.Year(); year <= windowLastYear(window) && year < currentYear; year++ {
+			if len(years) == 0 || years[len(years)-1] < year {
+				years = append(years, year)
+			}
+		}
+	}
+	if len(years) == 0 {
+		return windows, nil
+	}
+
+	// Временная директория для архивов (удаляется по политике archive.cleanup)
+	tempDir, err := arch.OpenTempDir(cfg.Archive.TempDir)
+	if err != nil {
+		return windows, err
+	}
+	failed := false
+	defer func() {
+		tempDir.Close(failed, logger)
+	}()
+
+	token := cfg.GetTokens()[0]
+	loaded := make(map[int]bool, len(years))
+	for _, year := range years {
+		if ctx.Err() != nil {
+			return windows, data.RunStopError(ctx)
+		}
+		if !data.TakeRequest() {
+			return windows, data.ErrBudgetExhausted
+		}
+
+		// Архив разбирается заново, даже если не изменился с прошлой обработки
+		if err := storage.ClearArchiveHash(ctx, dbpool, instrument.Figi, year); err != nil {
+			return windows, err
+		}
+		if err := storage.CreateYearPartitions(dbpool, config.CandleInterval1Min, year); err != nil {
+			return windows, fmt.Errorf("ошибка создания партиций за %d год: %w", year, err)
+		}
+
+		candles, err := arch.DownloadYearArchive(context.WithoutCancel(ctx), token, instrument.Figi, year, tempDir.Path, cfg.GetArchiveMaxSize(), dbpool, logger)
+		if err != nil {
+			failed = true
+			logger.WithFields(logrus.Fields{
+				"figi":  instrument.Figi,
+				"year":  year,
+				"error": err,
+			}).Warn("Архив недоступен, период загружается через API")
+			continue
+		}
+		loaded[year] = true
+		report.Archived = append(report.Archived, year)
+		logger.Infof("Загружено %d свечей за %d год для %s", len(candles), year, instrument.Ticker)
+
+		// Пауза между запросами
+		time.Sleep(cfg.GetRateLimitPause())
+	}
+
+	var rest []data.DateWindow
+	for _, window := range windows {
+		for year := window.From.Year(); year <= windowLastYear(window); year++ {
+			if !loaded[year] {
+				rest = append(rest, window)
+				break
+			}
+		}
+	}
+	return rest, nil
+}
+
+// windowLastYear возвращает год последнего момента периода [From, To)
+func windowLastYear(window data.DateWindow) int {
+	return window.To.Add(-time.Nanosecond).Year()
+}
@@ -0,0 +1,208 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// Имена загрузчиков в run_log
+const (
+	// LoaderCandles загрузчик свечей
+	LoaderCandles = "candles"
+	// LoaderDividends загрузчик дивидендов
+	LoaderDividends = "dividends"
+	// LoaderInstruments загрузчик инструментов
+	LoaderInstruments = "instruments"
+	// LoaderACI загрузчик НКД облигаций
+	LoaderACI = "aci"
+)
+
+// ShouldSkipRun проверяет, завершался ли загрузчик за последние loading.min_run_interval
+func ShouldSkipRun(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	loader, intervalType string,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) (bool, error) {
+	minInterval, err := cfg.GetMinRunInterval()
+	if err != nil {
+		return false, err
+	}
+	if minInterval == 0 {
+		return false, nil
+	}
+
+	lastRun, err := storage.GetLastCompletedRun(ctx, dbpool, loader, intervalType)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки последнего запуска: %w", err)
+	}
+	if lastRun == nil || lastRun.FinishedAt == nil {
+		return false, nil
+	}
+
+	since := time.Since(*lastRun.FinishedAt)
+	if since >= minInterval {
+		return false, nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"loader":         loader,
+		"intervalType":   intervalType,
+		"lastRun":        lastRun.FinishedAt.Format(time.RFC3339),
+		"minRunInterval": minInterval,
+	}).Infof("Последний запуск %v назад, пропускаем", since.Round(time.Second))
+
+	return true, nil
+}
+
+// runBaseline сводки сохранения свечей и запросов к API на момент начала запуска
+type runBaseline struct {
+	saved   storage.SaveSummary
+	fetched storage.FetchSummary
+}
+
+// runBaselines сводки на момент начала запуска (по ID запуска),
+// чтобы в одном процессе (loader-plan) считать свечи каждого запуска отдельно
+var (
+	runBaselinesMu sync.Mutex
+	runBaselines   = make(map[int64]runBaseline)
+)
+
+// StartRun регистрирует запуск загрузчика в run_log
+// ошибка регистрации не прерывает загрузку, возвращается 0
+func StartRun(ctx context.Context, dbpool *pgxpool.Pool, loader, intervalType string, logger *logrus.Logger) int64 {
+	runID, err := storage.StartRun(ctx, dbpool, loader, intervalType)
+	if err != nil {
+		logger.Warnf("Не удалось зарегистрировать запуск: %v", err)
+		return 0
+	}
+
+	runBaselinesMu.Lock()
+	runBaselines[runID] = runBaseline{saved: storage.GetSaveSummary(), fetched: data.GetFetchSummary()}
+	runBaselinesMu.Unlock()
+
+	return runID
+}
+
+// FinishRun фиксирует результат запуска загрузчика в run_log; запуск, прерванный ошибкой runErr, - failed
+func FinishRun(ctx context.Context, dbpool *pgxpool.Pool, runID int64, total, failed int, runErr error, logger *logrus.Logger) {
+	status := storage.RunStatus(total, failed)
+	if runErr != nil {
+		status = storage.RunStatusFailed
+	}
+	finishRun(ctx, dbpool, runID, status, total, failed, runErr, logger)
+}
+
+// FinishRunWithFailures фиксирует результат запуска, ошибка которого объединяет ошибки отдельных
+// единиц (типов инструментов), учтённых в failed: статус определяется по количеству (partial, если
+// часть единиц загружена), текст ошибки сохраняется. Без единиц (total = 0) ошибка означает прерванный запуск
+func FinishRunWithFailures(ctx context.Context, dbpool *pgxpool.Pool, runID int64, total, failed int, runErr error, logger *logrus.Logger) {
+	status := storage.RunStatus(total, failed)
+	if runErr != nil && total == 0 {
+		status = storage.RunStatusFailed
+	}
+	finishRun(ctx, dbpool, runID, status, total, failed, runErr, logger)
+}
+
+// finishRun записывает статус и итоги запуска в run_log
+func finishRun(ctx context.Context, dbpool *pgxpool.Pool, runID int64, status string, total, failed int, runErr error, logger *logrus.Logger) {
+	if runID == 0 {
+		return
+	}
+
+	// Свечи, сохранённые и полученные из API за этот запуск
+	runBaselinesMu.Lock()
+	baseline := runBaselines[runID]
+	delete(runBaselines, runID)
+	runBaselinesMu.Unlock()
+	saved := storage.GetSaveSummary().Sub(baseline.saved)
+	fetched := data.GetFetchSummary().Sub(baseline.fetched)
+
+	if err := storage.FinishRun(ctx, dbpool, runID, status, total, failed, saved, fetched, runErr); err != nil {
+		logger.Warnf("Не удалось зафиксировать завершение запуска: %v", err)
+	}
+}
+
+// FailedFigis возвращает FIGI инструментов с ошибками (результат RunInstruments) по порядку
+func FailedFigis(failed map[string]error) []string {
+	figis := make([]string, 0, len(failed))
+	for figi := range failed {
+		figis = append(figis, figi)
+	}
+	sort.Strings(figis)
+	return figis
+}
+
+// RecordFailedInstruments сохраняет FIGI инструментов с ошибками запуска для повтора (loader-cli --retry-failed)
+// ошибка сохранения не прерывает загрузку
+func RecordFailedInstruments(ctx context.Context, dbpool *pgxpool.Pool, runID int64, figis []string, logger *logrus.Logger) {
+	if runID == 0 {
+		return
+	}
+	if err := storage.SetRunFailedFigis(ctx, dbpool, runID, figis); err != nil {
+		logger.Warnf("Не удалось сохранить инструменты с ошибками запуска: %v", err)
+	}
+}
+
+// FailedRun инструменты с ошибками последнего запуска загрузчика по интервалу
+type FailedRun struct {
+	RunID        int64
+	IntervalType string
+	Figis        []string
+}
+
+// LastFailedRuns возвращает инструменты с ошибками последнего завершённого запуска загрузчика по каждому интервалу
+func LastFailedRuns(ctx context.Context, dbpool *pgxpool.Pool, loader string, intervalTypes []string) ([]FailedRun, error) {
+	runs := make([]FailedRun, 0, len(intervalTypes))
+	for _, intervalType := range intervalTypes {
+		runID, figis, err := storage.GetLastRunFailedFigis(ctx, dbpool, loader, intervalType)
+		if err != nil {
+			return nil, err
+		}
+		if runID == 0 {
+			continue
+		}
+		runs = append(runs, FailedRun{RunID: runID, IntervalType: intervalType, Figis: figis})
+	}
+	return runs, nil
+}
+
+// ClearRetriedFailures оставляет в запусках только инструменты, снова завершившиеся с ошибкой при повторе:
+// успешно повторённые удаляются из списка, следующий повтор их не загружает
+func ClearRetriedFailures(ctx context.Context, dbpool *pgxpool.Pool, runs []FailedRun, failed map[string]error, logger *logrus.Logger) {
+	for _, run := range runs {
+		remaining := make([]string, 0, len(run.Figis))
+		for _, figi := range run.Figis {
+			if _, exists := failed[figi]; exists {
+				remaining = append(remaining, figi)
+			}
+		}
+		RecordFailedInstruments(ctx, dbpool, run.RunID, remaining, logger)
+
+		logger.WithFields(logrus.Fields{
+			"runID":        run.RunID,
+			"intervalType": run.IntervalType,
+			"retried":      len(run.Figis),
+			"remaining":    len(remaining),
+		}).Info("Повтор инструментов с ошибками завершён")
+	}
+}
@@ -0,0 +1,178 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"market-loader/internal/data"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// errStreamClosed стрим закрыт сервером без ошибки
+var errStreamClosed = errors.New("стрим свечей закрыт")
+
+// RunCandleStream подписывается на минутные свечи инструментов и сохраняет их по мере поступления.
+// При обрыве стрим переподключается с нарастающей задержкой и повторной подпиской.
+// По завершении ctx сохраняет время последней полученной свечи по каждому инструменту
+func RunCandleStream(ctx context.Context, instance *Result, logger *logrus.Logger) error {
+	if len(instance.Instruments) == 0 {
+		return fmt.Errorf("нет включённых инструментов для подписки")
+	}
+
+	figis := make([]string, 0, len(instance.Instruments))
+	for _, instrument := range instance.Instruments {
+		figis = append(figis, instrument.Figi)
+	}
+
+	// Время последней полученной свечи по инструментам
+	lastReceived := make(map[string]time.Time)
+	defer persistStreamProgress(instance, lastReceived, logger)
+
+	delay := config.StreamReconnectDelay
+	for {
+		received, err := listenCandleStream(ctx, instance, figis, lastReceived, logger)
+		if ctx.Err() != nil {
+			logger.Info("Стрим свечей остановлен")
+			return nil
+		}
+
+		// После успешного приёма данных начинаем задержку заново
+		if received > 0 {
+			delay = config.StreamReconnectDelay
+		}
+
+		logger.WithFields(logrus.Fields{
+			"error":    err,
+			"received": received,
+			"delay":    delay,
+		}).Warn("Обрыв стрима свечей, переподключаемся")
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Стрим свечей остановлен")
+			return nil
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > config.StreamMaxReconnectDelay {
+			delay = config.StreamMaxReconnectDelay
+		}
+	}
+}
+
+// listenCandleStream открывает стрим, подписывается на свечи и сохраняет их до обрыва или отмены ctx
+// возвращает количество полученных свечей
+func listenCandleStream(
+	ctx context.Context,
+	instance *Result,
+	figis []string,
+	lastReceived map[string]time.Time,
+	logger *logrus.Logger,
+) (int, error) {
+	stream, err := instance.Client.NewMarketDataStreamClient().MarketDataStream()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка открытия стрима: %w", err)
+	}
+
+	// Только закрытые свечи: незавершённая минута не перезаписывается многократно
+	candles, err := stream.SubscribeCandle(figis, pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE, true, nil)
+	if err != nil {
+		stream.Stop()
+		return 0, fmt.Errorf("ошибка подписки на свечи: %w", err)
+	}
+
+	logger.WithField("instruments", len(figis)).Info("Подписка на минутные свечи оформлена")
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- stream.Listen()
+	}()
+
+	received := 0
+	for {
+		select {
+		case <-ctx.Done():
+			stream.Stop()
+			<-listenErr
+			return received, nil
+
+		case err := <-listenErr:
+			if err == nil {
+				err = errStreamClosed
+			}
+			return received, err
+
+		case candle, ok := <-candles:
+			if !ok {
+				if err := <-listenErr; err != nil {
+					return received, err
+				}
+				return received, errStreamClosed
+			}
+			received++
+			saveStreamCandle(instance, candle, lastReceived, logger)
+		}
+	}
+}
+
+// saveStreamCandle сохраняет свечу из стрима через общий механизм сохранения
+func saveStreamCandle(instance *Result, candle *pb.Candle, lastReceived map[string]time.Time, logger *logrus.Logger) {
+	historic := &pb.HistoricCandle{
+		Open:       candle.GetOpen(),
+		High:       candle.GetHigh(),
+		Low:        candle.GetLow(),
+		Close:      candle.GetClose(),
+		Volume:     candle.GetVolume(),
+		Time:       candle.GetTime(),
+		IsComplete: true,
+	}
+
+	figi := candle.GetFigi()
+	candles := data.TransformCandles(figi, []*pb.HistoricCandle{historic})
+	if len(candles) == 0 {
+		return
+	}
+	if err := storage.SaveCandles(instance.DBPool, figi, candles, config.CandleInterval1Min, logger); err != nil {
+		logger.WithFields(logrus.Fields{
+			"figi":  figi,
+			"error": err,
+		}).Error("Ошибка сохранения свечи из стрима")
+		return
+	}
+
+	candleTime := candle.GetTime().AsTime()
+	if candleTime.After(lastReceived[figi]) {
+		lastReceived[figi] = candleTime
+	}
+}
+
+// persistStreamProgress обновляет время последней загрузки по инструментам, получившим свечи
+func persistStreamProgress(instance *Result, lastReceived map[string]time.Time, logger *logrus.Logger) {
+	// Контекст стрима уже отменён, сохраняем прогресс в отдельном контексте
+	ctx := context.Background()
+	for figi, lastTime := range lastReceived {
+		if err := data.ProcessLoadResult(ctx, instance.DBPool, figi, config.CandleInterval1Min, nil, logger); err != nil {
+			logger.WithFields(logrus.Fields{
+				"figi":     figi,
+				"lastTime": lastTime,
+				"error":    err,
+			}).Warn("Не удалось сохранить прогресс стрима")
+		}
+	}
+	logger.WithField("instruments", len(lastReceived)).Info("Прогресс стрима сохранён")
+}
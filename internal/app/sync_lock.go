@@ -0,0 +1,60 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"errors"
+
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// AcquireSyncLock согласует синхронизацию инструментов (instruments = true) с загрузкой свечей (loading.sync_lock):
+// синхронизация ждёт завершения загрузок свечей, загрузка свечей - завершения синхронизации.
+// Возвращает функцию освобождения блокировки; skip = true - блокировка не освободилась
+// за loading.sync_lock.wait и запуск нужно пропустить. Ошибка блокировки не прерывает запуск
+func AcquireSyncLock(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	instruments bool,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) (release func(), skip bool) {
+	release = func() {}
+	if !cfg.Loading.SyncLock.Enabled {
+		return release, false
+	}
+
+	// Значение проверяется в Initialize
+	wait, _ := cfg.GetSyncLockWait()
+	lock, err := storage.AcquireSyncLock(ctx, dbpool, !instruments, wait)
+	if errors.Is(err, storage.ErrSyncLockTimeout) {
+		log := logger.WithField("wait", wait)
+		if instruments {
+			log.Warn("Идёт загрузка свечей, синхронизация инструментов пропущена")
+		} else {
+			log.Warn("Идёт синхронизация инструментов, загрузка свечей пропущена")
+		}
+		return release, true
+	}
+	if err != nil {
+		logger.Warnf("Не удалось взять блокировку синхронизации, продолжаем без неё: %v", err)
+		return release, false
+	}
+
+	return func() {
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+			logger.Warnf("Не удалось освободить блокировку синхронизации: %v", err)
+		}
+	}, false
+}
@@ -0,0 +1,215 @@
+// Package app - основные функции загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package app
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// VerifyResult результат сверки количества свечей с торговым календарём
+type VerifyResult struct {
+	Figi         string
+	Ticker       string
+	IntervalType string
+	Actual       int64
+	Expected     int
+	Deviation    float64 // относительное отклонение (доля)
+}
+
+// PriceScaleResult инструмент с большой долей цен, не кратных шагу цены
+type PriceScaleResult struct {
+	Figi         string
+	Ticker       string
+	IntervalType string
+	Increment    float64
+	Checked      int64
+	OffTick      int64
+}
+
+var (
+	verifyMu      sync.Mutex
+	verifyFlagged []VerifyResult
+	scaleFlagged  []PriceScaleResult
+)
+
+// VerifyCandleCount сверяет количество загруженных свечей с ожидаемым числом периодов
+// по торговому календарю (только дневные, недельные и месячные интервалы).
+// При отклонении больше loading.verify.tolerance пишет предупреждение и запоминает инструмент для итога
+func VerifyCandleCount(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	intervalType string,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) {
+	if !cfg.Loading.Verify.Enabled {
+		return
+	}
+
+	stats, err := storage.GetCandleStats(ctx, dbpool, instrument.Figi, intervalType)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"figi":  instrument.Figi,
+			"error": err,
+		}).Warn("Не удалось проверить количество свечей")
+		return
+	}
+	if stats.Count == 0 {
+		return
+	}
+
+	expected, ok := cfg.ExpectedPeriods(intervalType, stats.First, stats.Last)
+	if !ok || expected == 0 {
+		return
+	}
+
+	deviation := math.Abs(float64(stats.Count)-float64(expected)) / float64(expected)
+	if deviation <= cfg.GetVerifyTolerance() {
+		return
+	}
+
+	result := VerifyResult{
+		Figi:         instrument.Figi,
+		Ticker:       instrument.Ticker,
+		IntervalType: intervalType,
+		Actual:       stats.Count,
+		Expected:     expected,
+		Deviation:    deviation,
+	}
+
+	verifyMu.Lock()
+	verifyFlagged = append(verifyFlagged, result)
+	verifyMu.Unlock()
+
+	logger.WithFields(logrus.Fields{
+		"figi":         instrument.Figi,
+		"ticker":       instrument.Ticker,
+		"intervalType": intervalType,
+		"actual":       stats.Count,
+		"expected":     expected,
+		"from":         stats.First.Format("2006-01-02"),
+		"to":           stats.Last.Format("2006-01-02"),
+		"deviation":    math.Round(deviation*100) / 100,
+	}).Warn("Количество свечей отличается от ожидаемого по торговому календарю")
+}
+
+// VerifyPriceScale проверяет кратность цен последних свечей инструмента шагу цены (min_price_increment).
+// Если доля некратных цен больше loading.verify.price_scale_threshold, пишет предупреждение
+// о возможной ошибке масштаба цен и запоминает инструмент для итога
+func VerifyPriceScale(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	intervalType string,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) {
+	if !cfg.Loading.Verify.PriceScale {
+		return
+	}
+
+	stats, err := storage.CheckPriceScale(ctx, dbpool, instrument.Figi, intervalType, storage.PriceScaleSample)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"figi":  instrument.Figi,
+			"error": err,
+		}).Warn("Не удалось проверить шаг цены свечей")
+		return
+	}
+	if stats.Checked == 0 || stats.Ratio() <= cfg.GetPriceScaleThreshold() {
+		return
+	}
+
+	result := PriceScaleResult{
+		Figi:         instrument.Figi,
+		Ticker:       instrument.Ticker,
+		IntervalType: intervalType,
+		Increment:    stats.Increment,
+		Checked:      stats.Checked,
+		OffTick:      stats.OffTick,
+	}
+
+	verifyMu.Lock()
+	scaleFlagged = append(scaleFlagged, result)
+	verifyMu.Unlock()
+
+	logger.WithFields(logrus.Fields{
+		"figi":              instrument.Figi,
+		"ticker":            instrument.Ticker,
+		"intervalType":      intervalType,
+		"minPriceIncrement": stats.Increment,
+		"checked":           stats.Checked,
+		"offTick":           stats.OffTick,
+		"ratio":             math.Round(stats.Ratio()*100) / 100,
+	}).Warn("Цены свечей не кратны шагу цены: возможна ошибка масштаба")
+}
+
+// GetPriceScaleFlagged возвращает инструменты, не прошедшие проверку шага цены за запуск
+func GetPriceScaleFlagged() []PriceScaleResult {
+	verifyMu.Lock()
+	defer verifyMu.Unlock()
+	return append([]PriceScaleResult(nil), scaleFlagged...)
+}
+
+// GetVerifyFlagged возвращает инструменты, не прошедшие проверку количества свечей за запуск
+func GetVerifyFlagged() []VerifyResult {
+	verifyMu.Lock()
+	defer verifyMu.Unlock()
+	return append([]VerifyResult(nil), verifyFlagged...)
+}
+
+// LogVerifySummary выводит инструменты, не прошедшие проверку количества свечей и шага цены
+func LogVerifySummary(logger *logrus.Logger) {
+	logPriceScaleSummary(logger)
+
+	flagged := GetVerifyFlagged()
+	if len(flagged) == 0 {
+		return
+	}
+
+	for _, result := range flagged {
+		logger.WithFields(logrus.Fields{
+			"figi":         result.Figi,
+			"ticker":       result.Ticker,
+			"intervalType": result.IntervalType,
+			"actual":       result.Actual,
+			"expected":     result.Expected,
+		}).Warn("Подозрение на неполную загрузку")
+	}
+	logger.WithField("count", len(flagged)).Warn("Инструментов с отклонением количества свечей")
+}
+
+// logPriceScaleSummary выводит инструменты с подозрением на ошибку масштаба цен
+func logPriceScaleSummary(logger *logrus.Logger) {
+	flagged := GetPriceScaleFlagged()
+	if len(flagged) == 0 {
+		return
+	}
+
+	for _, result := range flagged {
+		logger.WithFields(logrus.Fields{
+			"figi":              result.Figi,
+			"ticker":            result.Ticker,
+			"intervalType":      result.IntervalType,
+			"minPriceIncrement": result.Increment,
+			"offTick":           result.OffTick,
+			"checked":           result.Checked,
+		}).Warn("Подозрение на ошибку масштаба цен")
+	}
+	logger.WithField("count", len(flagged)).Warn("Инструментов с ценами, не кратными шагу цены")
+}
@@ -10,12 +10,17 @@ package arch
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"market-loader/internal/storage"
 	"market-loader/pkg/config"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -24,8 +29,37 @@ import (
 	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
 )
 
+// ErrArchiveTooLarge размер архива превышает archive.max_size_mb
+var ErrArchiveTooLarge = errors.New("размер архива превышает допустимый")
+
+// ErrArchiveUnchanged архив совпадает с уже обработанным, разбор пропущен (archive.skip_unchanged)
+var ErrArchiveUnchanged = errors.New("архив не изменился с прошлой обработки")
+
+// ErrArchiveIncomplete - свечи части CSV файлов архива не сохранены, хеш архива не записывается,
+// чтобы при следующем запуске архив был разобран заново
+var ErrArchiveIncomplete = errors.New("свечи сохранены не из всех файлов архива")
+
+// skipUnchanged пропускать разбор архива, если его хеш совпадает с последним обработанным
+var skipUnchanged atomic.Bool
+
+// SetSkipUnchanged включает пропуск разбора архивов, не изменившихся с прошлой обработки
+func SetSkipUnchanged(enabled bool) {
+	skipUnchanged.Store(enabled)
+}
+
 // DownloadYearArchive загружает архив за указанный год
-func DownloadYearArchive(ctx context.Context, token, figi string, year int, tempDir string, dbpool *pgxpool.Pool, logger *logrus.Logger) ([]*pb.HistoricCandle, error) {
+// maxSize ограничивает размер архива в байтах (0 - без ограничения).
+// Частично скачанный архив удаляется всегда, обработанный - по политике archive.cleanup.
+// При archive.skip_unchanged архив с тем же хешом, что и при прошлой обработке, не разбирается (ErrArchiveUnchanged)
+func DownloadYearArchive(
+	ctx context.Context,
+	token, figi string,
+	year int,
+	tempDir string,
+	maxSize int64,
+	dbpool *pgxpool.Pool,
+	logger *logrus.Logger,
+) ([]*pb.HistoricCandle, error) {
 	// Формируем URL для запроса архива
 	url := fmt.Sprintf("https://invest-public-api.tbank.ru/history-data?figi=%s&year=%d", figi, year)
 
@@ -75,23 +109,104 @@ func DownloadYearArchive(ctx context.Context, token, figi string, year int, temp
 		}
 	}()
 
+	// Заявленный размер уже превышает лимит - не скачиваем
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		logger.WithFields(logrus.Fields{
+			"figi":    figi,
+			"year":    year,
+			"size":    resp.ContentLength,
+			"maxSize": maxSize,
+		}).Warn("Размер архива превышает лимит, загрузка прервана")
+		return nil, fmt.Errorf("%w: %d байт (лимит %d)", ErrArchiveTooLarge, resp.ContentLength, maxSize)
+	}
+
 	// Сохраняем архив во временный файл
 	archivePath := filepath.Join(tempDir, fmt.Sprintf("%s_%d.zip", figi, year))
 
+	hash, err := saveArchive(archivePath, resp.Body, maxSize)
+	if err != nil {
+		if errors.Is(err, ErrArchiveTooLarge) {
+			logger.WithFields(logrus.Fields{
+				"figi":    figi,
+				"year":    year,
+				"maxSize": maxSize,
+			}).Warn("Размер архива превышает лимит, загрузка прервана")
+		}
+		return nil, err
+	}
+
+	// Архив удаляется после обработки по политике archive.cleanup
+	failed := true
+	defer func() {
+		cleanupArchive(archivePath, failed, logger)
+	}()
+
+	// Архив не изменился с прошлой обработки - разбор не нужен
+	if skipUnchanged.Load() {
+		lastHash, err := storage.GetArchiveHash(ctx, dbpool, figi, year)
+		if err != nil {
+			logger.Warnf("Не удалось проверить хеш архива за %d год для %s: %v", year, figi, err)
+		} else if lastHash == hash {
+			logger.WithFields(logrus.Fields{
+				"figi":   figi,
+				"year":   year,
+				"sha256": hash,
+			}).Info("Архив не изменился с прошлой обработки, разбор пропущен")
+			failed = false
+			return nil, ErrArchiveUnchanged
+		}
+	}
+
+	// Обрабатываем ZIP архив; хеш сохраняется, только если сохранены свечи всех файлов
+	candles, err := processArchive(archivePath, figi, dbpool, logger)
+	if err != nil {
+		return candles, err
+	}
+	failed = false
+
+	if skipUnchanged.Load() {
+		if err := storage.SaveArchiveHash(ctx, dbpool, figi, year, hash, len(candles)); err != nil {
+			logger.Warnf("Не удалось сохранить хеш архива за %d год для %s: %v", year, figi, err)
+		}
+	}
+	return candles, nil
+}
+
+// saveArchive сохраняет тело ответа в файл с ограничением размера и возвращает SHA-256 архива (hex),
+// хеш считается при записи. При ошибке частично записанный файл удаляется
+func saveArchive(archivePath string, body io.Reader, maxSize int64) (hash string, err error) {
 	archiveFile, err := os.Create(archivePath)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка создания файла архива: %w", err)
+		return "", fmt.Errorf("ошибка создания файла архива: %w", err)
 	}
+
 	defer func() {
-		if err := archiveFile.Close(); err != nil {
-			logger.Errorf("Ошибка закрытия файла архива: %v", err)
+		if closeErr := archiveFile.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("ошибка закрытия файла архива: %w", closeErr)
+		}
+		if err != nil {
+			_ = os.Remove(archivePath)
 		}
 	}()
 
-	if _, err := io.Copy(archiveFile, resp.Body); err != nil {
-		return nil, fmt.Errorf("ошибка сохранения архива: %w", err)
+	hasher := sha256.New()
+	writer := io.MultiWriter(archiveFile, hasher)
+
+	if maxSize <= 0 {
+		if _, err := io.Copy(writer, body); err != nil {
+			return "", fmt.Errorf("ошибка сохранения архива: %w", err)
+		}
+		return hex.EncodeToString(hasher.Sum(nil)), nil
+	}
+
+	// Читаем на байт больше лимита, чтобы отличить превышение от точного совпадения
+	written, err := io.Copy(writer, io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("ошибка сохранения архива: %w", err)
+	}
+	if written > maxSize {
+		return "", fmt.Errorf("%w: более %d байт", ErrArchiveTooLarge, maxSize)
 	}
 
-	// Обрабатываем ZIP архив
-	return processArchive(archivePath, figi, dbpool, logger)
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
@@ -9,17 +9,43 @@
 package arch
 
 import (
+	"errors"
+	"fmt"
 	"market-loader/pkg/config"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
 )
 
+// decimalSeparator десятичный разделитель чисел в CSV (archive.decimal_separator)
+var decimalSeparator atomic.Value
+
+// SetDecimalSeparator задаёт десятичный разделитель чисел в CSV: "." или ","
+func SetDecimalSeparator(separator string) {
+	decimalSeparator.Store(separator)
+}
+
+// normalizeNumber приводит число из CSV к записи с точкой.
+// При разделителе "," точки и пробелы считаются разделителями разрядов
+func normalizeNumber(value string) string {
+	value = strings.TrimSpace(value)
+
+	separator, _ := decimalSeparator.Load().(string)
+	if separator != config.DecimalSeparatorComma {
+		return value
+	}
+
+	value = strings.NewReplacer(".", "", " ", "", "\u00a0", "").Replace(value)
+	return strings.Replace(value, ",", ".", 1)
+}
+
 // parsePriceString точно парсит строку цены в pb.Quotation
 func parsePriceString(priceStr string) *pb.Quotation {
-	// Убираем пробелы
-	priceStr = strings.TrimSpace(priceStr)
+	// Убираем пробелы и приводим разделитель к точке
+	priceStr = normalizeNumber(priceStr)
 
 	// Ищем точку
 	dotIndex := strings.Index(priceStr, ".")
@@ -70,3 +96,47 @@ func parsePriceString(priceStr string) *pb.Quotation {
 		Nano:  int32(nano),
 	}
 }
+
+// ErrDecimalVolume объём свечи в архиве задан дробным числом, а объём свечи в API целый (int64)
+var ErrDecimalVolume = errors.New("дробный объём не поддерживается")
+
+// parseVolumeString парсит объём свечи из архива.
+// Целые значения в десятичной записи ("100.000") допускаются,
+// для дробных возвращается ErrDecimalVolume
+func parseVolumeString(volumeStr string) (int64, error) {
+	volumeStr = normalizeNumber(volumeStr)
+
+	if volume, err := strconv.ParseInt(volumeStr, 10, 64); err == nil {
+		return volume, nil
+	}
+
+	dotIndex := strings.Index(volumeStr, ".")
+	if dotIndex == -1 {
+		return 0, fmt.Errorf("некорректный объём '%s'", volumeStr)
+	}
+
+	units, err := strconv.ParseInt(volumeStr[:dotIndex], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("некорректный объём '%s': %w", volumeStr, err)
+	}
+
+	// Дробная часть из одних нулей - объём целый
+	if strings.Trim(volumeStr[dotIndex+1:], "0") == "" {
+		return units, nil
+	}
+
+	return 0, fmt.Errorf("%w: '%s'", ErrDecimalVolume, volumeStr)
+}
+
+// parseTimestamp парсит время свечи из архива (ISO 8601 / RFC 3339)
+// допускаются дробные секунды и смещение часового пояса, результат в UTC
+func parseTimestamp(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	timestamp, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("некорректное время '%s': %w", value, err)
+	}
+
+	return timestamp.UTC(), nil
+}
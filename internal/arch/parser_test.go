@@ -0,0 +1,144 @@
+// Package arch содержит функции для работы с архивом свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package arch
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"market-loader/pkg/config"
+)
+
+func TestParseVolumeString(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int64
+		wantErr error
+		invalid bool
+	}{
+		{name: "integer", value: "1500", want: 1500},
+		{name: "spaces", value: " 42 ", want: 42},
+		{name: "integral decimal", value: "100.000", want: 100},
+		{name: "integral decimal without zeros", value: "7.", want: 7},
+		{name: "fractional", value: "12.5", wantErr: ErrDecimalVolume},
+		{name: "small fraction", value: "0.001", wantErr: ErrDecimalVolume},
+		{name: "not a number", value: "abc", invalid: true},
+		{name: "empty", value: "", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVolumeString(tt.value)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("parseVolumeString(%q) error = %v, want %v", tt.value, err, tt.wantErr)
+				}
+			case tt.invalid:
+				if err == nil || errors.Is(err, ErrDecimalVolume) {
+					t.Errorf("parseVolumeString(%q) error = %v, want invalid volume error", tt.value, err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("parseVolumeString(%q) unexpected error: %v", tt.value, err)
+				}
+				if got != tt.want {
+					t.Errorf("parseVolumeString(%q) = %d, want %d", tt.value, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "utc", value: "2024-12-19T04:00:00Z", want: time.Date(2024, time.December, 19, 4, 0, 0, 0, time.UTC)},
+		{name: "spaces", value: " 2024-12-19T04:00:00Z ", want: time.Date(2024, time.December, 19, 4, 0, 0, 0, time.UTC)},
+		{name: "fractional seconds", value: "2024-12-19T07:00:00.123Z", want: time.Date(2024, time.December, 19, 7, 0, 0, 123000000, time.UTC)},
+		{name: "moscow offset", value: "2024-12-19T07:00:00+03:00", want: time.Date(2024, time.December, 19, 4, 0, 0, 0, time.UTC)},
+		{name: "offset and fraction", value: "2024-12-19T07:00:00.5+03:00", want: time.Date(2024, time.December, 19, 4, 0, 0, 500000000, time.UTC)},
+		{name: "offset crosses day", value: "2025-01-01T01:30:00+03:00", want: time.Date(2024, time.December, 31, 22, 30, 0, 0, time.UTC)},
+		{name: "negative offset", value: "2024-12-19T23:00:00-05:00", want: time.Date(2024, time.December, 20, 4, 0, 0, 0, time.UTC)},
+		{name: "leap day", value: "2024-02-29T10:00:00Z", want: time.Date(2024, time.February, 29, 10, 0, 0, 0, time.UTC)},
+		{name: "without zone", value: "2024-12-19T04:00:00", wantErr: true},
+		{name: "date only", value: "2024-12-19", wantErr: true},
+		{name: "space separator", value: "2024-12-19 04:00:00Z", wantErr: true},
+		{name: "invalid date", value: "2023-02-29T10:00:00Z", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimestamp(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseTimestamp(%q) = %v, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTimestamp(%q) unexpected error: %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseTimestamp(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("parseTimestamp(%q) location = %v, want UTC", tt.value, got.Location())
+			}
+		})
+	}
+}
+
+func TestParsePriceString(t *testing.T) {
+	tests := []struct {
+		name      string
+		separator string
+		value     string
+		wantUnits int64
+		wantNano  int32
+	}{
+		{name: "dot integer", separator: config.DecimalSeparatorDot, value: "123", wantUnits: 123},
+		{name: "dot fraction", separator: config.DecimalSeparatorDot, value: "270.5", wantUnits: 270, wantNano: 500000000},
+		{name: "dot spaces", separator: config.DecimalSeparatorDot, value: " 1.25 ", wantUnits: 1, wantNano: 250000000},
+		{name: "dot smallest nano", separator: config.DecimalSeparatorDot, value: "0.000000001", wantNano: 1},
+		{name: "dot truncated to nano", separator: config.DecimalSeparatorDot, value: "1.1234567891", wantUnits: 1, wantNano: 123456789},
+		{name: "dot trailing separator", separator: config.DecimalSeparatorDot, value: "42.", wantUnits: 42},
+		{name: "dot rejects comma", separator: config.DecimalSeparatorDot, value: "123,45"},
+		{name: "dot invalid", separator: config.DecimalSeparatorDot, value: "abc"},
+		{name: "comma fraction", separator: config.DecimalSeparatorComma, value: "123,45", wantUnits: 123, wantNano: 450000000},
+		{name: "comma integer", separator: config.DecimalSeparatorComma, value: "123", wantUnits: 123},
+		{name: "comma dot thousands", separator: config.DecimalSeparatorComma, value: "1.234,5", wantUnits: 1234, wantNano: 500000000},
+		{name: "comma space thousands", separator: config.DecimalSeparatorComma, value: "1 234,05", wantUnits: 1234, wantNano: 50000000},
+		{name: "comma nbsp thousands", separator: config.DecimalSeparatorComma, value: "1\u00a0234,5", wantUnits: 1234, wantNano: 500000000},
+		{name: "comma invalid", separator: config.DecimalSeparatorComma, value: "abc,5"},
+	}
+
+	prev, _ := decimalSeparator.Load().(string)
+	t.Cleanup(func() {
+		SetDecimalSeparator(prev)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetDecimalSeparator(tt.separator)
+			got := parsePriceString(tt.value)
+			if got.GetUnits() != tt.wantUnits || got.GetNano() != tt.wantNano {
+				t.Errorf("parsePriceString(%q) с разделителем %q = %d.%09d, want %d.%09d",
+					tt.value, tt.separator, got.GetUnits(), got.GetNano(), tt.wantUnits, tt.wantNano)
+			}
+		})
+	}
+}
@@ -11,11 +11,12 @@ package arch
 import (
 	"archive/zip"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"market-loader/internal/data"
 	"market-loader/internal/storage"
 	"market-loader/pkg/config"
-	"strconv"
 	"strings"
 	"time"
 
@@ -26,7 +27,8 @@ import (
 	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
 )
 
-// processArchive обрабатывает ZIP архив и извлекает данные свечей
+// processArchive обрабатывает ZIP архив и извлекает данные свечей.
+// Если свечи какого-либо файла не сохранены, возвращает ErrArchiveIncomplete вместе с сохранёнными свечами
 func processArchive(archivePath, figi string, dbpool *pgxpool.Pool, logger *logrus.Logger) ([]*pb.HistoricCandle, error) {
 	reader, err := zip.OpenReader(archivePath)
 	if err != nil {
@@ -43,6 +45,9 @@ func processArchive(archivePath, figi string, dbpool *pgxpool.Pool, logger *logr
 
 	// Ищем CSV файлы в архиве
 	csvFileCount := 0
+	// Файлы, свечи которых не сохранены, и первая ошибка сохранения
+	var failedFiles []string
+	var saveErr error
 	for _, file := range reader.File {
 		logger.Debugf("Файл в архиве: %s, размер: %d", file.Name, file.UncompressedSize64)
 
@@ -87,8 +92,8 @@ func processArchive(archivePath, figi string, dbpool *pgxpool.Pool, logger *logr
 				continue
 			}
 
-			// Парсим время (формат ISO 8601: 2024-12-19T04:00:00Z)
-			timestamp, err := time.Parse("2006-01-02T15:04:05Z", record[1])
+			// Парсим время (формат ISO 8601: 2024-12-19T04:00:00Z, 2024-12-19T07:00:00.123+03:00)
+			timestamp, err := parseTimestamp(record[1])
 			if err != nil {
 				logger.Debugf("Строка %d: ошибка парсинга времени '%s': %v", rowCount, record[1], err)
 				continue
@@ -106,8 +111,14 @@ func processArchive(archivePath, figi string, dbpool *pgxpool.Pool, logger *logr
 			highStr := strings.TrimSpace(record[4])
 			lowStr := strings.TrimSpace(record[5])
 
-			volume, err := strconv.ParseInt(record[6], 10, 64)
+			volume, err := parseVolumeString(record[6])
 			if err != nil {
+				// Объём свечи в API целый (int64): дробный объём не округляется и не пропускается,
+				// архив считается некорректным
+				if errors.Is(err, ErrDecimalVolume) {
+					_ = rc.Close()
+					return candles, fmt.Errorf("файл %s, строка %d: %w", file.Name, rowCount, err)
+				}
 				logger.Debugf("Строка %d: ошибка парсинга volume '%s': %v", rowCount, record[6], err)
 				continue
 			}
@@ -136,11 +147,30 @@ func processArchive(archivePath, figi string, dbpool *pgxpool.Pool, logger *logr
 			logger.Errorf("Ошибка закрытия файла в архиве: %v", err)
 		}
 
-		// Сохраняем свечи из этого файла сразу
+		// Схлопываем дубли времени в файле (встречаются на границах дней в архивах)
+		merged := data.MergeCandles(nil, fileCandles)
+		duplicates := len(fileCandles) - len(merged)
+		fileCandles = merged
+		if duplicates > 0 {
+			logger.Warnf("Файл %s: схлопнуто %d дублей свечей (остаётся последняя)", file.Name, duplicates)
+			storage.AddDuplicates(duplicates)
+		}
+
+		// Применяем преобразования и сохраняем свечи из этого файла сразу
+		fileCandles = data.TransformCandles(figi, fileCandles)
 		if len(fileCandles) > 0 {
 			logger.Debugf("Сохраняем %d свечей из файла %s...", len(fileCandles), file.Name)
-			if err := storage.SaveCandles(dbpool, figi, fileCandles, config.CandleInterval1Min, logger); err != nil {
+			// Файл-источник записывается вместе со свечами (archive.track_source_file)
+			if err := storage.SaveArchiveCandles(dbpool, figi, fileCandles, config.CandleInterval1Min, file.Name, logger); err != nil {
+				// Без инструмента в БД остальные файлы архива тоже не сохранятся
+				if errors.Is(err, storage.ErrUnknownInstrument) {
+					return candles, err
+				}
 				logger.Warnf("Ошибка сохранения свечей из файла %s: %v", file.Name, err)
+				failedFiles = append(failedFiles, file.Name)
+				if saveErr == nil {
+					saveErr = err
+				}
 				continue
 			}
 			logger.Debugf("Успешно сохранено %d свечей из файла %s", len(fileCandles), file.Name)
@@ -152,5 +182,9 @@ func processArchive(archivePath, figi string, dbpool *pgxpool.Pool, logger *logr
 	}
 
 	logger.Debugf("Всего обработано CSV файлов: %d, создано свечей: %d", csvFileCount, len(candles))
+	if len(failedFiles) > 0 {
+		return candles, fmt.Errorf("%w: %d из %d (%s): %w", ErrArchiveIncomplete,
+			len(failedFiles), csvFileCount, strings.Join(failedFiles, ", "), saveErr)
+	}
 	return candles, nil
 }
@@ -0,0 +1,69 @@
+// Package arch содержит функции для работы с архивом свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package arch
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// zipFixture упаковывает файлы из testdata в ZIP архив во временном каталоге теста
+func zipFixture(t *testing.T, names ...string) string {
+	t.Helper()
+	archivePath := filepath.Join(t.TempDir(), "fixture.zip")
+	archiveFile, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("создание архива: %v", err)
+	}
+	defer archiveFile.Close()
+
+	writer := zip.NewWriter(archiveFile)
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatalf("чтение %s: %v", name, err)
+		}
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("добавление %s в архив: %v", name, err)
+		}
+		if _, err := entry.Write(content); err != nil {
+			t.Fatalf("запись %s в архив: %v", name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("закрытие архива: %v", err)
+	}
+	return archivePath
+}
+
+func TestProcessArchiveRejectsDecimalVolume(t *testing.T) {
+	archivePath := zipFixture(t, "decimal_volume.csv")
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	// Ошибка возникает при разборе, до сохранения в БД
+	candles, err := processArchive(archivePath, "BBG004730N88", nil, logger)
+	if !errors.Is(err, ErrDecimalVolume) {
+		t.Fatalf("processArchive() error = %v, want %v", err, ErrDecimalVolume)
+	}
+	if !strings.Contains(err.Error(), "decimal_volume.csv, строка 2") {
+		t.Errorf("ошибка %q не указывает файл и строку", err)
+	}
+	if len(candles) != 0 {
+		t.Errorf("processArchive() вернул %d свечей, ожидалось 0", len(candles))
+	}
+}
@@ -0,0 +1,103 @@
+// Package arch содержит функции для работы с архивом свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package arch
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"market-loader/pkg/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	cleanupMu sync.RWMutex
+	// cleanupPolicy политика удаления скачанных архивов (archive.cleanup)
+	cleanupPolicy = config.ArchiveCleanupAlways
+)
+
+// SetCleanupPolicy задает политику удаления скачанных архивов: always, on-success, never
+func SetCleanupPolicy(policy string) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	cleanupPolicy = policy
+}
+
+func getCleanupPolicy() string {
+	cleanupMu.RLock()
+	defer cleanupMu.RUnlock()
+	return cleanupPolicy
+}
+
+// shouldCleanup проверяет, нужно ли удалить архив или директорию по итогам обработки
+func shouldCleanup(failed bool) bool {
+	switch getCleanupPolicy() {
+	case config.ArchiveCleanupNever:
+		return false
+	case config.ArchiveCleanupOnSuccess:
+		return !failed
+	default:
+		return true
+	}
+}
+
+// cleanupArchive удаляет обработанный архив по политике очистки, при ошибке обработки
+// архив остаётся для отладки (кроме политики always)
+func cleanupArchive(archivePath string, failed bool, logger *logrus.Logger) {
+	if !shouldCleanup(failed) {
+		if failed {
+			logger.WithField("path", archivePath).Info("Архив сохранён для отладки (archive.cleanup)")
+		}
+		return
+	}
+	if err := os.Remove(archivePath); err != nil && !os.IsNotExist(err) {
+		logger.Warnf("Ошибка удаления архива %s: %v", archivePath, err)
+	}
+}
+
+// TempDir временная директория для скачивания архивов
+type TempDir struct {
+	Path string
+	// created директория создана загрузчиком в системной временной директории
+	created bool
+}
+
+// OpenTempDir подготавливает директорию для архивов: path создаётся при отсутствии,
+// пустой path - новая директория в системной временной директории
+func OpenTempDir(path string) (*TempDir, error) {
+	if path != "" {
+		if err := os.MkdirAll(path, config.DefaultDirPerm); err != nil {
+			return nil, fmt.Errorf("ошибка создания временной директории %s: %w", path, err)
+		}
+		return &TempDir{Path: path}, nil
+	}
+
+	path, err := os.MkdirTemp("", "tinvest_archives")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания временной директории: %w", err)
+	}
+	return &TempDir{Path: path, created: true}, nil
+}
+
+// Close удаляет созданную загрузчиком директорию по политике очистки (failed - были ошибки обработки).
+// Директория, заданная пользователем, не удаляется: архивы в ней удаляются по одному после обработки
+func (d *TempDir) Close(failed bool, logger *logrus.Logger) {
+	if !d.created {
+		return
+	}
+	if !shouldCleanup(failed) {
+		logger.WithField("path", d.Path).Info("Временная директория с архивами сохранена (archive.cleanup)")
+		return
+	}
+	if err := os.RemoveAll(d.Path); err != nil {
+		logger.Errorf("Ошибка удаления временной директории: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+// Package arch содержит функции для работы с архивом свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package arch
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"market-loader/pkg/config"
+)
+
+const (
+	// RejectReadError строка не читается как CSV
+	RejectReadError = "ошибка чтения CSV"
+	// RejectTooFewFields недостаточно полей в строке
+	RejectTooFewFields = "недостаточно полей"
+	// RejectBadTimestamp некорректное время
+	RejectBadTimestamp = "некорректное время"
+	// RejectDecimalVolume дробный объём
+	RejectDecimalVolume = "дробный объём"
+	// RejectBadVolume некорректный объём
+	RejectBadVolume = "некорректный объём"
+
+	// maxRejectedSamples сколько отклонённых строк сохранять для примера
+	maxRejectedSamples = 20
+)
+
+// RejectedRow отклонённая строка архива
+type RejectedRow struct {
+	File   string
+	Line   int
+	Reason string
+	Detail string
+}
+
+// ArchiveStats статистика проверки архива
+type ArchiveStats struct {
+	Files         int            // CSV файлов в архиве
+	Rows          int            // строк во всех CSV
+	Candles       int            // строк, разобранных в свечи
+	Duplicates    int            // свечей с повторяющимся временем в пределах файла
+	Rejected      int            // отклонённых строк
+	RejectReasons map[string]int // количество отклонённых строк по причинам
+	Samples       []RejectedRow  // первые отклонённые строки
+	First         time.Time      // время первой свечи
+	Last          time.Time      // время последней свечи
+}
+
+// ValidateArchive разбирает все CSV файлы ZIP архива так же, как при загрузке,
+// и возвращает статистику без обращения к БД
+func ValidateArchive(path string) (*ArchiveStats, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия архива: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	stats := &ArchiveStats{RejectReasons: make(map[string]int)}
+	for _, file := range reader.File {
+		if !strings.HasSuffix(file.Name, ".csv") {
+			continue
+		}
+		stats.Files++
+
+		if err := validateCSVFile(file, stats); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// validateCSVFile разбирает один CSV файл архива и дополняет статистику
+func validateCSVFile(file *zip.File, stats *ArchiveStats) error {
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла %s в архиве: %w", file.Name, err)
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	csvReader := csv.NewReader(rc)
+	csvReader.Comma = ';' // T-Invest использует точку с запятой как разделитель
+	csvReader.FieldsPerRecord = -1
+
+	line := 0
+	seen := make(map[int64]bool)
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		line++
+		stats.Rows++
+
+		if err != nil {
+			stats.reject(file.Name, line, RejectReadError, err.Error())
+			continue
+		}
+
+		// Строка: UID, UTC, open, close, high, low, volume
+		if len(record) < config.MinCSVFields {
+			stats.reject(file.Name, line, RejectTooFewFields, fmt.Sprintf("%d полей", len(record)))
+			continue
+		}
+
+		timestamp, err := parseTimestamp(record[1])
+		if err != nil {
+			stats.reject(file.Name, line, RejectBadTimestamp, err.Error())
+			continue
+		}
+
+		if _, err := parseVolumeString(record[6]); err != nil {
+			if errors.Is(err, ErrDecimalVolume) {
+				stats.reject(file.Name, line, RejectDecimalVolume, err.Error())
+			} else {
+				stats.reject(file.Name, line, RejectBadVolume, err.Error())
+			}
+			continue
+		}
+
+		stats.Candles++
+		if seen[timestamp.UnixNano()] {
+			stats.Duplicates++
+		}
+		seen[timestamp.UnixNano()] = true
+		if stats.First.IsZero() || timestamp.Before(stats.First) {
+			stats.First = timestamp
+		}
+		if timestamp.After(stats.Last) {
+			stats.Last = timestamp
+		}
+	}
+}
+
+// reject учитывает отклонённую строку
+func (s *ArchiveStats) reject(file string, line int, reason, detail string) {
+	s.Rejected++
+	s.RejectReasons[reason]++
+	if len(s.Samples) < maxRejectedSamples {
+		s.Samples = append(s.Samples, RejectedRow{File: file, Line: line, Reason: reason, Detail: detail})
+	}
+}
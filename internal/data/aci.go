@@ -0,0 +1,52 @@
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"fmt"
+	"market-loader/internal/money"
+	"market-loader/internal/storage"
+	"strconv"
+	"time"
+
+	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+)
+
+// LoadAccruedInterests загружает НКД облигации по дням за период
+func LoadAccruedInterests(client *investgo.Client, figi string, from, to time.Time) ([]storage.AccruedInterest, error) {
+	instrumentsClient := client.NewInstrumentsServiceClient()
+
+	// Загружаем НКД через API
+	response, err := instrumentsClient.GetAccruedInterests(figi, from, to)
+	if err = wrapAuthError(err, false); err != nil {
+		return nil, fmt.Errorf("ошибка загрузки НКД: %w", err)
+	}
+
+	result := make([]storage.AccruedInterest, 0, len(response.GetAccruedInterests()))
+	for _, aci := range response.GetAccruedInterests() {
+		if aci.GetDate() == nil || aci.GetValue() == nil {
+			continue
+		}
+
+		// Валюта в ответе не передаётся - при сохранении берётся валюта облигации
+		valueStr := money.ConvertMoneyValue(aci.GetValue().GetUnits(), aci.GetValue().GetNano())
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("некорректное значение НКД %q: %w", valueStr, err)
+		}
+
+		result = append(result, storage.AccruedInterest{
+			Figi:  figi,
+			Date:  aci.GetDate().AsTime(),
+			Value: value,
+		})
+	}
+
+	return result, nil
+}
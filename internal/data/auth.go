@@ -0,0 +1,43 @@
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrInvalidToken токен API недействителен или просрочен: продолжать запуск бессмысленно
+var ErrInvalidToken = errors.New("недействительный или просроченный токен T-Invest")
+
+// wrapAuthError помечает ошибку авторизации как ErrInvalidToken: Unauthenticated - всегда,
+// PermissionDenied - только для запросов уровня счёта (accountLevel), для инструмента это отсутствие доступа к нему
+func wrapAuthError(err error, accountLevel bool) error {
+	if err == nil || errors.Is(err, ErrInvalidToken) {
+		return err
+	}
+	switch status.Code(err) {
+	case codes.Unauthenticated:
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	case codes.PermissionDenied:
+		if accountLevel {
+			return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+		}
+	}
+	return err
+}
+
+// WrapAccountError помечает ошибку запроса уровня счёта (информация о пользователе, счета)
+// как ErrInvalidToken, если она связана с токеном
+func WrapAccountError(err error) error {
+	return wrapAuthError(err, true)
+}
@@ -0,0 +1,68 @@
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrBudgetExhausted бюджет запросов к API на запуск (loading.max_requests_per_run) исчерпан
+var ErrBudgetExhausted = errors.New("бюджет запросов к API на запуск исчерпан")
+
+// ErrRunDeadline истекла максимальная длительность загрузки (loading.max_run_duration)
+var ErrRunDeadline = errors.New("истекло время загрузки")
+
+var (
+	// requestBudget максимум запросов к API за запуск (0 - без ограничения)
+	requestBudget atomic.Int64
+	// requestsUsed выполнено запросов к API за запуск
+	requestsUsed atomic.Int64
+)
+
+// SetRequestBudget задаёт максимум запросов к API за запуск (0 - без ограничения)
+func SetRequestBudget(limit int) {
+	if limit < 0 {
+		limit = 0
+	}
+	requestBudget.Store(int64(limit))
+}
+
+// TakeRequest учитывает запрос к API, false - бюджет запросов исчерпан и запрос выполнять нельзя
+func TakeRequest() bool {
+	limit := requestBudget.Load()
+	if limit == 0 {
+		requestsUsed.Add(1)
+		return true
+	}
+	for {
+		used := requestsUsed.Load()
+		if used >= limit {
+			return false
+		}
+		if requestsUsed.CompareAndSwap(used, used+1) {
+			return true
+		}
+	}
+}
+
+// RequestsUsed возвращает количество запросов к API за запуск
+func RequestsUsed() int64 {
+	return requestsUsed.Load()
+}
+
+// RunStopError возвращает причину остановки загрузки по отменённому контексту:
+// ErrRunDeadline при истечении loading.max_run_duration, иначе ошибку контекста
+func RunStopError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrRunDeadline
+	}
+	return ctx.Err()
+}
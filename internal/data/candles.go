@@ -10,30 +10,119 @@ package data
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
+	"market-loader/pkg/config"
+
 	"github.com/russianinvestments/invest-api-go-sdk/investgo"
 	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrInstrumentForbidden нет доступа к инструменту (например, только для квалифицированных инвесторов)
+	ErrInstrumentForbidden = errors.New("нет доступа к инструменту")
+	// ErrInstrumentNotFound инструмент не найден в API
+	ErrInstrumentNotFound = errors.New("инструмент не найден в API")
 )
 
+// IsInstrumentInaccessible проверяет, что инструмент недоступен для токена и повтор запуска не поможет
+func IsInstrumentInaccessible(err error) bool {
+	return errors.Is(err, ErrInstrumentForbidden) || errors.Is(err, ErrInstrumentNotFound)
+}
+
 // LoadCandleChunk загружает один чанк свечей согласно лимитам API
-func LoadCandleChunk(_ context.Context, client *investgo.Client, figi string, from, to time.Time, interval pb.CandleInterval) ([]*pb.HistoricCandle, error) {
+func LoadCandleChunk(ctx context.Context, client *investgo.Client, figi string, from, to time.Time, interval pb.CandleInterval) ([]*pb.HistoricCandle, error) {
 	marketDataClient := client.NewMarketDataServiceClient()
 
-	// Загружаем чанк данных
-	candles, err := marketDataClient.GetHistoricCandles(&investgo.GetHistoricCandlesRequest{
-		Instrument: figi,
-		Interval:   interval,
-		From:       from,
-		To:         to,
-		File:       false,
-		FileName:   "",
+	// Загружаем чанк данных (временные ошибки API повторяются)
+	var candles []*pb.HistoricCandle
+	// Повторы учитываются в бюджете запросов на запуск, как и сам запрос
+	err := withAPIRetry(ctx, client, true, func() error {
+		var err error
+		candles, err = marketDataClient.GetHistoricCandles(&investgo.GetHistoricCandlesRequest{
+			Instrument: figi,
+			Interval:   interval,
+			From:       from,
+			To:         to,
+			File:       false,
+			FileName:   "",
+		})
+
+		// Учитываем запрос в сводке запуска (неудачный - без свечей)
+		recordFetch(candles)
+		return err
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("ошибка загрузки свечей: %w", err)
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrBudgetExhausted) {
+			return nil, err
+		}
+		switch status.Code(err) {
+		case codes.PermissionDenied:
+			return nil, fmt.Errorf("%w: %w", ErrInstrumentForbidden, err)
+		case codes.NotFound:
+			return nil, fmt.Errorf("%w: %w", ErrInstrumentNotFound, err)
+		default:
+			return nil, fmt.Errorf("ошибка загрузки свечей: %w", err)
+		}
 	}
 
 	return candles, nil
 }
+
+// MergeCandles объединяет свечи по времени: при совпадении времени остаётся свеча из incoming
+// (внутри одного среза - последняя). Результат упорядочен по возрастанию времени, без дублей
+func MergeCandles(existing, incoming []*pb.HistoricCandle) []*pb.HistoricCandle {
+	byTime := make(map[int64]*pb.HistoricCandle, len(existing)+len(incoming))
+	for _, candles := range [][]*pb.HistoricCandle{existing, incoming} {
+		for _, candle := range candles {
+			byTime[candle.GetTime().AsTime().UnixNano()] = candle
+		}
+	}
+
+	merged := make([]*pb.HistoricCandle, 0, len(byTime))
+	for _, candle := range byTime {
+		merged = append(merged, candle)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].GetTime().AsTime().Before(merged[j].GetTime().AsTime())
+	})
+	return merged
+}
+
+// DropIncompleteCandles убирает с конца упорядоченного по времени среза свечи, период которых
+// ещё не закрылся к now (текущая минута, день): они будут загружены в следующем запуске
+func DropIncompleteCandles(candles []*pb.HistoricCandle, intervalType string, now time.Time) []*pb.HistoricCandle {
+	end := len(candles)
+	for end > 0 && config.IntervalPeriodEnd(intervalType, candles[end-1].GetTime().AsTime()).After(now) {
+		end--
+	}
+	return candles[:end]
+}
+
+// TruncatedResumeFrom проверяет, что ответ API усечён лимитом количества свечей: свечей не меньше limit,
+// а последняя свеча заметно раньше конца чанка to. Возвращает время последней свечи, с которого нужно
+// продолжить загрузку (вместо перехода к to), и true при усечении
+func TruncatedResumeFrom(candles []*pb.HistoricCandle, limit int, from, to time.Time, unit time.Duration) (time.Time, bool) {
+	if limit <= 0 || len(candles) < limit {
+		return time.Time{}, false
+	}
+
+	var last time.Time
+	for _, candle := range candles {
+		if candleTime := candle.GetTime().AsTime(); candleTime.After(last) {
+			last = candleTime
+		}
+	}
+
+	// Без продвижения вперёд повтор запроса вернул бы те же свечи
+	if !last.After(from) || !last.Add(unit).Before(to) {
+		return time.Time{}, false
+	}
+	return last, true
+}
@@ -0,0 +1,166 @@
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testBase начало отсчёта времени тестовых свечей
+var testBase = time.Date(2024, time.December, 19, 7, 0, 0, 0, time.UTC)
+
+// testCandle свеча через minute минут от testBase с ценой закрытия closeUnits (метка свечи в тестах)
+func testCandle(minute int, closeUnits int64) *pb.HistoricCandle {
+	return &pb.HistoricCandle{
+		Time:  timestamppb.New(testBase.Add(time.Duration(minute) * time.Minute)),
+		Close: &pb.Quotation{Units: closeUnits},
+	}
+}
+
+// candleMark минута и цена закрытия свечи для сравнения результатов
+type candleMark struct {
+	minute     int
+	closeUnits int64
+}
+
+func candleMarks(candles []*pb.HistoricCandle) []candleMark {
+	marks := make([]candleMark, 0, len(candles))
+	for _, candle := range candles {
+		marks = append(marks, candleMark{
+			minute:     int(candle.GetTime().AsTime().Sub(testBase) / time.Minute),
+			closeUnits: candle.GetClose().GetUnits(),
+		})
+	}
+	return marks
+}
+
+func equalMarks(a, b []candleMark) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMergeCandles(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []*pb.HistoricCandle
+		incoming []*pb.HistoricCandle
+		want     []candleMark
+	}{
+		{
+			name: "both empty",
+			want: []candleMark{},
+		},
+		{
+			name:     "only existing",
+			existing: []*pb.HistoricCandle{testCandle(0, 1), testCandle(1, 2)},
+			want:     []candleMark{{0, 1}, {1, 2}},
+		},
+		{
+			name:     "only incoming unsorted",
+			incoming: []*pb.HistoricCandle{testCandle(2, 3), testCandle(0, 1), testCandle(1, 2)},
+			want:     []candleMark{{0, 1}, {1, 2}, {2, 3}},
+		},
+		{
+			name:     "incoming replaces existing",
+			existing: []*pb.HistoricCandle{testCandle(0, 1), testCandle(1, 2)},
+			incoming: []*pb.HistoricCandle{testCandle(1, 20), testCandle(2, 30)},
+			want:     []candleMark{{0, 1}, {1, 20}, {2, 30}},
+		},
+		{
+			name:     "last duplicate within incoming wins",
+			incoming: []*pb.HistoricCandle{testCandle(1, 10), testCandle(0, 1), testCandle(1, 11)},
+			want:     []candleMark{{0, 1}, {1, 11}},
+		},
+		{
+			name:     "last duplicate within existing wins",
+			existing: []*pb.HistoricCandle{testCandle(0, 1), testCandle(0, 2)},
+			want:     []candleMark{{0, 2}},
+		},
+		{
+			name:     "interleaved",
+			existing: []*pb.HistoricCandle{testCandle(4, 4), testCandle(0, 0), testCandle(2, 2)},
+			incoming: []*pb.HistoricCandle{testCandle(3, 33), testCandle(1, 11), testCandle(4, 44)},
+			want:     []candleMark{{0, 0}, {1, 11}, {2, 2}, {3, 33}, {4, 44}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := candleMarks(MergeCandles(tt.existing, tt.incoming))
+			if !equalMarks(got, tt.want) {
+				t.Errorf("MergeCandles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeCandlesSameInstantInDifferentZones(t *testing.T) {
+	// Одно и то же время, заданное в разных часовых поясах, - одна свеча
+	msk := time.FixedZone("MSK", 3*60*60)
+	existing := []*pb.HistoricCandle{{Time: timestamppb.New(testBase), Close: &pb.Quotation{Units: 1}}}
+	incoming := []*pb.HistoricCandle{{Time: timestamppb.New(testBase.In(msk)), Close: &pb.Quotation{Units: 2}}}
+
+	got := candleMarks(MergeCandles(existing, incoming))
+	if want := []candleMark{{0, 2}}; !equalMarks(got, want) {
+		t.Errorf("MergeCandles() = %v, want %v", got, want)
+	}
+}
+
+func TestTruncatedResumeFrom(t *testing.T) {
+	minutes := func(ms ...int) []*pb.HistoricCandle {
+		candles := make([]*pb.HistoricCandle, 0, len(ms))
+		for _, m := range ms {
+			candles = append(candles, testCandle(m, 0))
+		}
+		return candles
+	}
+	at := func(minute int) time.Time {
+		return testBase.Add(time.Duration(minute) * time.Minute)
+	}
+
+	tests := []struct {
+		name          string
+		candles       []*pb.HistoricCandle
+		limit         int
+		to            time.Time
+		wantResume    time.Time
+		wantTruncated bool
+	}{
+		{name: "no limit", candles: minutes(0, 1, 2), limit: 0, to: at(60)},
+		{name: "fewer than limit", candles: minutes(0, 1), limit: 3, to: at(60)},
+		{name: "empty response", limit: 3, to: at(60)},
+		{name: "limit reached before end", candles: minutes(0, 1, 2), limit: 3, to: at(60), wantResume: at(2), wantTruncated: true},
+		{name: "more than limit", candles: minutes(0, 1, 2, 3), limit: 3, to: at(60), wantResume: at(3), wantTruncated: true},
+		{name: "unsorted response", candles: minutes(5, 1, 3), limit: 3, to: at(60), wantResume: at(5), wantTruncated: true},
+		{name: "two units before end", candles: minutes(0, 1, 2), limit: 3, to: at(4), wantResume: at(2), wantTruncated: true},
+		{name: "last period ends at chunk end", candles: minutes(0, 1, 2), limit: 3, to: at(3)},
+		{name: "last after chunk end", candles: minutes(0, 1, 5), limit: 3, to: at(3)},
+		{name: "no progress from chunk start", candles: minutes(0, 0, 0), limit: 3, to: at(60)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resume, truncated := TruncatedResumeFrom(tt.candles, tt.limit, testBase, tt.to, time.Minute)
+			if truncated != tt.wantTruncated || !resume.Equal(tt.wantResume) {
+				t.Errorf("TruncatedResumeFrom() = (%v, %v), want (%v, %v)", resume, truncated, tt.wantResume, tt.wantTruncated)
+			}
+		})
+	}
+}
@@ -12,19 +12,77 @@ import (
 	"context"
 	"fmt"
 	"market-loader/pkg/config"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	// "market-loader/pkg/mainlib"
 
 	"github.com/russianinvestments/invest-api-go-sdk/investgo"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/metadata"
 )
 
-// CreateTinvestClient создает клиент для работы с T-Invest API
+var (
+	runIDOnce sync.Once
+	runID     string
+)
+
+// RunID возвращает идентификатор текущего процесса для сопоставления запросов к API
+func RunID() string {
+	runIDOnce.Do(func() {
+		runID = fmt.Sprintf("%s-%d", time.Now().UTC().Format("20060102T150405"), os.Getpid())
+	})
+	return runID
+}
+
+// AppName возвращает имя приложения для API с развёрнутым шаблоном суффикса
+func AppName(cfg *config.Config) string {
+	suffix := strings.TrimSpace(cfg.Tinvest.AppNameSuffix)
+	if suffix == "" {
+		return cfg.Tinvest.AppName
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	suffix = strings.NewReplacer(
+		"{hostname}", hostname,
+		"{pid}", strconv.Itoa(os.Getpid()),
+		"{run_id}", RunID(),
+		"{date}", time.Now().Format("20060102"),
+	).Replace(suffix)
+
+	if cfg.Tinvest.AppName == "" {
+		return suffix
+	}
+	return cfg.Tinvest.AppName + "-" + suffix
+}
+
+// CreateTinvestClient создает клиент для работы с T-Invest API (с первым токеном из конфигурации)
 func CreateTinvestClient(ctx context.Context, cfg *config.Config) (*investgo.Client, error) {
+	tokens := cfg.GetTokens()
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("не задан токен API (tinvest.token или tinvest.tokens)")
+	}
+	return CreateTinvestClientWithToken(ctx, cfg, tokens[0])
+}
+
+// CreateTinvestClientWithToken создает клиент для работы с T-Invest API с указанным токеном
+func CreateTinvestClientWithToken(ctx context.Context, cfg *config.Config, token string) (*investgo.Client, error) {
 	config := investgo.Config{
 		EndPoint: cfg.Tinvest.Endpoint,
-		Token:    cfg.Tinvest.Token,
-		AppName:  cfg.Tinvest.AppName,
+		Token:    token,
+		AppName:  AppName(cfg),
+	}
+
+	// SDK использует контекст клиента для всех запросов, поэтому заголовок попадёт в каждый вызов
+	if header := strings.ToLower(strings.TrimSpace(cfg.Tinvest.TrackingHeader)); header != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, header, RunID())
 	}
 
 	// Создаем простой логгер для SDK
@@ -0,0 +1,170 @@
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"market-loader/pkg/config"
+
+	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+)
+
+// pooledClient клиент API одного токена со временем последнего запроса
+type pooledClient struct {
+	client   *investgo.Client
+	lastUsed time.Time
+}
+
+// ClientPool распределяет запросы между клиентами нескольких токенов (tinvest.tokens).
+// Выбирается клиент, дольше всех не использовавшийся; у каждого токена своя пауза rate_limit_pause.
+type ClientPool struct {
+	mu      sync.Mutex
+	cfg     *config.Config
+	clients []*pooledClient
+}
+
+// NewClientPool создает пул из уже подключённого клиента первого токена и клиентов остальных токенов
+func NewClientPool(ctx context.Context, cfg *config.Config, first *investgo.Client) (*ClientPool, error) {
+	pool := &ClientPool{
+		cfg:     cfg,
+		clients: []*pooledClient{{client: first}},
+	}
+
+	tokens := cfg.GetTokens()
+	for i := 1; i < len(tokens); i++ {
+		client, err := CreateTinvestClientWithToken(ctx, cfg, tokens[i])
+		if err != nil {
+			pool.stop(1)
+			return nil, fmt.Errorf("ошибка создания клиента для токена %d: %w", i+1, err)
+		}
+		pool.clients = append(pool.clients, &pooledClient{client: client})
+	}
+
+	return pool, nil
+}
+
+// Len возвращает количество клиентов (токенов) в пуле
+func (p *ClientPool) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.clients)
+}
+
+// Acquire возвращает клиент, дольше всех не использовавшийся, и при необходимости
+// ждёт, пока для его токена пройдёт пауза rate_limit_pause с последнего запроса
+func (p *ClientPool) Acquire(ctx context.Context) (*investgo.Client, error) {
+	p.mu.Lock()
+	next := p.clients[0]
+	for _, candidate := range p.clients[1:] {
+		if candidate.lastUsed.Before(next.lastUsed) {
+			next = candidate
+		}
+	}
+	readyAt := p.reserveLocked(next)
+	p.mu.Unlock()
+
+	if err := waitUntil(ctx, readyAt); err != nil {
+		return nil, err
+	}
+	return next.client, nil
+}
+
+// Reserve ждёт, пока для токена клиента пройдёт пауза rate_limit_pause с последнего запроса,
+// и резервирует за ним время запроса (для повтора запроса тем же клиентом). Клиент не из пула не ждёт
+func (p *ClientPool) Reserve(ctx context.Context, client *investgo.Client) error {
+	p.mu.Lock()
+	var readyAt time.Time
+	for _, pooled := range p.clients {
+		if pooled.client == client {
+			readyAt = p.reserveLocked(pooled)
+			break
+		}
+	}
+	p.mu.Unlock()
+
+	return waitUntil(ctx, readyAt)
+}
+
+// reserveLocked резервирует время следующего запроса клиента, вызывается под mu.
+// Время резервируется сразу, чтобы параллельные вызовы выбрали другой токен
+func (p *ClientPool) reserveLocked(pooled *pooledClient) time.Time {
+	now := time.Now()
+	readyAt := pooled.lastUsed.Add(p.cfg.GetRateLimitPause())
+	if readyAt.Before(now) {
+		readyAt = now
+	}
+	pooled.lastUsed = readyAt
+	return readyAt
+}
+
+// waitUntil ждёт наступления момента t или отмены контекста
+func waitUntil(ctx context.Context, t time.Time) error {
+	wait := time.Until(t)
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// Stop закрывает клиенты дополнительных токенов (клиент первого токена закрывается владельцем)
+func (p *ClientPool) Stop() {
+	if p == nil {
+		return
+	}
+	p.stop(1)
+}
+
+// stop закрывает клиенты, начиная с индекса from
+func (p *ClientPool) stop(from int) {
+	for _, pooled := range p.clients[from:] {
+		_ = pooled.client.Stop()
+	}
+}
+
+var (
+	clientPoolMu sync.RWMutex
+	clientPool   *ClientPool
+	// sharedLimit пул используется и при одном токене: параллельные загрузки делят паузу токена
+	sharedLimit bool
+)
+
+// SetClientPool задает пул клиентов для загрузки свечей (nil или один токен - пул не используется)
+func SetClientPool(pool *ClientPool) {
+	clientPoolMu.Lock()
+	defer clientPoolMu.Unlock()
+	clientPool = pool
+}
+
+// SetSharedRateLimit включает общую паузу rate_limit_pause через пул и при одном токене
+// (для параллельной загрузки интервалов)
+func SetSharedRateLimit(enabled bool) {
+	clientPoolMu.Lock()
+	defer clientPoolMu.Unlock()
+	sharedLimit = enabled
+}
+
+// activeClientPool возвращает пул, если настроено больше одного токена или включена общая пауза
+func activeClientPool() *ClientPool {
+	clientPoolMu.RLock()
+	defer clientPoolMu.RUnlock()
+	if clientPool.Len() > 1 || (sharedLimit && clientPool.Len() > 0) {
+		return clientPool
+	}
+	return nil
+}
@@ -10,17 +10,25 @@ package data
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
 	"github.com/sirupsen/logrus"
 
 	"market-loader/internal/storage"
 	"market-loader/pkg/config"
 )
 
+// defaultLimitWarned интервалы, для которых уже выведено предупреждение о лимите по умолчанию
+var defaultLimitWarned sync.Map
+
 // LoadCandleData универсальная функция для загрузки данных свечей
 func LoadCandleData(
 	ctx context.Context,
@@ -32,39 +40,97 @@ func LoadCandleData(
 	cfg *config.Config,
 	logger *logrus.Logger,
 ) error {
+	// Неизвестный интервал - ошибка, а не молчаливая загрузка минутных свечей
+	candleInterval, err := config.GetCandleIntervalChecked(intervalType)
+	if err != nil {
+		return err
+	}
+
+	order, err := cfg.GetLoadingOrder()
+	if err != nil {
+		return err
+	}
+	newestFirst := order == config.LoadingOrderNewest
+
 	var from time.Time
+	// Последние свечи актуальны: при загрузке от новых к старым догружается только история
+	upToDate := false
 
 	// Определяем период загрузки
 	if !lastLoadedTime.IsZero() {
 		// Существующий инструмент - ставим время с последней свечи
-		from = lastLoadedTime
+		// (после выходных и праздников - с открытия следующей сессии, если включено в календаре)
+		from = cfg.ResumeFrom(intervalType, lastLoadedTime)
+		if !from.Equal(lastLoadedTime) {
+			logger.WithFields(logrus.Fields{
+				"figi":           instrument.Figi,
+				"ticker":         instrument.Ticker,
+				"lastLoadedTime": lastLoadedTime.Format(time.RFC3339),
+				"from":           from.Format(time.RFC3339),
+			}).Debug("Пропускаем неторговый период")
+		}
 
-		// Проверяем, нужно ли обновлять данные
-		if !config.ShouldUpdateData(lastLoadedTime, intervalType) {
+		// Проверяем, нужно ли обновлять данные (инструменты из always_refresh обновляются всегда)
+		switch {
+		case cfg.IsAlwaysRefresh(instrument.Figi, instrument.Ticker):
+			logger.WithFields(logrus.Fields{
+				"figi":   instrument.Figi,
+				"ticker": instrument.Ticker,
+			}).Debug("Инструмент в always_refresh, обновляем без проверки актуальности")
+		case !config.ShouldUpdateData(lastLoadedTime, intervalType):
 			logger.WithFields(logrus.Fields{
 				"figi":   instrument.Figi,
 				"ticker": instrument.Ticker,
 			}).Debug("Данные актуальны, пропускаем")
-			return nil
+			if !newestFirst {
+				return nil
+			}
+			upToDate = true
 		}
 	} else {
 		// Новый инструмент - загружаем полную историю
 		from = cfg.GetStartDate()
-		// Корректируем дату по IPO (чтобы не запрашивать данные которых нет)
-		if instrument.IpoDate.After(from) {
-			from = instrument.IpoDate
+		// start_date раньше первой свечи инструмента - поведение по loading.before_listing
+		if listing := ListingDate(instrument, intervalType); listing.After(from) {
+			behavior, err := cfg.GetBeforeListing()
+			if err != nil {
+				return err
+			}
+			switch behavior {
+			case config.BeforeListingSkip:
+				logger.WithFields(logrus.Fields{
+					"figi":        instrument.Figi,
+					"ticker":      instrument.Ticker,
+					"startDate":   from.Format("2006-01-02"),
+					"listingDate": listing.Format("2006-01-02"),
+				}).Info("Дата начала загрузки раньше первой свечи инструмента, пропускаем")
+				return nil
+			case config.BeforeListingError:
+				return fmt.Errorf("дата начала загрузки %s раньше первой свечи инструмента %s (%s)",
+					from.Format("2006-01-02"), instrument.Ticker, listing.Format("2006-01-02"))
+			default:
+				// Корректируем дату (чтобы не запрашивать данные которых нет)
+				from = listing
+			}
 		}
 	}
-	to := time.Now()
+	to := config.Now()
 
-	// Определяем единицу времени и ключ конфигурации по типу интервала
-	timeUnit, configKey := config.GetTimeUnitAndConfigKey(intervalType)
+	// Определяем ключ конфигурации по типу интервала
+	_, configKey := config.GetTimeUnitAndConfigKey(intervalType)
 
-	// Рассчитываем размер чанка
-	chunkSize := time.Duration(cfg.GetIntervalLimit(configKey)) * timeUnit
+	// Лимит не задан в loading.limits - предупреждаем один раз за запуск
+	if !cfg.HasIntervalLimit(configKey) {
+		if _, warned := defaultLimitWarned.LoadOrStore(configKey, true); !warned {
+			logger.WithFields(logrus.Fields{
+				"interval": configKey,
+				"apiLimit": cfg.GetIntervalLimit(configKey),
+			}).Warn("Лимит для интервала не задан в loading.limits, используется значение по умолчанию")
+		}
+	}
 
-	// Определяем формат даты для логирования
-	dateFormat := config.GetDateFormat(intervalType)
+	// Рассчитываем размер чанка (с учётом максимума API)
+	chunkSize := config.CalculateChunkSize(intervalType, cfg.GetIntervalLimit(configKey))
 
 	// Формируем дополнительные поля для логов в зависимости от типа интервала
 	logFields := logrus.Fields{
@@ -96,12 +162,168 @@ func LoadCandleData(
 	}
 	logFields["operation"] = operationType
 
-	logger.WithFields(logFields).Info("Загружаем данные с разбивкой по лимитам API")
+	// Загружаем данные чанками (новый инструмент при loading.order: newest - только от новых свечей к старым)
+	totalCandles := 0
+	if !upToDate && (!newestFirst || !lastLoadedTime.IsZero()) {
+		logger.WithFields(logFields).Info("Загружаем данные с разбивкой по лимитам API")
+		if totalCandles, err = loadCandleRange(ctx, client, dbpool, instrument, from, to, intervalType, candleInterval, chunkSize, cfg, logger); err != nil {
+			return err
+		}
+	}
+
+	// История от новых свечей к старым до start_date, продолжается с места остановки прошлого запуска
+	if newestFirst {
+		count, err := backfillCandles(ctx, client, dbpool, instrument, lastLoadedTime, to, intervalType, candleInterval, chunkSize, cfg, logger)
+		totalCandles += count
+		if err != nil {
+			return err
+		}
+		if upToDate && totalCandles == 0 {
+			return nil
+		}
+	}
+
+	// Определяем сообщение завершения
+	completionMessage := "Данные обновлены"
+	if lastLoadedTime.IsZero() {
+		completionMessage = "Полная история загружена"
+	}
+
+	logger.WithFields(logrus.Fields{
+		"figi":         instrument.Figi,
+		"ticker":       instrument.Ticker,
+		"isin":         instrument.Isin,
+		"totalCandles": totalCandles,
+	}).Info(completionMessage)
+
+	return nil
+}
+
+// backfillCandles загружает историю инструмента от новых свечей к старым (loading.order: newest):
+// чанками назад от самой ранней загруженной границы (для нового инструмента - от to) до start_date
+// (не раньше первой свечи инструмента). Граница сохраняется в candle_backfill после каждого чанка,
+// когда его свечи записаны в БД, поэтому прерванная загрузка продолжается без пропусков.
+// Возвращает количество сохранённых свечей
+func backfillCandles(
+	ctx context.Context,
+	client *investgo.Client,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	lastLoadedTime, to time.Time,
+	intervalType string,
+	candleInterval pb.CandleInterval,
+	chunkSize time.Duration,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) (int, error) {
+	// Прогресс сохраняется и после истечения времени загрузки
+	dbCtx := context.WithoutCancel(ctx)
+
+	state, err := storage.GetBackfillState(dbCtx, dbpool, instrument.Figi, intervalType)
+	if err != nil || state.Complete {
+		return 0, err
+	}
+
+	target := cfg.GetStartDate()
+	if listing := ListingDate(instrument, intervalType); listing.After(target) {
+		target = listing
+	}
+
+	// Верхняя граница: место остановки прошлого запуска, самая ранняя свеча в БД или текущее время
+	upper := state.Oldest
+	if upper.IsZero() {
+		upper = to
+		if !lastLoadedTime.IsZero() {
+			stats, err := storage.GetCandleStats(dbCtx, dbpool, instrument.Figi, intervalType)
+			if err != nil {
+				return 0, err
+			}
+			if !stats.First.IsZero() {
+				upper = stats.First
+			}
+		}
+	}
+
+	dateFormat := config.GetDateFormat(intervalType)
+	if upper.After(target) {
+		logger.WithFields(logrus.Fields{
+			"figi":      instrument.Figi,
+			"ticker":    instrument.Ticker,
+			"startTime": target.Format(dateFormat),
+			"endTime":   upper.Format(dateFormat),
+		}).Info("Загружаем историю от новых свечей к старым")
+	}
+
+	totalCandles := 0
+	for upper.After(target) {
+		from := upper.Add(-chunkSize)
+		if from.Before(target) {
+			from = target
+		}
+
+		count, err := loadCandleRange(ctx, client, dbpool, instrument, from, upper, intervalType, candleInterval, chunkSize, cfg, logger)
+		totalCandles += count
+		if err != nil {
+			return totalCandles, err
+		}
+
+		// Граница не должна опережать данные в БД
+		if err := storage.FlushCandlesFor(dbpool, instrument.Figi, intervalType, logger); err != nil {
+			return totalCandles, err
+		}
+		if err := storage.SaveBackfillState(dbCtx, dbpool, instrument.Figi, intervalType, storage.BackfillState{Oldest: from}); err != nil {
+			return totalCandles, err
+		}
+		upper = from
+	}
+
+	if err := storage.SaveBackfillState(dbCtx, dbpool, instrument.Figi, intervalType, storage.BackfillState{Oldest: target, Complete: true}); err != nil {
+		return totalCandles, err
+	}
+	logger.WithFields(logrus.Fields{
+		"figi":         instrument.Figi,
+		"ticker":       instrument.Ticker,
+		"startTime":    target.Format(dateFormat),
+		"totalCandles": totalCandles,
+	}).Info("История загружена до даты начала загрузки")
+
+	return totalCandles, nil
+}
+
+// loadCandleRange загружает свечи за период [from, to) чанками размера chunkSize и буферизует их для записи,
+// возвращает количество сохранённых свечей
+func loadCandleRange(
+	ctx context.Context,
+	client *investgo.Client,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	from, to time.Time,
+	intervalType string,
+	candleInterval pb.CandleInterval,
+	chunkSize time.Duration,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) (int, error) {
+	var err error
+
+	// Определяем формат даты для логирования
+	dateFormat := config.GetDateFormat(intervalType)
+
+	// Максимум свечей в одном ответе API: при таком количестве ответ может быть усечён
+	timeUnit, configKey := config.GetTimeUnitAndConfigKey(intervalType)
+	responseLimit := cfg.GetIntervalLimit(configKey)
+	if limitCap, exists := config.IntervalLimitCaps[configKey]; exists && (responseLimit <= 0 || responseLimit > limitCap) {
+		responseLimit = limitCap
+	}
 
-	// Загружаем данные чанками
 	totalCandles := 0
 	currentFrom := from
 
+	// Подробности по чанкам - на уровне Debug, на Info - прогресс каждые progressEvery чанков
+	progressEvery := cfg.GetProgressEveryChunks()
+	totalChunks := int(math.Ceil(float64(to.Sub(from)) / float64(chunkSize)))
+	chunkNumber := 0
+
 	for currentFrom.Before(to) {
 		currentTo := currentFrom.Add(chunkSize)
 		if currentTo.After(to) {
@@ -114,25 +336,86 @@ func LoadCandleData(
 			"isin":      instrument.Isin,
 			"chunkFrom": currentFrom.Format(dateFormat),
 			"chunkTo":   currentTo.Format(dateFormat),
-		}).Info("Загружаем чанк")
+		}).Debug("Загружаем чанк")
+
+		// Время загрузки истекло: загруженные чанки сохраняются, продолжение - в следующем запуске
+		if ctx.Err() != nil {
+			logger.WithFields(logrus.Fields{
+				"figi":     instrument.Figi,
+				"ticker":   instrument.Ticker,
+				"loadedTo": currentFrom.Format(dateFormat),
+			}).Info("Время загрузки истекло, загрузка продолжится в следующем запуске")
+			return totalCandles, RunStopError(ctx)
+		}
+
+		// Бюджет запросов на запуск: загруженные чанки сохраняются, продолжение - в следующем запуске
+		if !TakeRequest() {
+			logger.WithFields(logrus.Fields{
+				"figi":     instrument.Figi,
+				"ticker":   instrument.Ticker,
+				"loadedTo": currentFrom.Format(dateFormat),
+			}).Info("Бюджет запросов к API исчерпан, загрузка продолжится в следующем запуске")
+			return totalCandles, ErrBudgetExhausted
+		}
+
+		// Начатый чанк дозагружается и после истечения времени загрузки
+		chunkCtx := context.WithoutCancel(ctx)
+
+		// При нескольких токенах чанк загружается клиентом очередного токена,
+		// пул сам выдерживает паузу rate_limit_pause для каждого токена
+		chunkClient := client
+		pool := activeClientPool()
+		if pool != nil {
+			if chunkClient, err = pool.Acquire(chunkCtx); err != nil {
+				return totalCandles, err
+			}
+		}
 
 		// Загружаем чанк данных
-		candles, err := LoadCandleChunk(ctx, client, instrument.Figi, currentFrom, currentTo, config.GetCandleInterval(intervalType))
+		candles, err := LoadCandleChunk(chunkCtx, chunkClient, instrument.Figi, currentFrom, currentTo, candleInterval)
+		// Бюджет исчерпан на повторах чанка: загрузка продолжится с этого чанка в следующем запуске
+		if errors.Is(err, ErrBudgetExhausted) {
+			logger.WithFields(logrus.Fields{
+				"figi":     instrument.Figi,
+				"ticker":   instrument.Ticker,
+				"loadedTo": currentFrom.Format(dateFormat),
+			}).Info("Бюджет запросов к API исчерпан, загрузка продолжится в следующем запуске")
+			return totalCandles, ErrBudgetExhausted
+		}
 		if err != nil {
-			return fmt.Errorf("ошибка загрузки чанка %s - %s: %w",
+			return totalCandles, fmt.Errorf("ошибка загрузки чанка %s - %s: %w",
 				currentFrom.Format("2006-01-02"), currentTo.Format("2006-01-02"), err)
 		}
 
 		// Проверяем лимиты API
-		if cfg.Loading.RateLimitPause > 0 {
-			logger.Infof("Пауза %d секунд для соблюдения лимитов API...", cfg.Loading.RateLimitPause)
-			time.Sleep(time.Duration(cfg.Loading.RateLimitPause) * time.Second)
+		if pause := cfg.GetRateLimitPause(); pause > 0 && pool == nil {
+			logger.Debugf("Пауза %v для соблюдения лимитов API...", pause)
+			time.Sleep(pause)
 		}
 
-		// Сохраняем чанк в БД
+		// Ответ усечён лимитом API - следующий запрос с последней полученной свечи, а не с конца чанка
+		nextFrom := currentTo
+		if resumeFrom, truncated := TruncatedResumeFrom(candles, responseLimit, currentFrom, currentTo, timeUnit); truncated {
+			logger.WithFields(logrus.Fields{
+				"figi":       instrument.Figi,
+				"ticker":     instrument.Ticker,
+				"candles":    len(candles),
+				"chunkTo":    currentTo.Format(dateFormat),
+				"resumeFrom": resumeFrom.Format(dateFormat),
+			}).Warn("Ответ API усечён лимитом количества свечей, продолжаем с последней полученной свечи")
+			nextFrom = resumeFrom
+		}
+
+		// Упорядочиваем по времени без дублей, применяем преобразования и сохраняем чанк в БД
+		candles = MergeCandles(nil, candles)
+		// При зафиксированном моменте (--as-of) незакрытые к нему свечи отбрасываются всегда
+		if _, pinned := config.AsOf(); cfg.Loading.DropIncompleteLast || pinned {
+			candles = DropIncompleteCandles(candles, intervalType, config.Now())
+		}
+		candles = TransformCandles(instrument.Figi, candles)
 		if len(candles) > 0 {
-			if err := storage.SaveCandles(dbpool, instrument.Figi, candles, intervalType, logger); err != nil {
-				return fmt.Errorf("ошибка сохранения чанка: %w", err)
+			if err := storage.BufferCandles(dbpool, instrument.Figi, candles, intervalType, logger); err != nil {
+				return totalCandles, fmt.Errorf("ошибка сохранения чанка: %w", err)
 			}
 
 			totalCandles += len(candles)
@@ -142,32 +425,134 @@ func LoadCandleData(
 				"isin":      instrument.Isin,
 				"chunkSize": len(candles),
 				"total":     totalCandles,
-			}).Info("Чанк сохранен")
+			}).Debug("Чанк сохранен")
+		}
+
+		chunkNumber++
+		if chunkNumber%progressEvery == 0 && chunkNumber < totalChunks {
+			logger.WithFields(logrus.Fields{
+				"figi":         instrument.Figi,
+				"ticker":       instrument.Ticker,
+				"chunks":       chunkNumber,
+				"totalChunks":  totalChunks,
+				"loadedTo":     currentTo.Format(dateFormat),
+				"totalCandles": totalCandles,
+			}).Infof("Прогресс загрузки: %d из %d чанков", chunkNumber, totalChunks)
 		}
 
 		// Переходим к следующему чанку
-		currentFrom = currentTo
+		currentFrom = nextFrom
 
 		// Пауза между запросами согласно конфигурации
-		time.Sleep(time.Duration(cfg.Loading.RateLimitPause) * time.Second)
+		if pool == nil {
+			time.Sleep(cfg.GetRateLimitPause())
+		}
 	}
 
-	// Определяем сообщение завершения
-	completionMessage := "Данные обновлены"
-	if lastLoadedTime.IsZero() {
-		completionMessage = "Полная история загружена"
+	return totalCandles, nil
+}
+
+// DateWindow период загрузки вокруг даты события [From, To)
+type DateWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+// EventWindows строит периоды загрузки вокруг дат событий: от date - window до конца дня date + window.
+// Пересекающиеся и соседние периоды объединяются, будущее отсекается текущим моментом
+func EventWindows(dates []time.Time, window time.Duration) []DateWindow {
+	now := config.Now().UTC()
+	windows := make([]DateWindow, 0, len(dates))
+	for _, date := range dates {
+		day := date.UTC().Truncate(24 * time.Hour)
+		from := day.Add(-window)
+		to := day.Add(24*time.Hour + window)
+		if to.After(now) {
+			to = now
+		}
+		if !from.Before(to) {
+			continue
+		}
+		windows = append(windows, DateWindow{From: from, To: to})
+	}
+
+	sort.Slice(windows, func(i, j int) bool { return windows[i].From.Before(windows[j].From) })
+
+	merged := windows[:0]
+	for _, w := range windows {
+		if last := len(merged) - 1; last >= 0 && !w.From.After(merged[last].To) {
+			if w.To.After(merged[last].To) {
+				merged[last].To = w.To
+			}
+			continue
+		}
+		merged = append(merged, w)
+	}
+	return merged
+}
+
+// LoadCandleWindows загружает свечи только за указанные периоды (окна вокруг дат событий)
+// без загрузки всей истории; прогресс загрузки инструмента (last_loaded_time) не обновляется
+func LoadCandleWindows(
+	ctx context.Context,
+	client *investgo.Client,
+	dbpool *pgxpool.Pool,
+	instrument storage.Instrument,
+	windows []DateWindow,
+	intervalType string,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) error {
+	candleInterval, err := config.GetCandleIntervalChecked(intervalType)
+	if err != nil {
+		return err
+	}
+
+	_, configKey := config.GetTimeUnitAndConfigKey(intervalType)
+	chunkSize := config.CalculateChunkSize(intervalType, cfg.GetIntervalLimit(configKey))
+
+	totalCandles := 0
+	for _, window := range windows {
+		logger.WithFields(logrus.Fields{
+			"figi":     instrument.Figi,
+			"ticker":   instrument.Ticker,
+			"interval": intervalType,
+			"from":     window.From.Format(time.RFC3339),
+			"to":       window.To.Format(time.RFC3339),
+		}).Debug("Загружаем окно события")
+
+		count, err := loadCandleRange(ctx, client, dbpool, instrument, window.From, window.To, intervalType, candleInterval, chunkSize, cfg, logger)
+		totalCandles += count
+		if err != nil {
+			return err
+		}
 	}
 
 	logger.WithFields(logrus.Fields{
 		"figi":         instrument.Figi,
 		"ticker":       instrument.Ticker,
-		"isin":         instrument.Isin,
+		"interval":     intervalType,
+		"windows":      len(windows),
 		"totalCandles": totalCandles,
-	}).Info(completionMessage)
+	}).Info("Окна событий загружены")
 
 	return nil
 }
 
+// ListingDate возвращает дату первой свечи инструмента для интервала:
+// минутной для внутридневных интервалов, дневной для остальных, без них - дату IPO
+func ListingDate(instrument storage.Instrument, intervalType string) time.Time {
+	first := instrument.First1MinCandleDate
+	switch intervalType {
+	case config.CandleIntervalDay, config.CandleIntervalWeek, config.CandleIntervalMonth:
+		first = instrument.First1DayCandleDate
+	}
+	if first.IsZero() {
+		return instrument.IpoDate
+	}
+	return first
+}
+
 // ProcessLoadResult обрабатывает результат загрузки данных
 func ProcessLoadResult(
 	ctx context.Context,
@@ -176,6 +561,19 @@ func ProcessLoadResult(
 	loadError error,
 	logger *logrus.Logger,
 ) error {
+	// Сохраняем буфер до обновления прогресса: last_loaded_time не должен опережать данные в БД
+	if err := storage.FlushCandlesFor(dbpool, figi, intervalType, logger); err != nil {
+		logger.WithFields(logrus.Fields{
+			"figi":         figi,
+			"intervalType": intervalType,
+			"error":        err,
+		}).Error("Ошибка сброса буфера отложенной записи")
+		if loadError == nil {
+			loadError = err
+		}
+		return loadError
+	}
+
 	// Получаем время последней загруженной свечи из БД
 	lastCandleTime, err := storage.GetLastCandleTime(ctx, dbpool, figi, intervalType)
 	if err != nil {
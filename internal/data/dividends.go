@@ -9,6 +9,7 @@
 package data
 
 import (
+	"context"
 	"fmt"
 	"market-loader/internal/money"
 	"market-loader/internal/storage"
@@ -19,11 +20,16 @@ import (
 )
 
 // LoadDividends загружает дивиденды для инструмента
-func LoadDividends(client *investgo.Client, figi string, from, to time.Time) ([]storage.Dividend, error) {
+func LoadDividends(ctx context.Context, client *investgo.Client, figi string, from, to time.Time) ([]storage.Dividend, error) {
 	instrumentsClient := client.NewInstrumentsServiceClient()
 
-	// Загружаем дивиденды через API
-	dividends, err := instrumentsClient.GetDividents(figi, from, to)
+	// Загружаем дивиденды через API (временные ошибки API повторяются)
+	var dividends *investgo.GetDividendsResponse
+	err := withAPIRetry(ctx, client, false, func() error {
+		var err error
+		dividends, err = instrumentsClient.GetDividents(figi, from, to)
+		return err
+	})
 
 	if err != nil {
 		return nil, fmt.Errorf("ошибка загрузки дивидендов: %w", err)
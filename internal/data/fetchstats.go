@@ -0,0 +1,64 @@
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"math"
+	"sync"
+
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	fetchSummaryMu sync.Mutex
+	fetchSummary   storage.FetchSummary
+)
+
+// recordFetch учитывает запрос свечей к API: количество свечей и примерный объём ответа
+func recordFetch(candles []*pb.HistoricCandle) {
+	var size int64
+	for _, candle := range candles {
+		size += int64(proto.Size(candle))
+	}
+
+	fetchSummaryMu.Lock()
+	defer fetchSummaryMu.Unlock()
+
+	fetchSummary.Requests++
+	fetchSummary.Candles += int64(len(candles))
+	fetchSummary.Bytes += size
+}
+
+// GetFetchSummary возвращает сводку по запросам свечей к API за запуск
+func GetFetchSummary() storage.FetchSummary {
+	fetchSummaryMu.Lock()
+	defer fetchSummaryMu.Unlock()
+	return fetchSummary
+}
+
+// LogFetchSummary выводит итог запросов свечей к API за запуск
+func LogFetchSummary(logger *logrus.Logger) {
+	summary := GetFetchSummary()
+	if summary.Requests == 0 {
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"requests":          summary.Requests,
+		"candles":           summary.Candles,
+		"bytes":             summary.Bytes,
+		"avgCandlesRequest": math.Round(summary.AvgCandles()*10) / 10,
+	}).Infof("Запросов свечей к API: %d, получено свечей: %d (~%.1f МБ)",
+		summary.Requests, summary.Candles, float64(summary.Bytes)/config.BytesInMB)
+}
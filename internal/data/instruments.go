@@ -11,6 +11,8 @@ package data
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"market-loader/internal/money"
@@ -21,6 +23,7 @@ import (
 	"github.com/russianinvestments/invest-api-go-sdk/investgo"
 	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // CreateInstrumentFromProto создает структуру Instrument из protobuf данных
@@ -41,7 +44,8 @@ func CreateInstrumentFromProto(
 		inst.Figi = orEmpty(&v.Figi)
 		inst.Ticker = orEmpty(&v.Ticker)
 		inst.Name = escapeTabs(v.GetName())
-		inst.InstrumentType = "share"
+		inst.InstrumentType = config.InstrumentTypeShare
+		inst.Sector = v.GetSector()
 		inst.Currency = orEmpty(&v.Currency)
 		inst.LotSize = v.Lot
 		inst.MinPriceIncrement = money.ConvertQuotationToFloat(v.MinPriceIncrement)
@@ -49,6 +53,7 @@ func CreateInstrumentFromProto(
 		inst.Enabled = v.ApiTradeAvailableFlag
 		inst.ShortEnabledFlag = v.ShortEnabledFlag
 		inst.Isin = orEmpty(&v.Isin)
+		inst.First1MinCandleDate, inst.First1DayCandleDate = firstCandleDates(v)
 		if ts := v.IpoDate; ts != nil {
 			t := ts.AsTime()
 			inst.IpoDate = t
@@ -84,7 +89,8 @@ func CreateInstrumentFromProto(
 		inst.Figi = orEmpty(&v.Figi)
 		inst.Ticker = orEmpty(&v.Ticker)
 		inst.Name = escapeTabs(v.GetName())
-		inst.InstrumentType = "bond"
+		inst.InstrumentType = config.InstrumentTypeBond
+		inst.Sector = v.GetSector()
 		inst.Currency = orEmpty(&v.Currency)
 		inst.LotSize = v.Lot
 		inst.MinPriceIncrement = money.ConvertQuotationToFloat(v.MinPriceIncrement)
@@ -92,6 +98,7 @@ func CreateInstrumentFromProto(
 		inst.Enabled = v.ApiTradeAvailableFlag
 		inst.ShortEnabledFlag = v.ShortEnabledFlag
 		inst.Isin = orEmpty(&v.Isin)
+		inst.First1MinCandleDate, inst.First1DayCandleDate = firstCandleDates(v)
 		if v.IssueSize > 0 {
 			inst.IssueSize = v.IssueSize
 		}
@@ -117,7 +124,8 @@ func CreateInstrumentFromProto(
 		inst.Figi = orEmpty(&v.Figi)
 		inst.Ticker = orEmpty(&v.Ticker)
 		inst.Name = escapeTabs(v.GetName())
-		inst.InstrumentType = "etf"
+		inst.InstrumentType = config.InstrumentTypeEtf
+		inst.Sector = v.GetSector()
 		inst.Currency = orEmpty(&v.Currency)
 		inst.LotSize = v.Lot
 		inst.MinPriceIncrement = money.ConvertQuotationToFloat(v.MinPriceIncrement)
@@ -125,6 +133,26 @@ func CreateInstrumentFromProto(
 		inst.Enabled = v.ApiTradeAvailableFlag
 		inst.ShortEnabledFlag = v.ShortEnabledFlag
 		inst.Isin = orEmpty(&v.Isin)
+		inst.First1MinCandleDate, inst.First1DayCandleDate = firstCandleDates(v)
+		inst.RealExchange = v.RealExchange.String()
+		if v.ForQualInvestorFlag {
+			flag := true
+			inst.ForQualInvestorFlag = flag
+
+		}
+	case *pb.Currency:
+		inst.Figi = orEmpty(&v.Figi)
+		inst.Ticker = orEmpty(&v.Ticker)
+		inst.Name = escapeTabs(v.GetName())
+		inst.InstrumentType = config.InstrumentTypeCurrency
+		inst.Currency = orEmpty(&v.Currency)
+		inst.LotSize = v.Lot
+		inst.MinPriceIncrement = money.ConvertQuotationToFloat(v.MinPriceIncrement)
+		inst.TradingStatus = tradingStatusToString(v.TradingStatus)
+		inst.Enabled = v.ApiTradeAvailableFlag
+		inst.ShortEnabledFlag = v.ShortEnabledFlag
+		inst.Isin = orEmpty(&v.Isin)
+		inst.First1MinCandleDate, inst.First1DayCandleDate = firstCandleDates(v)
 		inst.RealExchange = v.RealExchange.String()
 		if v.ForQualInvestorFlag {
 			flag := true
@@ -135,10 +163,43 @@ func CreateInstrumentFromProto(
 		return nil, fmt.Errorf("unknown instrument type: %T", protoInstrument)
 	}
 
+	// Канонический сектор для группировки (сырое значение API сохраняется в sector)
+	inst.SectorNormalized = config.NormalizeSector(inst.Sector, currentSectorMapping())
+
 	return &inst, nil
 }
 
-// processInstruments обрабатывает и сохраняет инструменты
+// sectorMapping соответствие секторов API каноническим (по умолчанию config.DefaultSectorMapping)
+var sectorMapping atomic.Pointer[map[string]string]
+
+// SetSectorMapping задаёт соответствие секторов API каноническим для CreateInstrumentFromProto
+func SetSectorMapping(mapping map[string]string) {
+	sectorMapping.Store(&mapping)
+}
+
+// currentSectorMapping возвращает текущее соответствие секторов
+func currentSectorMapping() map[string]string {
+	if mapping := sectorMapping.Load(); mapping != nil {
+		return *mapping
+	}
+	return config.DefaultSectorMapping
+}
+
+// firstCandleDates возвращает даты первых минутной и дневной свечей инструмента (нулевые, если не заданы)
+func firstCandleDates(instrument interface {
+	GetFirst_1MinCandleDate() *timestamppb.Timestamp
+	GetFirst_1DayCandleDate() *timestamppb.Timestamp
+}) (first1Min, first1Day time.Time) {
+	if ts := instrument.GetFirst_1MinCandleDate(); ts != nil {
+		first1Min = ts.AsTime()
+	}
+	if ts := instrument.GetFirst_1DayCandleDate(); ts != nil {
+		first1Day = ts.AsTime()
+	}
+	return first1Min, first1Day
+}
+
+// processInstruments обрабатывает и сохраняет инструменты пачками по batchSize
 func processInstruments[T interface {
 	GetFigi() string
 	GetTicker() string
@@ -151,12 +212,14 @@ func processInstruments[T interface {
 	ctx context.Context,
 	client *investgo.Client,
 	instruments []T,
-	instrumentType string,
+	instrumentType config.InstrumentType,
 	dataSourceID *int32,
 	dbpool *pgxpool.Pool,
+	batchSize int,
 	logger *logrus.Logger,
 ) error {
 	count := 0
+	batch := make([]storage.Instrument, 0, batchSize)
 
 	for _, protoInstrument := range instruments {
 		if config.IsNormalTrading(protoInstrument.GetTradingStatus()) {
@@ -170,20 +233,17 @@ func processInstruments[T interface {
 					"type":   instrumentType,
 					"error":  err,
 				}).Error("Ошибка создания инструмента")
+				continue
 			}
 
-			if err := storage.SaveInstrument(ctx, dbpool, *instrument); err != nil {
-				logger.WithFields(logrus.Fields{
-					"figi":   protoInstrument.GetFigi(),
-					"ticker": protoInstrument.GetTicker(),
-					"type":   instrumentType,
-					"error":  err,
-				}).Error("Ошибка сохранения инструмента")
-				continue
+			batch = append(batch, *instrument)
+			if len(batch) >= batchSize {
+				count += saveInstrumentBatch(ctx, dbpool, batch, instrumentType, logger)
+				batch = batch[:0]
 			}
-			count++
 		}
 	}
+	count += saveInstrumentBatch(ctx, dbpool, batch, instrumentType, logger)
 
 	logger.WithFields(logrus.Fields{
 		"type":  instrumentType,
@@ -192,42 +252,126 @@ func processInstruments[T interface {
 	return nil
 }
 
-// LoadInstrumentsByType загружает инструменты определенного типа из API и сохраняет в БД
+// saveInstrumentBatch сохраняет пачку инструментов одним запросом; если пачка не сохранилась,
+// инструменты сохраняются по одному, чтобы ошибка одного не отменяла остальные.
+// Возвращает количество сохранённых инструментов
+func saveInstrumentBatch(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	batch []storage.Instrument,
+	instrumentType config.InstrumentType,
+	logger *logrus.Logger,
+) int {
+	if len(batch) == 0 {
+		return 0
+	}
+	err := storage.SaveInstrumentsMetadata(ctx, dbpool, batch)
+	if err == nil {
+		return len(batch)
+	}
+	logger.WithFields(logrus.Fields{
+		"type":  instrumentType,
+		"count": len(batch),
+		"error": err,
+	}).Warn("Ошибка сохранения пачки инструментов, сохраняем по одному")
+
+	count := 0
+	for _, instrument := range batch {
+		if err := storage.SaveInstrumentMetadata(ctx, dbpool, instrument); err != nil {
+			logger.WithFields(logrus.Fields{
+				"figi":   instrument.Figi,
+				"ticker": instrument.Ticker,
+				"type":   instrumentType,
+				"error":  err,
+			}).Error("Ошибка сохранения инструмента")
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// LoadInstrumentsByType загружает инструменты определенного типа из API и сохраняет в БД пачками по batchSize
 func LoadInstrumentsByType(
 	ctx context.Context,
 	client *investgo.Client,
 	dbpool *pgxpool.Pool,
-	instrumentType string,
+	instrumentType config.InstrumentType,
+	status pb.InstrumentStatus,
 	dataSourceID *int32,
+	batchSize int,
 	logger *logrus.Logger,
 ) error {
 	instrumentsClient := client.NewInstrumentsServiceClient()
 
 	// Получаем инструменты в зависимости от типа
 	switch instrumentType {
-	case "share":
-		response, err := instrumentsClient.Shares(pb.InstrumentStatus_INSTRUMENT_STATUS_ALL)
+	case config.InstrumentTypeShare:
+		response, err := instrumentsClient.Shares(status)
 		if err != nil {
 			return fmt.Errorf("ошибка загрузки акций: %w", err)
 		}
-		return processInstruments(ctx, client, response.Instruments, instrumentType, dataSourceID, dbpool, logger)
-	case "bond":
-		response, err := instrumentsClient.Bonds(pb.InstrumentStatus_INSTRUMENT_STATUS_ALL)
+		return processInstruments(ctx, client, response.Instruments, instrumentType, dataSourceID, dbpool, batchSize, logger)
+	case config.InstrumentTypeBond:
+		response, err := instrumentsClient.Bonds(status)
 		if err != nil {
 			return fmt.Errorf("ошибка загрузки облигаций: %w", err)
 		}
-		return processInstruments(ctx, client, response.Instruments, instrumentType, dataSourceID, dbpool, logger)
-	case "etf":
-		response, err := instrumentsClient.Etfs(pb.InstrumentStatus_INSTRUMENT_STATUS_ALL)
+		return processInstruments(ctx, client, response.Instruments, instrumentType, dataSourceID, dbpool, batchSize, logger)
+	case config.InstrumentTypeEtf:
+		response, err := instrumentsClient.Etfs(status)
 		if err != nil {
 			return fmt.Errorf("ошибка загрузки ETF: %w", err)
 		}
-		return processInstruments(ctx, client, response.Instruments, instrumentType, dataSourceID, dbpool, logger)
+		return processInstruments(ctx, client, response.Instruments, instrumentType, dataSourceID, dbpool, batchSize, logger)
+	case config.InstrumentTypeCurrency:
+		response, err := instrumentsClient.Currencies(status)
+		if err != nil {
+			return fmt.Errorf("ошибка загрузки валют: %w", err)
+		}
+		if err := processInstruments(ctx, client, response.Instruments, instrumentType, dataSourceID, dbpool, batchSize, logger); err != nil {
+			return err
+		}
+		return saveCurrencyPairs(ctx, dbpool, response.Instruments, logger)
 	default:
 		return fmt.Errorf("неподдерживаемый тип инструмента: %s", instrumentType)
 	}
 }
 
+// saveCurrencyPairs сохраняет валютные пары (iso_currency_name/currency) для пересчёта курсов
+func saveCurrencyPairs(ctx context.Context, dbpool *pgxpool.Pool, currencies []*pb.Currency, logger *logrus.Logger) error {
+	count := 0
+	for _, currency := range currencies {
+		if !config.IsNormalTrading(currency.GetTradingStatus()) {
+			continue
+		}
+		base := currency.GetIsoCurrencyName()
+		quote := currency.GetCurrency()
+		if base == "" || quote == "" || base == quote {
+			continue
+		}
+
+		pair := storage.CurrencyPair{
+			Base:    base,
+			Quote:   quote,
+			Figi:    currency.GetFigi(),
+			Nominal: money.ConvertMoneyValueToFloat(currency.GetNominal()),
+		}
+		if err := storage.SaveCurrencyPair(ctx, dbpool, pair); err != nil {
+			logger.WithFields(logrus.Fields{
+				"figi":  pair.Figi,
+				"pair":  base + "/" + quote,
+				"error": err,
+			}).Error("Ошибка сохранения валютной пары")
+			continue
+		}
+		count++
+	}
+
+	logger.WithField("count", count).Info("Валютные пары сохранены")
+	return nil
+}
+
 // GetOrCreateTInvestDataSource получает или создает запись источника данных T-Invest
 func GetOrCreateTInvestDataSource(ctx context.Context, dbpool *pgxpool.Pool) (*int32, error) {
 	// Сначала пытаемся найти существующую запись
@@ -251,3 +395,86 @@ func GetOrCreateTInvestDataSource(ctx context.Context, dbpool *pgxpool.Pool) (*i
 
 	return &dataSourceID, nil
 }
+
+// ResolveInstrument находит один инструмент в API по FIGI или тикеру (FindInstrument),
+// загружает его полные данные и сохраняет в БД без загрузки всего справочника.
+// Поддерживаются акции, облигации и ETF
+func ResolveInstrument(
+	ctx context.Context,
+	client *investgo.Client,
+	dbpool *pgxpool.Pool,
+	query string,
+	logger *logrus.Logger,
+) (*storage.Instrument, error) {
+	instrumentsClient := client.NewInstrumentsServiceClient()
+
+	found, err := instrumentsClient.FindInstrument(query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска инструмента %s: %w", query, err)
+	}
+
+	// Точное совпадение FIGI важнее совпадения тикера (тикер может быть на нескольких биржах)
+	var match *pb.InstrumentShort
+	for _, candidate := range found.GetInstruments() {
+		if candidate.GetFigi() == query {
+			match = candidate
+			break
+		}
+		if match == nil && strings.EqualFold(candidate.GetTicker(), query) {
+			match = candidate
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("инструмент %s не найден в API", query)
+	}
+
+	// Тип из API приводится к каноническому виду (share, bond, etf)
+	matchType, _ := config.ParseInstrumentType(match.GetInstrumentType())
+
+	var protoInstrument interface{}
+	switch matchType {
+	case config.InstrumentTypeShare:
+		response, err := instrumentsClient.ShareByFigi(match.GetFigi())
+		if err != nil {
+			return nil, fmt.Errorf("ошибка загрузки акции %s: %w", match.GetFigi(), err)
+		}
+		protoInstrument = response.GetInstrument()
+	case config.InstrumentTypeBond:
+		response, err := instrumentsClient.BondByFigi(match.GetFigi())
+		if err != nil {
+			return nil, fmt.Errorf("ошибка загрузки облигации %s: %w", match.GetFigi(), err)
+		}
+		protoInstrument = response.GetInstrument()
+	case config.InstrumentTypeEtf:
+		response, err := instrumentsClient.EtfByFigi(match.GetFigi())
+		if err != nil {
+			return nil, fmt.Errorf("ошибка загрузки ETF %s: %w", match.GetFigi(), err)
+		}
+		protoInstrument = response.GetInstrument()
+	default:
+		return nil, fmt.Errorf("тип инструмента %s (%s) не поддерживается для точечной загрузки",
+			match.GetInstrumentType(), match.GetFigi())
+	}
+
+	dataSourceID, err := GetOrCreateTInvestDataSource(ctx, dbpool)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения источника данных T-Invest: %w", err)
+	}
+
+	instrument, err := CreateInstrumentFromProto(protoInstrument, *dataSourceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.SaveInstrumentMetadata(ctx, dbpool, *instrument); err != nil {
+		return nil, err
+	}
+
+	logger.WithFields(logrus.Fields{
+		"query":  query,
+		"figi":   instrument.Figi,
+		"ticker": instrument.Ticker,
+		"type":   instrument.InstrumentType,
+	}).Info("Инструмент загружен из API")
+
+	return instrument, nil
+}
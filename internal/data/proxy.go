@@ -0,0 +1,54 @@
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"market-loader/pkg/config"
+)
+
+// ConfigureProxy настраивает HTTP CONNECT прокси для запросов к API из tinvest.proxy и tinvest.no_proxy.
+// gRPC клиент SDK и HTTP клиенты (архивы) берут прокси из HTTPS_PROXY и NO_PROXY,
+// поэтому настройки выставляются в переменные окружения до первого подключения.
+// Возвращает прокси для endpoint API (без пароля) или пустую строку, если прокси не используется
+func ConfigureProxy(cfg *config.Config) (string, error) {
+	proxyURL, err := cfg.GetProxyURL()
+	if err != nil {
+		return "", err
+	}
+	if proxyURL != nil {
+		if err := os.Setenv("HTTPS_PROXY", proxyURL.String()); err != nil {
+			return "", err
+		}
+	}
+	if noProxy := strings.TrimSpace(cfg.Tinvest.NoProxy); noProxy != "" {
+		if err := os.Setenv("NO_PROXY", noProxy); err != nil {
+			return "", err
+		}
+	}
+
+	return effectiveProxy(cfg.Tinvest.Endpoint), nil
+}
+
+// effectiveProxy возвращает прокси, через который пойдёт подключение к endpoint (без пароля)
+func effectiveProxy(endpoint string) string {
+	if endpoint == "" {
+		return ""
+	}
+	req := &http.Request{URL: &url.URL{Scheme: "https", Host: endpoint}}
+	proxyURL, err := http.ProxyFromEnvironment(req)
+	if err != nil || proxyURL == nil {
+		return ""
+	}
+	return proxyURL.Redacted()
+}
@@ -0,0 +1,129 @@
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultAPIRetries количество повторов запроса к API при временной ошибке по умолчанию
+	DefaultAPIRetries = 2
+	// DefaultAPIRetryDelay задержка перед первым повтором (далее удваивается)
+	DefaultAPIRetryDelay = time.Second
+)
+
+var (
+	apiRetryMu    sync.RWMutex
+	apiRetries    = DefaultAPIRetries
+	apiRetryDelay = DefaultAPIRetryDelay
+	// apiRateLimitPause пауза rate_limit_pause между запросами, которую выдерживают и повторы
+	apiRateLimitPause func() time.Duration
+)
+
+// SetAPIRetryPolicy задает количество повторов и начальную задержку для запросов к API
+// retries = 0 - без повторов
+func SetAPIRetryPolicy(retries int, delay time.Duration) {
+	apiRetryMu.Lock()
+	defer apiRetryMu.Unlock()
+
+	if retries < 0 {
+		retries = 0
+	}
+	apiRetries = retries
+	apiRetryDelay = delay
+}
+
+// SetAPIRateLimitPause задает источник паузы rate_limit_pause для повторов запросов
+// (функция, чтобы учитывалась пауза, изменённая при перечитывании конфигурации)
+func SetAPIRateLimitPause(pause func() time.Duration) {
+	apiRetryMu.Lock()
+	defer apiRetryMu.Unlock()
+	apiRateLimitPause = pause
+}
+
+func getAPIRetryPolicy() (int, time.Duration) {
+	apiRetryMu.RLock()
+	defer apiRetryMu.RUnlock()
+	return apiRetries, apiRetryDelay
+}
+
+func getAPIRateLimitPause() time.Duration {
+	apiRetryMu.RLock()
+	pause := apiRateLimitPause
+	apiRetryMu.RUnlock()
+	if pause == nil {
+		return 0
+	}
+	return pause()
+}
+
+// IsRetryableAPIError проверяет, что ошибка API временная и запрос можно повторить:
+// недоступность сервиса (Unavailable), таймаут (DeadlineExceeded), превышение лимита запросов
+// (ResourceExhausted), прерванный запрос (Aborted). Ошибки без gRPC статуса (проверки, БД, контекст) не повторяются
+func IsRetryableAPIError(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// withAPIRetry выполняет запрос к API клиентом client, повторяя его при временных ошибках с нарастающей задержкой.
+// Каждый повтор выдерживает паузу rate_limit_pause токена (через пул клиентов, если он используется),
+// при budgeted повтор учитывается в бюджете запросов, и при исчерпании бюджета возвращается ErrBudgetExhausted.
+// Ошибка недействительного токена возвращается как ErrInvalidToken
+func withAPIRetry(ctx context.Context, client *investgo.Client, budgeted bool, op func() error) error {
+	retries, delay := getAPIRetryPolicy()
+
+	err := op()
+	for attempt := 1; attempt <= retries && IsRetryableAPIError(err); attempt++ {
+		if budgeted && !TakeRequest() {
+			return fmt.Errorf("%w: %w", ErrBudgetExhausted, wrapAuthError(err, false))
+		}
+		if waitRetry(ctx, client, delay) != nil {
+			return wrapAuthError(err, false)
+		}
+		delay *= 2
+
+		err = op()
+	}
+	return wrapAuthError(err, false)
+}
+
+// waitRetry ждёт задержку перед повтором, но не меньше паузы rate_limit_pause.
+// При пуле клиентов пауза токена выдерживается пулом, и время повтора резервируется за токеном
+func waitRetry(ctx context.Context, client *investgo.Client, delay time.Duration) error {
+	pool := activeClientPool()
+	if pause := getAPIRateLimitPause(); pool == nil && pause > delay {
+		delay = pause
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+	}
+
+	if pool != nil {
+		return pool.Reserve(ctx, client)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsRetryableAPIError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"unavailable", status.Error(codes.Unavailable, "unavailable"), true},
+		{"deadline exceeded", status.Error(codes.DeadlineExceeded, "timeout"), true},
+		{"resource exhausted", status.Error(codes.ResourceExhausted, "rate limit"), true},
+		{"aborted", status.Error(codes.Aborted, "aborted"), true},
+		{"wrapped unavailable", fmt.Errorf("запрос: %w", status.Error(codes.Unavailable, "unavailable")), true},
+		{"invalid argument", status.Error(codes.InvalidArgument, "bad request"), false},
+		{"permission denied", status.Error(codes.PermissionDenied, "forbidden"), false},
+		{"unknown status", status.Error(codes.Unknown, "unknown"), false},
+		{"plain error", errors.New("ошибка проверки"), false},
+		{"context canceled", context.Canceled, false},
+		{"context deadline", context.DeadlineExceeded, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryableAPIError(tt.err); got != tt.want {
+				t.Errorf("IsRetryableAPIError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
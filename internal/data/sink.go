@@ -0,0 +1,95 @@
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"context"
+	"time"
+
+	"market-loader/internal/money"
+	"market-loader/internal/storage"
+	"market-loader/pkg/config"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// CandleSink публикует сохранённые в БД свечи во внешнюю систему (брокер сообщений)
+type CandleSink interface {
+	Publish(ctx context.Context, figi, intervalType string, candles []*pb.HistoricCandle) error
+	// Close отправляет оставшиеся сообщения и закрывает подключение
+	Close() error
+}
+
+// noopSink свечи никуда не публикуются (по умолчанию)
+type noopSink struct{}
+
+// Publish ничего не делает
+func (noopSink) Publish(context.Context, string, string, []*pb.HistoricCandle) error {
+	return nil
+}
+
+// Close ничего не делает
+func (noopSink) Close() error {
+	return nil
+}
+
+// CandleMessage сообщение о свече для брокера (JSON)
+type CandleMessage struct {
+	Figi     string    `json:"figi"`
+	Interval string    `json:"interval"`
+	Time     time.Time `json:"time"`
+	Open     string    `json:"open"`
+	High     string    `json:"high"`
+	Low      string    `json:"low"`
+	Close    string    `json:"close"`
+	Volume   int64     `json:"volume"`
+}
+
+// newCandleMessage создает сообщение о свече (цены - десятичные строки без потери точности)
+func newCandleMessage(figi, intervalType string, candle *pb.HistoricCandle) CandleMessage {
+	return CandleMessage{
+		Figi:     figi,
+		Interval: config.Interval2text(intervalType),
+		Time:     candle.GetTime().AsTime(),
+		Open:     money.ConvertMoneyValue(candle.GetOpen().GetUnits(), candle.GetOpen().GetNano()),
+		High:     money.ConvertMoneyValue(candle.GetHigh().GetUnits(), candle.GetHigh().GetNano()),
+		Low:      money.ConvertMoneyValue(candle.GetLow().GetUnits(), candle.GetLow().GetNano()),
+		Close:    money.ConvertMoneyValue(candle.GetClose().GetUnits(), candle.GetClose().GetNano()),
+		Volume:   candle.GetVolume(),
+	}
+}
+
+// NewCandleSink создает публикацию свечей по конфигурации: sink.kafka.brokers - Kafka, иначе no-op
+func NewCandleSink(cfg *config.Config) (CandleSink, error) {
+	if len(cfg.Sink.Kafka.Brokers) == 0 {
+		return noopSink{}, nil
+	}
+	return newKafkaSink(cfg.Sink.Kafka.Brokers, cfg.GetSinkTopic())
+}
+
+// SetCandleSink подключает публикацию к сохранению свечей: свечи публикуются после успешной записи в БД.
+// Ошибка публикации не прерывает загрузку, только логируется
+func SetCandleSink(sink CandleSink, logger *logrus.Logger) {
+	if _, ok := sink.(noopSink); ok || sink == nil {
+		storage.SetSaveHook(nil)
+		return
+	}
+
+	storage.SetSaveHook(func(figi, intervalType string, candles []*pb.HistoricCandle) {
+		if err := sink.Publish(context.Background(), figi, intervalType, candles); err != nil {
+			logger.WithFields(logrus.Fields{
+				"figi":         figi,
+				"intervalType": intervalType,
+				"candles":      len(candles),
+				"error":        err,
+			}).Warn("Ошибка публикации свечей")
+		}
+	})
+}
@@ -0,0 +1,62 @@
+//go:build kafka
+
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaSink публикует свечи в топик Kafka, ключ сообщения - FIGI
+type kafkaSink struct {
+	writer *kafka.Writer
+}
+
+// newKafkaSink создает публикацию свечей в Kafka
+func newKafkaSink(brokers []string, topic string) (CandleSink, error) {
+	return &kafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{}, // свечи одного инструмента - в одну партицию, по порядку
+			RequiredAcks: kafka.RequireAll,
+		},
+	}, nil
+}
+
+// Publish отправляет свечи пачкой, по сообщению на свечу
+func (s *kafkaSink) Publish(ctx context.Context, figi, intervalType string, candles []*pb.HistoricCandle) error {
+	messages := make([]kafka.Message, 0, len(candles))
+	for _, candle := range candles {
+		value, err := json.Marshal(newCandleMessage(figi, intervalType, candle))
+		if err != nil {
+			return fmt.Errorf("ошибка кодирования свечи: %w", err)
+		}
+		messages = append(messages, kafka.Message{Key: []byte(figi), Value: value})
+	}
+
+	if err := s.writer.WriteMessages(ctx, messages...); err != nil {
+		return fmt.Errorf("ошибка отправки свечей в Kafka: %w", err)
+	}
+	return nil
+}
+
+// Close закрывает подключения к брокерам
+func (s *kafkaSink) Close() error {
+	if err := s.writer.Close(); err != nil {
+		return fmt.Errorf("ошибка закрытия публикации в Kafka: %w", err)
+	}
+	return nil
+}
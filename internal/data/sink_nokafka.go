@@ -0,0 +1,18 @@
+//go:build !kafka
+
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import "errors"
+
+// newKafkaSink без тега сборки kafka публикация недоступна
+func newKafkaSink(_ []string, _ string) (CandleSink, error) {
+	return nil, errors.New("поддержка Kafka не включена при сборке (соберите с BUILD_TAGS=kafka)")
+}
@@ -0,0 +1,130 @@
+// Package data - Запросы в API и обработка данных
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package data
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"market-loader/internal/money"
+	"market-loader/pkg/config"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+)
+
+const (
+	// TransformDropZeroVolume удаляет свечи с нулевым объёмом
+	TransformDropZeroVolume = "drop_zero_volume"
+	// TransformClampOutliers ограничивает high/low относительно open/close
+	TransformClampOutliers = "clamp_outliers"
+)
+
+// CandleTransformer преобразует свечи перед сохранением в БД
+type CandleTransformer interface {
+	Transform(figi string, candles []*pb.HistoricCandle) []*pb.HistoricCandle
+}
+
+// CandleTransformerFunc функция как CandleTransformer
+type CandleTransformerFunc func(figi string, candles []*pb.HistoricCandle) []*pb.HistoricCandle
+
+// Transform вызывает функцию
+func (f CandleTransformerFunc) Transform(figi string, candles []*pb.HistoricCandle) []*pb.HistoricCandle {
+	return f(figi, candles)
+}
+
+// TransformChain последовательно применяет преобразования
+type TransformChain []CandleTransformer
+
+// Transform применяет преобразования по порядку
+func (c TransformChain) Transform(figi string, candles []*pb.HistoricCandle) []*pb.HistoricCandle {
+	for _, transformer := range c {
+		candles = transformer.Transform(figi, candles)
+	}
+	return candles
+}
+
+var (
+	transformerMu sync.RWMutex
+	transformer   CandleTransformer
+)
+
+// SetCandleTransformer задаёт преобразование свечей для всех загрузчиков (nil - без изменений)
+func SetCandleTransformer(t CandleTransformer) {
+	transformerMu.Lock()
+	defer transformerMu.Unlock()
+	transformer = t
+}
+
+// TransformCandles применяет заданное преобразование; без преобразования возвращает свечи как есть
+func TransformCandles(figi string, candles []*pb.HistoricCandle) []*pb.HistoricCandle {
+	transformerMu.RLock()
+	t := transformer
+	transformerMu.RUnlock()
+
+	if t == nil {
+		return candles
+	}
+	return t.Transform(figi, candles)
+}
+
+// NewCandleTransformer собирает цепочку встроенных преобразований по именам из конфигурации
+// пустой список - nil (без преобразований)
+func NewCandleTransformer(names []string, cfg *config.Config) (CandleTransformer, error) {
+	var chain TransformChain
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case TransformDropZeroVolume:
+			chain = append(chain, CandleTransformerFunc(dropZeroVolume))
+		case TransformClampOutliers:
+			chain = append(chain, clampOutliers(cfg.GetClampOutlierRatio()))
+		default:
+			return nil, fmt.Errorf("неизвестное преобразование свечей: %q (доступны: %s, %s)",
+				name, TransformDropZeroVolume, TransformClampOutliers)
+		}
+	}
+
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+// dropZeroVolume удаляет свечи без сделок
+func dropZeroVolume(_ string, candles []*pb.HistoricCandle) []*pb.HistoricCandle {
+	result := candles[:0:0]
+	for _, candle := range candles {
+		if candle.GetVolume() > 0 {
+			result = append(result, candle)
+		}
+	}
+	return result
+}
+
+// clampOutliers ограничивает high сверху и low снизу долей ratio от max/min(open, close)
+func clampOutliers(ratio float64) CandleTransformer {
+	return CandleTransformerFunc(func(_ string, candles []*pb.HistoricCandle) []*pb.HistoricCandle {
+		for _, candle := range candles {
+			open := money.ConvertQuotationToFloat(candle.GetOpen())
+			closePrice := money.ConvertQuotationToFloat(candle.GetClose())
+
+			maxHigh := math.Max(open, closePrice) * (1 + ratio)
+			if money.ConvertQuotationToFloat(candle.GetHigh()) > maxHigh {
+				candle.High = money.FloatToQuotation(maxHigh)
+			}
+
+			minLow := math.Min(open, closePrice) * (1 - ratio)
+			if money.ConvertQuotationToFloat(candle.GetLow()) < minLow {
+				candle.Low = money.FloatToQuotation(minLow)
+			}
+		}
+		return candles
+	})
+}
@@ -0,0 +1,304 @@
+// Package export содержит функции выгрузки данных из БД в CSV/JSON
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"market-loader/internal/storage"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// FormatCSV выгрузка в CSV
+	FormatCSV = "csv"
+	// FormatJSON выгрузка в JSON (массив объектов)
+	FormatJSON = "json"
+)
+
+// ValidateFormat проверяет формат выгрузки
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatCSV, FormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("неподдерживаемый формат выгрузки: %s", format)
+	}
+}
+
+// recordWriter пишет записи в выбранном формате
+type recordWriter struct {
+	format string
+	csv    *csv.Writer
+	out    io.Writer
+	count  int
+}
+
+func newRecordWriter(w io.Writer, format string, header []string) (*recordWriter, error) {
+	rw := &recordWriter{format: format, out: w}
+
+	switch format {
+	case FormatCSV:
+		rw.csv = csv.NewWriter(w)
+		if err := rw.csv.Write(header); err != nil {
+			return nil, fmt.Errorf("ошибка записи заголовка CSV: %w", err)
+		}
+	case FormatJSON:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return nil, fmt.Errorf("ошибка записи JSON: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("неподдерживаемый формат выгрузки: %s", format)
+	}
+
+	return rw, nil
+}
+
+// write пишет одну запись: row для CSV, obj для JSON
+func (rw *recordWriter) write(row []string, obj interface{}) error {
+	rw.count++
+
+	if rw.format == FormatCSV {
+		if err := rw.csv.Write(row); err != nil {
+			return fmt.Errorf("ошибка записи CSV: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации JSON: %w", err)
+	}
+	if rw.count > 1 {
+		if _, err := io.WriteString(rw.out, ",\n"); err != nil {
+			return fmt.Errorf("ошибка записи JSON: %w", err)
+		}
+	}
+	if _, err := rw.out.Write(data); err != nil {
+		return fmt.Errorf("ошибка записи JSON: %w", err)
+	}
+	return nil
+}
+
+// close завершает выгрузку
+func (rw *recordWriter) close() error {
+	if rw.format == FormatCSV {
+		rw.csv.Flush()
+		if err := rw.csv.Error(); err != nil {
+			return fmt.Errorf("ошибка записи CSV: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := io.WriteString(rw.out, "]\n"); err != nil {
+		return fmt.Errorf("ошибка записи JSON: %w", err)
+	}
+	return nil
+}
+
+// formatFloat форматирует число без потери точности и лишних нулей
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// candleColumn колонка выгрузки свечей: значение для CSV и для JSON
+type candleColumn struct {
+	text  func(candle storage.Candle) string
+	value func(candle storage.Candle) interface{}
+}
+
+// typicalPrice типичная цена свечи (high + low + close) / 3
+func typicalPrice(candle storage.Candle) float64 {
+	return (candle.HighPrice + candle.LowPrice + candle.ClosePrice) / 3
+}
+
+// candleColumns доступные колонки выгрузки свечей (typical вычисляется на лету)
+var candleColumns = map[string]candleColumn{
+	"figi": {
+		text:  func(c storage.Candle) string { return c.FIGI },
+		value: func(c storage.Candle) interface{} { return c.FIGI },
+	},
+	"time": {
+		text:  func(c storage.Candle) string { return c.Time.UTC().Format(time.RFC3339) },
+		value: func(c storage.Candle) interface{} { return c.Time.UTC().Format(time.RFC3339) },
+	},
+	"open": {
+		text:  func(c storage.Candle) string { return formatFloat(c.OpenPrice) },
+		value: func(c storage.Candle) interface{} { return c.OpenPrice },
+	},
+	"high": {
+		text:  func(c storage.Candle) string { return formatFloat(c.HighPrice) },
+		value: func(c storage.Candle) interface{} { return c.HighPrice },
+	},
+	"low": {
+		text:  func(c storage.Candle) string { return formatFloat(c.LowPrice) },
+		value: func(c storage.Candle) interface{} { return c.LowPrice },
+	},
+	"close": {
+		text:  func(c storage.Candle) string { return formatFloat(c.ClosePrice) },
+		value: func(c storage.Candle) interface{} { return c.ClosePrice },
+	},
+	"volume": {
+		text:  func(c storage.Candle) string { return strconv.FormatInt(c.Volume, 10) },
+		value: func(c storage.Candle) interface{} { return c.Volume },
+	},
+	"interval_type": {
+		text:  func(c storage.Candle) string { return c.IntervalType },
+		value: func(c storage.Candle) interface{} { return c.IntervalType },
+	},
+	"typical": {
+		text:  func(c storage.Candle) string { return formatFloat(typicalPrice(c)) },
+		value: func(c storage.Candle) interface{} { return typicalPrice(c) },
+	},
+}
+
+// DefaultCandleColumns колонки выгрузки свечей по умолчанию
+var DefaultCandleColumns = []string{"figi", "time", "open", "high", "low", "close", "volume", "interval_type"}
+
+// ParseCandleColumns разбирает список колонок через запятую и проверяет имена
+// пустая строка - колонки по умолчанию
+func ParseCandleColumns(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return DefaultCandleColumns, nil
+	}
+
+	var columns []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, exists := candleColumns[name]; !exists {
+			return nil, fmt.Errorf("неизвестная колонка %q (доступны: %s, typical)", name, strings.Join(DefaultCandleColumns, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("колонка %q указана несколько раз", name)
+		}
+		seen[name] = true
+		columns = append(columns, name)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("не указано ни одной колонки")
+	}
+	return columns, nil
+}
+
+// candleRecord JSON-объект свечи с колонками в заданном порядке
+type candleRecord struct {
+	columns []string
+	candle  storage.Candle
+}
+
+// MarshalJSON сериализует только выбранные колонки, сохраняя их порядок
+func (r candleRecord) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range r.columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(candleColumns[name].value(r.candle))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Candles выгружает свечи инструментов за период одной выгрузкой (по инструментам подряд),
+// возвращает количество записей. columns - колонки из ParseCandleColumns, nil - все колонки в прежнем формате
+func Candles(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	w io.Writer,
+	format string,
+	figis []string,
+	intervalType string,
+	from, to time.Time,
+	columns []string,
+) (int, error) {
+	header := columns
+	if header == nil {
+		header = DefaultCandleColumns
+	}
+	rw, err := newRecordWriter(w, format, header)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, figi := range figis {
+		err = storage.StreamCandles(ctx, dbpool, figi, intervalType, from, to, func(candle storage.Candle) error {
+			row := make([]string, 0, len(header))
+			for _, name := range header {
+				row = append(row, candleColumns[name].text(candle))
+			}
+			// Без выбора колонок JSON совпадает с прежним форматом (поля storage.Candle)
+			if columns == nil {
+				return rw.write(row, candle)
+			}
+			return rw.write(row, candleRecord{columns: columns, candle: candle})
+		})
+		if err != nil {
+			return rw.count, fmt.Errorf("ошибка выгрузки свечей %s: %w", figi, err)
+		}
+	}
+
+	return rw.count, rw.close()
+}
+
+// Dividends выгружает дивиденды, возвращает количество записей
+func Dividends(w io.Writer, format string, dividends []storage.Dividend) (int, error) {
+	header := []string{"figi", "payment_date", "declared_date", "amount", "currency", "yield_percent"}
+	rw, err := newRecordWriter(w, format, header)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, dividend := range dividends {
+		declaredDate := ""
+		if dividend.DeclaredDate != nil {
+			declaredDate = dividend.DeclaredDate.UTC().Format("2006-01-02")
+		}
+		yieldPercent := ""
+		if dividend.YieldPercent != nil {
+			yieldPercent = formatFloat(*dividend.YieldPercent)
+		}
+
+		if err := rw.write([]string{
+			dividend.Figi,
+			dividend.PaymentDate.UTC().Format("2006-01-02"),
+			declaredDate,
+			formatFloat(dividend.Amount),
+			dividend.Currency,
+			yieldPercent,
+		}, dividend); err != nil {
+			return rw.count, err
+		}
+	}
+
+	return rw.count, rw.close()
+}
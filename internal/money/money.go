@@ -10,6 +10,7 @@ package money
 
 import (
 	"fmt"
+	"math"
 
 	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
 )
@@ -54,3 +55,12 @@ func ConvertMoneyValueToFloat(m *pb.MoneyValue) float64 {
 	}
 	return float64(m.Units) + float64(m.Nano)/1e9
 }
+
+// FloatToQuotation преобразует число в Quotation с точностью до нано
+func FloatToQuotation(value float64) *pb.Quotation {
+	nanoTotal := int64(math.Round(value * 1e9))
+	return &pb.Quotation{
+		Units: nanoTotal / 1e9,
+		Nano:  int32(nanoTotal % 1e9),
+	}
+}
@@ -0,0 +1,57 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AccruedInterest накопленный купонный доход (НКД) облигации на дату
+type AccruedInterest struct {
+	Figi     string    `json:"figi"`
+	Date     time.Time `json:"date"`
+	Value    float64   `json:"value"`
+	Currency string    `json:"currency"`
+}
+
+// SaveAccruedInterest сохраняет НКД облигации на дату
+// если валюта не указана, используется валюта инструмента
+func SaveAccruedInterest(ctx context.Context, dbpool *pgxpool.Pool, aci AccruedInterest) error {
+	query := `
+		INSERT INTO accrued_interest (figi, date, value, currency)
+		VALUES ($1, $2, $3,
+			COALESCE(NULLIF($4, ''), (SELECT NULLIF(currency, '') FROM instruments WHERE figi = $1)))
+		ON CONFLICT (figi, date) DO UPDATE SET
+			value = EXCLUDED.value,
+			currency = EXCLUDED.currency
+	`
+
+	err := execWithRetry(ctx, dbpool, query, aci.Figi, aci.Date, aci.Value, aci.Currency)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения НКД: %w", err)
+	}
+	return nil
+}
+
+// GetLastAccruedInterestDate получает последнюю дату сохранённого НКД облигации (нулевое время - записей нет)
+func GetLastAccruedInterestDate(ctx context.Context, dbpool *pgxpool.Pool, figi string) (time.Time, error) {
+	var lastDate sql.NullTime
+	if err := dbpool.QueryRow(ctx, `SELECT MAX(date) FROM accrued_interest WHERE figi = $1`, figi).Scan(&lastDate); err != nil {
+		return time.Time{}, fmt.Errorf("ошибка получения последней даты НКД: %w", err)
+	}
+	if !lastDate.Valid {
+		return time.Time{}, nil
+	}
+	return lastDate.Time, nil
+}
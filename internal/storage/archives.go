@@ -0,0 +1,63 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GetArchiveHash возвращает SHA-256 последнего обработанного архива инструмента за год (пусто - не обрабатывался)
+func GetArchiveHash(ctx context.Context, dbpool *pgxpool.Pool, figi string, year int) (string, error) {
+	var hash string
+	err := dbpool.QueryRow(ctx, `SELECT sha256 FROM archive_files WHERE figi = $1 AND year = $2`, figi, year).Scan(&hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения хеша архива: %w", err)
+	}
+	return hash, nil
+}
+
+// SaveArchiveHash сохраняет SHA-256 обработанного архива инструмента за год
+func SaveArchiveHash(ctx context.Context, dbpool *pgxpool.Pool, figi string, year int, hash string, candles int) error {
+	_, err := dbpool.Exec(ctx, `
+		INSERT INTO archive_files (figi, year, sha256, candles, processed_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (figi, year) DO UPDATE SET
+			sha256 = EXCLUDED.sha256,
+			candles = EXCLUDED.candles,
+			processed_at = EXCLUDED.processed_at
+	`, figi, year, hash, candles)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения хеша архива: %w", err)
+	}
+	return nil
+}
+
+// ClearArchiveHashes удаляет хеши архивов инструмента, чтобы архивы были обработаны заново
+func ClearArchiveHashes(ctx context.Context, dbpool *pgxpool.Pool, figi string) error {
+	if _, err := dbpool.Exec(ctx, `DELETE FROM archive_files WHERE figi = $1`, figi); err != nil {
+		return fmt.Errorf("ошибка удаления хешей архивов: %w", err)
+	}
+	return nil
+}
+
+// ClearArchiveHash удаляет хеш архива инструмента за год, чтобы архив был обработан заново
+func ClearArchiveHash(ctx context.Context, dbpool *pgxpool.Pool, figi string, year int) error {
+	if _, err := dbpool.Exec(ctx, `DELETE FROM archive_files WHERE figi = $1 AND year = $2`, figi, year); err != nil {
+		return fmt.Errorf("ошибка удаления хеша архива: %w", err)
+	}
+	return nil
+}
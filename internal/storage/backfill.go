@@ -0,0 +1,56 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BackfillState прогресс загрузки истории от новых свечей к старым (loading.order: newest)
+type BackfillState struct {
+	Oldest   time.Time // история загружена начиная с этого времени (нулевое - загрузка не начиналась)
+	Complete bool      // история загружена до даты начала загрузки
+}
+
+// GetBackfillState возвращает прогресс загрузки истории инструмента по интервалу
+func GetBackfillState(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string) (BackfillState, error) {
+	var state BackfillState
+	err := dbpool.QueryRow(ctx, `
+		SELECT oldest_time, complete FROM candle_backfill WHERE figi = $1 AND interval_type = $2
+	`, figi, intervalType).Scan(&state.Oldest, &state.Complete)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return BackfillState{}, nil
+	}
+	if err != nil {
+		return BackfillState{}, fmt.Errorf("ошибка получения прогресса загрузки истории: %w", err)
+	}
+	return state, nil
+}
+
+// SaveBackfillState сохраняет прогресс загрузки истории инструмента по интервалу
+func SaveBackfillState(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string, state BackfillState) error {
+	_, err := dbpool.Exec(ctx, `
+		INSERT INTO candle_backfill (figi, interval_type, oldest_time, complete, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (figi, interval_type) DO UPDATE SET
+			oldest_time = EXCLUDED.oldest_time,
+			complete = EXCLUDED.complete,
+			updated_at = EXCLUDED.updated_at
+	`, figi, intervalType, state.Oldest, state.Complete)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения прогресса загрузки истории: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// bufferKey ключ буфера: свечи одного инструмента и интервала
+type bufferKey struct {
+	figi         string
+	intervalType string
+}
+
+// candleBuffer буфер отложенной записи свечей (write-behind)
+// свечи накапливаются между чанками и сохраняются при достижении size
+// или по истечении flushInterval с последнего сброса (проверяется при следующей записи, таймера нет)
+type candleBuffer struct {
+	mu            sync.Mutex
+	size          int
+	flushInterval time.Duration
+	pending       map[bufferKey][]*pb.HistoricCandle
+	count         int
+	lastFlush     time.Time
+}
+
+// writeBuffer буфер отложенной записи (size = 0 - выключен)
+var writeBuffer = &candleBuffer{pending: make(map[bufferKey][]*pb.HistoricCandle)}
+
+// SetWriteBuffer включает буфер отложенной записи свечей
+// size = 0 - буфер выключен, свечи сохраняются сразу
+func SetWriteBuffer(size int, flushInterval time.Duration) {
+	writeBuffer.mu.Lock()
+	defer writeBuffer.mu.Unlock()
+
+	if size < 0 {
+		size = 0
+	}
+	writeBuffer.size = size
+	writeBuffer.flushInterval = flushInterval
+	writeBuffer.lastFlush = time.Now()
+}
+
+// BufferCandles добавляет свечи в буфер отложенной записи и сбрасывает его
+// при достижении порога по размеру или времени. Если буфер выключен - сохраняет сразу.
+func BufferCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle, intervalType string, logger *logrus.Logger) error {
+	// Неизвестный тип интервала отклоняется сразу, а не при сбросе буфера
+	if err := validateIntervalType(intervalType); err != nil {
+		return err
+	}
+
+	writeBuffer.mu.Lock()
+	if writeBuffer.size == 0 {
+		// Без буфера сохранения разных инструментов идут параллельно, без общей блокировки
+		writeBuffer.mu.Unlock()
+		return SaveCandles(dbpool, figi, candles, intervalType, logger)
+	}
+	defer writeBuffer.mu.Unlock()
+
+	key := bufferKey{figi: figi, intervalType: intervalType}
+	writeBuffer.pending[key] = append(writeBuffer.pending[key], candles...)
+	writeBuffer.count += len(candles)
+
+	bySize := writeBuffer.count >= writeBuffer.size
+	byTime := writeBuffer.flushInterval > 0 && time.Since(writeBuffer.lastFlush) >= writeBuffer.flushInterval
+	if !bySize && !byTime {
+		return nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"buffered": writeBuffer.count,
+		"bySize":   bySize,
+		"byTime":   byTime,
+	}).Debug("Сброс буфера отложенной записи")
+
+	// Ошибки других инструментов не прерывают сброс и не возвращаются вызывающему:
+	// их свечи остаются в буфере и вернутся ошибкой из FlushCandlesFor своего инструмента
+	var ownErr error
+	for failedKey, err := range writeBuffer.flushLocked(dbpool, nil, logger) {
+		if failedKey == key {
+			ownErr = err
+			continue
+		}
+		logger.WithFields(logrus.Fields{
+			"figi":     failedKey.figi,
+			"interval": failedKey.intervalType,
+			"error":    err,
+		}).Warn("Свечи не сохранены при сбросе буфера, остаются в буфере")
+	}
+	return ownErr
+}
+
+// FlushCandles сохраняет все свечи из буфера отложенной записи
+// вызывается перед завершением загрузчика
+func FlushCandles(dbpool *pgxpool.Pool, logger *logrus.Logger) error {
+	writeBuffer.mu.Lock()
+	defer writeBuffer.mu.Unlock()
+	return joinFlushErrors(writeBuffer.flushLocked(dbpool, nil, logger))
+}
+
+// FlushCandlesFor сохраняет свечи инструмента и интервала из буфера
+// вызывается перед обновлением last_loaded_time, чтобы прогресс не опережал сохранённые данные
+func FlushCandlesFor(dbpool *pgxpool.Pool, figi, intervalType string, logger *logrus.Logger) error {
+	writeBuffer.mu.Lock()
+	defer writeBuffer.mu.Unlock()
+	return joinFlushErrors(writeBuffer.flushLocked(dbpool, &bufferKey{figi: figi, intervalType: intervalType}, logger))
+}
+
+// flushLocked сохраняет свечи из буфера (only = nil - все ключи), вызывается под mu.
+// Ошибка одного ключа не прерывает сброс остальных; возвращает ошибки по ключам
+func (b *candleBuffer) flushLocked(dbpool *pgxpool.Pool, only *bufferKey, logger *logrus.Logger) map[bufferKey]error {
+	keys := make([]bufferKey, 0, len(b.pending))
+	for key := range b.pending {
+		if only == nil || key == *only {
+			keys = append(keys, key)
+		}
+	}
+	sortBufferKeys(keys)
+
+	var errs map[bufferKey]error
+	for _, key := range keys {
+		candles := b.pending[key]
+		if err := SaveCandles(dbpool, key.figi, candles, key.intervalType, logger); err != nil {
+			// Несохранённые свечи остаются в буфере
+			if errs == nil {
+				errs = make(map[bufferKey]error)
+			}
+			errs[key] = fmt.Errorf("ошибка сброса буфера для %s (%s): %w", key.figi, key.intervalType, err)
+			continue
+		}
+		delete(b.pending, key)
+		b.count -= len(candles)
+	}
+
+	if only == nil {
+		b.lastFlush = time.Now()
+	}
+	return errs
+}
+
+// joinFlushErrors объединяет ошибки сброса по ключам в порядке ключей
+func joinFlushErrors(errs map[bufferKey]error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	keys := make([]bufferKey, 0, len(errs))
+	for key := range errs {
+		keys = append(keys, key)
+	}
+	sortBufferKeys(keys)
+
+	joined := make([]error, 0, len(keys))
+	for _, key := range keys {
+		joined = append(joined, errs[key])
+	}
+	return errors.Join(joined...)
+}
+
+// sortBufferKeys упорядочивает ключи по инструменту и интервалу
+func sortBufferKeys(keys []bufferKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].figi != keys[j].figi {
+			return keys[i].figi < keys[j].figi
+		}
+		return keys[i].intervalType < keys[j].intervalType
+	})
+}
@@ -14,6 +14,7 @@ import (
 	"errors"
 	"fmt"
 	"market-loader/internal/money"
+	"market-loader/pkg/config"
 	"strings"
 	"time"
 
@@ -34,18 +35,24 @@ type Candle struct {
 	ClosePrice   float64   `json:"close_price"`
 	Volume       int64     `json:"volume"`
 	IntervalType string    `json:"interval_type"`
+	// Источник свечи (config.CandleSource*), заполняется при чтении StreamCandles, в выгрузку не попадает
+	Source string `json:"-"`
 }
 
-// GetLastLoadedTime получает время последней загрузки из таблицы candles
+// GetLastLoadedTime получает время последней загрузки из таблицы свечей
 func GetLastLoadedTime(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string) (time.Time, error) {
-	query := `SELECT MAX(time) FROM candles WHERE figi = $1 AND interval_type = $2`
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return time.Time{}, err
+	}
+	query := fmt.Sprintf(`SELECT MAX(time) FROM %s WHERE figi = $1 AND interval_type = $2`, table)
 
 	var lastLoadedTime sql.NullTime
-	err := dbpool.QueryRow(ctx, query, figi, intervalType).Scan(&lastLoadedTime)
+	err = dbpool.QueryRow(ctx, query, figi, intervalType).Scan(&lastLoadedTime)
 
 	// Если нет данных (NULL) или ошибка
 	if err != nil {
-		return time.Time{}, fmt.Errorf("ошибка выполнения запроса к таблице candles: %w", err)
+		return time.Time{}, fmt.Errorf("ошибка выполнения запроса к таблице %s: %w", table, err)
 	}
 
 	// Если MAX(time) вернул NULL (нет свечей)
@@ -58,10 +65,14 @@ func GetLastLoadedTime(ctx context.Context, dbpool *pgxpool.Pool, figi, interval
 
 // GetEarliestCandle получает самую раннюю свечу
 func GetEarliestCandle(dbpool *pgxpool.Pool, figi, intervalType string) (time.Time, error) {
-	query := `SELECT MIN(time) FROM candles WHERE figi = $1 AND interval_type = $2`
+	table, err := candleTableFor(context.Background(), dbpool, intervalType)
+	if err != nil {
+		return time.Time{}, err
+	}
+	query := fmt.Sprintf(`SELECT MIN(time) FROM %s WHERE figi = $1 AND interval_type = $2`, table)
 
 	var earliestTime sql.NullTime
-	err := dbpool.QueryRow(context.Background(), query, figi, intervalType).Scan(&earliestTime)
+	err = dbpool.QueryRow(context.Background(), query, figi, intervalType).Scan(&earliestTime)
 
 	if err == pgx.ErrNoRows || !earliestTime.Valid {
 		return time.Time{}, nil
@@ -72,14 +83,18 @@ func GetEarliestCandle(dbpool *pgxpool.Pool, figi, intervalType string) (time.Ti
 
 // GetLastCandleTime возвращает время последней загруженной свечи для инструмента и интервала
 func GetLastCandleTime(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string) (time.Time, error) {
-	query := `
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return time.Time{}, err
+	}
+	query := fmt.Sprintf(`
 		SELECT MAX("time") 
-		FROM candles 
+		FROM %s 
 		WHERE figi = $1 AND interval_type = $2
-	`
+	`, table)
 
 	var lastTime *time.Time
-	err := dbpool.QueryRow(ctx, query, figi, intervalType).Scan(&lastTime)
+	err = dbpool.QueryRow(ctx, query, figi, intervalType).Scan(&lastTime)
 	if err != nil {
 		if err.Error() == "no rows in result set" {
 			return time.Time{}, nil // Нет данных
@@ -94,29 +109,273 @@ func GetLastCandleTime(ctx context.Context, dbpool *pgxpool.Pool, figi, interval
 	return *lastTime, nil
 }
 
+// CandleStats количество свечей и границы загруженного периода
+type CandleStats struct {
+	Count int64
+	First time.Time
+	Last  time.Time
+}
+
+// GetCandleStats возвращает количество свечей инструмента по интервалу и время первой и последней свечи
+func GetCandleStats(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string) (CandleStats, error) {
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return CandleStats{}, err
+	}
+	query := fmt.Sprintf(`
+		SELECT COUNT(*), MIN("time"), MAX("time")
+		FROM %s
+		WHERE figi = $1 AND interval_type = $2
+	`, table)
+
+	var stats CandleStats
+	var first, last sql.NullTime
+	if err := dbpool.QueryRow(ctx, query, figi, intervalType).Scan(&stats.Count, &first, &last); err != nil {
+		return CandleStats{}, fmt.Errorf("ошибка получения статистики свечей: %w", err)
+	}
+	stats.First = first.Time
+	stats.Last = last.Time
+
+	return stats, nil
+}
+
+// StreamCandles построчно читает свечи инструмента за период и передаёт их в fn
+// нулевые from/to - без ограничения, чтение прекращается при ошибке fn.
+// На каждое время передаётся одна свеча: если источников несколько, выбирается свеча
+// более предпочтительного источника (SetCandleSourcePreference). В общей таблице с первичным ключом
+// (figi, time, interval_type) повторов нет, и выбор ничего не меняет
+func StreamCandles(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	figi, intervalType string,
+	from, to time.Time,
+	fn func(Candle) error,
+) error {
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`SELECT figi, time, open_price, high_price, low_price, close_price, volume, interval_type,
+		source_file IS NOT NULL
+		FROM %s WHERE figi = $1 AND interval_type = $2`, table)
+	args := []interface{}{figi, intervalType}
+
+	if !from.IsZero() {
+		args = append(args, from)
+		query += fmt.Sprintf(" AND time >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		query += fmt.Sprintf(" AND time <= $%d", len(args))
+	}
+	query += " ORDER BY time"
+
+	rows, err := dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("ошибка запроса свечей: %w", err)
+	}
+	defer rows.Close()
+
+	// Свеча передаётся в fn, когда прочитаны все строки за её время
+	var pending *Candle
+	for rows.Next() {
+		var candle Candle
+		var fromArchive bool
+		if err := rows.Scan(
+			&candle.FIGI,
+			&candle.Time,
+			&candle.OpenPrice,
+			&candle.HighPrice,
+			&candle.LowPrice,
+			&candle.ClosePrice,
+			&candle.Volume,
+			&candle.IntervalType,
+			&fromArchive,
+		); err != nil {
+			return fmt.Errorf("ошибка сканирования свечи: %w", err)
+		}
+		candle.Source = candleSource(fromArchive)
+
+		if pending != nil && pending.Time.Equal(candle.Time) {
+			*pending = preferCandle(*pending, candle)
+			continue
+		}
+		if pending != nil {
+			if err := fn(*pending); err != nil {
+				return err
+			}
+		}
+		pending = &candle
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка итерации по свечам: %w", err)
+	}
+
+	if pending != nil {
+		return fn(*pending)
+	}
+	return nil
+}
+
+// GetCandles возвращает свечи инструмента за период, упорядоченные по времени, по одной на каждое время
+// (при нескольких источниках - по порядку предпочтения источников)
+func GetCandles(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string, from, to time.Time) ([]Candle, error) {
+	var candles []Candle
+	err := StreamCandles(ctx, dbpool, figi, intervalType, from, to, func(candle Candle) error {
+		candles = append(candles, candle)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return candles, nil
+}
+
+// GetRecentCandles возвращает последние n свечей инструмента по интервалу, упорядоченные по возрастанию времени
+func GetRecentCandles(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string, n int) ([]Candle, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return nil, err
+	}
+	// Выборка с конца по первичному ключу (figi, time, interval_type): interval_type проверяется в индексе
+	query := fmt.Sprintf(`SELECT figi, time, open_price, high_price, low_price, close_price, volume, interval_type
+		FROM %s WHERE figi = $1 AND interval_type = $2
+		ORDER BY time DESC
+		LIMIT $3`, table)
+
+	rows, err := dbpool.Query(ctx, query, figi, intervalType, n)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса последних свечей: %w", err)
+	}
+	defer rows.Close()
+
+	candles := make([]Candle, 0, n)
+	for rows.Next() {
+		var candle Candle
+		if err := rows.Scan(
+			&candle.FIGI,
+			&candle.Time,
+			&candle.OpenPrice,
+			&candle.HighPrice,
+			&candle.LowPrice,
+			&candle.ClosePrice,
+			&candle.Volume,
+			&candle.IntervalType,
+		); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования свечи: %w", err)
+		}
+		candles = append(candles, candle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по свечам: %w", err)
+	}
+
+	// Разворачиваем в порядок по возрастанию времени
+	for i, j := 0, len(candles)-1; i < j; i, j = i+1, j-1 {
+		candles[i], candles[j] = candles[j], candles[i]
+	}
+
+	return candles, nil
+}
+
+// ErrUnknownInterval тип интервала свечей не входит в известные интервалы
+var ErrUnknownInterval = errors.New("неизвестный тип интервала свечей")
+
+// validateIntervalType проверяет, что тип интервала - один из 13 известных (config.CandleInterval*),
+// а не, например, текстовый интервал ("1min") вместо типа
+func validateIntervalType(intervalType string) error {
+	if config.Interval2text(intervalType) == "" {
+		return fmt.Errorf("%w: %q", ErrUnknownInterval, intervalType)
+	}
+	return nil
+}
+
 // SaveCandles сохраняет свечи в базу данных батчами (с логгером)
+// Свечи с неизвестным типом интервала не сохраняются (ErrUnknownInterval),
+// свечи с объёмом ниже минимального для интервала (SetMinVolume) и внутридневные свечи
+// вне основной сессии (SetRegularSession) пропускаются.
+// Шаг свечей сверяется с интервалом (SetIntervalCheck), в режиме strict пачка отклоняется (ErrIntervalMismatch).
+// Свечи считаются загруженными через API: source_file сохранённых свечей очищается
 func SaveCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle, intervalType string, logger *logrus.Logger) error {
+	return saveCandles(dbpool, figi, candles, intervalType, "", logger)
+}
+
+// SaveArchiveCandles сохраняет свечи, загруженные из CSV файла архива sourceFile, как SaveCandles.
+// В source_file записывается имя файла (archive.track_source_file) или отметка архива без имени файла
+func SaveArchiveCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle, intervalType, sourceFile string, logger *logrus.Logger) error {
+	if !TrackSourceFile() {
+		sourceFile = untrackedArchiveSource
+	}
+	return saveCandles(dbpool, figi, candles, intervalType, sourceFile, logger)
+}
+
+// saveCandles сохраняет свечи вместе с файлом-источником (пустой sourceFile - свечи из API, source_file = NULL)
+func saveCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle, intervalType, sourceFile string, logger *logrus.Logger) error {
+	if len(candles) == 0 {
+		return nil
+	}
+
+	// Тип интервала проверяется один раз на батч
+	if err := validateIntervalType(intervalType); err != nil {
+		return err
+	}
+
+	if err := checkIntervalSpacing(figi, candles, intervalType, logger); err != nil {
+		return err
+	}
+
+	candles = filterMinVolume(candles, intervalType)
+	candles = filterRegularSession(candles, intervalType)
 	if len(candles) == 0 {
 		return nil
 	}
 
 	//	const batchSize = 1000 // Размер батча
 
+	// Свечи ссылаются на instruments (candles_figi_fkey), проверяем заранее
+	exists, err := InstrumentExists(context.Background(), dbpool, figi)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownInstrument, figi)
+	}
+
+	// Таблица свечей интервала (общая candles или отдельная в режиме table_per_interval)
+	table, err := candleTableFor(context.Background(), dbpool, intervalType)
+	if err != nil {
+		return err
+	}
+
 	// Логируем начало сохранения
 	// logger.Debugf("Начинаем сохранение %d свечей батчами", len(candles))
 	logger.Debugf("Начинаем сохранение %d свечей", len(candles))
 
 	// Подготавливаем запрос
-	query := `
-		INSERT INTO candles (figi, time, open_price, high_price, low_price, close_price, volume, interval_type)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	// xmax = 0 только у новых строк, у обновлённых через ON CONFLICT - id транзакции.
+	// source_file перезаписывается вместе с ценами, чтобы источник строки соответствовал последней записи
+	query := fmt.Sprintf(`
+		INSERT INTO %s (figi, time, open_price, high_price, low_price, close_price, volume, interval_type, source_file)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))
 		ON CONFLICT (figi, time, interval_type) DO UPDATE SET
 			open_price = EXCLUDED.open_price,
 			high_price = EXCLUDED.high_price,
 			low_price = EXCLUDED.low_price,
 			close_price = EXCLUDED.close_price,
-			volume = EXCLUDED.volume
-	`
+			volume = EXCLUDED.volume,
+			source_file = EXCLUDED.source_file
+		RETURNING (xmax = 0)
+	`, table)
+
+	var inserted, conflicts, partitions int64
+	defer func() {
+		addSaveSummary(inserted, conflicts, partitions)
+	}()
 
 	// Обрабатываем свечи батчами
 	//	totalBatches := (len(candles) + batchSize - 1) / batchSize
@@ -141,7 +400,8 @@ func SaveCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle
 		// Выполняем вставку батча
 		//		for _, candle := range batch {
 		//_, err := tx.Exec(context.Background(), query,
-		_, err := dbpool.Exec(context.Background(), query,
+		var isNew bool
+		err := queryRowWithRetry(context.Background(), dbpool, []interface{}{&isNew}, query,
 			figi,
 			candle.GetTime().AsTime(),
 			money.ConvertMoneyValue(candle.GetOpen().GetUnits(), candle.GetOpen().GetNano()),
@@ -150,22 +410,40 @@ func SaveCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle
 			money.ConvertMoneyValue(candle.GetClose().GetUnits(), candle.GetClose().GetNano()),
 			candle.GetVolume(),
 			intervalType,
+			sourceFile,
 		)
 
 		if err != nil {
 			// Проверяем, является ли ошибка связанной с отсутствием партиции
 			var pgErr *pgconn.PgError
 			if errors.As(err, &pgErr) {
+				// Инструмент удалён из instruments после проверки
+				if pgErr.Code == "23503" {
+					knownInstruments.Delete(figi)
+					return fmt.Errorf("%w: %s", ErrUnknownInstrument, figi)
+				}
+
+				// Логируем только первое событие по каждой партиции
+				logPartition := partitionLogSampler.Allow(partitionNameFor(table, candle.GetTime().AsTime()))
+
 				// Проверяем код ошибки
 				switch {
 				case pgErr.Code == "23514":
-					logger.Debugf("Обнаружена ошибка отсутствия партиции (код 23514) для времени %s", candle.GetTime().AsTime().Format("2006-01-02"))
+					if logPartition {
+						logger.Debugf("Обнаружена ошибка отсутствия партиции (код 23514) для времени %s", candle.GetTime().AsTime().Format("2006-01-02"))
+					}
 				case strings.Contains(pgErr.Message, "no partition of relation"):
-					logger.Debugf("Обнаружена ошибка отсутствия партиции (английское сообщение) для времени %s", candle.GetTime().AsTime().Format("2006-01-02"))
+					if logPartition {
+						logger.Debugf("Обнаружена ошибка отсутствия партиции (английское сообщение) для времени %s", candle.GetTime().AsTime().Format("2006-01-02"))
+					}
 				case strings.Contains(pgErr.Message, "для строки не найдена секция"):
-					logger.Debugf("Обнаружена ошибка отсутствия партиции (русское сообщение) для времени %s", candle.GetTime().AsTime().Format("2006-01-02"))
+					if logPartition {
+						logger.Debugf("Обнаружена ошибка отсутствия партиции (русское сообщение) для времени %s", candle.GetTime().AsTime().Format("2006-01-02"))
+					}
 				case strings.Contains(pgErr.Message, "partition"):
-					logger.Debugf("Обнаружена ошибка партиции (общее сообщение) для времени %s", candle.GetTime().AsTime().Format("2006-01-02"))
+					if logPartition {
+						logger.Debugf("Обнаружена ошибка партиции (общее сообщение) для времени %s", candle.GetTime().AsTime().Format("2006-01-02"))
+					}
 				default:
 					// Это не ошибка партиции - откатываем транзакцию и возвращаем ошибку
 					//		if rollbackErr := tx.Rollback(context.Background()); rollbackErr != nil {
@@ -175,7 +453,9 @@ func SaveCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle
 				}
 
 				// Если это ошибка партиции - обрабатываем её
-				logger.Debugf("Создаем партицию для времени %s...", candle.GetTime().AsTime().Format("2006-01-02"))
+				if logPartition {
+					logger.Debugf("Создаем партицию для времени %s...", candle.GetTime().AsTime().Format("2006-01-02"))
+				}
 
 				// Подтверждаем текущую транзакцию перед созданием партиции
 				//			if commitErr := tx.Commit(context.Background()); commitErr != nil {
@@ -184,9 +464,10 @@ func SaveCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle
 				//			}
 
 				// Создаем партицию
-				if createErr := CreatePartition(dbpool, candle.GetTime().AsTime()); createErr != nil {
+				if createErr := createCandlePartition(context.Background(), dbpool, table, intervalType, candle.GetTime().AsTime()); createErr != nil {
 					return fmt.Errorf("ошибка создания партиции: %w", createErr)
 				}
+				partitions++
 
 				// Начинаем новую транзакцию для повторной вставки
 				//			tx, err = dbpool.Begin(context.Background())
@@ -196,7 +477,7 @@ func SaveCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle
 
 				// Повторяем вставку этой свечи
 				//		_, retryErr := tx.Exec(context.Background(), query,
-				_, retryErr := dbpool.Exec(context.Background(), query,
+				retryErr := queryRowWithRetry(context.Background(), dbpool, []interface{}{&isNew}, query,
 					figi,
 					candle.GetTime().AsTime(),
 					money.ConvertMoneyValue(candle.GetOpen().GetUnits(), candle.GetOpen().GetNano()),
@@ -205,6 +486,7 @@ func SaveCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle
 					money.ConvertMoneyValue(candle.GetClose().GetUnits(), candle.GetClose().GetNano()),
 					candle.GetVolume(),
 					intervalType,
+					sourceFile,
 				)
 				if retryErr != nil {
 					//			if rollbackErr := tx.Rollback(context.Background()); rollbackErr != nil {
@@ -212,15 +494,19 @@ func SaveCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle
 					//			}
 					return fmt.Errorf("ошибка вставки свечи после создания партиции: %w", retryErr)
 				}
-
-				continue
+			} else {
+				// Если это не PostgreSQL ошибка - откатываем транзакцию и возвращаем ошибку
+				//		if rollbackErr := tx.Rollback(context.Background()); rollbackErr != nil {
+				//			logger.Errorf("Ошибка отката транзакции: %v", rollbackErr)
+				//		}
+				return fmt.Errorf("ошибка вставки свечи: %w", err)
 			}
+		}
 
-			// Если это не PostgreSQL ошибка - откатываем транзакцию и возвращаем ошибку
-			//		if rollbackErr := tx.Rollback(context.Background()); rollbackErr != nil {
-			//			logger.Errorf("Ошибка отката транзакции: %v", rollbackErr)
-			//		}
-			return fmt.Errorf("ошибка вставки свечи: %w", err)
+		if isNew {
+			inserted++
+		} else {
+			conflicts++
 		}
 		//		}
 
@@ -230,5 +516,115 @@ func SaveCandles(dbpool *pgxpool.Pool, figi string, candles []*pb.HistoricCandle
 		//	}
 	}
 
+	// Публикуем сохранённые свечи (sink.kafka)
+	notifySaved(figi, intervalType, candles)
+
 	return nil
 }
+
+// DuplicateCandle группа дублирующихся свечей (figi, time, interval_type)
+type DuplicateCandle struct {
+	FIGI         string
+	Time         time.Time
+	IntervalType string
+	Count        int
+}
+
+// FindDuplicateCandles находит дубли свечей (пустой figi - по всем инструментам)
+func FindDuplicateCandles(ctx context.Context, dbpool *pgxpool.Pool, figi string) ([]DuplicateCandle, error) {
+	tables, err := existingCandleTables(ctx, dbpool)
+	if err != nil {
+		return nil, err
+	}
+
+	var duplicates []DuplicateCandle
+	for _, table := range tables {
+		found, err := findDuplicateCandlesIn(ctx, dbpool, table, figi)
+		if err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, found...)
+	}
+	return duplicates, nil
+}
+
+// findDuplicateCandlesIn находит дубли свечей в одной таблице свечей
+func findDuplicateCandlesIn(ctx context.Context, dbpool *pgxpool.Pool, table, figi string) ([]DuplicateCandle, error) {
+	query := fmt.Sprintf(`
+		SELECT figi, time, interval_type, COUNT(*)
+		FROM %s
+		WHERE ($1 = '' OR figi = $1)
+		GROUP BY figi, time, interval_type
+		HAVING COUNT(*) > 1
+		ORDER BY figi, interval_type, time
+	`, table)
+
+	rows, err := dbpool.Query(ctx, query, figi)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска дублей свечей: %w", err)
+	}
+	defer rows.Close()
+
+	var duplicates []DuplicateCandle
+	for rows.Next() {
+		var duplicate DuplicateCandle
+		if err := rows.Scan(&duplicate.FIGI, &duplicate.Time, &duplicate.IntervalType, &duplicate.Count); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования дубля свечи: %w", err)
+		}
+		duplicates = append(duplicates, duplicate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по дублям свечей: %w", err)
+	}
+
+	return duplicates, nil
+}
+
+// RemoveDuplicateCandles удаляет дубли свечей в транзакции, оставляя запись с последним created_at
+// возвращает количество удалённых строк (пустой figi - по всем инструментам)
+func RemoveDuplicateCandles(ctx context.Context, dbpool *pgxpool.Pool, figi string) (int64, error) {
+	tables, err := existingCandleTables(ctx, dbpool)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer func() {
+		// После Commit откат ничего не делает
+		_ = tx.Rollback(ctx)
+	}()
+
+	var deleted int64
+	for _, table := range tables {
+		// ctid уникален только внутри партиции, поэтому строку определяем парой (tableoid, ctid)
+		query := fmt.Sprintf(`
+			DELETE FROM %[1]s c
+			USING (
+				SELECT tableoid, ctid,
+					ROW_NUMBER() OVER (
+						PARTITION BY figi, time, interval_type
+						ORDER BY created_at DESC NULLS LAST, id DESC
+					) AS rn
+				FROM %[1]s
+				WHERE ($1 = '' OR figi = $1)
+			) d
+			WHERE c.tableoid = d.tableoid AND c.ctid = d.ctid AND d.rn > 1
+		`, table)
+
+		tag, err := tx.Exec(ctx, query, figi)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка удаления дублей свечей в %s: %w", table, err)
+		}
+		deleted += tag.RowsAffected()
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка подтверждения удаления дублей свечей: %w", err)
+	}
+
+	return deleted, nil
+}
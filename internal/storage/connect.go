@@ -15,17 +15,31 @@ import (
 	"market-loader/pkg/config"
 	"market-loader/pkg/database"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // ConnectToDatabase подключается к базе данных и инициализирует её
 func ConnectToDatabase(ctx context.Context, dbConfig *config.DatabaseConfig) (*pgxpool.Pool, error) {
+	// Таблицы свечей: общая candles или отдельная для каждого интервала
+	SetTablePerInterval(dbConfig.TablePerInterval)
+	SetPartitionByInterval(dbConfig.PartitionByInterval)
+	if dbConfig.TablePerInterval && dbConfig.PartitionByInterval {
+		return nil, fmt.Errorf("database.table_per_interval и database.partition_by_interval нельзя включить одновременно")
+	}
+
 	// Подключаемся к БД
 	dbpool, err := database.Connect(ctx, dbConfig)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка подключения к БД: %w", err)
 	}
 
+	// Создаем схему, если она задана и ещё не существует
+	if err := CreateSchema(ctx, dbpool, dbConfig.GetSchema()); err != nil {
+		dbpool.Close()
+		return nil, err
+	}
+
 	// Сначала выполняем миграции для существующих таблиц
 	if err := MigrateDatabase(dbpool); err != nil {
 		dbpool.Close()
@@ -38,6 +52,12 @@ func ConnectToDatabase(ctx context.Context, dbConfig *config.DatabaseConfig) (*p
 		return nil, fmt.Errorf("ошибка инициализации БД: %w", err)
 	}
 
+	// Партиционирование candles в БД должно совпадать с настройкой
+	if err := checkCandlePartitioning(ctx, dbpool); err != nil {
+		dbpool.Close()
+		return nil, err
+	}
+
 	// После миграций создаем индексы и ограничения
 	if err := CreateIndexesAndConstraints(dbpool); err != nil {
 		dbpool.Close()
@@ -52,3 +72,16 @@ func ConnectToDatabase(ctx context.Context, dbConfig *config.DatabaseConfig) (*p
 
 	return dbpool, nil
 }
+
+// CreateSchema создает схему БД, если её нет
+func CreateSchema(ctx context.Context, dbpool *pgxpool.Pool, schema string) error {
+	if schema == config.DefaultSchema {
+		return nil
+	}
+
+	query := "CREATE SCHEMA IF NOT EXISTS " + pgx.Identifier{schema}.Sanitize()
+	if _, err := dbpool.Exec(ctx, query); err != nil {
+		return fmt.Errorf("ошибка создания схемы %s: %w", schema, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LoadCoverage полнота загрузки свечей инструмента по интервалу (loading.coverage)
+type LoadCoverage struct {
+	Figi         string
+	Ticker       string
+	IntervalType string
+	Candles      int64     // загружено свечей
+	Expected     int       // ожидаемое количество по торговому календарю, 0 - неизвестно
+	Ratio        float64   // Candles / Expected, 0 при неизвестном ожидаемом количестве
+	Loaded       bool      // инструмент считается загруженным (min_ratio, min_candles)
+	From         time.Time // начало периода, за который считалось ожидаемое количество
+	CheckedAt    time.Time
+}
+
+// SaveLoadCoverage сохраняет полноту загрузки инструмента по интервалу
+func SaveLoadCoverage(ctx context.Context, dbpool *pgxpool.Pool, coverage LoadCoverage) error {
+	var expected *int
+	var ratio *float64
+	if coverage.Expected > 0 {
+		expected = &coverage.Expected
+		ratio = &coverage.Ratio
+	}
+
+	_, err := dbpool.Exec(ctx, `
+		INSERT INTO load_coverage (figi, interval_type, candles, expected, completeness, loaded, from_time, checked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (figi, interval_type) DO UPDATE SET
+			candles = EXCLUDED.candles,
+			expected = EXCLUDED.expected,
+			completeness = EXCLUDED.completeness,
+			loaded = EXCLUDED.loaded,
+			from_time = EXCLUDED.from_time,
+			checked_at = EXCLUDED.checked_at
+	`, coverage.Figi, coverage.IntervalType, coverage.Candles, expected, ratio, coverage.Loaded, coverage.From)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения полноты загрузки: %w", err)
+	}
+	return nil
+}
+
+// GetLoadCoverage возвращает полноту загрузки включённых инструментов по интервалу:
+// сначала недогруженные по возрастанию полноты, затем загруженные
+func GetLoadCoverage(ctx context.Context, dbpool *pgxpool.Pool, intervalType string) ([]LoadCoverage, error) {
+	rows, err := dbpool.Query(ctx, `
+		SELECT c.figi, i.ticker, c.interval_type, c.candles, COALESCE(c.expected, 0),
+			COALESCE(c.completeness, 0)::float8, c.loaded, c.from_time, c.checked_at
+		FROM load_coverage c
+		JOIN instruments i ON i.figi = c.figi
+		WHERE c.interval_type = $1 AND i.enabled = true
+		ORDER BY c.loaded, c.completeness ASC NULLS FIRST, i.ticker
+	`, intervalType)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса полноты загрузки: %w", err)
+	}
+	defer rows.Close()
+
+	var coverages []LoadCoverage
+	for rows.Next() {
+		var coverage LoadCoverage
+		if err := rows.Scan(&coverage.Figi, &coverage.Ticker, &coverage.IntervalType, &coverage.Candles, &coverage.Expected,
+			&coverage.Ratio, &coverage.Loaded, &coverage.From, &coverage.CheckedAt); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования полноты загрузки: %w", err)
+		}
+		coverages = append(coverages, coverage)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по полноте загрузки: %w", err)
+	}
+
+	return coverages, nil
+}
@@ -20,31 +20,71 @@ import (
 
 // Dividend структура дивиденда
 type Dividend struct {
-	Figi         string
-	PaymentDate  time.Time
-	DeclaredDate *time.Time
-	Amount       float64
-	Currency     string
-	YieldPercent *float64
+	Figi         string     `json:"figi"`
+	PaymentDate  time.Time  `json:"payment_date"`
+	DeclaredDate *time.Time `json:"declared_date"`
+	Amount       float64    `json:"amount"`
+	Currency     string     `json:"currency"`
+	YieldPercent *float64   `json:"yield_percent"`
 }
 
+// saveDividendQuery сохраняет дивиденд; если валюта дивиденда не указана, используется валюта инструмента
+const saveDividendQuery = `
+	INSERT INTO dividends (figi, payment_date, declared_date, amount, currency, yield_percent)
+	VALUES ($1, $2, $3, $4,
+		COALESCE(NULLIF($5, ''), (SELECT NULLIF(currency, '') FROM instruments WHERE figi = $1)),
+		$6)
+	ON CONFLICT (figi, payment_date) DO UPDATE SET
+		declared_date = EXCLUDED.declared_date,
+		amount = EXCLUDED.amount,
+		currency = EXCLUDED.currency,
+		yield_percent = EXCLUDED.yield_percent
+`
+
 // SaveDividend сохраняет информацию о дивиденде
+// если валюта дивиденда не указана, используется валюта инструмента
 func SaveDividend(ctx context.Context, dbpool *pgxpool.Pool, dividend Dividend) error {
-	query := `
-		INSERT INTO dividends (figi, payment_date, declared_date, amount, currency, yield_percent)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (figi, payment_date) DO UPDATE SET
-			declared_date = EXCLUDED.declared_date,
-			amount = EXCLUDED.amount,
-			currency = EXCLUDED.currency,
-			yield_percent = EXCLUDED.yield_percent
-	`
-
-	_, err := dbpool.Exec(ctx, query,
+	err := execWithRetry(ctx, dbpool, saveDividendQuery,
 		dividend.Figi, dividend.PaymentDate, dividend.DeclaredDate,
 		dividend.Amount, dividend.Currency, dividend.YieldPercent)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения дивиденда: %w", err)
+	}
+	return nil
+}
+
+// SaveDividends сохраняет дивиденды одной транзакцией: при ошибке не сохраняется ни один.
+// При временной ошибке соединения транзакция повторяется целиком
+func SaveDividends(ctx context.Context, dbpool *pgxpool.Pool, dividends []Dividend) error {
+	if len(dividends) == 0 {
+		return nil
+	}
+
+	err := withRetry(ctx, func() error {
+		tx, err := dbpool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = tx.Rollback(ctx)
+		}()
 
-	return fmt.Errorf("ошибка сохранения дивиденда: %w", err)
+		batch := &pgx.Batch{}
+		for _, dividend := range dividends {
+			batch.Queue(saveDividendQuery,
+				dividend.Figi, dividend.PaymentDate, dividend.DeclaredDate,
+				dividend.Amount, dividend.Currency, dividend.YieldPercent)
+		}
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения дивидендов (%d): %w", len(dividends), err)
+	}
+	return nil
 }
 
 // GetLastDividendDate получает дату последней выплаты дивидендов
@@ -54,9 +94,67 @@ func GetLastDividendDate(ctx context.Context, dbpool *pgxpool.Pool, figi string)
 	var lastDividendDate sql.NullTime
 	err := dbpool.QueryRow(ctx, query, figi).Scan(&lastDividendDate)
 
-	if err == pgx.ErrNoRows || !lastDividendDate.Valid {
+	if err != nil && err != pgx.ErrNoRows {
+		return time.Time{}, fmt.Errorf("ошибка сканирования даты последнего дивиденда: %w", err)
+	}
+	if !lastDividendDate.Valid {
 		return time.Time{}, nil // Нет записей - новый инструмент
 	}
 
-	return lastDividendDate.Time, fmt.Errorf("ошибка сканирования даты последнего дивиденда: %w", err)
+	return lastDividendDate.Time, nil
+}
+
+// GetDividends возвращает дивиденды инструмента за период, упорядоченные по дате выплаты
+// пустой figi - все инструменты, пустая currency - все валюты (без учёта регистра),
+// нулевые from/to - без ограничения
+func GetDividends(ctx context.Context, dbpool *pgxpool.Pool, figi, currency string, from, to time.Time) ([]Dividend, error) {
+	query := `SELECT figi, payment_date, declared_date, amount, COALESCE(currency, ''), yield_percent
+		FROM dividends WHERE true`
+	var args []interface{}
+
+	if figi != "" {
+		args = append(args, figi)
+		query += fmt.Sprintf(" AND figi = $%d", len(args))
+	}
+	if currency != "" {
+		args = append(args, currency)
+		query += fmt.Sprintf(" AND lower(currency) = lower($%d)", len(args))
+	}
+	if !from.IsZero() {
+		args = append(args, from)
+		query += fmt.Sprintf(" AND payment_date >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		query += fmt.Sprintf(" AND payment_date <= $%d", len(args))
+	}
+	query += " ORDER BY payment_date, figi"
+
+	rows, err := dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса дивидендов: %w", err)
+	}
+	defer rows.Close()
+
+	var dividends []Dividend
+	for rows.Next() {
+		var dividend Dividend
+		if err := rows.Scan(
+			&dividend.Figi,
+			&dividend.PaymentDate,
+			&dividend.DeclaredDate,
+			&dividend.Amount,
+			&dividend.Currency,
+			&dividend.YieldPercent,
+		); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования дивиденда: %w", err)
+		}
+		dividends = append(dividends, dividend)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по дивидендам: %w", err)
+	}
+
+	return dividends, nil
 }
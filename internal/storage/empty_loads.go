@@ -0,0 +1,70 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EmptyLoad учёт запусков подряд, в которых инструмент без свечей не вернул ни одной свечи
+type EmptyLoad struct {
+	Runs      int       // запусков подряд без свечей
+	CheckedAt time.Time // время последней проверки (запроса к API)
+}
+
+// GetEmptyLoad возвращает учёт пустых загрузок инструмента по интервалу (нулевой - загрузок без свечей не было)
+func GetEmptyLoad(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string) (EmptyLoad, error) {
+	var state EmptyLoad
+	err := dbpool.QueryRow(ctx, `SELECT empty_runs, checked_at FROM empty_loads WHERE figi = $1 AND interval_type = $2`,
+		figi, intervalType).Scan(&state.Runs, &state.CheckedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return EmptyLoad{}, nil
+	}
+	if err != nil {
+		return EmptyLoad{}, fmt.Errorf("ошибка получения пустых загрузок: %w", err)
+	}
+	return state, nil
+}
+
+// RecordEmptyLoad учитывает загрузку без свечей: увеличивает счётчик запусков подряд и время проверки.
+// Время проверки задаётся до записи, поэтому повтор после потери соединения не считает запуск дважды
+func RecordEmptyLoad(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string) (int, error) {
+	checkedAt := time.Now()
+	var runs int
+	err := withRetry(ctx, func() error {
+		return dbpool.QueryRow(ctx, `
+			INSERT INTO empty_loads (figi, interval_type, empty_runs, checked_at)
+			VALUES ($1, $2, 1, $3)
+			ON CONFLICT (figi, interval_type) DO UPDATE SET
+				empty_runs = CASE WHEN empty_loads.checked_at = EXCLUDED.checked_at
+					THEN empty_loads.empty_runs ELSE empty_loads.empty_runs + 1 END,
+				checked_at = EXCLUDED.checked_at
+			RETURNING empty_runs
+		`, figi, intervalType, checkedAt).Scan(&runs)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("ошибка сохранения пустой загрузки: %w", err)
+	}
+	return runs, nil
+}
+
+// ClearEmptyLoad сбрасывает учёт пустых загрузок (у инструмента появились свечи)
+func ClearEmptyLoad(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string) error {
+	err := execWithRetry(ctx, dbpool, `DELETE FROM empty_loads WHERE figi = $1 AND interval_type = $2`, figi, intervalType)
+	if err != nil {
+		return fmt.Errorf("ошибка сброса пустых загрузок: %w", err)
+	}
+	return nil
+}
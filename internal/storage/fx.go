@@ -0,0 +1,175 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// FXCrossCurrency валюта для кросс-курсов, если прямой пары нет
+	FXCrossCurrency = "rub"
+	// fxCacheSize максимальное количество закешированных курсов
+	fxCacheSize = 10000
+)
+
+// ErrFXRateNotFound курс для пары не найден (нет пары или свечей)
+var ErrFXRateNotFound = errors.New("курс валютной пары не найден")
+
+// CurrencyPair валютная пара: FIGI валютного инструмента с ценой base в quote
+type CurrencyPair struct {
+	Base    string  // Базовая валюта (ISO, нижний регистр), например usd
+	Quote   string  // Валюта котирования, например rub
+	Figi    string  // FIGI валютного инструмента
+	Nominal float64 // Номинал: цена свечи указана за Nominal единиц base
+}
+
+// SaveCurrencyPair сохраняет валютную пару
+func SaveCurrencyPair(ctx context.Context, dbpool *pgxpool.Pool, pair CurrencyPair) error {
+	query := `
+		INSERT INTO currency_pairs (base_currency, quote_currency, figi, nominal)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (base_currency, quote_currency) DO UPDATE SET
+			figi = EXCLUDED.figi,
+			nominal = EXCLUDED.nominal,
+			updated_at = NOW()
+	`
+
+	nominal := pair.Nominal
+	if nominal <= 0 {
+		nominal = 1
+	}
+
+	err := execWithRetry(ctx, dbpool, query,
+		strings.ToLower(pair.Base), strings.ToLower(pair.Quote), pair.Figi, nominal)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения валютной пары: %w", err)
+	}
+	return nil
+}
+
+// fxCacheKey ключ кеша курсов (время округляется до минуты)
+type fxCacheKey struct {
+	base  string
+	quote string
+	at    time.Time
+}
+
+var (
+	fxCacheMu sync.Mutex
+	fxCache   = make(map[fxCacheKey]float64)
+)
+
+// GetFXRate возвращает курс base/quote (сколько quote стоит одна единица base)
+// по закрытию ближайшей к at свечи валютного инструмента.
+// Ищется прямая пара, затем обратная, затем кросс-курс через рубль.
+func GetFXRate(ctx context.Context, dbpool *pgxpool.Pool, base, quote string, at time.Time) (float64, error) {
+	base = strings.ToLower(base)
+	quote = strings.ToLower(quote)
+	if base == quote {
+		return 1, nil
+	}
+
+	key := fxCacheKey{base: base, quote: quote, at: at.UTC().Truncate(time.Minute)}
+	fxCacheMu.Lock()
+	rate, cached := fxCache[key]
+	fxCacheMu.Unlock()
+	if cached {
+		return rate, nil
+	}
+
+	rate, err := pairRate(ctx, dbpool, base, quote, at)
+	if errors.Is(err, ErrFXRateNotFound) && base != FXCrossCurrency && quote != FXCrossCurrency {
+		// Кросс-курс: base/rub / quote/rub
+		var baseRub, quoteRub float64
+		baseRub, err = pairRate(ctx, dbpool, base, FXCrossCurrency, at)
+		if err == nil {
+			quoteRub, err = pairRate(ctx, dbpool, quote, FXCrossCurrency, at)
+		}
+		if err == nil {
+			rate = baseRub / quoteRub
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s/%s на %s: %w", base, quote, at.Format(time.RFC3339), err)
+	}
+
+	fxCacheMu.Lock()
+	if len(fxCache) >= fxCacheSize {
+		fxCache = make(map[fxCacheKey]float64)
+	}
+	fxCache[key] = rate
+	fxCacheMu.Unlock()
+
+	return rate, nil
+}
+
+// pairRate курс по прямой или обратной паре
+func pairRate(ctx context.Context, dbpool *pgxpool.Pool, base, quote string, at time.Time) (float64, error) {
+	price, err := nearestPairPrice(ctx, dbpool, base, quote, at)
+	if err == nil {
+		return price, nil
+	}
+	if !errors.Is(err, ErrFXRateNotFound) {
+		return 0, err
+	}
+
+	// Обратная пара
+	price, err = nearestPairPrice(ctx, dbpool, quote, base, at)
+	if err != nil {
+		return 0, err
+	}
+	return 1 / price, nil
+}
+
+// nearestPairPrice цена одной единицы base в quote по ближайшей к at свече пары
+// в режиме table_per_interval курс берётся по дневным свечам
+func nearestPairPrice(ctx context.Context, dbpool *pgxpool.Pool, base, quote string, at time.Time) (float64, error) {
+	table, err := candleTableFor(ctx, dbpool, config.CandleIntervalDay)
+	if err != nil {
+		return 0, err
+	}
+	query := fmt.Sprintf(`
+		SELECT c.close_price / p.nominal
+		FROM currency_pairs p
+		JOIN LATERAL (
+			(SELECT close_price, $3 - time AS diff FROM %[1]s
+				WHERE figi = p.figi AND time <= $3 ORDER BY time DESC LIMIT 1)
+			UNION ALL
+			(SELECT close_price, time - $3 AS diff FROM %[1]s
+				WHERE figi = p.figi AND time > $3 ORDER BY time ASC LIMIT 1)
+		) c ON true
+		WHERE p.base_currency = $1 AND p.quote_currency = $2
+		ORDER BY c.diff
+		LIMIT 1
+	`, table)
+
+	var price float64
+	err = dbpool.QueryRow(ctx, query, base, quote, at.UTC()).Scan(&price)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrFXRateNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения курса %s/%s: %w", base, quote, err)
+	}
+	if price <= 0 {
+		return 0, ErrFXRateNotFound
+	}
+	return price, nil
+}
@@ -0,0 +1,183 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrGroupNotFound группы инструментов нет в watchlist_groups
+var ErrGroupNotFound = errors.New("группа инструментов не найдена")
+
+// WatchlistGroup группа инструментов (портфель, сектор, стратегия)
+type WatchlistGroup struct {
+	Name      string
+	Members   int
+	CreatedAt time.Time
+}
+
+// AddGroupMembers добавляет инструменты в группу, создавая группу при необходимости.
+// Возвращает количество добавленных (уже входившие в группу не считаются)
+func AddGroupMembers(ctx context.Context, dbpool *pgxpool.Pool, group string, figis []string) (int64, error) {
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var groupID int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO watchlist_groups (name) VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	`, group).Scan(&groupID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка создания группы %s: %w", group, err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO watchlist_members (group_id, figi)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT DO NOTHING
+	`, groupID, figis)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка добавления инструментов в группу %s: %w", group, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка сохранения группы %s: %w", group, err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RemoveGroupMembers удаляет инструменты из группы, возвращает количество удалённых
+func RemoveGroupMembers(ctx context.Context, dbpool *pgxpool.Pool, group string, figis []string) (int64, error) {
+	groupID, err := getGroupID(ctx, dbpool, group)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := dbpool.Exec(ctx, `DELETE FROM watchlist_members WHERE group_id = $1 AND figi = ANY($2)`, groupID, figis)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления инструментов из группы %s: %w", group, err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteGroup удаляет группу вместе со списком её инструментов (сами инструменты не затрагиваются)
+func DeleteGroup(ctx context.Context, dbpool *pgxpool.Pool, group string) error {
+	tag, err := dbpool.Exec(ctx, `DELETE FROM watchlist_groups WHERE name = $1`, group)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления группы %s: %w", group, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, group)
+	}
+	return nil
+}
+
+// GetGroups возвращает группы инструментов с количеством инструментов в каждой
+func GetGroups(ctx context.Context, dbpool *pgxpool.Pool) ([]WatchlistGroup, error) {
+	rows, err := dbpool.Query(ctx, `
+		SELECT g.name, COUNT(m.figi), g.created_at
+		FROM watchlist_groups g
+		LEFT JOIN watchlist_members m ON m.group_id = g.id
+		GROUP BY g.id
+		ORDER BY g.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса групп инструментов: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []WatchlistGroup
+	for rows.Next() {
+		var group WatchlistGroup
+		if err := rows.Scan(&group.Name, &group.Members, &group.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования группы: %w", err)
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по группам: %w", err)
+	}
+
+	return groups, nil
+}
+
+// GetInstrumentsByGroup возвращает инструменты группы независимо от enabled и статуса торгов:
+// состав группы задаётся явно
+func GetInstrumentsByGroup(ctx context.Context, dbpool *pgxpool.Pool, group string) ([]Instrument, error) {
+	groupID, err := getGroupID(ctx, dbpool, group)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := dbpool.Query(ctx, `
+		SELECT i.figi, i.ticker, i.name, i.instrument_type, i.enabled, i.data_source_id, i.last_loaded_time, i.ipo_date,
+			i.for_qual_investor_flag,
+			COALESCE(i.first_1min_candle_date, '0001-01-01'), COALESCE(i.first_1day_candle_date, '0001-01-01')
+		FROM watchlist_members m
+		JOIN instruments i ON i.figi = m.figi
+		WHERE m.group_id = $1
+		ORDER BY i.instrument_type, i.ticker
+	`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса инструментов группы %s: %w", group, err)
+	}
+	defer rows.Close()
+
+	var instruments []Instrument
+	for rows.Next() {
+		var instrument Instrument
+		err := rows.Scan(
+			&instrument.Figi,
+			&instrument.Ticker,
+			&instrument.Name,
+			&instrument.InstrumentType,
+			&instrument.Enabled,
+			&instrument.DataSourceID,
+			&instrument.LastLoadedTime,
+			&instrument.IpoDate,
+			&instrument.ForQualInvestorFlag,
+			&instrument.First1MinCandleDate,
+			&instrument.First1DayCandleDate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования инструмента: %w", err)
+		}
+		instruments = append(instruments, instrument)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по инструментам: %w", err)
+	}
+
+	return instruments, nil
+}
+
+// getGroupID возвращает идентификатор группы по имени
+func getGroupID(ctx context.Context, dbpool *pgxpool.Pool, group string) (int64, error) {
+	var groupID int64
+	err := dbpool.QueryRow(ctx, `SELECT id FROM watchlist_groups WHERE name = $1`, group).Scan(&groupID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("%w: %s", ErrGroupNotFound, group)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения группы %s: %w", group, err)
+	}
+	return groupID, nil
+}
@@ -0,0 +1,41 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"sync"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+)
+
+// SaveHook вызывается после успешного сохранения свечей в БД
+type SaveHook func(figi, intervalType string, candles []*pb.HistoricCandle)
+
+var (
+	saveHookMu sync.RWMutex
+	saveHook   SaveHook
+)
+
+// SetSaveHook задаёт функцию, вызываемую после успешного сохранения свечей (nil - отключить)
+func SetSaveHook(hook SaveHook) {
+	saveHookMu.Lock()
+	defer saveHookMu.Unlock()
+	saveHook = hook
+}
+
+// notifySaved передаёт сохранённые свечи в SaveHook
+func notifySaved(figi, intervalType string, candles []*pb.HistoricCandle) {
+	saveHookMu.RLock()
+	hook := saveHook
+	saveHookMu.RUnlock()
+
+	if hook != nil {
+		hook(figi, intervalType, candles)
+	}
+}
@@ -18,29 +18,64 @@ import (
 
 const newView = 1
 
+// PartitionName возвращает имя месячной партиции candles для времени
+func PartitionName(t time.Time) string {
+	return partitionNameFor(SharedCandleTable, t)
+}
+
+// partitionNameFor возвращает имя месячной партиции таблицы свечей для времени (месяц по UTC)
+func partitionNameFor(table string, t time.Time) string {
+	t = t.UTC()
+	return fmt.Sprintf("%s_%d_%02d", table, t.Year(), t.Month())
+}
+
 // CreatePartition создает партицию
+// при составном партиционировании месячные партиции создаются в секциях интервалов (CreateCandlePartition)
 func CreatePartition(dbpool *pgxpool.Pool, t time.Time) error {
-	// Начало месяца
-	monthStart := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
-	// Конец месяца (начало следующего месяца минус 1 секунда)
-	monthEnd := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0).Add(-time.Second)
-	// Название партиции
-	partitionName := fmt.Sprintf("candles_%d_%02d", t.Year(), t.Month())
+	if partitionByInterval.Load() {
+		return nil
+	}
+	return createPartitionFor(dbpool, SharedCandleTable, t)
+}
 
-	query := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s PARTITION OF candles
-			FOR VALUES FROM ('%s') TO ('%s')
-		`, partitionName,
-		monthStart.Format("2006-01-02 15:04:05"),
-		monthEnd.Format("2006-01-02 15:04:05"))
+// CreateCandlePartition создает месячную партицию в таблице свечей интервала
+func CreateCandlePartition(dbpool *pgxpool.Pool, intervalType string, t time.Time) error {
+	table, err := candleTableFor(context.Background(), dbpool, intervalType)
+	if err != nil {
+		return err
+	}
+	return createCandlePartition(context.Background(), dbpool, table, intervalType, t)
+}
 
-	_, err := dbpool.Exec(context.Background(), query)
+// createPartitionFor создает месячную партицию таблицы свечей
+func createPartitionFor(dbpool *pgxpool.Pool, table string, t time.Time) error {
+	_, err := dbpool.Exec(context.Background(), partitionDefinition(table, t))
 	if err != nil {
 		return fmt.Errorf("ошибка создания партиции: %w", err)
 	}
 	return nil
 }
 
+// partitionDefinition возвращает DDL месячной партиции таблицы свечей.
+// Диапазон партиции [начало месяца, начало следующего месяца): верхняя граница в PostgreSQL не включается,
+// поэтому свеча в последнюю долю секунды месяца попадает в его партицию
+func partitionDefinition(table string, t time.Time) string {
+	t = t.UTC()
+	// Начало месяца
+	monthStart := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	// Начало следующего месяца (граница не включается)
+	nextMonthStart := monthStart.AddDate(0, 1, 0)
+	// Название партиции
+	partitionName := partitionNameFor(table, t)
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s PARTITION OF %s
+			FOR VALUES FROM ('%s') TO ('%s')
+		`, partitionName, table,
+		monthStart.Format("2006-01-02 15:04:05"),
+		nextMonthStart.Format("2006-01-02 15:04:05"))
+}
+
 // CreateInitialPartition создает начальную партицию для текущего месяца
 func CreateInitialPartition(dbpool *pgxpool.Pool) error {
 	// Создаем партицию для текущего месяца
@@ -50,19 +85,70 @@ func CreateInitialPartition(dbpool *pgxpool.Pool) error {
 	return nil
 }
 
-// CreateYearPartitions создает все партиции для указанного года
-func CreateYearPartitions(dbpool *pgxpool.Pool, year int) error {
+// CreateYearPartitions создает все партиции для указанного года в таблице свечей интервала
+func CreateYearPartitions(dbpool *pgxpool.Pool, intervalType string, year int) error {
 	for month := 1; month <= 12; month++ {
 		t := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-		if err := CreatePartition(dbpool, t); err != nil {
+		if err := CreateCandlePartition(dbpool, intervalType, t); err != nil {
 			return fmt.Errorf("ошибка создания партиции для %d-%02d: %w", year, month, err)
 		}
 	}
 	return nil
 }
 
+// EnsureFuturePartitions создает партиции свечей от текущего месяца на monthsAhead месяцев вперёд
+// во всех существующих таблицах свечей, чтобы первая свеча нового месяца не создавала партицию во время загрузки.
+// Возвращает количество созданных партиций (существующие пропускаются)
+func EnsureFuturePartitions(ctx context.Context, dbpool *pgxpool.Pool, monthsAhead int) (int, error) {
+	tables, err := existingCandleTables(ctx, dbpool)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	created := 0
+	for _, table := range tables {
+		// При составном партиционировании месячные партиции находятся в секциях интервалов
+		if table == SharedCandleTable && partitionByInterval.Load() {
+			continue
+		}
+		for i := 0; i <= monthsAhead; i++ {
+			month := monthStart.AddDate(0, i, 0)
+			partitionName := partitionNameFor(table, month)
+
+			var exists bool
+			if err := dbpool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, partitionName).Scan(&exists); err != nil {
+				return created, fmt.Errorf("ошибка проверки партиции %s: %w", partitionName, err)
+			}
+			if exists {
+				continue
+			}
+
+			if err := createPartitionFor(dbpool, table, month); err != nil {
+				return created, fmt.Errorf("ошибка создания партиции для %s: %w", month.Format("2006-01"), err)
+			}
+			created++
+		}
+	}
+	return created, nil
+}
+
 // InitDatabase инициализирует базу данных, создавая необходимые таблицы
 func InitDatabase(dbpool *pgxpool.Pool) error {
+	for _, query := range tableDefinitions() {
+		_, err := dbpool.Exec(context.Background(), query)
+		if err != nil {
+			return fmt.Errorf("ошибка создания таблицы: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// tableDefinitions возвращает DDL таблиц в порядке создания (data_sources - первой)
+func tableDefinitions() []string {
 	// Создаем таблицу data_sources
 	dataSourcesTable := `
 		CREATE TABLE IF NOT EXISTS data_sources (
@@ -93,6 +179,7 @@ func InitDatabase(dbpool *pgxpool.Pool) error {
 			ipo_date date NULL,
 			issue_size bigint NULL,
 			sector varchar(100) NULL,
+			sector_normalized varchar(50) NULL,
 			real_exchange varchar(50) NULL,
 			first_1min_candle_date timestamp NULL,
 			first_1day_candle_date timestamp NULL,
@@ -101,6 +188,8 @@ func InitDatabase(dbpool *pgxpool.Pool) error {
 			updated_at timestamp DEFAULT now() NOT NULL,
 			last_loaded_time timestamp NULL,
 			enabled bool DEFAULT false NOT NULL,
+			enabled_at timestamp NULL,
+			for_qual_investor_flag boolean DEFAULT false NOT NULL,
 			CONSTRAINT instruments_pkey PRIMARY KEY (figi),
 			CONSTRAINT instruments_data_source_id_fkey FOREIGN KEY (data_source_id) REFERENCES data_sources(id)
 		);
@@ -119,9 +208,16 @@ func InitDatabase(dbpool *pgxpool.Pool) error {
 			volume BIGINT NOT NULL,
 			interval_type VARCHAR(30) NOT NULL,
 			created_at TIMESTAMP DEFAULT NOW(),
+			source_file VARCHAR(255) NULL,
 			PRIMARY KEY (figi, time, interval_type)
-		) PARTITION BY RANGE ("time");
+		) PARTITION BY %s;
 	`
+	// Составное партиционирование: секции по interval_type, в них - месячные партиции
+	candlesPartitioning := `RANGE ("time")`
+	if partitionByInterval.Load() {
+		candlesPartitioning = `LIST (interval_type)`
+	}
+	candlesTable = fmt.Sprintf(candlesTable, candlesPartitioning)
 
 	// Создаем таблицу dividends
 	dividendsTable := `
@@ -139,21 +235,164 @@ func InitDatabase(dbpool *pgxpool.Pool) error {
 		);
 	`
 
-	// Выполняем создание таблиц
+	// Создаем таблицу run_log (журнал запусков загрузчиков)
+	runLogTable := `
+		CREATE TABLE IF NOT EXISTS run_log (
+			id BIGSERIAL,
+			loader VARCHAR(50) NOT NULL,
+			interval_type VARCHAR(30) NOT NULL DEFAULT '',
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			finished_at TIMESTAMPTZ NULL,
+			status VARCHAR(20) NOT NULL,
+			instruments_total INT NOT NULL DEFAULT 0,
+			instruments_failed INT NOT NULL DEFAULT 0,
+			candles_inserted BIGINT NOT NULL DEFAULT 0,
+			candles_updated BIGINT NOT NULL DEFAULT 0,
+			api_requests BIGINT NOT NULL DEFAULT 0,
+			api_candles BIGINT NOT NULL DEFAULT 0,
+			api_bytes BIGINT NOT NULL DEFAULT 0,
+			error TEXT NULL,
+			failed_figis TEXT[] NULL,
+			PRIMARY KEY (id)
+		);
+	`
+
+	// Создаем таблицу currency_pairs (валютные пары для пересчёта курсов)
+	currencyPairsTable := `
+		CREATE TABLE IF NOT EXISTS currency_pairs (
+			base_currency VARCHAR(3) NOT NULL,
+			quote_currency VARCHAR(3) NOT NULL,
+			figi VARCHAR(50) NOT NULL REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE,
+			nominal DECIMAL(20, 9) NOT NULL DEFAULT 1,
+			updated_at TIMESTAMPTZ DEFAULT NOW() NOT NULL,
+			PRIMARY KEY (base_currency, quote_currency)
+		);
+	`
+
+	// Создаем таблицу archive_files (хеши обработанных годовых архивов свечей)
+	archiveFilesTable := `
+		CREATE TABLE IF NOT EXISTS archive_files (
+			figi VARCHAR(50) NOT NULL REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE,
+			year INT NOT NULL,
+			sha256 VARCHAR(64) NOT NULL,
+			candles BIGINT NOT NULL DEFAULT 0,
+			processed_at TIMESTAMPTZ DEFAULT NOW() NOT NULL,
+			PRIMARY KEY (figi, year)
+		);
+	`
+
+	// Создаем таблицу session_vwap (дневной VWAP по минутным свечам, loader-vwap)
+	sessionVWAPTable := `
+		CREATE TABLE IF NOT EXISTS session_vwap (
+			figi VARCHAR(50) NOT NULL REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE,
+			day DATE NOT NULL,
+			vwap DECIMAL(20, 9) NULL,
+			volume BIGINT NOT NULL DEFAULT 0,
+			candles INT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMPTZ DEFAULT NOW() NOT NULL,
+			PRIMARY KEY (figi, day)
+		);
+	`
+
+	// Создаем таблицу accrued_interest (НКД облигаций по дням, loader-aci)
+	accruedInterestTable := `
+		CREATE TABLE IF NOT EXISTS accrued_interest (
+			figi VARCHAR(50) NOT NULL REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE,
+			date DATE NOT NULL,
+			value NUMERIC(20, 10) NOT NULL,
+			currency VARCHAR(3) NULL,
+			created_at TIMESTAMPTZ DEFAULT NOW() NULL,
+			PRIMARY KEY (figi, date)
+		);
+	`
+
+	// Создаем таблицу returns (доходность по ценам закрытия свечей, loader-returns)
+	returnsTable := `
+		CREATE TABLE IF NOT EXISTS returns (
+			figi VARCHAR(50) NOT NULL REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE,
+			time TIMESTAMP NOT NULL,
+			interval_type VARCHAR(30) NOT NULL,
+			method VARCHAR(10) NOT NULL,
+			total_return BOOLEAN NOT NULL DEFAULT FALSE,
+			value DOUBLE PRECISION NOT NULL,
+			updated_at TIMESTAMPTZ DEFAULT NOW() NOT NULL,
+			PRIMARY KEY (figi, interval_type, method, total_return, time)
+		);
+	`
+
+	// Создаем таблицу empty_loads (инструменты без свечей, не вернувшие свечей несколько запусков подряд)
+	emptyLoadsTable := `
+		CREATE TABLE IF NOT EXISTS empty_loads (
+			figi VARCHAR(50) NOT NULL REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE,
+			interval_type VARCHAR(30) NOT NULL,
+			empty_runs INT NOT NULL DEFAULT 0,
+			checked_at TIMESTAMPTZ DEFAULT NOW() NOT NULL,
+			PRIMARY KEY (figi, interval_type)
+		);
+	`
+
+	// Создаем таблицы групп инструментов (портфели, секторы) и их состава
+	watchlistGroupsTable := `
+		CREATE TABLE IF NOT EXISTS watchlist_groups (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(100) NOT NULL UNIQUE,
+			created_at TIMESTAMPTZ DEFAULT NOW() NOT NULL
+		);
+	`
+	watchlistMembersTable := `
+		CREATE TABLE IF NOT EXISTS watchlist_members (
+			group_id INT NOT NULL REFERENCES watchlist_groups(id) ON DELETE CASCADE,
+			figi VARCHAR(50) NOT NULL REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE,
+			added_at TIMESTAMPTZ DEFAULT NOW() NOT NULL,
+			PRIMARY KEY (group_id, figi)
+		);
+	`
+
+	// Создаем таблицу candle_backfill (прогресс загрузки истории от новых свечей к старым, loading.order: newest)
+	candleBackfillTable := `
+		CREATE TABLE IF NOT EXISTS candle_backfill (
+			figi VARCHAR(50) NOT NULL REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE,
+			interval_type VARCHAR(30) NOT NULL,
+			oldest_time TIMESTAMP NOT NULL,
+			complete BOOLEAN NOT NULL DEFAULT FALSE,
+			updated_at TIMESTAMPTZ DEFAULT NOW() NOT NULL,
+			PRIMARY KEY (figi, interval_type)
+		);
+	`
+
+	// Создаем таблицу load_coverage (полнота загрузки по торговому календарю, loading.coverage)
+	loadCoverageTable := `
+		CREATE TABLE IF NOT EXISTS load_coverage (
+			figi VARCHAR(50) NOT NULL REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE,
+			interval_type VARCHAR(30) NOT NULL,
+			candles BIGINT NOT NULL,
+			expected INT NULL,
+			completeness NUMERIC(8, 4) NULL,
+			loaded BOOLEAN NOT NULL,
+			from_time TIMESTAMP NOT NULL,
+			checked_at TIMESTAMPTZ DEFAULT NOW() NOT NULL,
+			PRIMARY KEY (figi, interval_type)
+		);
+	`
+
 	// data_sources должна быть создана первой
-	queries := []string{dataSourcesTable, instrumentsTable, candlesTable, dividendsTable}
-	for _, query := range queries {
+	return []string{dataSourcesTable, instrumentsTable, candlesTable, dividendsTable, runLogTable, currencyPairsTable, archiveFilesTable, sessionVWAPTable, accruedInterestTable, returnsTable, emptyLoadsTable, watchlistGroupsTable, watchlistMembersTable, candleBackfillTable, loadCoverageTable}
+}
+
+// CreateIndexesAndConstraints создает индексы и ограничения для таблиц
+func CreateIndexesAndConstraints(dbpool *pgxpool.Pool) error {
+	for _, query := range indexDefinitions() {
 		_, err := dbpool.Exec(context.Background(), query)
 		if err != nil {
-			return fmt.Errorf("ошибка создания таблицы: %w", err)
+			return fmt.Errorf("ошибка создания индекса/ограничения/представления: %w", err)
 		}
 	}
 
 	return nil
 }
 
-// CreateIndexesAndConstraints создает индексы и ограничения для таблиц
-func CreateIndexesAndConstraints(dbpool *pgxpool.Pool) error {
+// indexDefinitions возвращает DDL индексов, внешних ключей, триггеров и представления instrument_view
+func indexDefinitions() []string {
 	// Создаем индексы для оптимизации запросов
 	indexes := []string{
 		// Индексы для candles
@@ -166,29 +405,34 @@ func CreateIndexesAndConstraints(dbpool *pgxpool.Pool) error {
 		`CREATE INDEX IF NOT EXISTS idx_instruments_enabled ON instruments(enabled);`,
 		`CREATE INDEX IF NOT EXISTS idx_instruments_isin ON instruments(isin);`,
 		`CREATE INDEX IF NOT EXISTS idx_instruments_sector ON instruments(sector);`,
+		`CREATE INDEX IF NOT EXISTS idx_instruments_sector_normalized ON instruments(sector_normalized);`,
 		`CREATE INDEX IF NOT EXISTS idx_instruments_real_exchange ON instruments(real_exchange);`,
 		`CREATE INDEX IF NOT EXISTS idx_instruments_ipo_date ON instruments(ipo_date);`,
 		`CREATE INDEX IF NOT EXISTS idx_instruments_first_1min_candle_date ON instruments(first_1min_candle_date);`,
 		`CREATE INDEX IF NOT EXISTS idx_instruments_first_1day_candle_date ON instruments(first_1day_candle_date);`,
 		`CREATE INDEX IF NOT EXISTS idx_instruments_data_source_id ON instruments(data_source_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_instruments_enabled_at ON instruments(enabled_at);`,
 
 		// Индексы для dividends
 		`CREATE INDEX IF NOT EXISTS idx_dividends_figi ON dividends(figi);`,
 		`CREATE INDEX IF NOT EXISTS idx_dividends_payment_date ON dividends(payment_date);`,
+
+		// Индексы для run_log
+		`CREATE INDEX IF NOT EXISTS idx_run_log_loader_interval ON run_log(loader, interval_type, finished_at);`,
 	}
 
 	// Создаем внешние ключи для обеспечения целостности данных
 	foreignKeys := []string{
 		`DO $$ 
 		BEGIN
-			IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE constraint_name = 'candles_figi_fkey') THEN
+			IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE constraint_schema = current_schema() AND constraint_name = 'candles_figi_fkey') THEN
 				ALTER TABLE candles ADD CONSTRAINT candles_figi_fkey 
 					FOREIGN KEY (figi) REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE;
 			END IF;
 		END $$;`,
 		`DO $$ 
 		BEGIN
-			IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE constraint_name = 'dividends_figi_fkey') THEN
+			IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE constraint_schema = current_schema() AND constraint_name = 'dividends_figi_fkey') THEN
 				ALTER TABLE dividends ADD CONSTRAINT dividends_figi_fkey 
 					FOREIGN KEY (figi) REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE;
 			END IF;
@@ -217,25 +461,40 @@ func CreateIndexesAndConstraints(dbpool *pgxpool.Pool) error {
 			i.enabled,
 			i.last_loaded_time,
 			i.created_at,
-			i.updated_at
+			i.updated_at,
+			i.sector_normalized
 		FROM instruments i
 		LEFT JOIN data_sources ds ON i.data_source_id = ds.id;
 	`
 
-	// Выполняем создание индексов, ограничений и представления
-	queries := make([]string, 0, len(indexes)+len(foreignKeys)+newView)
+	// Триггер заполняет enabled_at при включении инструмента (enabled false -> true)
+	enabledAtTrigger := []string{
+		`CREATE OR REPLACE FUNCTION instruments_set_enabled_at() RETURNS trigger AS $$
+		BEGIN
+			IF NEW.enabled AND (TG_OP = 'INSERT' OR NOT OLD.enabled) THEN
+				NEW.enabled_at := NOW();
+			END IF;
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`DO $$ 
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'instruments_enabled_at_trigger' AND tgrelid = 'instruments'::regclass) THEN
+				CREATE TRIGGER instruments_enabled_at_trigger
+					BEFORE INSERT OR UPDATE OF enabled ON instruments
+					FOR EACH ROW EXECUTE FUNCTION instruments_set_enabled_at();
+			END IF;
+		END $$;`,
+	}
+
+	// Индексы, ограничения, триггеры и представление
+	queries := make([]string, 0, len(indexes)+len(foreignKeys)+len(enabledAtTrigger)+newView)
 	queries = append(queries, indexes...)
 	queries = append(queries, foreignKeys...)
+	queries = append(queries, enabledAtTrigger...)
 	queries = append(queries, createView)
 
-	for _, query := range queries {
-		_, err := dbpool.Exec(context.Background(), query)
-		if err != nil {
-			return fmt.Errorf("ошибка создания индекса/ограничения/представления: %w", err)
-		}
-	}
-
-	return nil
+	return queries
 }
 
 // MigrateDatabase выполняет миграции для существующих таблиц
@@ -244,9 +503,9 @@ func MigrateDatabase(dbpool *pgxpool.Pool) error {
 	addEnabledColumn := `
 		DO $$ 
 		BEGIN
-			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'instruments') THEN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'instruments') THEN
 				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
-					WHERE table_name = 'instruments' AND column_name = 'enabled') THEN
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'enabled') THEN
 					ALTER TABLE instruments ADD COLUMN enabled BOOLEAN DEFAULT FALSE;
 				END IF;
 			END IF;
@@ -257,7 +516,7 @@ func MigrateDatabase(dbpool *pgxpool.Pool) error {
 	addDividendsUniqueConstraint := `
 		DO $$ 
 		BEGIN
-			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'dividends') THEN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'dividends') THEN
 				-- Проверяем, есть ли дублирующиеся записи перед добавлением ограничения
 				IF EXISTS (
 					SELECT figi, payment_date, COUNT(*) 
@@ -275,7 +534,7 @@ func MigrateDatabase(dbpool *pgxpool.Pool) error {
 				END IF;
 				
 				IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints 
-					WHERE table_name = 'dividends' AND constraint_type = 'UNIQUE' 
+					WHERE table_schema = current_schema() AND table_name = 'dividends' AND constraint_type = 'UNIQUE' 
 					AND constraint_name LIKE '%figi%payment_date%') THEN
 					ALTER TABLE dividends ADD CONSTRAINT dividends_figi_payment_date_unique 
 						UNIQUE (figi, payment_date);
@@ -302,52 +561,64 @@ func MigrateDatabase(dbpool *pgxpool.Pool) error {
 	addInstrumentFields := `
 		DO $$ 
 		BEGIN
-			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'instruments') THEN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'instruments') THEN
 				-- Добавляем новые поля если их нет
 				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
-					WHERE table_name = 'instruments' AND column_name = 'isin') THEN
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'isin') THEN
 					ALTER TABLE instruments ADD COLUMN isin varchar(12) NULL;
 				END IF;
 				
 				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
-					WHERE table_name = 'instruments' AND column_name = 'short_enabled_flag') THEN
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'short_enabled_flag') THEN
 					ALTER TABLE instruments ADD COLUMN short_enabled_flag boolean DEFAULT false NOT NULL;
 				END IF;
 				
 				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
-					WHERE table_name = 'instruments' AND column_name = 'ipo_date') THEN
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'ipo_date') THEN
 					ALTER TABLE instruments ADD COLUMN ipo_date date NULL;
 				END IF;
 				
 				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
-					WHERE table_name = 'instruments' AND column_name = 'issue_size') THEN
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'issue_size') THEN
 					ALTER TABLE instruments ADD COLUMN issue_size bigint NULL;
 				END IF;
 				
 				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
-					WHERE table_name = 'instruments' AND column_name = 'sector') THEN
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'sector') THEN
 					ALTER TABLE instruments ADD COLUMN sector varchar(100) NULL;
 				END IF;
 				
 				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
-					WHERE table_name = 'instruments' AND column_name = 'real_exchange') THEN
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'real_exchange') THEN
 					ALTER TABLE instruments ADD COLUMN real_exchange varchar(50) NULL;
 				END IF;
 				
 				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
-					WHERE table_name = 'instruments' AND column_name = 'first_1min_candle_date') THEN
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'first_1min_candle_date') THEN
 					ALTER TABLE instruments ADD COLUMN first_1min_candle_date timestamp NULL;
 				END IF;
 				
 				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
-					WHERE table_name = 'instruments' AND column_name = 'first_1day_candle_date') THEN
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'first_1day_candle_date') THEN
 					ALTER TABLE instruments ADD COLUMN first_1day_candle_date timestamp NULL;
 				END IF;
 				
 				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
-					WHERE table_name = 'instruments' AND column_name = 'data_source_id') THEN
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'data_source_id') THEN
 					ALTER TABLE instruments ADD COLUMN data_source_id int4 NULL;
 				END IF;
+				
+				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'enabled_at') THEN
+					ALTER TABLE instruments ADD COLUMN enabled_at timestamp NULL;
+					-- Уже включённые инструменты считаем включёнными при создании
+					UPDATE instruments SET enabled_at = created_at WHERE enabled = true;
+				END IF;
+				
+				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'for_qual_investor_flag') THEN
+					ALTER TABLE instruments ADD COLUMN for_qual_investor_flag boolean DEFAULT false NOT NULL;
+				END IF;
 			END IF;
 		END $$;
 	`
@@ -356,33 +627,33 @@ func MigrateDatabase(dbpool *pgxpool.Pool) error {
 	addNewIndexes := `
 		DO $$ 
 		BEGIN
-			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'instruments') THEN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'instruments') THEN
 				-- Создаем индексы для новых полей если их нет
-				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_instruments_isin') THEN
+				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = current_schema() AND indexname = 'idx_instruments_isin') THEN
 					CREATE INDEX idx_instruments_isin ON instruments USING btree (isin);
 				END IF;
 				
-				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_instruments_sector') THEN
+				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = current_schema() AND indexname = 'idx_instruments_sector') THEN
 					CREATE INDEX idx_instruments_sector ON instruments USING btree (sector);
 				END IF;
 				
-				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_instruments_real_exchange') THEN
+				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = current_schema() AND indexname = 'idx_instruments_real_exchange') THEN
 					CREATE INDEX idx_instruments_real_exchange ON instruments USING btree (real_exchange);
 				END IF;
 				
-				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_instruments_ipo_date') THEN
+				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = current_schema() AND indexname = 'idx_instruments_ipo_date') THEN
 					CREATE INDEX idx_instruments_ipo_date ON instruments USING btree (ipo_date);
 				END IF;
 				
-				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_instruments_first_1min_candle_date') THEN
+				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = current_schema() AND indexname = 'idx_instruments_first_1min_candle_date') THEN
 					CREATE INDEX idx_instruments_first_1min_candle_date ON instruments USING btree (first_1min_candle_date);
 				END IF;
 				
-				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_instruments_first_1day_candle_date') THEN
+				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = current_schema() AND indexname = 'idx_instruments_first_1day_candle_date') THEN
 					CREATE INDEX idx_instruments_first_1day_candle_date ON instruments USING btree (first_1day_candle_date);
 				END IF;
 				
-				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_instruments_data_source_id') THEN
+				IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = current_schema() AND indexname = 'idx_instruments_data_source_id') THEN
 					CREATE INDEX idx_instruments_data_source_id ON instruments USING btree (data_source_id);
 				END IF;
 			END IF;
@@ -393,10 +664,10 @@ func MigrateDatabase(dbpool *pgxpool.Pool) error {
 	addDataSourceForeignKey := `
 		DO $$ 
 		BEGIN
-			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'instruments') 
-			   AND EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'data_sources') THEN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'instruments') 
+			   AND EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'data_sources') THEN
 				IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints 
-					WHERE table_name = 'instruments' AND constraint_name = 'instruments_data_source_id_fkey') THEN
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND constraint_name = 'instruments_data_source_id_fkey') THEN
 					ALTER TABLE instruments ADD CONSTRAINT instruments_data_source_id_fkey 
 						FOREIGN KEY (data_source_id) REFERENCES data_sources(id);
 				END IF;
@@ -404,6 +675,145 @@ func MigrateDatabase(dbpool *pgxpool.Pool) error {
 		END $$;
 	`
 
+	// Добавляем счётчики вставленных и обновлённых свечей в run_log
+	addRunLogCandleCounters := `
+		DO $$ 
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'run_log') THEN
+				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+					WHERE table_schema = current_schema() AND table_name = 'run_log' AND column_name = 'candles_inserted') THEN
+					ALTER TABLE run_log ADD COLUMN candles_inserted BIGINT NOT NULL DEFAULT 0;
+				END IF;
+
+				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+					WHERE table_schema = current_schema() AND table_name = 'run_log' AND column_name = 'candles_updated') THEN
+					ALTER TABLE run_log ADD COLUMN candles_updated BIGINT NOT NULL DEFAULT 0;
+				END IF;
+			END IF;
+		END $$;
+	`
+
+	// Добавляем счётчики запросов свечей к API в run_log
+	addRunLogFetchCounters := `
+		DO $$ 
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'run_log') THEN
+				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+					WHERE table_schema = current_schema() AND table_name = 'run_log' AND column_name = 'api_requests') THEN
+					ALTER TABLE run_log ADD COLUMN api_requests BIGINT NOT NULL DEFAULT 0;
+				END IF;
+
+				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+					WHERE table_schema = current_schema() AND table_name = 'run_log' AND column_name = 'api_candles') THEN
+					ALTER TABLE run_log ADD COLUMN api_candles BIGINT NOT NULL DEFAULT 0;
+				END IF;
+
+				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+					WHERE table_schema = current_schema() AND table_name = 'run_log' AND column_name = 'api_bytes') THEN
+					ALTER TABLE run_log ADD COLUMN api_bytes BIGINT NOT NULL DEFAULT 0;
+				END IF;
+			END IF;
+		END $$;
+	`
+
+	// Добавляем FIGI инструментов с ошибками запуска в run_log (повтор --retry-failed)
+	addRunLogFailedFigis := `
+		DO $$ 
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'run_log') THEN
+				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+					WHERE table_schema = current_schema() AND table_name = 'run_log' AND column_name = 'failed_figis') THEN
+					ALTER TABLE run_log ADD COLUMN failed_figis TEXT[] NULL;
+				END IF;
+			END IF;
+		END $$;
+	`
+
+	// Добавляем колонку source_file (CSV файл архива, из которого загружена свеча)
+	addCandlesSourceFile := `
+		DO $$ 
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'candles') THEN
+				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+					WHERE table_schema = current_schema() AND table_name = 'candles' AND column_name = 'source_file') THEN
+					ALTER TABLE candles ADD COLUMN source_file VARCHAR(255) NULL;
+				END IF;
+			END IF;
+		END $$;
+	`
+
+	// Исправляем границы месячных партиций свечей, созданных прежними версиями: верхняя граница
+	// 23:59:59 последнего дня месяца не включает последнюю секунду, граница - начало следующего месяца.
+	// Партиция переподключается с новыми границами в одной транзакции, данные не переносятся
+	fixCandlePartitionBounds := `
+		DO $$ 
+		DECLARE
+			part record;
+			lower_bound timestamp;
+			upper_bound timestamp;
+		BEGIN
+			FOR part IN
+				SELECT parent.relname AS parent_name, child.relname AS child_name,
+					pg_get_expr(child.relpartbound, child.oid) AS bound
+				FROM pg_inherits inh
+				JOIN pg_class child ON child.oid = inh.inhrelid
+				JOIN pg_class parent ON parent.oid = inh.inhparent
+				JOIN pg_namespace ns ON ns.oid = child.relnamespace
+				WHERE ns.nspname = current_schema()
+					AND parent.relname LIKE 'candles%'
+					AND pg_get_expr(child.relpartbound, child.oid) LIKE 'FOR VALUES FROM (''%'') TO (''____-__-__ 23:59:59'')'
+			LOOP
+				lower_bound := substring(part.bound from 'FROM \(''([^'']+)''\)')::timestamp;
+				upper_bound := substring(part.bound from 'TO \(''([^'']+)''\)')::timestamp + INTERVAL '1 second';
+				-- Только границы вида "конец месяца минус секунда"
+				IF upper_bound <> date_trunc('month', upper_bound) THEN
+					CONTINUE;
+				END IF;
+				EXECUTE format('ALTER TABLE %I DETACH PARTITION %I', part.parent_name, part.child_name);
+				EXECUTE format('ALTER TABLE %I ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)',
+					part.parent_name, part.child_name,
+					to_char(lower_bound, 'YYYY-MM-DD HH24:MI:SS'), to_char(upper_bound, 'YYYY-MM-DD HH24:MI:SS'));
+			END LOOP;
+		END $$;
+	`
+
+	// Заполняем пустую валюту дивидендов валютой инструмента
+	backfillDividendCurrency := `
+		DO $$ 
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'dividends')
+				AND EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'instruments') THEN
+				UPDATE dividends d SET currency = i.currency
+				FROM instruments i
+				WHERE d.figi = i.figi AND COALESCE(d.currency, '') = '' AND COALESCE(i.currency, '') <> '';
+			END IF;
+		END $$;
+	`
+
+	// Приводим instrument_type к каноническому виду (нижний регистр, config.InstrumentType)
+	normalizeInstrumentType := `
+		DO $$ 
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'instruments') THEN
+				UPDATE instruments SET instrument_type = lower(trim(instrument_type))
+				WHERE instrument_type <> lower(trim(instrument_type));
+			END IF;
+		END $$;
+	`
+
+	// Добавляем канонический сектор instruments.sector_normalized (заполняется loader-instruments)
+	addSectorNormalized := `
+		DO $$ 
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'instruments') THEN
+				IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+					WHERE table_schema = current_schema() AND table_name = 'instruments' AND column_name = 'sector_normalized') THEN
+					ALTER TABLE instruments ADD COLUMN sector_normalized varchar(50) NULL;
+				END IF;
+			END IF;
+		END $$;
+	`
+
 	// Обновляем представление instrument_view
 	updateInstrumentView := `
 		DROP VIEW IF EXISTS instrument_view;
@@ -427,7 +837,8 @@ func MigrateDatabase(dbpool *pgxpool.Pool) error {
 			i.enabled,
 			i.last_loaded_time,
 			i.created_at,
-			i.updated_at
+			i.updated_at,
+			i.sector_normalized
 		FROM instruments i
 		LEFT JOIN data_sources ds ON i.data_source_id = ds.id;
 	`
@@ -439,6 +850,14 @@ func MigrateDatabase(dbpool *pgxpool.Pool) error {
 		addInstrumentFields,
 		addNewIndexes,
 		addDataSourceForeignKey,
+		addRunLogCandleCounters,
+		addCandlesSourceFile,
+		fixCandlePartitionBounds,
+		backfillDividendCurrency,
+		addRunLogFetchCounters,
+		normalizeInstrumentType,
+		addSectorNormalized,
+		addRunLogFailedFigis,
 		updateInstrumentView,
 	}
 
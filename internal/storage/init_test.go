@@ -0,0 +1,121 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"regexp"
+	"testing"
+	"time"
+	_ "time/tzdata" // часовые пояса с переходом на летнее время независимо от системы
+)
+
+// partitionDDL разбирает имя и границы партиции из DDL
+var partitionDDL = regexp.MustCompile(`CREATE TABLE IF NOT EXISTS (\S+) PARTITION OF (\S+)\s+FOR VALUES FROM \('([^']+)'\) TO \('([^']+)'\)`)
+
+func TestPartitionDefinition(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	msk := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		wantName string
+		wantFrom string
+		wantTo   string
+	}{
+		{
+			name:     "mid month",
+			at:       time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC),
+			wantName: "candles_2024_06", wantFrom: "2024-06-01 00:00:00", wantTo: "2024-07-01 00:00:00",
+		},
+		{
+			name:     "month start",
+			at:       time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+			wantName: "candles_2024_06", wantFrom: "2024-06-01 00:00:00", wantTo: "2024-07-01 00:00:00",
+		},
+		{
+			name:     "last second fraction of month",
+			at:       time.Date(2024, time.June, 30, 23, 59, 59, 999999999, time.UTC),
+			wantName: "candles_2024_06", wantFrom: "2024-06-01 00:00:00", wantTo: "2024-07-01 00:00:00",
+		},
+		{
+			name:     "local time already in next month",
+			at:       time.Date(2024, time.July, 1, 2, 0, 0, 0, msk),
+			wantName: "candles_2024_06", wantFrom: "2024-06-01 00:00:00", wantTo: "2024-07-01 00:00:00",
+		},
+		{
+			name:     "dst spring forward",
+			at:       time.Date(2024, time.March, 10, 3, 30, 0, 0, newYork),
+			wantName: "candles_2024_03", wantFrom: "2024-03-01 00:00:00", wantTo: "2024-04-01 00:00:00",
+		},
+		{
+			name:     "dst month end in local time",
+			at:       time.Date(2024, time.March, 31, 21, 30, 0, 0, newYork),
+			wantName: "candles_2024_04", wantFrom: "2024-04-01 00:00:00", wantTo: "2024-05-01 00:00:00",
+		},
+		{
+			name:     "dst fall back",
+			at:       time.Date(2024, time.November, 3, 1, 30, 0, 0, newYork),
+			wantName: "candles_2024_11", wantFrom: "2024-11-01 00:00:00", wantTo: "2024-12-01 00:00:00",
+		},
+		{
+			name:     "leap day",
+			at:       time.Date(2024, time.February, 29, 23, 59, 59, 0, time.UTC),
+			wantName: "candles_2024_02", wantFrom: "2024-02-01 00:00:00", wantTo: "2024-03-01 00:00:00",
+		},
+		{
+			name:     "february without leap day",
+			at:       time.Date(2023, time.February, 28, 23, 59, 59, 0, time.UTC),
+			wantName: "candles_2023_02", wantFrom: "2023-02-01 00:00:00", wantTo: "2023-03-01 00:00:00",
+		},
+		{
+			name:     "december",
+			at:       time.Date(2024, time.December, 31, 23, 59, 59, 500000000, time.UTC),
+			wantName: "candles_2024_12", wantFrom: "2024-12-01 00:00:00", wantTo: "2025-01-01 00:00:00",
+		},
+		{
+			name:     "january after december",
+			at:       time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			wantName: "candles_2025_01", wantFrom: "2025-01-01 00:00:00", wantTo: "2025-02-01 00:00:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ddl := partitionDefinition(SharedCandleTable, tt.at)
+			match := partitionDDL.FindStringSubmatch(ddl)
+			if match == nil {
+				t.Fatalf("partitionDefinition() вернул неожиданный DDL: %s", ddl)
+			}
+			if match[1] != tt.wantName || match[2] != SharedCandleTable {
+				t.Errorf("партиция %s таблицы %s, want %s таблицы %s", match[1], match[2], tt.wantName, SharedCandleTable)
+			}
+			if match[3] != tt.wantFrom || match[4] != tt.wantTo {
+				t.Errorf("границы [%s, %s), want [%s, %s)", match[3], match[4], tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestPartitionDefinitionCoversContiguousMonths(t *testing.T) {
+	// Верхняя граница партиции совпадает с нижней границей следующей: между ними нет зазора
+	for month := time.Date(2023, time.November, 1, 0, 0, 0, 0, time.UTC); month.Year() < 2025; month = month.AddDate(0, 1, 0) {
+		current := partitionDDL.FindStringSubmatch(partitionDefinition(SharedCandleTable, month))
+		next := partitionDDL.FindStringSubmatch(partitionDefinition(SharedCandleTable, month.AddDate(0, 1, 0)))
+		if current == nil || next == nil {
+			t.Fatalf("неожиданный DDL для %s", month.Format("2006-01"))
+		}
+		if current[4] != next[3] {
+			t.Errorf("%s: верхняя граница %s, а следующая партиция начинается с %s", month.Format("2006-01"), current[4], next[3])
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"market-loader/pkg/config"
+)
+
+// InstrumentFilterSource возвращает текущую политику отбора инструментов (allowlist/denylist)
+type InstrumentFilterSource func() (*config.InstrumentFilter, error)
+
+var (
+	instrumentFilterMu     sync.RWMutex
+	instrumentFilterSource InstrumentFilterSource
+
+	// excludeQualOnly не загружать инструменты только для квалифицированных инвесторов
+	excludeQualOnly atomic.Bool
+)
+
+// SetExcludeQualOnly включает исключение инструментов только для квалифицированных инвесторов
+// (for_qual_investor_flag) из отбора включённых инструментов
+func SetExcludeQualOnly(enabled bool) {
+	excludeQualOnly.Store(enabled)
+}
+
+// SetInstrumentFilterSource задаёт источник политики отбора включённых инструментов (nil - без ограничения).
+// Политика запрашивается при каждом отборе, поэтому изменения списков применяются без перезапуска
+func SetInstrumentFilterSource(source InstrumentFilterSource) {
+	instrumentFilterMu.Lock()
+	defer instrumentFilterMu.Unlock()
+	instrumentFilterSource = source
+}
+
+// filterInstruments оставляет инструменты, разрешённые политикой отбора
+// (и не только для квалифицированных инвесторов при SetExcludeQualOnly)
+func filterInstruments(instruments []Instrument) ([]Instrument, error) {
+	instrumentFilterMu.RLock()
+	source := instrumentFilterSource
+	instrumentFilterMu.RUnlock()
+
+	var filter *config.InstrumentFilter
+	if source != nil {
+		var err error
+		if filter, err = source(); err != nil {
+			return nil, err
+		}
+	}
+	excludeQual := excludeQualOnly.Load()
+	if filter == nil && !excludeQual {
+		return instruments, nil
+	}
+
+	allowed := instruments[:0]
+	for _, instrument := range instruments {
+		if excludeQual && instrument.ForQualInvestorFlag {
+			continue
+		}
+		if filter == nil || filter.Allows(instrument.Figi, instrument.Ticker) {
+			allowed = append(allowed, instrument)
+		}
+	}
+	return allowed, nil
+}
@@ -10,9 +10,14 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"market-loader/pkg/config"
+
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 )
@@ -22,7 +27,7 @@ type Instrument struct {
 	Figi              string
 	Ticker            string
 	Name              string
-	InstrumentType    string
+	InstrumentType    config.InstrumentType
 	Currency          string
 	LotSize           int32
 	MinPriceIncrement float64
@@ -32,7 +37,8 @@ type Instrument struct {
 	ShortEnabledFlag  bool      // Флаг доступности для шорта
 	IpoDate           time.Time // Дата IPO (для акций)
 	IssueSize         int64     // Размер выпуска
-	Sector            string    // Сектор экономики
+	Sector            string    // Сектор экономики (как в API)
+	SectorNormalized  string    // Канонический сектор (config.NormalizeSector)
 	RealExchange      string    // Реальная биржа торговли
 	// Даты первых свечей для оптимизации загрузки
 	First1MinCandleDate time.Time // Дата первой 1-минутной свечи
@@ -74,55 +80,128 @@ type Instrument struct {
 }
 
 // SaveInstrument сохраняет информацию об инструменте
+//
+// Deprecated: используйте SaveInstrumentMetadata
 func SaveInstrument(ctx context.Context, dbpool *pgxpool.Pool, instrument Instrument) error {
-	query := `
-		INSERT INTO instruments (
-			figi, ticker, name, instrument_type, currency, lot_size, min_price_increment, 
-			trading_status, enabled, isin, short_enabled_flag, ipo_date, issue_size, 
-			sector, real_exchange, first_1min_candle_date, first_1day_candle_date, 
-			data_source_id, created_at, updated_at
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
-		ON CONFLICT (figi) DO UPDATE SET
-			ticker = EXCLUDED.ticker,
-			name = EXCLUDED.name,
-			instrument_type = EXCLUDED.instrument_type,
-			currency = EXCLUDED.currency,
-			lot_size = EXCLUDED.lot_size,
-			min_price_increment = EXCLUDED.min_price_increment,
-			trading_status = EXCLUDED.trading_status,
-			isin = EXCLUDED.isin,
-			short_enabled_flag = EXCLUDED.short_enabled_flag,
-			ipo_date = EXCLUDED.ipo_date,
-			issue_size = EXCLUDED.issue_size,
-			sector = EXCLUDED.sector,
-			real_exchange = EXCLUDED.real_exchange,
-			first_1min_candle_date = EXCLUDED.first_1min_candle_date,
-			first_1day_candle_date = EXCLUDED.first_1day_candle_date,
-			data_source_id = EXCLUDED.data_source_id,
-			-- Не изменяем флаг enabled при обновлении существующих записей
-			updated_at = NOW()
-	`
+	return SaveInstrumentMetadata(ctx, dbpool, instrument)
+}
+
+// SaveInstrumentMetadata сохраняет данные инструмента из справочника провайдера (upsert по figi).
+// Обновляет: ticker, name, instrument_type, currency, lot_size, min_price_increment, trading_status,
+// isin, short_enabled_flag, ipo_date, issue_size, sector, sector_normalized, real_exchange, first_1min_candle_date,
+// first_1day_candle_date, data_source_id, for_qual_investor_flag, updated_at.
+// Эксплуатационные поля существующего инструмента (enabled, enabled_at, last_loaded_time) не изменяются:
+// enabled задаётся только при вставке нового инструмента, далее - SetInstrumentEnabled,
+// last_loaded_time - UpdateLastLoadedTime
+func SaveInstrumentMetadata(ctx context.Context, dbpool *pgxpool.Pool, instrument Instrument) error {
+	query := `INSERT INTO instruments (` + instrumentColumns + `) VALUES ` + instrumentPlaceholders(0) + instrumentUpsert
+
+	if err := execWithRetry(ctx, dbpool, query, instrumentArgs(instrument)...); err != nil {
+		return fmt.Errorf("ошибка сохранения инструмента: %w", err)
+	}
+	return nil
+}
+
+// SaveInstrumentsMetadata сохраняет пачку инструментов одним запросом (multi-row upsert по figi)
+// с той же логикой обновления, что и SaveInstrumentMetadata. При повторе FIGI в пачке сохраняется последний
+func SaveInstrumentsMetadata(ctx context.Context, dbpool *pgxpool.Pool, instruments []Instrument) error {
+	if len(instruments) == 0 {
+		return nil
+	}
+
+	// ON CONFLICT DO UPDATE не может изменить одну строку дважды за запрос
+	index := make(map[string]int, len(instruments))
+	unique := make([]Instrument, 0, len(instruments))
+	for _, instrument := range instruments {
+		if i, exists := index[instrument.Figi]; exists {
+			unique[i] = instrument
+			continue
+		}
+		index[instrument.Figi] = len(unique)
+		unique = append(unique, instrument)
+	}
+
+	values := make([]string, 0, len(unique))
+	args := make([]interface{}, 0, len(unique)*instrumentColumnCount)
+	for _, instrument := range unique {
+		values = append(values, instrumentPlaceholders(len(args)))
+		args = append(args, instrumentArgs(instrument)...)
+	}
+	query := `INSERT INTO instruments (` + instrumentColumns + `) VALUES ` + strings.Join(values, ", ") + instrumentUpsert
+
+	if err := execWithRetry(ctx, dbpool, query, args...); err != nil {
+		return fmt.Errorf("ошибка сохранения пачки инструментов (%d): %w", len(unique), err)
+	}
+	return nil
+}
+
+// instrumentColumns столбцы справочника инструмента в порядке instrumentArgs
+const instrumentColumns = `
+	figi, ticker, name, instrument_type, currency, lot_size, min_price_increment,
+	trading_status, enabled, isin, short_enabled_flag, ipo_date, issue_size,
+	sector, real_exchange, first_1min_candle_date, first_1day_candle_date,
+	data_source_id, created_at, updated_at, for_qual_investor_flag, sector_normalized
+`
+
+// instrumentColumnCount количество столбцов instrumentColumns
+const instrumentColumnCount = 22
+
+// instrumentUpsert обновление существующего инструмента: enabled и last_loaded_time не изменяются
+const instrumentUpsert = `
+	ON CONFLICT (figi) DO UPDATE SET
+		ticker = EXCLUDED.ticker,
+		name = EXCLUDED.name,
+		instrument_type = EXCLUDED.instrument_type,
+		currency = EXCLUDED.currency,
+		lot_size = EXCLUDED.lot_size,
+		min_price_increment = EXCLUDED.min_price_increment,
+		trading_status = EXCLUDED.trading_status,
+		isin = EXCLUDED.isin,
+		short_enabled_flag = EXCLUDED.short_enabled_flag,
+		ipo_date = EXCLUDED.ipo_date,
+		issue_size = EXCLUDED.issue_size,
+		sector = EXCLUDED.sector,
+		sector_normalized = EXCLUDED.sector_normalized,
+		real_exchange = EXCLUDED.real_exchange,
+		first_1min_candle_date = EXCLUDED.first_1min_candle_date,
+		first_1day_candle_date = EXCLUDED.first_1day_candle_date,
+		data_source_id = EXCLUDED.data_source_id,
+		for_qual_investor_flag = EXCLUDED.for_qual_investor_flag,
+		-- Не изменяем enabled и last_loaded_time при обновлении существующих записей
+		updated_at = NOW()
+`
+
+// instrumentPlaceholders возвращает параметры строки VALUES для инструмента, начиная с $offset+1
+func instrumentPlaceholders(offset int) string {
+	placeholders := make([]string, instrumentColumnCount)
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", offset+i+1)
+	}
+	// Пустой канонический сектор сохраняется как NULL
+	placeholders[instrumentColumnCount-1] = fmt.Sprintf("NULLIF($%d, '')", offset+instrumentColumnCount)
+	return "(" + strings.Join(placeholders, ", ") + ")"
+}
 
-	_, err := dbpool.Exec(ctx, query,
+// instrumentArgs возвращает значения столбцов instrumentColumns
+func instrumentArgs(instrument Instrument) []interface{} {
+	return []interface{}{
 		instrument.Figi, instrument.Ticker, instrument.Name, instrument.InstrumentType,
 		instrument.Currency, instrument.LotSize, instrument.MinPriceIncrement, instrument.TradingStatus, instrument.Enabled,
 		instrument.Isin, instrument.ShortEnabledFlag, instrument.IpoDate, instrument.IssueSize,
 		instrument.Sector, instrument.RealExchange, instrument.First1MinCandleDate, instrument.First1DayCandleDate,
-		instrument.DataSourceID, instrument.CreatedAt, instrument.UpdatedAt)
-
-	if err != nil {
-		return fmt.Errorf("ошибка сохранения инструмента: %w", err)
+		instrument.DataSourceID, instrument.CreatedAt, instrument.UpdatedAt, instrument.ForQualInvestorFlag,
+		instrument.SectorNormalized,
 	}
-	return nil
 }
 
 // getInstrumentsInternal внутренняя функция для получения инструментов
-func getInstrumentsInternal(ctx context.Context, dbpool *pgxpool.Pool, instrumentType string, enabledOnly bool) ([]Instrument, error) {
+func getInstrumentsInternal(ctx context.Context, dbpool *pgxpool.Pool, instrumentType config.InstrumentType, enabledOnly bool) ([]Instrument, error) {
 	var query string
 	var args []interface{}
 
-	baseQuery := `SELECT figi, ticker, name, instrument_type, data_source_id, last_loaded_time, ipo_date
+	baseQuery := `SELECT figi, ticker, name, instrument_type, data_source_id, last_loaded_time, ipo_date,
+				for_qual_investor_flag,
+				COALESCE(first_1min_candle_date, '0001-01-01'), COALESCE(first_1day_candle_date, '0001-01-01')
 				FROM instruments 
 				WHERE trading_status = 'normal_trading'`
 	// baseQuery := `SELECT figi, ticker, name, instrument_type, currency, lot_size, min_price_increment,
@@ -179,6 +258,9 @@ func getInstrumentsInternal(ctx context.Context, dbpool *pgxpool.Pool, instrumen
 			// &instrument.UpdatedAt,
 			&instrument.LastLoadedTime,
 			&instrument.IpoDate,
+			&instrument.ForQualInvestorFlag,
+			&instrument.First1MinCandleDate,
+			&instrument.First1DayCandleDate,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка сканирования инструмента: %w", err)
@@ -190,6 +272,10 @@ func getInstrumentsInternal(ctx context.Context, dbpool *pgxpool.Pool, instrumen
 		return nil, fmt.Errorf("ошибка итерации по инструментам: %w", err)
 	}
 
+	// Включённые инструменты дополнительно отбираются по allowlist/denylist
+	if enabledOnly {
+		return filterInstruments(instruments)
+	}
 	return instruments, nil
 }
 
@@ -208,17 +294,80 @@ func LoadInstruments(ctx context.Context, dbpool *pgxpool.Pool, logger *logrus.L
 }
 
 // GetInstruments получает список инструментов из базы данных
-func GetInstruments(ctx context.Context, dbpool *pgxpool.Pool, instrumentType string) ([]Instrument, error) {
+func GetInstruments(ctx context.Context, dbpool *pgxpool.Pool, instrumentType config.InstrumentType) ([]Instrument, error) {
 	return getInstrumentsInternal(ctx, dbpool, instrumentType, false)
 }
 
 // GetEnabledInstruments получает только включенные инструменты для загрузки свечей
-func GetEnabledInstruments(ctx context.Context, dbpool *pgxpool.Pool, instrumentType string) ([]Instrument, error) {
+func GetEnabledInstruments(ctx context.Context, dbpool *pgxpool.Pool, instrumentType config.InstrumentType) ([]Instrument, error) {
 	return getInstrumentsInternal(ctx, dbpool, instrumentType, true)
 }
 
+// GetInstrumentsEnabledSince получает инструменты, включённые (enabled_at) после since
+func GetInstrumentsEnabledSince(ctx context.Context, dbpool *pgxpool.Pool, since time.Time) ([]Instrument, error) {
+	query := `SELECT figi, ticker, name, instrument_type, data_source_id, last_loaded_time, ipo_date,
+				for_qual_investor_flag,
+				COALESCE(first_1min_candle_date, '0001-01-01'), COALESCE(first_1day_candle_date, '0001-01-01')
+				FROM instruments 
+				WHERE trading_status = 'normal_trading' AND enabled = true AND enabled_at > $1
+				ORDER BY instrument_type, ticker`
+
+	rows, err := dbpool.Query(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса новых включенных инструментов: %w", err)
+	}
+	defer rows.Close()
+
+	var instruments []Instrument
+	for rows.Next() {
+		var instrument Instrument
+		err := rows.Scan(
+			&instrument.Figi,
+			&instrument.Ticker,
+			&instrument.Name,
+			&instrument.InstrumentType,
+			&instrument.DataSourceID,
+			&instrument.LastLoadedTime,
+			&instrument.IpoDate,
+			&instrument.ForQualInvestorFlag,
+			&instrument.First1MinCandleDate,
+			&instrument.First1DayCandleDate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования инструмента: %w", err)
+		}
+		instrument.Enabled = true
+		instruments = append(instruments, instrument)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по инструментам: %w", err)
+	}
+
+	return filterInstruments(instruments)
+}
+
+// DisableInstrument выключает загрузку свечей по инструменту (enabled = false)
+func DisableInstrument(ctx context.Context, dbpool *pgxpool.Pool, figi string) error {
+	return SetInstrumentEnabled(ctx, dbpool, figi, false)
+}
+
+// SetInstrumentEnabled включает или выключает загрузку свечей по инструменту.
+// Обновляет только enabled и updated_at (enabled_at заполняет триггер при включении)
+func SetInstrumentEnabled(ctx context.Context, dbpool *pgxpool.Pool, figi string, enabled bool) error {
+	query := `UPDATE instruments SET enabled = $2, updated_at = NOW() WHERE figi = $1`
+
+	if err := execWithRetry(ctx, dbpool, query, figi, enabled); err != nil {
+		if enabled {
+			return fmt.Errorf("ошибка включения инструмента: %w", err)
+		}
+		return fmt.Errorf("ошибка выключения инструмента: %w", err)
+	}
+	return nil
+}
+
 // UpdateLastLoadedTime обновляет время последней загрузки для инструмента
-// поле для информации
+// поле для информации, обновляет только last_loaded_time
 func UpdateLastLoadedTime(ctx context.Context, dbpool *pgxpool.Pool, figi string, lastLoadedTime time.Time) error {
 	query := `
 		UPDATE instruments 
@@ -226,10 +375,123 @@ func UpdateLastLoadedTime(ctx context.Context, dbpool *pgxpool.Pool, figi string
 		WHERE figi = $2
 	`
 
-	_, err := dbpool.Exec(ctx, query, lastLoadedTime, figi)
+	err := execWithRetry(ctx, dbpool, query, lastLoadedTime, figi)
 	if err != nil {
 		return fmt.Errorf("ошибка обновления времени последней загрузки: %w", err)
 	}
 
 	return nil
 }
+
+// InstrumentView строка представления instrument_view (инструмент с названием источника данных)
+type InstrumentView struct {
+	Ticker              string
+	Figi                string
+	Name                string
+	InstrumentType      config.InstrumentType
+	Currency            string
+	LotSize             int32
+	Isin                string
+	ShortEnabledFlag    bool
+	IpoDate             *time.Time
+	IssueSize           int64
+	Sector              string
+	RealExchange        string
+	First1MinCandleDate *time.Time
+	First1DayCandleDate *time.Time
+	DataSourceName      string // Название источника данных из data_sources
+	Enabled             bool
+	LastLoadedTime      *time.Time
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+// InstrumentViewFilter фильтр выборки из instrument_view (пустые поля - без фильтра)
+type InstrumentViewFilter struct {
+	InstrumentType config.InstrumentType
+	Ticker         string
+	EnabledOnly    bool
+}
+
+// GetInstrumentView получает инструменты из представления instrument_view
+func GetInstrumentView(ctx context.Context, dbpool *pgxpool.Pool, filter InstrumentViewFilter) ([]InstrumentView, error) {
+	query := `
+		SELECT ticker, figi, name, instrument_type, currency, lot_size,
+			COALESCE(isin, ''), short_enabled_flag, ipo_date, COALESCE(issue_size, 0),
+			COALESCE(sector, ''), COALESCE(real_exchange, ''),
+			first_1min_candle_date, first_1day_candle_date,
+			COALESCE(data_source_name, ''), enabled, last_loaded_time, created_at, updated_at
+		FROM instrument_view
+		WHERE ($1 = '' OR instrument_type = $1)
+			AND ($2 = '' OR ticker = $2)
+			AND (NOT $3 OR enabled = true)
+		ORDER BY instrument_type, ticker
+	`
+
+	rows, err := dbpool.Query(ctx, query, filter.InstrumentType, filter.Ticker, filter.EnabledOnly)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса instrument_view: %w", err)
+	}
+	defer rows.Close()
+
+	var instruments []InstrumentView
+	for rows.Next() {
+		var instrument InstrumentView
+		err := rows.Scan(
+			&instrument.Ticker,
+			&instrument.Figi,
+			&instrument.Name,
+			&instrument.InstrumentType,
+			&instrument.Currency,
+			&instrument.LotSize,
+			&instrument.Isin,
+			&instrument.ShortEnabledFlag,
+			&instrument.IpoDate,
+			&instrument.IssueSize,
+			&instrument.Sector,
+			&instrument.RealExchange,
+			&instrument.First1MinCandleDate,
+			&instrument.First1DayCandleDate,
+			&instrument.DataSourceName,
+			&instrument.Enabled,
+			&instrument.LastLoadedTime,
+			&instrument.CreatedAt,
+			&instrument.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования instrument_view: %w", err)
+		}
+		instruments = append(instruments, instrument)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по instrument_view: %w", err)
+	}
+
+	return instruments, nil
+}
+
+// ErrUnknownInstrument инструмента нет в таблице instruments (свечи нельзя сохранить из-за FK)
+var ErrUnknownInstrument = errors.New("инструмент отсутствует в таблице instruments")
+
+// knownInstruments кеш FIGI, наличие которых в instruments уже подтверждено
+// кешируется только положительный результат, чтобы после синхронизации инструмент сразу находился
+var knownInstruments sync.Map
+
+// InstrumentExists проверяет наличие инструмента в таблице instruments (с кешированием)
+func InstrumentExists(ctx context.Context, dbpool *pgxpool.Pool, figi string) (bool, error) {
+	if _, ok := knownInstruments.Load(figi); ok {
+		return true, nil
+	}
+
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM instruments WHERE figi = $1)`
+	if err := dbpool.QueryRow(ctx, query, figi).Scan(&exists); err != nil {
+		return false, fmt.Errorf("ошибка проверки наличия инструмента: %w", err)
+	}
+
+	if exists {
+		knownInstruments.Store(figi, struct{}{})
+	}
+	return exists, nil
+}
@@ -0,0 +1,427 @@
+//go:build integration
+
+// Интеграционные тесты слоя хранения на настоящем PostgreSQL
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// Запуск: go test -tags integration ./internal/storage/
+// PostgreSQL поднимается в Docker-контейнере и удаляется после тестов;
+// без Docker тесты пропускаются
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	testPostgresImage    = "postgres:16-alpine"
+	testPostgresUser     = "loader"
+	testPostgresPassword = "loader"
+	testPostgresDB       = "market_loader"
+	testFigi             = "TEST00000001"
+)
+
+// testDBConfig - параметры подключения к тестовому контейнеру
+var testDBConfig *config.DatabaseConfig
+
+func TestMain(m *testing.M) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		fmt.Println("docker не найден, интеграционные тесты пропущены")
+		os.Exit(0)
+	}
+
+	dbConfig, stop, err := startPostgres()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "не удалось запустить PostgreSQL: %v\n", err)
+		os.Exit(1)
+	}
+	testDBConfig = dbConfig
+
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+// startPostgres запускает контейнер PostgreSQL и ждёт, пока он начнёт принимать подключения
+func startPostgres() (*config.DatabaseConfig, func(), error) {
+	out, err := exec.Command("docker", "run", "-d", "--rm",
+		"-e", "POSTGRES_USER="+testPostgresUser,
+		"-e", "POSTGRES_PASSWORD="+testPostgresPassword,
+		"-e", "POSTGRES_DB="+testPostgresDB,
+		"-p", "127.0.0.1::5432",
+		testPostgresImage).Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("docker run: %w", err)
+	}
+	id := strings.TrimSpace(string(out))
+	stop := func() {
+		_ = exec.Command("docker", "rm", "-f", id).Run()
+	}
+
+	out, err = exec.Command("docker", "port", id, "5432/tcp").Output()
+	if err != nil {
+		stop()
+		return nil, nil, fmt.Errorf("docker port: %w", err)
+	}
+	address := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	_, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		stop()
+		return nil, nil, fmt.Errorf("неожиданный адрес контейнера %q: %w", address, err)
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		stop()
+		return nil, nil, fmt.Errorf("неожиданный порт контейнера %q: %w", portText, err)
+	}
+
+	dbConfig := &config.DatabaseConfig{
+		Host:     "127.0.0.1",
+		Port:     port,
+		User:     testPostgresUser,
+		Password: testPostgresPassword,
+		DBName:   testPostgresDB,
+		SSLMode:  "disable",
+	}
+	if err := waitForPostgres(dbConfig, time.Minute); err != nil {
+		stop()
+		return nil, nil, err
+	}
+	return dbConfig, stop, nil
+}
+
+// waitForPostgres ждёт готовности сервера не дольше timeout
+func waitForPostgres(dbConfig *config.DatabaseConfig, timeout time.Duration) error {
+	dbURL := fmt.Sprintf("postgresql://%s:%s@%s:%d/%s?sslmode=%s",
+		dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.DBName, dbConfig.SSLMode)
+	deadline := time.Now().Add(timeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		conn, err := pgx.Connect(ctx, dbURL)
+		if err == nil {
+			err = conn.Ping(ctx)
+			_ = conn.Close(ctx)
+		}
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("PostgreSQL не готов за %v: %w", timeout, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// quietLogger возвращает логгер без вывода
+func quietLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// saveTestInstrument сохраняет тестовый инструмент: свечи и дивиденды ссылаются на instruments
+func saveTestInstrument(ctx context.Context, t *testing.T, dbpool *pgxpool.Pool) {
+	t.Helper()
+	now := time.Now().UTC().Truncate(time.Second)
+	err := SaveInstrumentMetadata(ctx, dbpool, Instrument{
+		Figi:           testFigi,
+		Ticker:         "TEST",
+		Name:           "Тестовый инструмент",
+		InstrumentType: config.InstrumentTypeShare,
+		Currency:       "rub",
+		LotSize:        1,
+		Enabled:        true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	})
+	if err != nil {
+		t.Fatalf("SaveInstrumentMetadata: %v", err)
+	}
+}
+
+func quotation(value float64) *pb.Quotation {
+	units := int64(value)
+	return &pb.Quotation{Units: units, Nano: int32((value - float64(units)) * 1e9)}
+}
+
+func historicCandle(at time.Time, open, high, low, closePrice float64, volume int64) *pb.HistoricCandle {
+	return &pb.HistoricCandle{
+		Open:       quotation(open),
+		High:       quotation(high),
+		Low:        quotation(low),
+		Close:      quotation(closePrice),
+		Volume:     volume,
+		Time:       timestamppb.New(at),
+		IsComplete: true,
+	}
+}
+
+func TestConnectToDatabaseIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+
+	// Повторный запуск миграций на уже инициализированной БД не должен падать
+	for i := 0; i < 2; i++ {
+		dbpool, err := ConnectToDatabase(ctx, testDBConfig)
+		if err != nil {
+			t.Fatalf("ConnectToDatabase (запуск %d): %v", i+1, err)
+		}
+		dbpool.Close()
+	}
+
+	dbpool, err := ConnectToDatabase(ctx, testDBConfig)
+	if err != nil {
+		t.Fatalf("ConnectToDatabase: %v", err)
+	}
+	defer dbpool.Close()
+
+	for _, table := range []string{"instruments", "candles", "dividends"} {
+		var exists bool
+		if err := dbpool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+			t.Fatalf("проверка таблицы %s: %v", table, err)
+		}
+		if !exists {
+			t.Errorf("таблица %s не создана", table)
+		}
+	}
+}
+
+func TestSaveCandlesRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dbpool, err := ConnectToDatabase(ctx, testDBConfig)
+	if err != nil {
+		t.Fatalf("ConnectToDatabase: %v", err)
+	}
+	defer dbpool.Close()
+	saveTestInstrument(ctx, t, dbpool)
+
+	logger := quietLogger()
+	interval := config.CandleIntervalTextDay
+	// Месяцы в прошлом: партиции для них создаются при сохранении, включая 29 февраля
+	times := []time.Time{
+		time.Date(2020, time.February, 28, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, time.March, 2, 0, 0, 0, 0, time.UTC),
+	}
+	candles := []*pb.HistoricCandle{
+		historicCandle(times[0], 100.5, 101, 99.5, 100.25, 10),
+		historicCandle(times[1], 100.25, 102, 100, 101.75, 20),
+		historicCandle(times[2], 101.75, 103, 101, 102.5, 30),
+	}
+	if err := SaveCandles(dbpool, testFigi, candles, interval, logger); err != nil {
+		t.Fatalf("SaveCandles: %v", err)
+	}
+
+	got, err := GetCandles(ctx, dbpool, testFigi, interval, times[0], times[2])
+	if err != nil {
+		t.Fatalf("GetCandles: %v", err)
+	}
+	if len(got) != len(times) {
+		t.Fatalf("GetCandles вернул %d свечей, ожидалось %d", len(got), len(times))
+	}
+	for i, candle := range got {
+		if !candle.Time.Equal(times[i]) {
+			t.Errorf("свеча %d: время %v, ожидалось %v", i, candle.Time, times[i])
+		}
+		if candle.Volume != candles[i].Volume {
+			t.Errorf("свеча %d: объём %d, ожидалось %d", i, candle.Volume, candles[i].Volume)
+		}
+		if candle.Source != config.CandleSourceAPI {
+			t.Errorf("свеча %d: источник %q, ожидался %q", i, candle.Source, config.CandleSourceAPI)
+		}
+	}
+	if got[0].OpenPrice != 100.5 || got[0].ClosePrice != 100.25 {
+		t.Errorf("цены первой свечи: open %v close %v, ожидались 100.5 и 100.25", got[0].OpenPrice, got[0].ClosePrice)
+	}
+
+	// Повторное сохранение с другими значениями обновляет строку, а не дублирует её
+	updated := historicCandle(times[1], 100.25, 104, 100, 103.5, 25)
+	if err := SaveCandles(dbpool, testFigi, []*pb.HistoricCandle{updated}, interval, logger); err != nil {
+		t.Fatalf("SaveCandles (обновление): %v", err)
+	}
+	got, err = GetCandles(ctx, dbpool, testFigi, interval, times[0], times[2])
+	if err != nil {
+		t.Fatalf("GetCandles: %v", err)
+	}
+	if len(got) != len(times) {
+		t.Fatalf("после обновления %d свечей, ожидалось %d", len(got), len(times))
+	}
+	if got[1].ClosePrice != 103.5 || got[1].HighPrice != 104 || got[1].Volume != 25 {
+		t.Errorf("свеча не обновлена: close %v high %v volume %d", got[1].ClosePrice, got[1].HighPrice, got[1].Volume)
+	}
+
+	// Свеча неизвестного инструмента не сохраняется
+	err = SaveCandles(dbpool, "UNKNOWN00001", candles[:1], interval, logger)
+	if err == nil {
+		t.Error("SaveCandles для неизвестного инструмента должен вернуть ошибку")
+	}
+}
+
+func TestDividendsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dbpool, err := ConnectToDatabase(ctx, testDBConfig)
+	if err != nil {
+		t.Fatalf("ConnectToDatabase: %v", err)
+	}
+	defer dbpool.Close()
+	saveTestInstrument(ctx, t, dbpool)
+
+	last, err := GetLastDividendDate(ctx, dbpool, "NODIVIDENDS1")
+	if err != nil {
+		t.Fatalf("GetLastDividendDate: %v", err)
+	}
+	if !last.IsZero() {
+		t.Errorf("для инструмента без дивидендов ожидалась нулевая дата, получено %v", last)
+	}
+
+	yield := 5.5
+	dividends := []Dividend{
+		{Figi: testFigi, PaymentDate: time.Date(2021, time.July, 15, 0, 0, 0, 0, time.UTC), Amount: 10.5, YieldPercent: &yield},
+		{Figi: testFigi, PaymentDate: time.Date(2022, time.July, 14, 0, 0, 0, 0, time.UTC), Amount: 12, Currency: "usd"},
+	}
+	if err := SaveDividends(ctx, dbpool, dividends); err != nil {
+		t.Fatalf("SaveDividends: %v", err)
+	}
+
+	last, err = GetLastDividendDate(ctx, dbpool, testFigi)
+	if err != nil {
+		t.Fatalf("GetLastDividendDate: %v", err)
+	}
+	if !last.Equal(dividends[1].PaymentDate) {
+		t.Errorf("последняя дата выплаты %v, ожидалась %v", last, dividends[1].PaymentDate)
+	}
+
+	got, err := GetDividends(ctx, dbpool, testFigi, "", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetDividends: %v", err)
+	}
+	if len(got) != len(dividends) {
+		t.Fatalf("GetDividends вернул %d дивидендов, ожидалось %d", len(got), len(dividends))
+	}
+	// Без указанной валюты берётся валюта инструмента
+	if got[0].Currency != "rub" {
+		t.Errorf("валюта первого дивиденда %q, ожидалась валюта инструмента rub", got[0].Currency)
+	}
+	if got[0].YieldPercent == nil || *got[0].YieldPercent != yield {
+		t.Errorf("доходность первого дивиденда %v, ожидалась %v", got[0].YieldPercent, yield)
+	}
+
+	// Фильтр по валюте без учёта регистра
+	got, err = GetDividends(ctx, dbpool, testFigi, "USD", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetDividends (валюта): %v", err)
+	}
+	if len(got) != 1 || got[0].Amount != 12 {
+		t.Errorf("фильтр по валюте вернул %+v, ожидался один дивиденд 12 usd", got)
+	}
+
+	// Повторное сохранение той же выплаты обновляет сумму
+	dividends[0].Amount = 11
+	if err := SaveDividend(ctx, dbpool, dividends[0]); err != nil {
+		t.Fatalf("SaveDividend: %v", err)
+	}
+	got, err = GetDividends(ctx, dbpool, testFigi, "", dividends[0].PaymentDate, dividends[0].PaymentDate)
+	if err != nil {
+		t.Fatalf("GetDividends (период): %v", err)
+	}
+	if len(got) != 1 || got[0].Amount != 11 {
+		t.Errorf("после обновления получено %+v, ожидался один дивиденд с суммой 11", got)
+	}
+}
+
+func TestCandleSourceFollowsLastSave(t *testing.T) {
+	ctx := context.Background()
+	dbpool, err := ConnectToDatabase(ctx, testDBConfig)
+	if err != nil {
+		t.Fatalf("ConnectToDatabase: %v", err)
+	}
+	defer dbpool.Close()
+	saveTestInstrument(ctx, t, dbpool)
+
+	logger := quietLogger()
+	at := time.Date(2021, time.March, 1, 7, 0, 0, 0, time.UTC)
+	candle := historicCandle(at, 10, 11, 9, 10.5, 100)
+	source := func() string {
+		t.Helper()
+		got, err := GetCandles(ctx, dbpool, testFigi, config.CandleInterval1Min, at, at)
+		if err != nil {
+			t.Fatalf("GetCandles: %v", err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("GetCandles вернул %d свечей, ожидалась 1", len(got))
+		}
+		return got[0].Source
+	}
+
+	// Без track_source_file свеча из архива всё равно помечается как архивная
+	if err := SaveArchiveCandles(dbpool, testFigi, []*pb.HistoricCandle{candle}, config.CandleInterval1Min, "fixture.csv", logger); err != nil {
+		t.Fatalf("SaveArchiveCandles: %v", err)
+	}
+	if got := source(); got != config.CandleSourceArchive {
+		t.Errorf("после сохранения из архива источник %q, ожидался %q", got, config.CandleSourceArchive)
+	}
+
+	// Перезапись через API меняет источник
+	if err := SaveCandles(dbpool, testFigi, []*pb.HistoricCandle{candle}, config.CandleInterval1Min, logger); err != nil {
+		t.Fatalf("SaveCandles: %v", err)
+	}
+	if got := source(); got != config.CandleSourceAPI {
+		t.Errorf("после сохранения через API источник %q, ожидался %q", got, config.CandleSourceAPI)
+	}
+}
+
+func TestMigrateFixesLegacyPartitionBounds(t *testing.T) {
+	ctx := context.Background()
+	dbpool, err := ConnectToDatabase(ctx, testDBConfig)
+	if err != nil {
+		t.Fatalf("ConnectToDatabase: %v", err)
+	}
+	defer dbpool.Close()
+	saveTestInstrument(ctx, t, dbpool)
+
+	// Партиция с границей прежних версий: последняя секунда месяца в неё не попадает
+	if _, err := dbpool.Exec(ctx, `CREATE TABLE candles_2019_01 PARTITION OF candles
+		FOR VALUES FROM ('2019-01-01 00:00:00') TO ('2019-01-31 23:59:59')`); err != nil {
+		t.Fatalf("создание партиции: %v", err)
+	}
+
+	if err := MigrateDatabase(dbpool); err != nil {
+		t.Fatalf("MigrateDatabase: %v", err)
+	}
+
+	var bound string
+	if err := dbpool.QueryRow(ctx, `SELECT pg_get_expr(relpartbound, oid) FROM pg_class WHERE relname = 'candles_2019_01'`).Scan(&bound); err != nil {
+		t.Fatalf("чтение границ партиции: %v", err)
+	}
+	if want := "FOR VALUES FROM ('2019-01-01 00:00:00') TO ('2019-02-01 00:00:00')"; bound != want {
+		t.Errorf("границы партиции %q, ожидались %q", bound, want)
+	}
+
+	at := time.Date(2019, time.January, 31, 23, 59, 59, 500_000_000, time.UTC)
+	candle := historicCandle(at, 10, 11, 9, 10.5, 100)
+	if err := SaveCandles(dbpool, testFigi, []*pb.HistoricCandle{candle}, config.CandleInterval1Min, quietLogger()); err != nil {
+		t.Fatalf("SaveCandles в последнюю секунду месяца: %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Виды проблем целостности
+const (
+	// IssueOrphanCandles свечи инструмента, которого нет в instruments
+	IssueOrphanCandles = "orphan_candles"
+	// IssueOrphanDividends дивиденды инструмента, которого нет в instruments
+	IssueOrphanDividends = "orphan_dividends"
+	// IssueInvalidDataSource инструменты с несуществующим data_source_id
+	IssueInvalidDataSource = "invalid_data_source"
+	// IssueDetachedPartition партиция свечей, не подключённая к родительской таблице
+	IssueDetachedPartition = "detached_partition"
+
+	// integritySamples сколько примеров (FIGI, таблиц) выводить для проблемы
+	integritySamples = 10
+)
+
+// IntegrityIssue проблема целостности данных
+type IntegrityIssue struct {
+	Kind    string   // вид проблемы (IssueOrphanCandles, ...)
+	Table   string   // таблица
+	Count   int64    // количество строк (таблиц)
+	Samples []string // примеры: FIGI или имена партиций
+	Fixable bool     // можно безопасно исправить (FixIntegrity)
+}
+
+// IntegrityReport результат проверки целостности
+type IntegrityReport struct {
+	Issues []IntegrityIssue
+}
+
+// OK проверяет, что проблем не найдено
+func (r *IntegrityReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// CheckIntegrity проверяет ссылочную целостность: свечи и дивиденды без инструмента,
+// инструменты с несуществующим источником данных и партиции свечей без родительской таблицы
+func CheckIntegrity(ctx context.Context, dbpool *pgxpool.Pool) (*IntegrityReport, error) {
+	report := &IntegrityReport{}
+
+	tables, err := existingCandleTables(ctx, dbpool)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		issue, err := countOrphans(ctx, dbpool, IssueOrphanCandles, table)
+		if err != nil {
+			return nil, err
+		}
+		report.add(issue)
+	}
+
+	issue, err := countOrphans(ctx, dbpool, IssueOrphanDividends, "dividends")
+	if err != nil {
+		return nil, err
+	}
+	report.add(issue)
+
+	issue, err = checkDataSources(ctx, dbpool)
+	if err != nil {
+		return nil, err
+	}
+	report.add(issue)
+
+	issue, err = checkDetachedPartitions(ctx, dbpool, tables)
+	if err != nil {
+		return nil, err
+	}
+	report.add(issue)
+
+	return report, nil
+}
+
+// add добавляет проблему в отчёт, если она найдена
+func (r *IntegrityReport) add(issue IntegrityIssue) {
+	if issue.Count > 0 {
+		r.Issues = append(r.Issues, issue)
+	}
+}
+
+// countOrphans находит строки таблицы с figi, которого нет в instruments
+func countOrphans(ctx context.Context, dbpool *pgxpool.Pool, kind, table string) (IntegrityIssue, error) {
+	issue := IntegrityIssue{Kind: kind, Table: table, Fixable: true}
+
+	query := fmt.Sprintf(`
+		SELECT t.figi, COUNT(*)
+		FROM %s t
+		WHERE NOT EXISTS (SELECT 1 FROM instruments i WHERE i.figi = t.figi)
+		GROUP BY t.figi
+		ORDER BY COUNT(*) DESC
+	`, table)
+
+	rows, err := dbpool.Query(ctx, query)
+	if err != nil {
+		return issue, fmt.Errorf("ошибка проверки %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var figi string
+		var count int64
+		if err := rows.Scan(&figi, &count); err != nil {
+			return issue, fmt.Errorf("ошибка сканирования результата проверки %s: %w", table, err)
+		}
+		issue.Count += count
+		if len(issue.Samples) < integritySamples {
+			issue.Samples = append(issue.Samples, figi)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return issue, fmt.Errorf("ошибка итерации по результату проверки %s: %w", table, err)
+	}
+
+	return issue, nil
+}
+
+// checkDataSources находит инструменты с data_source_id, которого нет в data_sources
+func checkDataSources(ctx context.Context, dbpool *pgxpool.Pool) (IntegrityIssue, error) {
+	issue := IntegrityIssue{Kind: IssueInvalidDataSource, Table: "instruments", Fixable: true}
+
+	rows, err := dbpool.Query(ctx, `
+		SELECT i.figi
+		FROM instruments i
+		WHERE i.data_source_id IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM data_sources ds WHERE ds.id = i.data_source_id)
+		ORDER BY i.figi
+	`)
+	if err != nil {
+		return issue, fmt.Errorf("ошибка проверки источников данных: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var figi string
+		if err := rows.Scan(&figi); err != nil {
+			return issue, fmt.Errorf("ошибка сканирования инструмента: %w", err)
+		}
+		issue.Count++
+		if len(issue.Samples) < integritySamples {
+			issue.Samples = append(issue.Samples, figi)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return issue, fmt.Errorf("ошибка итерации по инструментам: %w", err)
+	}
+
+	return issue, nil
+}
+
+// checkDetachedPartitions находит таблицы с именами партиций свечей (<таблица>_YYYY_MM),
+// которые не подключены к родительской таблице: их данные не видны в запросах
+func checkDetachedPartitions(ctx context.Context, dbpool *pgxpool.Pool, tables []string) (IntegrityIssue, error) {
+	issue := IntegrityIssue{Kind: IssueDetachedPartition, Table: "pg_class"}
+
+	for _, table := range tables {
+		rows, err := dbpool.Query(ctx, `
+			SELECT c.relname
+			FROM pg_class c
+			WHERE c.relnamespace = current_schema()::regnamespace
+				AND c.relkind = 'r'
+				AND c.relname ~ ('^' || $1 || '_[0-9]{4}_[0-9]{2}$')
+				AND NOT EXISTS (
+					SELECT 1 FROM pg_inherits inh
+					WHERE inh.inhrelid = c.oid AND inh.inhparent = to_regclass($1)
+				)
+			ORDER BY c.relname
+		`, table)
+		if err != nil {
+			return issue, fmt.Errorf("ошибка проверки партиций %s: %w", table, err)
+		}
+
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return issue, fmt.Errorf("ошибка сканирования партиции: %w", err)
+			}
+			issue.Count++
+			if len(issue.Samples) < integritySamples {
+				issue.Samples = append(issue.Samples, name)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return issue, fmt.Errorf("ошибка итерации по партициям %s: %w", table, err)
+		}
+	}
+
+	return issue, nil
+}
+
+// FixIntegrity исправляет безопасные проблемы из отчёта: удаляет свечи и дивиденды без инструмента
+// и сбрасывает несуществующий data_source_id в NULL. Отключённые партиции не трогаются.
+// Возвращает количество исправленных строк по видам проблем
+func FixIntegrity(ctx context.Context, dbpool *pgxpool.Pool, report *IntegrityReport) (map[string]int64, error) {
+	fixed := make(map[string]int64)
+
+	for _, issue := range report.Issues {
+		if !issue.Fixable {
+			continue
+		}
+
+		var query string
+		switch issue.Kind {
+		case IssueOrphanCandles, IssueOrphanDividends:
+			query = fmt.Sprintf(`DELETE FROM %s t
+				WHERE NOT EXISTS (SELECT 1 FROM instruments i WHERE i.figi = t.figi)`, issue.Table)
+		case IssueInvalidDataSource:
+			query = `UPDATE instruments i SET data_source_id = NULL
+				WHERE i.data_source_id IS NOT NULL
+					AND NOT EXISTS (SELECT 1 FROM data_sources ds WHERE ds.id = i.data_source_id)`
+		default:
+			continue
+		}
+
+		tag, err := dbpool.Exec(ctx, query)
+		if err != nil {
+			return fixed, fmt.Errorf("ошибка исправления %s в %s: %w", issue.Kind, issue.Table, err)
+		}
+		fixed[issue.Kind] += tag.RowsAffected()
+	}
+
+	return fixed, nil
+}
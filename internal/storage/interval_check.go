@@ -0,0 +1,121 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"market-loader/pkg/config"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrIntervalMismatch шаг свечей пачки не соответствует типу интервала (storage.interval_check: strict)
+var ErrIntervalMismatch = errors.New("шаг свечей не соответствует интервалу")
+
+// intervalCheckMinCandles минимум свечей в пачке для вывода о слишком редких свечах:
+// на малых пачках большой шаг объясняется перерывами в торгах
+const intervalCheckMinCandles = 10
+
+var (
+	intervalCheckMu sync.RWMutex
+	// intervalCheckMode режим проверки шага свечей (config.IntervalCheck*), пусто - выключено
+	intervalCheckMode string
+)
+
+// SetIntervalCheck задаёт режим проверки шага свечей при сохранении (config.GetIntervalCheck)
+func SetIntervalCheck(mode string) {
+	intervalCheckMu.Lock()
+	defer intervalCheckMu.Unlock()
+	intervalCheckMode = mode
+}
+
+// checkIntervalSpacing сверяет шаг между соседними свечами пачки с типом интервала:
+// свечи чаще половины интервала (минутные под 1day) или, на пачке от intervalCheckMinCandles свечей,
+// не чаще следующего крупного периода (дневные под 1min) считаются несоответствием.
+// Несоответствие учитывается в сводке и пишется в лог, в режиме strict пачка отклоняется
+func checkIntervalSpacing(figi string, candles []*pb.HistoricCandle, intervalType string, logger *logrus.Logger) error {
+	intervalCheckMu.RLock()
+	mode := intervalCheckMode
+	intervalCheckMu.RUnlock()
+
+	if mode == "" || mode == config.IntervalCheckOff || len(candles) < 2 {
+		return nil
+	}
+
+	times := make([]time.Time, 0, len(candles))
+	for _, candle := range candles {
+		times = append(times, candle.GetTime().AsTime())
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	step := config.IntervalPeriodEnd(intervalType, times[0]).Sub(times[0])
+	var minGap time.Duration
+	distinct := 1
+	for i := 1; i < len(times); i++ {
+		gap := times[i].Sub(times[i-1])
+		if gap <= 0 {
+			continue
+		}
+		distinct++
+		if minGap == 0 || gap < minGap {
+			minGap = gap
+		}
+	}
+	if minGap == 0 {
+		return nil
+	}
+
+	tooDense := minGap*2 < step
+	sparse := sparseSpacing(intervalType, step)
+	tooSparse := sparse > 0 && distinct >= intervalCheckMinCandles && minGap >= sparse
+	if !tooDense && !tooSparse {
+		return nil
+	}
+
+	addIntervalMismatch(len(candles))
+	fields := logrus.Fields{
+		"figi":     figi,
+		"interval": config.Interval2text(intervalType),
+		"minGap":   minGap,
+		"expected": step,
+		"candles":  len(candles),
+		"from":     times[0].Format(time.RFC3339),
+		"to":       times[len(times)-1].Format(time.RFC3339),
+	}
+	if mode == config.IntervalCheckStrict {
+		logger.WithFields(fields).Error("Шаг свечей не соответствует интервалу, пачка не сохранена")
+		return fmt.Errorf("%w: %s, минимальный шаг %v при интервале %s", ErrIntervalMismatch, figi, minGap, config.Interval2text(intervalType))
+	}
+	logger.WithFields(fields).Warn("Шаг свечей не соответствует интервалу: возможно, свечи другого интервала")
+	return nil
+}
+
+// sparseSpacing возвращает шаг, начиная с которого свечи интервала считаются свечами более крупного интервала
+// (0 - не проверяется)
+func sparseSpacing(intervalType string, step time.Duration) time.Duration {
+	const day = 24 * time.Hour
+	switch {
+	case intervalType == config.CandleIntervalMonth:
+		return 0
+	case intervalType == config.CandleIntervalWeek:
+		return 28 * day
+	case intervalType == config.CandleIntervalDay:
+		return config.DaysInWeek * day
+	case step < day:
+		return day
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,77 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CandleLag отставание свечей инструмента от текущего момента
+type CandleLag struct {
+	Figi     string
+	Ticker   string
+	Name     string
+	LastTime time.Time     // время последней свечи, нулевое - свечей нет
+	Lag      time.Duration // now - LastTime, для инструментов без свечей 0
+}
+
+// InstrumentLag возвращает отставание свечей интервала по каждому включённому инструменту:
+// сначала инструменты без свечей, затем по убыванию отставания
+func InstrumentLag(ctx context.Context, dbpool *pgxpool.Pool, intervalType string) ([]CandleLag, error) {
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT i.figi, i.ticker, i.name, c.last_time
+		FROM instruments i
+		LEFT JOIN (
+			SELECT figi, MAX(time) AS last_time
+			FROM %s
+			WHERE interval_type = $1
+			GROUP BY figi
+		) c ON c.figi = i.figi
+		WHERE i.enabled = true
+		ORDER BY c.last_time ASC NULLS FIRST, i.ticker
+	`, table)
+
+	rows, err := dbpool.Query(ctx, query, intervalType)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса отставания инструментов: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+	var lags []CandleLag
+	for rows.Next() {
+		var (
+			lag      CandleLag
+			lastTime *time.Time
+		)
+		if err := rows.Scan(&lag.Figi, &lag.Ticker, &lag.Name, &lastTime); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования отставания инструмента: %w", err)
+		}
+		if lastTime != nil {
+			lag.LastTime = *lastTime
+			lag.Lag = now.Sub(lastTime.UTC())
+		}
+		lags = append(lags, lag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по отставанию инструментов: %w", err)
+	}
+
+	return lags, nil
+}
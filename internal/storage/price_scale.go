@@ -0,0 +1,78 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PriceScaleSample сколько последних свечей инструмента проверяется на кратность шагу цены
+const PriceScaleSample = 1000
+
+// PriceScaleStats результат проверки цен свечей на кратность min_price_increment
+type PriceScaleStats struct {
+	Increment float64 // шаг цены инструмента (0 - не задан, проверка не выполнялась)
+	Checked   int64   // проверено свечей
+	OffTick   int64   // свечей с ценой, не кратной шагу
+}
+
+// Ratio возвращает долю свечей с ценой, не кратной шагу цены
+func (s PriceScaleStats) Ratio() float64 {
+	if s.Checked == 0 {
+		return 0
+	}
+	return float64(s.OffTick) / float64(s.Checked)
+}
+
+// CheckPriceScale проверяет последние sample свечей инструмента: цены open, high, low, close
+// должны быть кратны min_price_increment. Большая доля некратных цен - признак ошибки масштаба
+// (цены в 10 или 100 раз больше/меньше), например, при расхождении архивов и API
+func CheckPriceScale(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string, sample int) (PriceScaleStats, error) {
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return PriceScaleStats{}, err
+	}
+
+	var stats PriceScaleStats
+	if err := dbpool.QueryRow(ctx,
+		`SELECT COALESCE(min_price_increment, 0)::float8 FROM instruments WHERE figi = $1`, figi,
+	).Scan(&stats.Increment); err != nil {
+		return PriceScaleStats{}, fmt.Errorf("ошибка получения шага цены: %w", err)
+	}
+	if stats.Increment <= 0 {
+		return stats, nil
+	}
+
+	query := fmt.Sprintf(`
+		WITH recent AS (
+			SELECT open_price, high_price, low_price, close_price
+			FROM %s
+			WHERE figi = $1 AND interval_type = $2
+			ORDER BY time DESC
+			LIMIT $3
+		), tick AS (
+			SELECT min_price_increment AS step FROM instruments WHERE figi = $1
+		)
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE
+				mod(open_price, tick.step) <> 0 OR mod(high_price, tick.step) <> 0 OR
+				mod(low_price, tick.step) <> 0 OR mod(close_price, tick.step) <> 0)
+		FROM recent, tick
+	`, table)
+
+	if err := dbpool.QueryRow(ctx, query, figi, intervalType, sample).Scan(&stats.Checked, &stats.OffTick); err != nil {
+		return PriceScaleStats{}, fmt.Errorf("ошибка проверки шага цены свечей: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,79 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// trackSourceFile сохранять имя CSV файла архива в candles.source_file
+var trackSourceFile atomic.Bool
+
+// untrackedArchiveSource значение source_file свечей из архива без archive.track_source_file:
+// источник свечи (архив) известен, имя файла не сохраняется
+const untrackedArchiveSource = config.CandleSourceArchive
+
+// SetTrackSourceFile включает или отключает сохранение файла-источника свечей из архивов
+func SetTrackSourceFile(enabled bool) {
+	trackSourceFile.Store(enabled)
+}
+
+// TrackSourceFile проверяет, включено ли сохранение файла-источника свечей
+func TrackSourceFile() bool {
+	return trackSourceFile.Load()
+}
+
+// CandlesBySourceFile возвращает минутные свечи, загруженные из указанного CSV файла архива,
+// упорядоченные по инструменту и времени
+func CandlesBySourceFile(ctx context.Context, dbpool *pgxpool.Pool, sourceFile string) ([]Candle, error) {
+	// Архивы содержат только минутные свечи
+	table, err := candleTableFor(ctx, dbpool, config.CandleInterval1Min)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT figi, time, open_price, high_price, low_price, close_price, volume, interval_type
+		FROM %s WHERE source_file = $1 ORDER BY figi, time`, table)
+
+	rows, err := dbpool.Query(ctx, query, sourceFile)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса свечей по файлу-источнику: %w", err)
+	}
+	defer rows.Close()
+
+	var candles []Candle
+	for rows.Next() {
+		var candle Candle
+		if err := rows.Scan(
+			&candle.FIGI,
+			&candle.Time,
+			&candle.OpenPrice,
+			&candle.HighPrice,
+			&candle.LowPrice,
+			&candle.ClosePrice,
+			&candle.Volume,
+			&candle.IntervalType,
+		); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования свечи: %w", err)
+		}
+		candles = append(candles, candle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по свечам: %w", err)
+	}
+
+	return candles, nil
+}
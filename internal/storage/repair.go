@@ -0,0 +1,190 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Причины, по которым свеча считается подозрительной
+const (
+	// SuspectNonPositivePrice нулевая или отрицательная цена
+	SuspectNonPositivePrice = "non_positive_price"
+	// SuspectHighBelowLow максимум ниже минимума
+	SuspectHighBelowLow = "high_below_low"
+	// SuspectOutOfRange цена открытия или закрытия вне диапазона [low, high]
+	SuspectOutOfRange = "open_close_out_of_range"
+	// SuspectNegativeVolume отрицательный объём
+	SuspectNegativeVolume = "negative_volume"
+)
+
+// SuspectCandle свеча с нарушением согласованности OHLCV
+type SuspectCandle struct {
+	Time   time.Time
+	Reason string // SuspectNonPositivePrice, ...
+}
+
+// FindSuspectCandles находит свечи инструмента за период [from, to) с несогласованными ценами или объёмом
+func FindSuspectCandles(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string, from, to time.Time) ([]SuspectCandle, error) {
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`
+		SELECT time, reason FROM (
+			SELECT time,
+				CASE
+					WHEN LEAST(open_price, high_price, low_price, close_price) <= 0 THEN '%s'
+					WHEN high_price < low_price THEN '%s'
+					WHEN open_price NOT BETWEEN low_price AND high_price
+						OR close_price NOT BETWEEN low_price AND high_price THEN '%s'
+					WHEN volume < 0 THEN '%s'
+				END AS reason
+			FROM %s
+			WHERE figi = $1 AND interval_type = $2 AND time >= $3 AND time < $4
+		) s
+		WHERE reason IS NOT NULL
+		ORDER BY time
+	`, SuspectNonPositivePrice, SuspectHighBelowLow, SuspectOutOfRange, SuspectNegativeVolume, table)
+
+	rows, err := dbpool.Query(ctx, query, figi, intervalType, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска подозрительных свечей: %w", err)
+	}
+	defer rows.Close()
+
+	var suspects []SuspectCandle
+	for rows.Next() {
+		var suspect SuspectCandle
+		if err := rows.Scan(&suspect.Time, &suspect.Reason); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования подозрительной свечи: %w", err)
+		}
+		suspects = append(suspects, suspect)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по подозрительным свечам: %w", err)
+	}
+
+	return suspects, nil
+}
+
+// CountCandles возвращает количество свечей инструмента за период [from, to)
+func CountCandles(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string, from, to time.Time) (int64, error) {
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return 0, err
+	}
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE figi = $1 AND interval_type = $2 AND time >= $3 AND time < $4`, table)
+
+	var count int64
+	if err := dbpool.QueryRow(ctx, query, figi, intervalType, from, to).Scan(&count); err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта свечей: %w", err)
+	}
+	return count, nil
+}
+
+// GetCandleDays возвращает дни (YYYY-MM-DD), в которых у инструмента есть свечи за период [from, to)
+func GetCandleDays(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string, from, to time.Time) (map[string]bool, error) {
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`
+		SELECT DISTINCT to_char(time, 'YYYY-MM-DD')
+		FROM %s
+		WHERE figi = $1 AND interval_type = $2 AND time >= $3 AND time < $4
+	`, table)
+
+	rows, err := dbpool.Query(ctx, query, figi, intervalType, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения дней со свечами: %w", err)
+	}
+	defer rows.Close()
+
+	days := make(map[string]bool)
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования дня: %w", err)
+		}
+		days[day] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по дням со свечами: %w", err)
+	}
+
+	return days, nil
+}
+
+// GetCandleVersions возвращает версии строк (xmin) свечей инструмента с указанным временем.
+// Любое обновление строки, в том числе upsert с теми же значениями, меняет её версию
+func GetCandleVersions(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string, times []time.Time) (map[time.Time]string, error) {
+	if len(times) == 0 {
+		return nil, nil
+	}
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := dbpool.Query(ctx,
+		fmt.Sprintf(`SELECT time, xmin::text FROM %s WHERE figi = $1 AND interval_type = $2 AND time = ANY($3)`, table),
+		figi, intervalType, times)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения версий свечей: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[time.Time]string, len(times))
+	for rows.Next() {
+		var candleTime time.Time
+		var version string
+		if err := rows.Scan(&candleTime, &version); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования версии свечи: %w", err)
+		}
+		versions[candleTime] = version
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по версиям свечей: %w", err)
+	}
+	return versions, nil
+}
+
+// DeleteUnchangedCandles удаляет свечи инструмента, версия которых не изменилась с GetCandleVersions,
+// то есть не перезаписанные после чтения версий. Возвращает количество удалённых
+func DeleteUnchangedCandles(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string, versions map[time.Time]string) (int64, error) {
+	if len(versions) == 0 {
+		return 0, nil
+	}
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return 0, err
+	}
+
+	times := make([]time.Time, 0, len(versions))
+	xmins := make([]string, 0, len(versions))
+	for candleTime, version := range versions {
+		times = append(times, candleTime)
+		xmins = append(xmins, version)
+	}
+
+	tag, err := dbpool.Exec(ctx,
+		fmt.Sprintf(`DELETE FROM %s c
+			USING unnest($3::timestamp[], $4::text[]) AS v(time, version)
+			WHERE c.figi = $1 AND c.interval_type = $2 AND c.time = v.time AND c.xmin::text = v.version`, table),
+		figi, intervalType, times, xmins)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления свечей: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
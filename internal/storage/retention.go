@@ -0,0 +1,182 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RetentionSummary итог применения сроков хранения свечей
+type RetentionSummary struct {
+	Deleted map[string]int64 // удалено свечей по типу интервала
+	Dropped []string         // удалённые пустые партиции
+}
+
+// candlePartition месячная партиция таблицы свечей
+type candlePartition struct {
+	Name  string
+	Month time.Time // начало месяца партиции
+}
+
+// EnforceRetention удаляет свечи старше срока хранения своего интервала (policy: тип интервала -> срок)
+// в каждой партиции отдельно, затем удаляет партиции, которые целиком старше срока и опустели.
+// В таблицах одного интервала (table_per_interval, секции partition_by_interval) партиция целиком
+// старше срока удаляется сразу, без построчного удаления свечей.
+// Интервалы без срока в policy не затрагиваются
+func EnforceRetention(ctx context.Context, dbpool *pgxpool.Pool, policy map[string]time.Duration) (RetentionSummary, error) {
+	summary := RetentionSummary{Deleted: make(map[string]int64)}
+	if len(policy) == 0 {
+		return summary, nil
+	}
+
+	tables, err := existingCandleTables(ctx, dbpool)
+	if err != nil {
+		return summary, err
+	}
+
+	// Порядок интервалов фиксирован для воспроизводимых логов
+	intervals := make([]string, 0, len(policy))
+	for intervalType := range policy {
+		intervals = append(intervals, intervalType)
+	}
+	sort.Strings(intervals)
+
+	now := time.Now().UTC()
+	for _, table := range tables {
+		partitions, err := candlePartitions(ctx, dbpool, table)
+		if err != nil {
+			return summary, err
+		}
+
+		// Таблица (секция) одного интервала: свечи других интервалов в её партициях отсутствуют
+		dedicated := table != SharedCandleTable
+
+		// Партиция, целиком старше срока хранения своих интервалов, удаляется, если опустела
+		var expired []string
+		for _, partition := range partitions {
+			monthEnd := partition.Month.AddDate(0, 1, 0)
+			fullyExpired := true
+			touched := false
+
+			for _, intervalType := range intervals {
+				if dedicated && table != intervalTable(config.Interval2text(intervalType)) {
+					continue
+				}
+				cutoff := now.Add(-policy[intervalType])
+				if !partition.Month.Before(cutoff) {
+					fullyExpired = false
+					continue
+				}
+				touched = true
+				if monthEnd.After(cutoff) {
+					fullyExpired = false
+				}
+
+				if dedicated && fullyExpired {
+					deleted, err := dropExpiredPartition(ctx, dbpool, partition.Name)
+					if err != nil {
+						return summary, err
+					}
+					summary.Deleted[intervalType] += deleted
+					summary.Dropped = append(summary.Dropped, partition.Name)
+					touched = false
+					break
+				}
+
+				tag, err := dbpool.Exec(ctx,
+					fmt.Sprintf(`DELETE FROM %s WHERE interval_type = $1 AND time < $2`, partition.Name),
+					intervalType, cutoff)
+				if err != nil {
+					return summary, fmt.Errorf("ошибка удаления устаревших свечей в %s: %w", partition.Name, err)
+				}
+				summary.Deleted[intervalType] += tag.RowsAffected()
+			}
+
+			if touched && fullyExpired {
+				expired = append(expired, partition.Name)
+			}
+		}
+
+		for _, name := range expired {
+			var empty bool
+			if err := dbpool.QueryRow(ctx, fmt.Sprintf(`SELECT NOT EXISTS (SELECT 1 FROM %s)`, name)).Scan(&empty); err != nil {
+				return summary, fmt.Errorf("ошибка проверки партиции %s: %w", name, err)
+			}
+			if !empty {
+				continue
+			}
+			if _, err := dbpool.Exec(ctx, fmt.Sprintf(`DROP TABLE %s`, name)); err != nil {
+				return summary, fmt.Errorf("ошибка удаления партиции %s: %w", name, err)
+			}
+			summary.Dropped = append(summary.Dropped, name)
+		}
+	}
+
+	return summary, nil
+}
+
+// dropExpiredPartition удаляет партицию таблицы одного интервала целиком, возвращает количество свечей в ней
+func dropExpiredPartition(ctx context.Context, dbpool *pgxpool.Pool, name string) (int64, error) {
+	var count int64
+	if err := dbpool.QueryRow(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM %s`, name)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта свечей в партиции %s: %w", name, err)
+	}
+	if _, err := dbpool.Exec(ctx, fmt.Sprintf(`DROP TABLE %s`, name)); err != nil {
+		return 0, fmt.Errorf("ошибка удаления партиции %s: %w", name, err)
+	}
+	return count, nil
+}
+
+// candlePartitions возвращает месячные партиции таблицы свечей (имя table_YYYY_MM)
+func candlePartitions(ctx context.Context, dbpool *pgxpool.Pool, table string) ([]candlePartition, error) {
+	rows, err := dbpool.Query(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass($1)
+		ORDER BY c.relname
+	`, table)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения партиций %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var partitions []candlePartition
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования партиции: %w", err)
+		}
+
+		// Партиции с другим форматом имени (созданные вручную) не затрагиваются
+		suffix, ok := strings.CutPrefix(name, table+"_")
+		if !ok {
+			continue
+		}
+		month, err := time.Parse("2006_01", suffix)
+		if err != nil {
+			continue
+		}
+		partitions = append(partitions, candlePartition{Name: name, Month: month})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по партициям: %w", err)
+	}
+
+	return partitions, nil
+}
@@ -0,0 +1,128 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// DefaultWriteRetries количество повторов записи при потере соединения по умолчанию
+	DefaultWriteRetries = 3
+	// DefaultWriteRetryDelay задержка перед первым повтором (далее удваивается)
+	DefaultWriteRetryDelay = 2 * time.Second
+)
+
+var (
+	retryMu         sync.RWMutex
+	writeRetries    = DefaultWriteRetries
+	writeRetryDelay = DefaultWriteRetryDelay
+)
+
+// SetWriteRetryPolicy задает количество повторов и начальную задержку для операций записи
+// retries = 0 - без повторов
+func SetWriteRetryPolicy(retries int, delay time.Duration) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+
+	if retries < 0 {
+		retries = 0
+	}
+	writeRetries = retries
+	writeRetryDelay = delay
+}
+
+func getWriteRetryPolicy() (int, time.Duration) {
+	retryMu.RLock()
+	defer retryMu.RUnlock()
+	return writeRetries, writeRetryDelay
+}
+
+// IsRecoverableError проверяет, является ли ошибка временной ошибкой соединения с БД,
+// после которой запрос можно повторить (пул переподключится сам)
+func IsRecoverableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Класс 08 - ошибки соединения,
+		// 57P01/57P02/57P03 - остановка или перезапуск сервера (failover)
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"):
+			return true
+		case pgErr.Code == "57P01", pgErr.Code == "57P02", pgErr.Code == "57P03":
+			return true
+		default:
+			return false
+		}
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// withRetry выполняет операцию записи, повторяя её при временных ошибках соединения.
+// Операции записи в storage идемпотентны (UPSERT/UPDATE), поэтому повтор безопасен.
+func withRetry(ctx context.Context, op func() error) error {
+	retries, delay := getWriteRetryPolicy()
+
+	err := op()
+	for attempt := 1; attempt <= retries && IsRecoverableError(err); attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+
+		err = op()
+	}
+	return err
+}
+
+// execWithRetry выполняет Exec с повтором при временных ошибках соединения
+func execWithRetry(ctx context.Context, dbpool *pgxpool.Pool, query string, args ...interface{}) error {
+	return withRetry(ctx, func() error {
+		_, err := dbpool.Exec(ctx, query, args...)
+		return err
+	})
+}
+
+// queryRowWithRetry выполняет QueryRow().Scan() с повтором при временных ошибках соединения
+func queryRowWithRetry(ctx context.Context, dbpool *pgxpool.Pool, dest []interface{}, query string, args ...interface{}) error {
+	return withRetry(ctx, func() error {
+		return dbpool.QueryRow(ctx, query, args...).Scan(dest...)
+	})
+}
@@ -0,0 +1,125 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// setTestRetryPolicy задаёт политику повторов без задержки и восстанавливает прежнюю после теста
+func setTestRetryPolicy(t *testing.T, retries int) {
+	t.Helper()
+	prevRetries, prevDelay := getWriteRetryPolicy()
+	SetWriteRetryPolicy(retries, time.Millisecond)
+	t.Cleanup(func() {
+		SetWriteRetryPolicy(prevRetries, prevDelay)
+	})
+}
+
+// failingOp возвращает операцию, которая завершается ошибками из errs по очереди, затем успешно
+func failingOp(errs ...error) (op func() error, calls *int) {
+	calls = new(int)
+	op = func() error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+	return op, calls
+}
+
+func TestWithRetry(t *testing.T) {
+	connectionLost := &pgconn.PgError{Code: "08006", Message: "connection failure"}
+	adminShutdown := &pgconn.PgError{Code: "57P01", Message: "terminating connection due to administrator command"}
+	uniqueViolation := &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"}
+
+	tests := []struct {
+		name      string
+		retries   int
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name:      "success without retry",
+			retries:   3,
+			wantCalls: 1,
+		},
+		{
+			name:      "connection error then success",
+			retries:   3,
+			errs:      []error{connectionLost},
+			wantCalls: 2,
+		},
+		{
+			name:      "failover then success",
+			retries:   3,
+			errs:      []error{adminShutdown, connectionLost},
+			wantCalls: 3,
+		},
+		{
+			name:      "non-recoverable error is not retried",
+			retries:   3,
+			errs:      []error{uniqueViolation},
+			wantErr:   uniqueViolation,
+			wantCalls: 1,
+		},
+		{
+			name:      "retries exhausted",
+			retries:   2,
+			errs:      []error{connectionLost, connectionLost, connectionLost, connectionLost},
+			wantErr:   connectionLost,
+			wantCalls: 3,
+		},
+		{
+			name:      "retries disabled",
+			retries:   0,
+			errs:      []error{connectionLost},
+			wantErr:   connectionLost,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestRetryPolicy(t, tt.retries)
+			op, calls := failingOp(tt.errs...)
+
+			err := withRetry(context.Background(), op)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("withRetry() error = %v, want %v", err, tt.wantErr)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("withRetry() called op %d times, want %d", *calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithRetryStopsOnCanceledContext(t *testing.T) {
+	setTestRetryPolicy(t, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	connectionLost := &pgconn.PgError{Code: "08006", Message: "connection failure"}
+	op, calls := failingOp(connectionLost, connectionLost)
+
+	if err := withRetry(ctx, op); !errors.Is(err, connectionLost) {
+		t.Errorf("withRetry() error = %v, want %v", err, connectionLost)
+	}
+	if *calls != 1 {
+		t.Errorf("withRetry() called op %d times after cancel, want 1", *calls)
+	}
+}
@@ -0,0 +1,152 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// ReturnMethodLog логарифмическая доходность ln(close / prevClose)
+	ReturnMethodLog = "log"
+	// ReturnMethodSimple простая доходность close / prevClose - 1
+	ReturnMethodSimple = "simple"
+)
+
+// ValidateReturnMethod проверяет способ расчёта доходности (log, simple)
+func ValidateReturnMethod(method string) error {
+	switch method {
+	case ReturnMethodLog, ReturnMethodSimple:
+		return nil
+	default:
+		return fmt.Errorf("неизвестный способ расчёта доходности: %q (допустимо: %s, %s)",
+			method, ReturnMethodLog, ReturnMethodSimple)
+	}
+}
+
+// ComputeReturns считает доходность инструмента по ценам закрытия свечей интервала за период from - to включительно
+// и записывает её в таблицу returns. Доходность первой свечи периода считается от последней свечи до from.
+// totalReturn - полная доходность: дивиденды с датой выплаты в периоде свечи прибавляются к цене закрытия
+// (дата отсечки в dividends не хранится, поэтому дивиденд учитывается в день выплаты).
+// Возвращает количество записанных значений
+func ComputeReturns(
+	ctx context.Context,
+	dbpool *pgxpool.Pool,
+	figi, intervalType string,
+	from, to time.Time,
+	method string,
+	totalReturn bool,
+) (int, error) {
+	if err := ValidateReturnMethod(method); err != nil {
+		return 0, err
+	}
+	if err := validateIntervalType(intervalType); err != nil {
+		return 0, err
+	}
+
+	prevTime, prevClose, err := closeBefore(ctx, dbpool, figi, intervalType, from)
+	if err != nil {
+		return 0, err
+	}
+
+	// Дивиденды за весь период, включая промежуток от предыдущей свечи
+	var dividends []Dividend
+	if totalReturn {
+		dividendsFrom := from
+		if !prevTime.IsZero() {
+			dividendsFrom = prevTime
+		}
+		if dividends, err = GetDividends(ctx, dbpool, figi, "", dividendsFrom, to); err != nil {
+			return 0, err
+		}
+	}
+
+	query := `
+		INSERT INTO returns (figi, time, interval_type, method, total_return, value, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (figi, interval_type, method, total_return, time) DO UPDATE SET
+			value = EXCLUDED.value,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	written := 0
+	err = StreamCandles(ctx, dbpool, figi, intervalType, from, to, func(candle Candle) error {
+		defer func() {
+			prevTime, prevClose = candle.Time, candle.ClosePrice
+		}()
+		if prevTime.IsZero() || prevClose <= 0 {
+			return nil
+		}
+
+		closePrice := candle.ClosePrice
+		if totalReturn {
+			closePrice += dividendsBetween(dividends, prevTime, candle.Time)
+		}
+
+		value := closePrice/prevClose - 1
+		if method == ReturnMethodLog {
+			if closePrice <= 0 {
+				return nil
+			}
+			value = math.Log(closePrice / prevClose)
+		}
+
+		if err := execWithRetry(ctx, dbpool, query, figi, candle.Time, intervalType, method, totalReturn, value); err != nil {
+			return fmt.Errorf("ошибка сохранения доходности за %s: %w", candle.Time.Format("2006-01-02 15:04"), err)
+		}
+		written++
+		return nil
+	})
+	return written, err
+}
+
+// closeBefore возвращает время и цену закрытия последней свечи до from (нулевое время - свечей нет)
+func closeBefore(ctx context.Context, dbpool *pgxpool.Pool, figi, intervalType string, from time.Time) (time.Time, float64, error) {
+	if from.IsZero() {
+		return time.Time{}, 0, nil
+	}
+	table, err := candleTableFor(ctx, dbpool, intervalType)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	query := fmt.Sprintf(`SELECT time, close_price FROM %s
+		WHERE figi = $1 AND interval_type = $2 AND time < $3
+		ORDER BY time DESC
+		LIMIT 1`, table)
+
+	var candleTime time.Time
+	var closePrice float64
+	err = dbpool.QueryRow(ctx, query, figi, intervalType, from).Scan(&candleTime, &closePrice)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, 0, nil
+	}
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("ошибка получения предыдущей свечи: %w", err)
+	}
+	return candleTime, closePrice, nil
+}
+
+// dividendsBetween суммирует дивиденды с датой выплаты в промежутке (after, until]
+func dividendsBetween(dividends []Dividend, after, until time.Time) float64 {
+	var sum float64
+	for _, dividend := range dividends {
+		if dividend.PaymentDate.After(after) && !dividend.PaymentDate.After(until) {
+			sum += dividend.Amount
+		}
+	}
+	return sum
+}
@@ -0,0 +1,191 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// RunStatusRunning запуск выполняется
+	RunStatusRunning = "running"
+	// RunStatusSuccess запуск завершён без ошибок
+	RunStatusSuccess = "success"
+	// RunStatusPartial запуск завершён, часть инструментов с ошибками
+	RunStatusPartial = "partial"
+	// RunStatusFailed запуск прерван
+	RunStatusFailed = "failed"
+)
+
+// RunLog запись о запуске загрузчика
+type RunLog struct {
+	ID                int64
+	Loader            string
+	IntervalType      string
+	StartedAt         time.Time
+	FinishedAt        *time.Time
+	Status            string
+	InstrumentsTotal  int
+	InstrumentsFailed int
+	CandlesInserted   int64
+	CandlesUpdated    int64
+	Error             *string
+}
+
+// FetchSummary сводка по запросам свечей к API за запуск
+type FetchSummary struct {
+	Requests int64 // запросов свечей к API
+	Candles  int64 // получено свечей
+	Bytes    int64 // примерный объём ответов (размер свечей в protobuf), байт
+}
+
+// Sub возвращает разницу сводок (прирост с момента other)
+func (s FetchSummary) Sub(other FetchSummary) FetchSummary {
+	return FetchSummary{
+		Requests: s.Requests - other.Requests,
+		Candles:  s.Candles - other.Candles,
+		Bytes:    s.Bytes - other.Bytes,
+	}
+}
+
+// AvgCandles возвращает среднее количество свечей на запрос
+func (s FetchSummary) AvgCandles() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Candles) / float64(s.Requests)
+}
+
+// StartRun регистрирует начало запуска загрузчика и возвращает его ID
+func StartRun(ctx context.Context, dbpool *pgxpool.Pool, loader, intervalType string) (int64, error) {
+	query := `
+		INSERT INTO run_log (loader, interval_type, started_at, status)
+		VALUES ($1, $2, NOW(), $3)
+		RETURNING id
+	`
+
+	var id int64
+	if err := dbpool.QueryRow(ctx, query, loader, intervalType, RunStatusRunning).Scan(&id); err != nil {
+		return 0, fmt.Errorf("ошибка регистрации запуска: %w", err)
+	}
+	return id, nil
+}
+
+// FinishRun фиксирует завершение запуска загрузчика
+// saved - свечи, вставленные и обновлённые (ON CONFLICT) за запуск, fetched - запросы свечей к API
+func FinishRun(ctx context.Context, dbpool *pgxpool.Pool, id int64, status string, total, failed int, saved SaveSummary, fetched FetchSummary, runErr error) error {
+	query := `
+		UPDATE run_log
+		SET finished_at = NOW(), status = $2, instruments_total = $3, instruments_failed = $4, error = $5,
+			candles_inserted = $6, candles_updated = $7,
+			api_requests = $8, api_candles = $9, api_bytes = $10
+		WHERE id = $1
+	`
+
+	var errText *string
+	if runErr != nil {
+		text := runErr.Error()
+		errText = &text
+	}
+
+	if _, err := dbpool.Exec(ctx, query, id, status, total, failed, errText, saved.Inserted, saved.Conflicts,
+		fetched.Requests, fetched.Candles, fetched.Bytes); err != nil {
+		return fmt.Errorf("ошибка фиксации завершения запуска: %w", err)
+	}
+	return nil
+}
+
+// GetLastCompletedRun возвращает последний завершённый (success/partial) запуск загрузчика
+// nil - запусков ещё не было
+func GetLastCompletedRun(ctx context.Context, dbpool *pgxpool.Pool, loader, intervalType string) (*RunLog, error) {
+	query := `
+		SELECT id, loader, interval_type, started_at, finished_at, status,
+			instruments_total, instruments_failed, candles_inserted, candles_updated, error
+		FROM run_log
+		WHERE loader = $1 AND interval_type = $2 AND status IN ($3, $4)
+		ORDER BY finished_at DESC
+		LIMIT 1
+	`
+
+	var run RunLog
+	err := dbpool.QueryRow(ctx, query, loader, intervalType, RunStatusSuccess, RunStatusPartial).Scan(
+		&run.ID,
+		&run.Loader,
+		&run.IntervalType,
+		&run.StartedAt,
+		&run.FinishedAt,
+		&run.Status,
+		&run.InstrumentsTotal,
+		&run.InstrumentsFailed,
+		&run.CandlesInserted,
+		&run.CandlesUpdated,
+		&run.Error,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения последнего запуска: %w", err)
+	}
+
+	return &run, nil
+}
+
+// SetRunFailedFigis сохраняет FIGI инструментов с ошибками запуска (пустой список - ошибок нет)
+func SetRunFailedFigis(ctx context.Context, dbpool *pgxpool.Pool, id int64, figis []string) error {
+	if figis == nil {
+		figis = []string{}
+	}
+	if _, err := dbpool.Exec(ctx, `UPDATE run_log SET failed_figis = $2 WHERE id = $1`, id, figis); err != nil {
+		return fmt.Errorf("ошибка сохранения инструментов с ошибками: %w", err)
+	}
+	return nil
+}
+
+// GetLastRunFailedFigis возвращает ID последнего завершённого (с любым статусом) запуска загрузчика
+// и FIGI инструментов с ошибками в нём. ID 0 - запусков ещё не было
+func GetLastRunFailedFigis(ctx context.Context, dbpool *pgxpool.Pool, loader, intervalType string) (int64, []string, error) {
+	query := `
+		SELECT id, COALESCE(failed_figis, '{}')
+		FROM run_log
+		WHERE loader = $1 AND interval_type = $2 AND finished_at IS NOT NULL
+		ORDER BY finished_at DESC
+		LIMIT 1
+	`
+
+	var id int64
+	var figis []string
+	err := dbpool.QueryRow(ctx, query, loader, intervalType).Scan(&id, &figis)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("ошибка получения инструментов с ошибками последнего запуска: %w", err)
+	}
+
+	return id, figis, nil
+}
+
+// RunStatus определяет итоговый статус запуска по количеству ошибок
+func RunStatus(total, failed int) string {
+	switch {
+	case failed == 0:
+		return RunStatusSuccess
+	case failed < total:
+		return RunStatusPartial
+	default:
+		return RunStatusFailed
+	}
+}
@@ -0,0 +1,230 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogSampler ограничивает повторяющиеся сообщения:
+// первое сообщение по ключу логируется, последующие только подсчитываются
+type LogSampler struct {
+	mu       sync.Mutex
+	enabled  bool
+	counters map[string]int
+}
+
+// NewLogSampler создает семплер логов
+func NewLogSampler(enabled bool) *LogSampler {
+	return &LogSampler{
+		enabled:  enabled,
+		counters: make(map[string]int),
+	}
+}
+
+// Allow возвращает true, если сообщение с ключом нужно залогировать
+func (s *LogSampler) Allow(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counters[key]++
+	return !s.enabled || s.counters[key] == 1
+}
+
+// SetEnabled включает или отключает подавление повторов
+func (s *LogSampler) SetEnabled(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enabled = enabled
+}
+
+// Suppressed возвращает количество подавленных сообщений по ключам
+func (s *LogSampler) Suppressed() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string]int)
+	if !s.enabled {
+		return result
+	}
+	for key, count := range s.counters {
+		if count > 1 {
+			result[key] = count - 1
+		}
+	}
+	return result
+}
+
+// SaveSummary сводка по сохранению свечей за запуск
+type SaveSummary struct {
+	Inserted          int64 // новые свечи
+	Conflicts         int64 // свечи, обновлённые через ON CONFLICT
+	PartitionsCreated int64 // партиции, созданные при сохранении
+	Duplicates        int64 // дубли свечей (одно время в пачке), схлопнутые до сохранения
+	BelowMinVolume    int64 // свечи с объёмом ниже loading.min_volume, не сохранённые
+	IntervalMismatch  int64 // свечи в пачках с шагом, не соответствующим интервалу (storage.interval_check)
+	OutsideSession    int64 // внутридневные свечи вне основной сессии (loading.sessions: regular), не сохранённые
+}
+
+// Sub возвращает разницу сводок (прирост с момента other)
+func (s SaveSummary) Sub(other SaveSummary) SaveSummary {
+	return SaveSummary{
+		Inserted:          s.Inserted - other.Inserted,
+		Conflicts:         s.Conflicts - other.Conflicts,
+		PartitionsCreated: s.PartitionsCreated - other.PartitionsCreated,
+		Duplicates:        s.Duplicates - other.Duplicates,
+		BelowMinVolume:    s.BelowMinVolume - other.BelowMinVolume,
+		IntervalMismatch:  s.IntervalMismatch - other.IntervalMismatch,
+		OutsideSession:    s.OutsideSession - other.OutsideSession,
+	}
+}
+
+// ConflictRatio возвращает долю обновлённых свечей среди сохранённых
+func (s SaveSummary) ConflictRatio() float64 {
+	total := s.Inserted + s.Conflicts
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Conflicts) / float64(total)
+}
+
+var (
+	// partitionLogSampler семплер логов создания партиций (ключ - имя партиции)
+	partitionLogSampler = NewLogSampler(true)
+
+	saveSummaryMu sync.Mutex
+	saveSummary   SaveSummary
+
+	// conflictAlertRatio доля обновлений, при превышении которой выводится подсказка (0 - выключено)
+	conflictAlertRatio = DefaultConflictAlertRatio
+)
+
+const (
+	// DefaultConflictAlertRatio доля обновлённых свечей по умолчанию для подсказки о повторной загрузке
+	DefaultConflictAlertRatio = 0.8
+	// conflictAlertMinCandles минимум сохранённых свечей для оценки доли обновлений
+	conflictAlertMinCandles = 1000
+)
+
+// SetConflictAlertRatio задаёт долю обновлённых свечей, при превышении которой выводится подсказка (0 - выключено)
+func SetConflictAlertRatio(ratio float64) {
+	saveSummaryMu.Lock()
+	defer saveSummaryMu.Unlock()
+	conflictAlertRatio = ratio
+}
+
+// LogConflictHint выводит подсказку, если обновления уже существующих свечей преобладают над вставками
+func LogConflictHint(summary SaveSummary, logger *logrus.Logger) {
+	saveSummaryMu.Lock()
+	ratio := conflictAlertRatio
+	saveSummaryMu.Unlock()
+
+	if ratio <= 0 || summary.Inserted+summary.Conflicts < conflictAlertMinCandles {
+		return
+	}
+	if summary.Conflicts <= summary.Inserted || summary.ConflictRatio() < ratio {
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"inserted":      summary.Inserted,
+		"conflicts":     summary.Conflicts,
+		"conflictRatio": math.Round(summary.ConflictRatio()*100) / 100,
+	}).Warn("Большинство свечей уже были в БД: данные загружаются повторно. " +
+		"Проверьте last_loaded_time инструментов или сдвиньте loading.start_date")
+}
+
+// SetLogSampling включает или отключает подавление повторяющихся логов сохранения
+func SetLogSampling(enabled bool) {
+	partitionLogSampler.SetEnabled(enabled)
+}
+
+// addSaveSummary добавляет результаты сохранения в сводку запуска
+func addSaveSummary(inserted, conflicts, partitions int64) {
+	saveSummaryMu.Lock()
+	defer saveSummaryMu.Unlock()
+
+	saveSummary.Inserted += inserted
+	saveSummary.Conflicts += conflicts
+	saveSummary.PartitionsCreated += partitions
+}
+
+// AddDuplicates учитывает в сводке запуска дубли свечей, схлопнутые до сохранения
+func AddDuplicates(count int) {
+	saveSummaryMu.Lock()
+	defer saveSummaryMu.Unlock()
+
+	saveSummary.Duplicates += int64(count)
+}
+
+// addBelowMinVolume учитывает в сводке запуска свечи, пропущенные по минимальному объёму
+func addBelowMinVolume(count int) {
+	saveSummaryMu.Lock()
+	defer saveSummaryMu.Unlock()
+
+	saveSummary.BelowMinVolume += int64(count)
+}
+
+// addIntervalMismatch учитывает в сводке запуска свечи пачки с шагом, не соответствующим интервалу
+func addIntervalMismatch(count int) {
+	saveSummaryMu.Lock()
+	defer saveSummaryMu.Unlock()
+
+	saveSummary.IntervalMismatch += int64(count)
+}
+
+// addOutsideSession учитывает в сводке запуска свечи вне основной сессии
+func addOutsideSession(count int) {
+	saveSummaryMu.Lock()
+	defer saveSummaryMu.Unlock()
+
+	saveSummary.OutsideSession += int64(count)
+}
+
+// GetSaveSummary возвращает сводку по сохранению свечей за запуск
+func GetSaveSummary() SaveSummary {
+	saveSummaryMu.Lock()
+	defer saveSummaryMu.Unlock()
+	return saveSummary
+}
+
+// LogSaveSummary выводит итог сохранения свечей и количество подавленных сообщений
+func LogSaveSummary(logger *logrus.Logger) {
+	summary := GetSaveSummary()
+
+	suppressed := partitionLogSampler.Suppressed()
+	partitions := make([]string, 0, len(suppressed))
+	for partition := range suppressed {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
+	for _, partition := range partitions {
+		logger.WithFields(logrus.Fields{
+			"partition":  partition,
+			"suppressed": suppressed[partition],
+		}).Debug("Подавлены повторяющиеся сообщения о партиции")
+	}
+
+	logger.WithFields(logrus.Fields{
+		"inserted":          summary.Inserted,
+		"conflicts":         summary.Conflicts,
+		"conflictRatio":     math.Round(summary.ConflictRatio()*100) / 100,
+		"partitionsCreated": summary.PartitionsCreated,
+		"duplicates":        summary.Duplicates,
+		"belowMinVolume":    summary.BelowMinVolume,
+		"intervalMismatch":  summary.IntervalMismatch,
+		"outsideSession":    summary.OutsideSession,
+	}).Infof("Создано партиций: %d, разрешено конфликтов: %d", summary.PartitionsCreated, summary.Conflicts)
+
+	LogConflictHint(summary, logger)
+}
@@ -0,0 +1,115 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SchemaDDL возвращает DDL схемы, которую создаёт загрузчик: таблицы, индексы, внешние ключи,
+// триггер и представление instrument_view (те же определения, что в InitDatabase и
+// CreateIndexesAndConstraints), и партицию candles текущего месяца.
+// Миграции существующих таблиц не включаются: DDL описывает итоговую схему
+func SchemaDDL() []string {
+	tables := tableDefinitions()
+	indexes := indexDefinitions()
+
+	statements := make([]string, 0, len(tables)+len(indexes)+1)
+	statements = append(statements, tables...)
+	statements = append(statements, indexes...)
+
+	// При составном партиционировании месячные партиции создаются в секциях интервалов при загрузке
+	if !partitionByInterval.Load() {
+		statements = append(statements, partitionDefinition(SharedCandleTable, time.Now()))
+	}
+	return statements
+}
+
+// PartitionsDDL возвращает DDL существующих в БД отдельных таблиц свечей интервалов,
+// секций и месячных партиций свечей (границы партиций - из каталога PostgreSQL)
+func PartitionsDDL(ctx context.Context, dbpool *pgxpool.Pool) ([]string, error) {
+	tables, err := existingCandleTables(ctx, dbpool)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []string
+	for _, table := range tables {
+		// Секции составного партиционирования выводятся как партиции candles
+		var isPartition bool
+		if err := dbpool.QueryRow(ctx,
+			`SELECT relispartition FROM pg_class WHERE oid = to_regclass($1)`, table,
+		).Scan(&isPartition); err != nil {
+			return nil, fmt.Errorf("ошибка проверки таблицы %s: %w", table, err)
+		}
+		if isPartition {
+			continue
+		}
+
+		if table != SharedCandleTable {
+			statements = append(statements, intervalTableDefinition(table))
+		}
+
+		partitions, err := partitionsDDLOf(ctx, dbpool, table)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, partitions...)
+	}
+	return statements, nil
+}
+
+// partitionsDDLOf возвращает DDL всех партиций таблицы, включая вложенные (родители - раньше потомков)
+func partitionsDDLOf(ctx context.Context, dbpool *pgxpool.Pool, table string) ([]string, error) {
+	rows, err := dbpool.Query(ctx, `
+		WITH RECURSIVE parts AS (
+			SELECT i.inhrelid AS oid, i.inhparent AS parent, 1 AS depth
+			FROM pg_inherits i
+			WHERE i.inhparent = to_regclass($1)
+			UNION ALL
+			SELECT i.inhrelid, i.inhparent, p.depth + 1
+			FROM pg_inherits i
+			JOIN parts p ON i.inhparent = p.oid
+		)
+		SELECT c.relname, pc.relname, pg_get_expr(c.relpartbound, c.oid),
+			CASE WHEN c.relkind = 'p' THEN pg_get_partkeydef(c.oid) ELSE '' END
+		FROM parts
+		JOIN pg_class c ON c.oid = parts.oid
+		JOIN pg_class pc ON pc.oid = parts.parent
+		ORDER BY parts.depth, c.relname
+	`, table)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения партиций %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var statements []string
+	for rows.Next() {
+		var name, parent, bound, partitionKey string
+		if err := rows.Scan(&name, &parent, &bound, &partitionKey); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования партиции: %w", err)
+		}
+
+		statement := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s %s", name, parent, bound)
+		if partitionKey != "" {
+			statement += " PARTITION BY " + partitionKey
+		}
+		statements = append(statements, statement)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по партициям: %w", err)
+	}
+
+	return statements, nil
+}
@@ -0,0 +1,60 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"market-loader/pkg/config"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+)
+
+var (
+	sessionMu sync.RWMutex
+	// regularOpen, regularClose границы основной сессии от начала суток UTC (обе нулевые - все сессии)
+	regularOpen, regularClose time.Duration
+)
+
+// SetRegularSession задаёт основную сессию (loading.sessions: regular): внутридневные свечи,
+// открытые вне [openAt, closeAt) от начала суток UTC, не сохраняются. Нулевые границы - свечи всех сессий
+func SetRegularSession(openAt, closeAt time.Duration) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	regularOpen, regularClose = openAt, closeAt
+}
+
+// filterRegularSession убирает внутридневные свечи вне основной сессии и учитывает их в сводке запуска
+func filterRegularSession(candles []*pb.HistoricCandle, intervalType string) []*pb.HistoricCandle {
+	sessionMu.RLock()
+	openAt, closeAt := regularOpen, regularClose
+	sessionMu.RUnlock()
+
+	if closeAt <= openAt {
+		return candles
+	}
+	switch intervalType {
+	case config.CandleIntervalDay, config.CandleIntervalWeek, config.CandleIntervalMonth:
+		return candles
+	}
+
+	result := candles[:0:0]
+	for _, candle := range candles {
+		t := candle.GetTime().AsTime().UTC()
+		sinceMidnight := t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+		if sinceMidnight >= openAt && sinceMidnight < closeAt {
+			result = append(result, candle)
+		}
+	}
+	if skipped := len(candles) - len(result); skipped > 0 {
+		addOutsideSession(skipped)
+	}
+	return result
+}
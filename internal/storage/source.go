@@ -0,0 +1,61 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"sync"
+
+	"market-loader/pkg/config"
+)
+
+var (
+	sourcePreferenceMu sync.RWMutex
+	// sourcePreference порядок предпочтения источников свечей при чтении (первый - самый предпочтительный)
+	sourcePreference = []string{config.CandleSourceAPI, config.CandleSourceArchive}
+)
+
+// SetCandleSourcePreference задаёт порядок предпочтения источников свечей при чтении (config.GetSourcePreference)
+func SetCandleSourcePreference(order []string) {
+	if len(order) == 0 {
+		return
+	}
+	sourcePreferenceMu.Lock()
+	defer sourcePreferenceMu.Unlock()
+	sourcePreference = append([]string(nil), order...)
+}
+
+// sourceRank возвращает место источника в порядке предпочтения (меньше - предпочтительнее),
+// неизвестный источник - после всех известных
+func sourceRank(source string) int {
+	sourcePreferenceMu.RLock()
+	defer sourcePreferenceMu.RUnlock()
+	for i, preferred := range sourcePreference {
+		if preferred == source {
+			return i
+		}
+	}
+	return len(sourcePreference)
+}
+
+// candleSource определяет источник свечи по source_file: заполнен - архив, иначе API
+func candleSource(fromArchive bool) string {
+	if fromArchive {
+		return config.CandleSourceArchive
+	}
+	return config.CandleSourceAPI
+}
+
+// preferCandle выбирает из двух свечей за одно время свечу более предпочтительного источника,
+// при равном предпочтении остаётся текущая
+func preferCandle(current, candidate Candle) Candle {
+	if sourceRank(candidate.Source) < sourceRank(current.Source) {
+		return candidate
+	}
+	return current
+}
@@ -0,0 +1,89 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// syncLockKey ключ advisory lock синхронизации справочника инструментов с загрузкой свечей
+	syncLockKey int64 = 0x6d6c5f73796e63 // "ml_sync"
+	// syncLockPoll интервал повторной попытки взять блокировку
+	syncLockPoll = 5 * time.Second
+)
+
+// ErrSyncLockTimeout блокировка не освободилась за время ожидания
+var ErrSyncLockTimeout = errors.New("блокировка синхронизации инструментов занята")
+
+// SyncLock взятая advisory lock; удерживается отдельным соединением пула до Release
+type SyncLock struct {
+	conn   *pgxpool.Conn
+	shared bool
+}
+
+// AcquireSyncLock берёт advisory lock синхронизации, ожидая её освобождения не дольше wait.
+// shared - загрузка свечей (несколько загрузчиков одновременно), иначе - синхронизация инструментов
+// (монопольно: ждёт завершения загрузок свечей, а они - завершения синхронизации)
+func AcquireSyncLock(ctx context.Context, dbpool *pgxpool.Pool, shared bool, wait time.Duration) (*SyncLock, error) {
+	conn, err := dbpool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения соединения для блокировки: %w", err)
+	}
+
+	query := `SELECT pg_try_advisory_lock($1)`
+	if shared {
+		query = `SELECT pg_try_advisory_lock_shared($1)`
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		var locked bool
+		if err := conn.QueryRow(ctx, query, syncLockKey).Scan(&locked); err != nil {
+			conn.Release()
+			return nil, fmt.Errorf("ошибка взятия блокировки синхронизации: %w", err)
+		}
+		if locked {
+			return &SyncLock{conn: conn, shared: shared}, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			conn.Release()
+			return nil, ErrSyncLockTimeout
+		}
+		select {
+		case <-ctx.Done():
+			conn.Release()
+			return nil, ctx.Err()
+		case <-time.After(min(syncLockPoll, remaining)):
+		}
+	}
+}
+
+// Release освобождает блокировку и возвращает соединение в пул
+func (l *SyncLock) Release(ctx context.Context) error {
+	query := `SELECT pg_advisory_unlock($1)`
+	if l.shared {
+		query = `SELECT pg_advisory_unlock_shared($1)`
+	}
+	defer l.conn.Release()
+
+	if _, err := l.conn.Exec(ctx, query, syncLockKey); err != nil {
+		// Блокировка снимается вместе с сессией: соединение закрывается, а не возвращается в пул
+		_ = l.conn.Conn().Close(ctx)
+		return fmt.Errorf("ошибка освобождения блокировки: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// SharedCandleTable общая таблица свечей всех интервалов
+const SharedCandleTable = "candles"
+
+var (
+	// tablePerInterval режим отдельной таблицы свечей для каждого интервала
+	tablePerInterval atomic.Bool
+	// partitionByInterval составное партиционирование candles: по interval_type, затем по месяцам
+	partitionByInterval atomic.Bool
+	// candleTablesReady таблицы свечей, созданные в этом процессе
+	candleTablesReady sync.Map
+
+	// intervalTexts интервалы, для которых могут существовать отдельные таблицы
+	intervalTexts = []string{
+		config.CandleIntervalText1Min, config.CandleIntervalText2Min, config.CandleIntervalText3Min,
+		config.CandleIntervalText5Min, config.CandleIntervalText10Min, config.CandleIntervalText15Min,
+		config.CandleIntervalText30Min, config.CandleIntervalTextHour, config.CandleIntervalText2Hour,
+		config.CandleIntervalText4Hour, config.CandleIntervalTextDay, config.CandleIntervalTextWeek,
+		config.CandleIntervalTextMonth,
+	}
+)
+
+// SetTablePerInterval включает режим отдельной таблицы свечей для каждого интервала (candles_1min, candles_1day)
+func SetTablePerInterval(enabled bool) {
+	tablePerInterval.Store(enabled)
+}
+
+// SetPartitionByInterval включает составное партиционирование общей таблицы candles:
+// секция интервала (candles_1min, LIST по interval_type) и месячные партиции в ней (candles_1min_2025_01)
+func SetPartitionByInterval(enabled bool) {
+	partitionByInterval.Store(enabled)
+}
+
+// CandleTable возвращает таблицу свечей для интервала
+func CandleTable(intervalType string) string {
+	if !tablePerInterval.Load() {
+		return SharedCandleTable
+	}
+	intervalText := config.Interval2text(intervalType)
+	if intervalText == "" {
+		return SharedCandleTable
+	}
+	return intervalTable(intervalText)
+}
+
+// intervalTable имя отдельной таблицы по текстовому интервалу (1min -> candles_1min)
+func intervalTable(intervalText string) string {
+	return SharedCandleTable + "_" + intervalText
+}
+
+// candleTableFor возвращает таблицу свечей для интервала, создавая её при необходимости
+func candleTableFor(ctx context.Context, dbpool *pgxpool.Pool, intervalType string) (string, error) {
+	table := CandleTable(intervalType)
+	if err := ensureCandleTable(ctx, dbpool, table); err != nil {
+		return "", err
+	}
+	return table, nil
+}
+
+// ensureCandleTable создает партиционированную таблицу свечей интервала с той же структурой, что и candles
+func ensureCandleTable(ctx context.Context, dbpool *pgxpool.Pool, table string) error {
+	if table == SharedCandleTable {
+		return nil // создается в InitDatabase
+	}
+	if _, ok := candleTablesReady.Load(table); ok {
+		return nil
+	}
+
+	if _, err := dbpool.Exec(ctx, intervalTableDefinition(table)); err != nil {
+		return fmt.Errorf("ошибка создания таблицы %s: %w", table, err)
+	}
+
+	// Партиция текущего месяца, как для общей таблицы
+	if err := createPartitionFor(dbpool, table, time.Now()); err != nil {
+		return err
+	}
+
+	candleTablesReady.Store(table, struct{}{})
+	return nil
+}
+
+// intervalTableDefinition возвращает DDL отдельной таблицы свечей интервала с индексом и внешним ключом
+func intervalTableDefinition(table string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			id BIGSERIAL,
+			figi VARCHAR(50) NOT NULL,
+			time TIMESTAMP NOT NULL,
+			open_price DECIMAL(20, 9) NOT NULL,
+			high_price DECIMAL(20, 9) NOT NULL,
+			low_price DECIMAL(20, 9) NOT NULL,
+			close_price DECIMAL(20, 9) NOT NULL,
+			volume BIGINT NOT NULL,
+			interval_type VARCHAR(30) NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW(),
+			source_file VARCHAR(255) NULL,
+			PRIMARY KEY (figi, time, interval_type)
+		) PARTITION BY RANGE ("time");
+
+		ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS source_file VARCHAR(255) NULL;
+
+		CREATE INDEX IF NOT EXISTS idx_%[1]s_time ON %[1]s(time);
+
+		DO $$ 
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE constraint_schema = current_schema() AND constraint_name = '%[1]s_figi_fkey') THEN
+				ALTER TABLE %[1]s ADD CONSTRAINT %[1]s_figi_fkey 
+					FOREIGN KEY (figi) REFERENCES instruments(figi) ON UPDATE CASCADE ON DELETE CASCADE;
+			END IF;
+		END $$;
+	`, table)
+}
+
+// monthPartitionParent возвращает таблицу, в которой создаются месячные партиции свечей интервала:
+// при составном партиционировании - секцию интервала общей таблицы (создаётся при необходимости), иначе саму таблицу
+func monthPartitionParent(ctx context.Context, dbpool *pgxpool.Pool, table, intervalType string) (string, error) {
+	if table != SharedCandleTable || !partitionByInterval.Load() {
+		return table, nil
+	}
+
+	intervalText := config.Interval2text(intervalType)
+	if intervalText == "" {
+		return "", fmt.Errorf("неизвестный интервал %q для партиции свечей", intervalType)
+	}
+	section := intervalTable(intervalText)
+	if _, ok := candleTablesReady.Load(section); ok {
+		return section, nil
+	}
+
+	query := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s PARTITION OF %s
+			FOR VALUES IN ('%s')
+			PARTITION BY RANGE ("time")
+	`, section, SharedCandleTable, intervalType)
+	if _, err := dbpool.Exec(ctx, query); err != nil {
+		return "", fmt.Errorf("ошибка создания секции %s: %w", section, err)
+	}
+
+	candleTablesReady.Store(section, struct{}{})
+	return section, nil
+}
+
+// createCandlePartition создает месячную партицию свечей интервала в таблице свечей
+func createCandlePartition(ctx context.Context, dbpool *pgxpool.Pool, table, intervalType string, t time.Time) error {
+	parent, err := monthPartitionParent(ctx, dbpool, table, intervalType)
+	if err != nil {
+		return err
+	}
+	return createPartitionFor(dbpool, parent, t)
+}
+
+// checkCandlePartitioning проверяет, что партиционирование candles в БД совпадает с database.partition_by_interval:
+// смена режима требует переноса данных (см. DATABASE.md)
+func checkCandlePartitioning(ctx context.Context, dbpool *pgxpool.Pool) error {
+	var strategy string
+	err := dbpool.QueryRow(ctx, `
+		SELECT COALESCE((SELECT partstrat::text FROM pg_partitioned_table WHERE partrelid = to_regclass($1)), '')
+	`, SharedCandleTable).Scan(&strategy)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки партиционирования %s: %w", SharedCandleTable, err)
+	}
+
+	switch {
+	case partitionByInterval.Load() && strategy == "r":
+		return fmt.Errorf("таблица %s партиционирована только по времени, а включён database.partition_by_interval: "+
+			"перенесите свечи по инструкции в DATABASE.md или выключите настройку", SharedCandleTable)
+	case !partitionByInterval.Load() && strategy == "l":
+		return fmt.Errorf("таблица %s партиционирована по interval_type: включите database.partition_by_interval", SharedCandleTable)
+	}
+	return nil
+}
+
+// existingCandleTables возвращает существующие таблицы свечей: общую и отдельные по интервалам
+func existingCandleTables(ctx context.Context, dbpool *pgxpool.Pool) ([]string, error) {
+	tables := []string{SharedCandleTable}
+	for _, intervalText := range intervalTexts {
+		table := intervalTable(intervalText)
+
+		var exists bool
+		if err := dbpool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("ошибка проверки таблицы %s: %w", table, err)
+		}
+		if exists {
+			tables = append(tables, table)
+		}
+	}
+	return tables, nil
+}
+
+// SplitCandlesByInterval переносит свечи из общей таблицы candles в отдельные таблицы интервалов.
+// Каждый интервал переносится в своей транзакции: копирование и удаление из candles.
+// Возвращает количество перенесённых свечей по интервалам
+func SplitCandlesByInterval(ctx context.Context, dbpool *pgxpool.Pool, logger *logrus.Logger) (map[string]int64, error) {
+	rows, err := dbpool.Query(ctx, `SELECT interval_type, MIN(time), MAX(time) FROM candles GROUP BY interval_type`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения интервалов свечей: %w", err)
+	}
+
+	type intervalRange struct {
+		intervalType string
+		from, to     time.Time
+	}
+	var ranges []intervalRange
+	for rows.Next() {
+		var r intervalRange
+		if err := rows.Scan(&r.intervalType, &r.from, &r.to); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("ошибка сканирования интервала свечей: %w", err)
+		}
+		ranges = append(ranges, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по интервалам свечей: %w", err)
+	}
+
+	moved := make(map[string]int64, len(ranges))
+	for _, r := range ranges {
+		intervalText := config.Interval2text(r.intervalType)
+		if intervalText == "" {
+			logger.WithField("intervalType", r.intervalType).Warn("Неизвестный интервал, свечи оставлены в candles")
+			continue
+		}
+		table := intervalTable(intervalText)
+
+		if err := ensureCandleTable(ctx, dbpool, table); err != nil {
+			return moved, err
+		}
+
+		// Партиции на весь диапазон переносимых свечей
+		for month := time.Date(r.from.Year(), r.from.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(r.to); month = month.AddDate(0, 1, 0) {
+			if err := createPartitionFor(dbpool, table, month); err != nil {
+				return moved, err
+			}
+		}
+
+		count, err := moveIntervalCandles(ctx, dbpool, table, r.intervalType)
+		if err != nil {
+			return moved, err
+		}
+		moved[intervalText] = count
+
+		logger.WithFields(logrus.Fields{
+			"table": table,
+			"moved": count,
+		}).Info("Свечи интервала перенесены в отдельную таблицу")
+	}
+
+	return moved, nil
+}
+
+// moveIntervalCandles копирует свечи интервала из candles в таблицу и удаляет их из candles в одной транзакции
+func moveIntervalCandles(ctx context.Context, dbpool *pgxpool.Pool, table, intervalType string) (int64, error) {
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer func() {
+		// После Commit откат ничего не делает
+		_ = tx.Rollback(ctx)
+	}()
+
+	insertQuery := fmt.Sprintf(`
+		INSERT INTO %s (figi, time, open_price, high_price, low_price, close_price, volume, interval_type, created_at, source_file)
+		SELECT figi, time, open_price, high_price, low_price, close_price, volume, interval_type, created_at, source_file
+		FROM candles
+		WHERE interval_type = $1
+		ON CONFLICT (figi, time, interval_type) DO NOTHING
+	`, table)
+	tag, err := tx.Exec(ctx, insertQuery, intervalType)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка копирования свечей в %s: %w", table, err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM candles WHERE interval_type = $1`, intervalType); err != nil {
+		return 0, fmt.Errorf("ошибка удаления перенесённых свечей из candles: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка подтверждения переноса свечей в %s: %w", table, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,50 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"sync"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+)
+
+var (
+	minVolumeMu sync.RWMutex
+	// minVolume минимальный объём сохраняемой свечи по типу интервала (нет ключа - без ограничения)
+	minVolume map[string]int64
+)
+
+// SetMinVolume задаёт минимальный объём сохраняемой свечи по типу интервала (config.GetMinVolume)
+func SetMinVolume(policy map[string]int64) {
+	minVolumeMu.Lock()
+	defer minVolumeMu.Unlock()
+	minVolume = policy
+}
+
+// filterMinVolume убирает свечи с объёмом ниже минимального для интервала и учитывает их в сводке запуска
+func filterMinVolume(candles []*pb.HistoricCandle, intervalType string) []*pb.HistoricCandle {
+	minVolumeMu.RLock()
+	threshold := minVolume[intervalType]
+	minVolumeMu.RUnlock()
+
+	if threshold <= 0 {
+		return candles
+	}
+
+	result := candles[:0:0]
+	for _, candle := range candles {
+		if candle.GetVolume() >= threshold {
+			result = append(result, candle)
+		}
+	}
+	if skipped := len(candles) - len(result); skipped > 0 {
+		addBelowMinVolume(skipped)
+	}
+	return result
+}
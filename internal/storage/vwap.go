@@ -0,0 +1,72 @@
+// Package storage содержит функции для работы с базой данных свечей
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"market-loader/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SessionVWAP средневзвешенная по объёму цена инструмента за торговый день
+type SessionVWAP struct {
+	Figi    string
+	Day     time.Time
+	VWAP    *float64 // nil - за день нулевой объём
+	Volume  int64
+	Candles int
+}
+
+// ComputeSessionVWAP считает VWAP за торговый день (UTC) по сохранённым минутным свечам:
+// sum(close * volume) / sum(volume), и записывает результат в session_vwap.
+// День без свечей не записывается (Candles = 0), день с нулевым объёмом записывается с vwap = NULL
+func ComputeSessionVWAP(ctx context.Context, dbpool *pgxpool.Pool, figi string, day time.Time) (SessionVWAP, error) {
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	result := SessionVWAP{Figi: figi, Day: dayStart}
+
+	table := CandleTable(config.CandleInterval1Min)
+	query := fmt.Sprintf(`
+		SELECT
+			(SUM(close_price * volume) / NULLIF(SUM(volume), 0))::DOUBLE PRECISION,
+			COALESCE(SUM(volume), 0),
+			COUNT(*)
+		FROM %s
+		WHERE figi = $1 AND interval_type = $2 AND time >= $3 AND time < $4
+	`, table)
+
+	err := dbpool.QueryRow(ctx, query, figi, config.CandleInterval1Min, dayStart, dayStart.AddDate(0, 0, 1)).
+		Scan(&result.VWAP, &result.Volume, &result.Candles)
+	if err != nil {
+		return result, fmt.Errorf("ошибка расчёта VWAP за %s: %w", dayStart.Format("2006-01-02"), err)
+	}
+
+	// Неторговый день или данных нет
+	if result.Candles == 0 {
+		return result, nil
+	}
+
+	_, err = dbpool.Exec(ctx, `
+		INSERT INTO session_vwap (figi, day, vwap, volume, candles, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (figi, day) DO UPDATE SET
+			vwap = EXCLUDED.vwap,
+			volume = EXCLUDED.volume,
+			candles = EXCLUDED.candles,
+			updated_at = EXCLUDED.updated_at
+	`, figi, dayStart, result.VWAP, result.Volume, result.Candles)
+	if err != nil {
+		return result, fmt.Errorf("ошибка сохранения VWAP за %s: %w", dayStart.Format("2006-01-02"), err)
+	}
+
+	return result, nil
+}
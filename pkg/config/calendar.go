@@ -0,0 +1,177 @@
+// Package config содержит общие функции и константы для загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxNonTradingDays максимальная длина неторгового периода при поиске следующей сессии
+const maxNonTradingDays = 31
+
+// holidaySet возвращает праздничные дни календаря (некорректные даты пропускаются)
+func (c *Config) holidaySet() map[string]bool {
+	holidays := make(map[string]bool, len(c.Calendar.Holidays))
+	for _, day := range c.Calendar.Holidays {
+		if parsed, err := time.Parse("2006-01-02", day); err == nil {
+			holidays[parsed.Format("2006-01-02")] = true
+		}
+	}
+	return holidays
+}
+
+// isTradingDay проверяет день по выходным и набору праздников
+func isTradingDay(day time.Time, holidays map[string]bool) bool {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	return !holidays[day.Format("2006-01-02")]
+}
+
+// IsTradingDay проверяет, является ли день торговым (не выходной и не праздник из calendar.holidays)
+func (c *Config) IsTradingDay(day time.Time) bool {
+	return isTradingDay(day, c.holidaySet())
+}
+
+// ExpectedPeriods возвращает ожидаемое количество свечей между from и to включительно по торговому календарю
+// для дневных, недельных и месячных интервалов; для внутридневных интервалов ok = false
+func (c *Config) ExpectedPeriods(intervalType string, from, to time.Time) (count int, ok bool) {
+	switch intervalType {
+	case CandleIntervalDay, CandleIntervalWeek, CandleIntervalMonth:
+	default:
+		return 0, false
+	}
+
+	holidays := c.holidaySet()
+	periods := make(map[string]bool)
+
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	for !day.After(last) {
+		if isTradingDay(day, holidays) {
+			// Период учитывается, если в нём есть хотя бы один торговый день
+			switch intervalType {
+			case CandleIntervalWeek:
+				year, week := day.ISOWeek()
+				periods[time.Date(year, 1, week, 0, 0, 0, 0, time.UTC).Format("2006-01-02")] = true
+			case CandleIntervalMonth:
+				periods[day.Format("2006-01")] = true
+			default:
+				periods[day.Format("2006-01-02")] = true
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return len(periods), true
+}
+
+// ExpectedCandles возвращает ожидаемое количество свечей между from и to по торговому календарю.
+// Для внутридневных интервалов - число интервалов сессии (calendar.session_open - calendar.session_close)
+// в каждом торговом дне; без заданной сессии ok = false
+func (c *Config) ExpectedCandles(intervalType string, from, to time.Time) (count int, ok bool) {
+	if count, ok := c.ExpectedPeriods(intervalType, from, to); ok {
+		return count, true
+	}
+	if c.Calendar.SessionOpen == "" || c.Calendar.SessionClose == "" {
+		return 0, false
+	}
+	openAt, err := parseClock(c.Calendar.SessionOpen)
+	if err != nil {
+		return 0, false
+	}
+	closeAt, err := parseClock(c.Calendar.SessionClose)
+	if err != nil || closeAt <= openAt {
+		return 0, false
+	}
+
+	step := IntervalPeriodEnd(intervalType, time.Time{}).Sub(time.Time{})
+	days, _ := c.ExpectedPeriods(CandleIntervalDay, from, to)
+	return days * int((closeAt-openAt)/step), true
+}
+
+// RegularSession возвращает границы основной сессии (calendar.regular_open, calendar.regular_close)
+// как смещения от начала суток UTC; обе границы обязательны, закрытие позже открытия
+func (c *Config) RegularSession() (openAt, closeAt time.Duration, err error) {
+	if c.Calendar.RegularOpen == "" || c.Calendar.RegularClose == "" {
+		return 0, 0, fmt.Errorf("не заданы calendar.regular_open и calendar.regular_close")
+	}
+	if openAt, err = parseClock(c.Calendar.RegularOpen); err != nil {
+		return 0, 0, err
+	}
+	if closeAt, err = parseClock(c.Calendar.RegularClose); err != nil {
+		return 0, 0, err
+	}
+	if closeAt <= openAt {
+		return 0, 0, fmt.Errorf("закрытие основной сессии (%s) должно быть позже открытия (%s)", c.Calendar.RegularClose, c.Calendar.RegularOpen)
+	}
+	return openAt, closeAt, nil
+}
+
+// parseClock разбирает время суток в формате HH:MM (UTC) в смещение от начала суток
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("некорректное время %q (формат HH:MM): %w", value, err)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// ResumeFrom возвращает момент, с которого продолжать загрузку внутридневных свечей после from.
+// Если включен calendar.skip_non_trading и from приходится на неторговый день или после закрытия
+// сессии (calendar.session_close), момент переносится на открытие сессии (calendar.session_open)
+// следующего торгового дня. Для дневных, недельных и месячных интервалов from не меняется.
+func (c *Config) ResumeFrom(intervalType string, from time.Time) time.Time {
+	if !c.Calendar.SkipNonTrading {
+		return from
+	}
+	switch intervalType {
+	case CandleIntervalDay, CandleIntervalWeek, CandleIntervalMonth:
+		return from
+	}
+
+	// Без session_open сессия считается открытой с начала суток
+	var openAt, closeAt time.Duration
+	if c.Calendar.SessionOpen != "" {
+		parsed, err := parseClock(c.Calendar.SessionOpen)
+		if err != nil {
+			return from
+		}
+		openAt = parsed
+	}
+	hasClose := c.Calendar.SessionClose != ""
+	if hasClose {
+		parsed, err := parseClock(c.Calendar.SessionClose)
+		if err != nil {
+			return from
+		}
+		closeAt = parsed
+	}
+
+	holidays := c.holidaySet()
+	utc := from.UTC()
+	day := time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC)
+
+	if isTradingDay(day, holidays) && (!hasClose || utc.Sub(day) < closeAt) {
+		// Торговый день до закрытия сессии: до открытия переносим на открытие
+		if open := day.Add(openAt); utc.Before(open) {
+			return open
+		}
+		return from
+	}
+
+	for i := 0; i < maxNonTradingDays; i++ {
+		day = day.AddDate(0, 0, 1)
+		if isTradingDay(day, holidays) {
+			return day.Add(openAt)
+		}
+	}
+	return from
+}
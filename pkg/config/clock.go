@@ -0,0 +1,50 @@
+// Package config содержит общие функции и константы для загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// asOf зафиксированный текущий момент запуска (--as-of), нулевое значение - реальное время
+var asOf time.Time
+
+// SetAsOf фиксирует текущий момент на весь запуск: период загрузки, проверки актуальности данных
+// и даты окончания по умолчанию считаются от него, свечи позже него не загружаются.
+// Нулевое значение снимает фиксацию
+func SetAsOf(t time.Time) {
+	asOf = t.UTC()
+}
+
+// AsOf возвращает зафиксированный момент запуска и признак фиксации
+func AsOf() (time.Time, bool) {
+	return asOf, !asOf.IsZero()
+}
+
+// Now возвращает текущий момент запуска: зафиксированный SetAsOf или реальное время
+func Now() time.Time {
+	if !asOf.IsZero() {
+		return asOf
+	}
+	return time.Now()
+}
+
+// ParseAsOf разбирает момент в формате RFC 3339 (2024-06-01T00:00:00Z) или дату YYYY-MM-DD (полночь UTC)
+func ParseAsOf(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := ParseDate(value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("неверный формат момента %q (ожидается RFC 3339 или YYYY-MM-DD)", value)
+}
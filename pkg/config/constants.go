@@ -17,10 +17,48 @@ const (
 	DefaultYearsBack = 5
 	// DefaultRetryDelay задержка между повторными попытками
 	DefaultRetryDelay = 5 * time.Second
-	// DefaultHTTPTimeout таймаут HTTP-запросов по умолчанию
+	// DefaultHTTPTimeout таймаут HTTP-запросов по умолчанию (также загрузка конфигурации по URL)
 	DefaultHTTPTimeout = 30 * time.Second
 	// DefaultUpdateThreshold минимальный порог времени для решения, что данные устарели
 	DefaultUpdateThreshold = 1 * time.Minute
+	// StreamReconnectDelay начальная задержка переподключения к стриму свечей
+	StreamReconnectDelay = 1 * time.Second
+	// StreamMaxReconnectDelay максимальная задержка переподключения к стриму свечей
+	StreamMaxReconnectDelay = 1 * time.Minute
+	// StartupConnectDelay начальная задержка повторного подключения к БД и API при запуске
+	StartupConnectDelay = 1 * time.Second
+	// StartupMaxConnectDelay максимальная задержка повторного подключения к БД и API при запуске
+	StartupMaxConnectDelay = 30 * time.Second
+	// DefaultPartitionsAhead на сколько месяцев вперёд создавать партиции свечей
+	DefaultPartitionsAhead = 3
+	// DefaultOutagePause пауза при недоступности API (технические работы)
+	DefaultOutagePause = 5 * time.Minute
+	// DefaultOutageMaxPauses сколько пауз подряд ждать восстановления API
+	DefaultOutageMaxPauses = 3
+	// DefaultSkipEmptyRecheck как часто повторно запрашивать инструмент, не возвращающий свечей
+	DefaultSkipEmptyRecheck = 7 * 24 * time.Hour
+	// DefaultIntervalWorkers интервалы одного инструмента загружаются последовательно
+	DefaultIntervalWorkers = 1
+	// DefaultInstrumentWorkers типы инструментов синхронизируются последовательно
+	DefaultInstrumentWorkers = 1
+	// DefaultInstrumentBatchSize инструментов в одном запросе сохранения справочника
+	DefaultInstrumentBatchSize = 500
+	// MaxInstrumentBatchSize предел пачки: 22 параметра на инструмент при лимите PostgreSQL 65535 параметров
+	MaxInstrumentBatchSize = 2000
+	// DefaultSinkTopic топик для публикации свечей по умолчанию
+	DefaultSinkTopic = "candles"
+	// DefaultSchema схема БД по умолчанию
+	DefaultSchema = "public"
+	// DefaultProgressEveryChunks через сколько чанков логировать прогресс загрузки
+	DefaultProgressEveryChunks = 50
+	// DefaultClampOutlierRatio допустимое отклонение high/low от open/close (доля) для clamp_outliers
+	DefaultClampOutlierRatio = 0.2
+	// DefaultVerifyTolerance допустимое отклонение количества свечей от ожидаемого (доля)
+	DefaultVerifyTolerance = 0.1
+	// DefaultCoverageMinRatio доля ожидаемых свечей, с которой инструмент считается загруженным
+	DefaultCoverageMinRatio = 0.95
+	// DefaultPriceScaleThreshold доля свечей с ценой, не кратной шагу цены, при которой инструмент отмечается
+	DefaultPriceScaleThreshold = 0.5
 	// MinutesInHour количество минут в часе
 	MinutesInHour = 60
 	// HoursInDay количество часов в сутках
@@ -31,6 +69,20 @@ const (
 	DaysInMonth = 30
 	// MinutesInDay количество минут в сутках
 	MinutesInDay = HoursInDay * MinutesInHour
+	// DaysInYear количество дней в году (без учёта високосных)
+	DaysInYear = 365
+	// WeeksInYear количество недель в году
+	WeeksInYear = 52
+	// MonthsInYear количество месяцев в году
+	MonthsInYear = 12
+	// QuarterDays количество дней в квартале (условное значение для расчётов)
+	QuarterDays = 90
+	// MaxYearsPerDay максимальный период запроса дневных свечей в годах
+	MaxYearsPerDay = 6
+	// MaxYearsPerWeek максимальный период запроса недельных свечей в годах
+	MaxYearsPerWeek = 5
+	// MaxYearsPerMonth максимальный период запроса месячных свечей в годах
+	MaxYearsPerMonth = 10
 	// Interval1Min интервал 1 минута
 	Interval1Min = 1
 	// Interval2Min интервал 2 минуты
@@ -117,7 +169,60 @@ const (
 	CandleIntervalTextMonth = "1month"
 
 	// Shares обозначает тип инструмента «акции»
-	Shares = "share"
+	//
+	// Deprecated: используйте InstrumentTypeShare
+	Shares = string(InstrumentTypeShare)
+
+	// InstrumentStatusBase загружать только инструменты, доступные для торговли через API
+	InstrumentStatusBase = "base"
+	// InstrumentStatusAll загружать весь список инструментов (включая недоступные)
+	InstrumentStatusAll = "all"
+
+	// BeforeListingClamp начинать загрузку с первой свечи инструмента (по умолчанию)
+	BeforeListingClamp = "clamp"
+	// BeforeListingSkip пропускать инструмент, если start_date раньше первой свечи
+	BeforeListingSkip = "skip"
+	// BeforeListingError завершать загрузку инструмента с ошибкой, если start_date раньше первой свечи
+	BeforeListingError = "error"
+
+	// DecimalSeparatorDot десятичный разделитель в CSV архивов по умолчанию
+	DecimalSeparatorDot = "."
+	// DecimalSeparatorComma десятичный разделитель в CSV с европейским форматом чисел
+	DecimalSeparatorComma = ","
+
+	// CandleSourceAPI свеча загружена через API (source_file пуст)
+	CandleSourceAPI = "api"
+	// CandleSourceArchive свеча загружена из годового архива (source_file заполнен)
+	CandleSourceArchive = "archive"
+
+	// LoadingOrderOldest история загружается от start_date к текущему времени
+	LoadingOrderOldest = "oldest"
+	// LoadingOrderNewest сначала последние свечи, затем история от новых к старым до start_date
+	LoadingOrderNewest = "newest"
+
+	// SessionsAll сохраняются свечи всех сессий, включая утренние и вечерние торги
+	SessionsAll = "all"
+	// SessionsRegular сохраняются только внутридневные свечи основной сессии
+	SessionsRegular = "regular"
+
+	// IntervalCheckOff шаг сохраняемых свечей не проверяется
+	IntervalCheckOff = "off"
+	// IntervalCheckWarn несоответствие шага свечей интервалу - предупреждение, свечи сохраняются
+	IntervalCheckWarn = "warn"
+	// IntervalCheckStrict несоответствие шага свечей интервалу - пачка не сохраняется
+	IntervalCheckStrict = "strict"
+
+	// ArchiveCleanupAlways удалять скачанные архивы после обработки
+	ArchiveCleanupAlways = "always"
+	// ArchiveCleanupOnSuccess удалять архивы после успешной обработки, при ошибке оставлять для отладки
+	ArchiveCleanupOnSuccess = "on-success"
+	// ArchiveCleanupNever не удалять скачанные архивы
+	ArchiveCleanupNever = "never"
+
+	// ConfigStdin путь конфигурации для чтения YAML из stdin (--conf -)
+	ConfigStdin = "-"
+	// MaxConfigSize максимальный размер конфигурации из stdin или по URL, байт
+	MaxConfigSize = 1 << 20
 
 	// MinCSVFields минимально число полей в CSV-строке
 	MinCSVFields = 7
@@ -127,4 +232,6 @@ const (
 	MaxNanoDigits = 9
 	// DefaultDirPerm права доступа создаваемых директорий
 	DefaultDirPerm = 0750
+	// BytesInMB количество байт в мегабайте
+	BytesInMB = 1024 * 1024
 )
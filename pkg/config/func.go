@@ -9,31 +9,598 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
 )
 
 // GetIntervalLimit получает лимит для конкретного интервала
 func (c *Config) GetIntervalLimit(interval string) int {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+
 	if limit, exists := c.Loading.Limits[interval]; exists {
 		return limit
 	}
-	// Значение по умолчанию
+	// Значение по умолчанию - максимум API для интервала
+	if limitCap, exists := IntervalLimitCaps[interval]; exists {
+		return limitCap
+	}
 	return MinutesInDay
 }
 
-// GetStartDate получает дату начала загрузки данных
+// HasIntervalLimit проверяет, что лимит для интервала задан в loading.limits
+func (c *Config) HasIntervalLimit(interval string) bool {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+
+	_, exists := c.Loading.Limits[interval]
+	return exists
+}
+
+// Validate проверяет конфигурацию: ключи loading.limits должны быть известными интервалами
+// (опечатка в ключе иначе молча заменяется лимитом по умолчанию)
+func (c *Config) Validate() error {
+	var unknown []string
+	for key := range c.Loading.Limits {
+		if _, exists := IntervalLimitCaps[key]; !exists {
+			unknown = append(unknown, fmt.Sprintf("%q", key))
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+
+	known := make([]string, 0, len(IntervalLimitCaps))
+	for key := range IntervalLimitCaps {
+		known = append(known, key)
+	}
+	sort.Strings(known)
+
+	return fmt.Errorf("loading.limits: неизвестный интервал %s (допустимо: %s)",
+		strings.Join(unknown, ", "), strings.Join(known, ", "))
+}
+
+// ValidateLimits проверяет loading.limits и возвращает предупреждения:
+// лимиты, превышающие максимум API (такие лимиты будут уменьшены), и некорректные лимиты.
+// Неизвестные ключи - ошибка Validate
+func (c *Config) ValidateLimits() []string {
+	keys := make([]string, 0, len(c.Loading.Limits))
+	for key := range c.Loading.Limits {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var warnings []string
+	for _, key := range keys {
+		limit := c.Loading.Limits[key]
+		limitCap, exists := IntervalLimitCaps[key]
+		switch {
+		case !exists:
+			continue
+		case limit > limitCap:
+			warnings = append(warnings, fmt.Sprintf("loading.limits: лимит %d для %q превышает максимум API %d, будет использовано %d", limit, key, limitCap, limitCap))
+		case limit <= 0:
+			warnings = append(warnings, fmt.Sprintf("loading.limits: лимит %d для %q некорректен, будет использовано %d", limit, key, limitCap))
+		}
+	}
+	return warnings
+}
+
+// GetStartDate получает дату начала загрузки данных (полночь UTC)
 func (c *Config) GetStartDate() time.Time {
 	if c.Loading.StartDate == "" {
 		// По умолчанию 5 лет назад
-		return time.Now().AddDate(-5, 0, 0)
+		return Now().UTC().AddDate(-DefaultYearsBack, 0, 0)
 	}
 
 	// Парсим дату из конфигурации
-	startDate, err := time.Parse("2006-01-02", c.Loading.StartDate)
+	startDate, err := ParseDate(c.Loading.StartDate)
 	if err != nil {
 		// В случае ошибки парсинга возвращаем 5 лет назад
-		return time.Now().AddDate(-5, 0, 0)
+		return Now().UTC().AddDate(-DefaultYearsBack, 0, 0)
 	}
 
 	return startDate
 }
+
+// ParseDate разбирает дату в формате YYYY-MM-DD как полночь UTC независимо от часового пояса системы
+func ParseDate(value string) (time.Time, error) {
+	date, err := time.ParseInLocation("2006-01-02", value, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверный формат даты %q (ожидается YYYY-MM-DD): %w", value, err)
+	}
+	return date, nil
+}
+
+// ParseFlexibleTime разбирает момент времени: дату YYYY-MM-DD (полночь UTC), RFC 3339, now, today (полночь UTC)
+// или смещение назад от now: -30m (минуты), -12h, -7d, -2w, -1mo, -1y
+func ParseFlexibleTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed.UTC(), nil
+	}
+	if date, err := ParseDate(value); err == nil {
+		return date, nil
+	}
+
+	utc := now.UTC()
+	value = strings.ToLower(value)
+	switch value {
+	case "now":
+		return utc, nil
+	case "today":
+		return time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+
+	invalid := fmt.Errorf("некорректный момент времени %q (ожидается YYYY-MM-DD, now, today или смещение -7d, -12h, -1mo)", value)
+	offset, ok := strings.CutPrefix(value, "-")
+	if !ok {
+		return time.Time{}, invalid
+	}
+	unit := strings.TrimLeft(offset, "0123456789")
+	count, err := strconv.Atoi(strings.TrimSuffix(offset, unit))
+	if err != nil || count <= 0 {
+		return time.Time{}, invalid
+	}
+
+	switch unit {
+	case "m":
+		return utc.Add(-time.Duration(count) * time.Minute), nil
+	case "h":
+		return utc.Add(-time.Duration(count) * time.Hour), nil
+	case "d":
+		return utc.AddDate(0, 0, -count), nil
+	case "w":
+		return utc.AddDate(0, 0, -count*DaysInWeek), nil
+	case "mo":
+		return utc.AddDate(0, -count, 0), nil
+	case "y":
+		return utc.AddDate(-count, 0, 0), nil
+	default:
+		return time.Time{}, invalid
+	}
+}
+
+// IsFutureDate проверяет, что дата позже текущего момента (с учётом --as-of); сравнение выполняется в UTC
+func IsFutureDate(date time.Time) bool {
+	return date.UTC().After(Now().UTC())
+}
+
+// GetInstrumentStatus возвращает статус инструментов для запроса списка в API (loading.instrument_status),
+// по умолчанию только доступные для торговли инструменты
+func (c *Config) GetInstrumentStatus() (pb.InstrumentStatus, error) {
+	switch strings.ToLower(strings.TrimSpace(c.Loading.InstrumentStatus)) {
+	case "", InstrumentStatusBase:
+		return pb.InstrumentStatus_INSTRUMENT_STATUS_BASE, nil
+	case InstrumentStatusAll:
+		return pb.InstrumentStatus_INSTRUMENT_STATUS_ALL, nil
+	default:
+		return pb.InstrumentStatus_INSTRUMENT_STATUS_UNSPECIFIED,
+			fmt.Errorf("неизвестное значение instrument_status: %q (допустимо: base, all)", c.Loading.InstrumentStatus)
+	}
+}
+
+// GetArchiveMaxSize возвращает максимальный размер архива в байтах (0 - без ограничения)
+func (c *Config) GetArchiveMaxSize() int64 {
+	if c.Archive.MaxSizeMB <= 0 {
+		return 0
+	}
+	return c.Archive.MaxSizeMB * BytesInMB
+}
+
+// GetWriteBufferFlushInterval возвращает интервал сброса буфера отложенной записи (0 - только по размеру)
+func (c *Config) GetWriteBufferFlushInterval() (time.Duration, error) {
+	interval, err := parseOptionalDuration(c.Loading.WriteBuffer.FlushInterval)
+	if err != nil {
+		return 0, fmt.Errorf("write_buffer.flush_interval: %w", err)
+	}
+	return interval, nil
+}
+
+// GetMinRunInterval возвращает минимальный интервал между запусками загрузчика (0 - без ограничения)
+func (c *Config) GetMinRunInterval() (time.Duration, error) {
+	interval, err := parseOptionalDuration(c.Loading.MinRunInterval)
+	if err != nil {
+		return 0, fmt.Errorf("min_run_interval: %w", err)
+	}
+	return interval, nil
+}
+
+// GetMaxRunDuration возвращает максимальную длительность загрузки (0 - без ограничения)
+func (c *Config) GetMaxRunDuration() (time.Duration, error) {
+	duration, err := parseOptionalDuration(c.Loading.MaxRunDuration)
+	if err != nil {
+		return 0, fmt.Errorf("max_run_duration: %w", err)
+	}
+	return duration, nil
+}
+
+// GetSyncLockWait возвращает время ожидания блокировки синхронизации инструментов (0 - не ждать)
+func (c *Config) GetSyncLockWait() (time.Duration, error) {
+	wait, err := parseOptionalDuration(c.Loading.SyncLock.Wait)
+	if err != nil {
+		return 0, fmt.Errorf("sync_lock.wait: %w", err)
+	}
+	return wait, nil
+}
+
+// GetTokens возвращает токены API: token и tokens без пустых значений и повторов
+func (c *Config) GetTokens() []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, token := range append([]string{c.Tinvest.Token}, c.Tinvest.Tokens...) {
+		token = strings.TrimSpace(token)
+		if token == "" || seen[token] {
+			continue
+		}
+		seen[token] = true
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// IsAlwaysRefresh проверяет, что инструмент (по FIGI или тикеру) обновляется каждый запуск
+func (c *Config) IsAlwaysRefresh(figi, ticker string) bool {
+	for _, item := range c.Loading.AlwaysRefresh {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.EqualFold(item, figi) || strings.EqualFold(item, ticker) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDecimalSeparator возвращает десятичный разделитель чисел в CSV архивов (по умолчанию ".")
+func (c *Config) GetDecimalSeparator() (string, error) {
+	switch value := strings.TrimSpace(c.Archive.DecimalSeparator); value {
+	case "":
+		return DecimalSeparatorDot, nil
+	case DecimalSeparatorDot, DecimalSeparatorComma:
+		return value, nil
+	default:
+		return "", fmt.Errorf("неизвестное значение decimal_separator: %q (допустимо: \".\", \",\")", c.Archive.DecimalSeparator)
+	}
+}
+
+// GetProxyURL возвращает прокси для запросов к API из tinvest.proxy (nil - не задан)
+func (c *Config) GetProxyURL() (*url.URL, error) {
+	value := strings.TrimSpace(c.Tinvest.Proxy)
+	if value == "" {
+		return nil, nil
+	}
+	proxyURL, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес прокси: %w", err)
+	}
+	if (proxyURL.Scheme != "http" && proxyURL.Scheme != "https") || proxyURL.Host == "" {
+		return nil, fmt.Errorf("некорректный адрес прокси %q: ожидается http://host:port или https://host:port", proxyURL.Redacted())
+	}
+	return proxyURL, nil
+}
+
+// GetArchiveCleanup возвращает политику удаления скачанных архивов (archive.cleanup).
+// По умолчанию архивы в системной временной директории удаляются всегда, в archive.temp_dir - никогда
+func (c *Config) GetArchiveCleanup() (string, error) {
+	switch value := strings.TrimSpace(c.Archive.Cleanup); value {
+	case "":
+		if c.Archive.TempDir != "" {
+			return ArchiveCleanupNever, nil
+		}
+		return ArchiveCleanupAlways, nil
+	case ArchiveCleanupAlways, ArchiveCleanupOnSuccess, ArchiveCleanupNever:
+		return value, nil
+	default:
+		return "", fmt.Errorf("неизвестное значение cleanup: %q (допустимо: always, on-success, never)", c.Archive.Cleanup)
+	}
+}
+
+// GetIntervalCheck возвращает режим проверки шага свечей при сохранении (storage.interval_check), по умолчанию off
+func (c *Config) GetIntervalCheck() (string, error) {
+	switch value := strings.ToLower(strings.TrimSpace(c.Storage.IntervalCheck)); value {
+	case "":
+		return IntervalCheckOff, nil
+	case IntervalCheckOff, IntervalCheckWarn, IntervalCheckStrict:
+		return value, nil
+	default:
+		return "", fmt.Errorf("неизвестное значение interval_check: %q (допустимо: off, warn, strict)", c.Storage.IntervalCheck)
+	}
+}
+
+// GetMinVolume возвращает минимальный объём сохраняемой свечи по типу интервала (loading.min_volume).
+// Интервалы без порога (и с порогом 0) сохраняются полностью
+func (c *Config) GetMinVolume() (map[string]int64, error) {
+	policy := make(map[string]int64, len(c.Loading.MinVolume))
+	for intervalText, minVolume := range c.Loading.MinVolume {
+		intervalType, err := ParseInterval(intervalText)
+		if err != nil {
+			return nil, fmt.Errorf("loading.min_volume: %w", err)
+		}
+		if minVolume < 0 {
+			return nil, fmt.Errorf("loading.min_volume: отрицательный объём для %s: %d", intervalText, minVolume)
+		}
+		if minVolume > 0 {
+			policy[intervalType] = minVolume
+		}
+	}
+	return policy, nil
+}
+
+// GetSourcePreference возвращает порядок предпочтения источников свечей при чтении (storage.source_preference).
+// Источники, не указанные в списке, идут после указанных в порядке по умолчанию: api, archive
+func (c *Config) GetSourcePreference() ([]string, error) {
+	order := make([]string, 0, 2)
+	seen := make(map[string]bool)
+	for _, value := range c.Storage.SourcePreference {
+		source := strings.ToLower(strings.TrimSpace(value))
+		switch source {
+		case CandleSourceAPI, CandleSourceArchive:
+		default:
+			return nil, fmt.Errorf("неизвестный источник свечей: %q (допустимо: api, archive)", value)
+		}
+		if seen[source] {
+			continue
+		}
+		seen[source] = true
+		order = append(order, source)
+	}
+	for _, source := range []string{CandleSourceAPI, CandleSourceArchive} {
+		if !seen[source] {
+			order = append(order, source)
+		}
+	}
+	return order, nil
+}
+
+// GetRetention возвращает сроки хранения свечей по типу интервала (storage.retention).
+// Срок 0 - хранить всегда: интервал в результат не попадает
+func (c *Config) GetRetention() (map[string]time.Duration, error) {
+	policy := make(map[string]time.Duration, len(c.Storage.Retention))
+	for intervalText, value := range c.Storage.Retention {
+		intervalType, err := ParseInterval(intervalText)
+		if err != nil {
+			return nil, fmt.Errorf("storage.retention: %w", err)
+		}
+		retention, err := parseRetention(value)
+		if err != nil {
+			return nil, fmt.Errorf("storage.retention.%s: %w", intervalText, err)
+		}
+		if retention == 0 {
+			continue
+		}
+		policy[intervalType] = retention
+	}
+	return policy, nil
+}
+
+// parseRetention парсит срок хранения: дни ("90d") или Go duration ("720h"), "0" - хранить всегда
+func parseRetention(value string) (time.Duration, error) {
+	if strings.TrimSpace(value) == "0" {
+		return 0, nil
+	}
+	retention, err := ParseDays(value)
+	if err != nil {
+		return 0, fmt.Errorf("некорректный срок хранения: %w", err)
+	}
+	if retention < 0 {
+		return 0, fmt.Errorf("срок хранения не может быть отрицательным: %q", value)
+	}
+	return retention, nil
+}
+
+// parseOptionalDuration разбирает необязательную длительность в формате Go duration ("90s", "30m", "2h"):
+// пустая строка - 0, некорректная или отрицательная - ошибка
+func parseOptionalDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("некорректная длительность %q: %w", value, err)
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("длительность не может быть отрицательной: %q", value)
+	}
+	return duration, nil
+}
+
+// ParseDays разбирает длительность в днях ("3d") или в формате Go duration ("36h"), отрицательная - ошибка
+func ParseDays(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+
+	var duration time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		count, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("некорректная длительность %q", value)
+		}
+		duration = time.Duration(count) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("некорректная длительность %q: %w", value, err)
+		}
+		duration = parsed
+	}
+
+	if duration < 0 {
+		return 0, fmt.Errorf("длительность не может быть отрицательной: %q", value)
+	}
+	return duration, nil
+}
+
+// GetBeforeListing возвращает поведение при start_date раньше первой свечи инструмента (по умолчанию clamp)
+func (c *Config) GetBeforeListing() (string, error) {
+	switch value := strings.ToLower(strings.TrimSpace(c.Loading.BeforeListing)); value {
+	case "":
+		return BeforeListingClamp, nil
+	case BeforeListingClamp, BeforeListingSkip, BeforeListingError:
+		return value, nil
+	default:
+		return "", fmt.Errorf("неизвестное значение before_listing: %q (допустимо: clamp, skip, error)", c.Loading.BeforeListing)
+	}
+}
+
+// GetLoadingOrder возвращает порядок загрузки истории (loading.order), по умолчанию oldest
+func (c *Config) GetLoadingOrder() (string, error) {
+	switch value := strings.ToLower(strings.TrimSpace(c.Loading.Order)); value {
+	case "":
+		return LoadingOrderOldest, nil
+	case LoadingOrderOldest, LoadingOrderNewest:
+		return value, nil
+	default:
+		return "", fmt.Errorf("неизвестное значение order: %q (допустимо: oldest, newest)", c.Loading.Order)
+	}
+}
+
+// GetSessions возвращает, свечи каких сессий сохранять (loading.sessions), по умолчанию all
+func (c *Config) GetSessions() (string, error) {
+	switch value := strings.ToLower(strings.TrimSpace(c.Loading.Sessions)); value {
+	case "":
+		return SessionsAll, nil
+	case SessionsAll, SessionsRegular:
+		return value, nil
+	default:
+		return "", fmt.Errorf("неизвестное значение sessions: %q (допустимо: all, regular)", c.Loading.Sessions)
+	}
+}
+
+// GetInstrumentWorkers возвращает, сколько типов инструментов синхронизировать параллельно
+func (c *Config) GetInstrumentWorkers() int {
+	if c.Loading.InstrumentWorkers <= 0 {
+		return DefaultInstrumentWorkers
+	}
+	return c.Loading.InstrumentWorkers
+}
+
+// GetInstrumentBatchSize возвращает, сколько инструментов сохранять одним запросом (не больше MaxInstrumentBatchSize)
+func (c *Config) GetInstrumentBatchSize() int {
+	if c.Loading.InstrumentBatchSize <= 0 {
+		return DefaultInstrumentBatchSize
+	}
+	return min(c.Loading.InstrumentBatchSize, MaxInstrumentBatchSize)
+}
+
+// GetIntervalWorkers возвращает, сколько интервалов одного инструмента загружать параллельно
+func (c *Config) GetIntervalWorkers() int {
+	if c.Loading.IntervalWorkers <= 0 {
+		return DefaultIntervalWorkers
+	}
+	return c.Loading.IntervalWorkers
+}
+
+// GetOutagePause возвращает паузу при недоступности API
+func (c *Config) GetOutagePause() time.Duration {
+	if c.Loading.Outage.Pause == "" {
+		return DefaultOutagePause
+	}
+	pause, err := time.ParseDuration(c.Loading.Outage.Pause)
+	if err != nil || pause <= 0 {
+		return DefaultOutagePause
+	}
+	return pause
+}
+
+// GetOutageMaxPauses возвращает, сколько пауз подряд ждать восстановления API до завершения запуска
+func (c *Config) GetOutageMaxPauses() int {
+	if c.Loading.Outage.MaxPauses <= 0 {
+		return DefaultOutageMaxPauses
+	}
+	return c.Loading.Outage.MaxPauses
+}
+
+// GetSkipEmptyRecheck возвращает, как часто повторно запрашивать инструмент без свечей (loading.skip_empty.recheck):
+// дни ("7d") или Go duration, по умолчанию 7 дней
+func (c *Config) GetSkipEmptyRecheck() time.Duration {
+	if c.Loading.SkipEmpty.Recheck == "" {
+		return DefaultSkipEmptyRecheck
+	}
+	recheck, err := ParseDays(c.Loading.SkipEmpty.Recheck)
+	if err != nil || recheck <= 0 {
+		return DefaultSkipEmptyRecheck
+	}
+	return recheck
+}
+
+// GetSinkTopic возвращает топик для публикации сохранённых свечей
+func (c *Config) GetSinkTopic() string {
+	if c.Sink.Topic == "" {
+		return DefaultSinkTopic
+	}
+	return c.Sink.Topic
+}
+
+// GetConnectTimeout возвращает время ожидания доступности БД и API при запуске (0 - без повторов)
+func (c *Config) GetConnectTimeout() (time.Duration, error) {
+	timeout, err := parseOptionalDuration(c.Startup.ConnectTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("connect_timeout: %w", err)
+	}
+	return timeout, nil
+}
+
+// GetPriceScaleThreshold возвращает долю свечей с ценой, не кратной шагу цены, для отметки инструмента
+func (c *Config) GetPriceScaleThreshold() float64 {
+	if c.Loading.Verify.PriceScaleThreshold <= 0 || c.Loading.Verify.PriceScaleThreshold > 1 {
+		return DefaultPriceScaleThreshold
+	}
+	return c.Loading.Verify.PriceScaleThreshold
+}
+
+// GetCoverageMinRatio возвращает долю ожидаемых свечей, с которой инструмент считается загруженным
+func (c *Config) GetCoverageMinRatio() float64 {
+	if c.Loading.Coverage.MinRatio <= 0 || c.Loading.Coverage.MinRatio > 1 {
+		return DefaultCoverageMinRatio
+	}
+	return c.Loading.Coverage.MinRatio
+}
+
+// GetVerifyTolerance возвращает допустимое относительное отклонение количества свечей от ожидаемого
+func (c *Config) GetVerifyTolerance() float64 {
+	if c.Loading.Verify.Tolerance <= 0 {
+		return DefaultVerifyTolerance
+	}
+	return c.Loading.Verify.Tolerance
+}
+
+// GetSchema возвращает схему БД для таблиц загрузчика
+func (d *DatabaseConfig) GetSchema() string {
+	if d.Schema == "" {
+		return DefaultSchema
+	}
+	return d.Schema
+}
+
+// GetClampOutlierRatio возвращает допустимое отклонение high/low от open/close для clamp_outliers
+func (c *Config) GetClampOutlierRatio() float64 {
+	if c.Loading.ClampOutlierRatio <= 0 {
+		return DefaultClampOutlierRatio
+	}
+	return c.Loading.ClampOutlierRatio
+}
+
+// GetProgressEveryChunks возвращает, через сколько чанков логировать прогресс загрузки
+func (c *Config) GetProgressEveryChunks() int {
+	if c.Loading.ProgressEvery <= 0 {
+		return DefaultProgressEveryChunks
+	}
+	return c.Loading.ProgressEvery
+}
+
+// GetPartitionsAhead возвращает, на сколько месяцев вперёд создавать партиции свечей
+func (d *DatabaseConfig) GetPartitionsAhead() int {
+	if d.PartitionsAhead <= 0 {
+		return DefaultPartitionsAhead
+	}
+	return d.PartitionsAhead
+}
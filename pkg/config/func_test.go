@@ -0,0 +1,165 @@
+// Package config содержит общие функции и константы для загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package config
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+)
+
+// setLocal подменяет часовой пояс системы на время теста
+func setLocal(t *testing.T, loc *time.Location) {
+	t.Helper()
+	prev := time.Local
+	time.Local = loc
+	t.Cleanup(func() {
+		time.Local = prev
+	})
+}
+
+// pinNow фиксирует текущий момент (--as-of) на время теста
+func pinNow(t *testing.T, now time.Time) {
+	t.Helper()
+	prev, _ := AsOf()
+	SetAsOf(now)
+	t.Cleanup(func() {
+		SetAsOf(prev)
+	})
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		local   *time.Location
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "utc system", local: time.UTC, value: "2024-06-01", want: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "east of utc", local: time.FixedZone("MSK", 3*60*60), value: "2024-06-01", want: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "west of utc", local: time.FixedZone("EST", -5*60*60), value: "2024-06-01", want: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "far east", local: time.FixedZone("LINT", 14*60*60), value: "2024-12-31", want: time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC)},
+		{name: "leap day", local: time.FixedZone("MSK", 3*60*60), value: "2024-02-29", want: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "not a leap year", local: time.UTC, value: "2023-02-29", wantErr: true},
+		{name: "time included", local: time.UTC, value: "2024-06-01T00:00:00Z", wantErr: true},
+		{name: "day first", local: time.UTC, value: "01.06.2024", wantErr: true},
+		{name: "empty", local: time.UTC, value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setLocal(t, tt.local)
+			got, err := ParseDate(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseDate(%q) = %v, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDate(%q) unexpected error: %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("ParseDate(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsFutureDate(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	msk := time.FixedZone("MSK", 3*60*60)
+	est := time.FixedZone("EST", -5*60*60)
+
+	tests := []struct {
+		name  string
+		local *time.Location
+		date  time.Time
+		want  bool
+	}{
+		{name: "past utc", local: time.UTC, date: now.Add(-time.Second), want: false},
+		{name: "now is not future", local: time.UTC, date: now, want: false},
+		{name: "future utc", local: time.UTC, date: now.Add(time.Second), want: true},
+		{name: "local date looks later but is past", local: msk, date: time.Date(2024, time.June, 1, 2, 0, 0, 0, msk), want: false},
+		{name: "local date looks earlier but is future", local: est, date: time.Date(2024, time.May, 31, 22, 0, 0, 0, est), want: true},
+		{name: "parsed today", local: msk, date: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), want: false},
+		{name: "parsed tomorrow", local: est, date: time.Date(2024, time.June, 2, 0, 0, 0, 0, time.UTC), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setLocal(t, tt.local)
+			pinNow(t, now)
+			if got := IsFutureDate(tt.date); got != tt.want {
+				t.Errorf("IsFutureDate(%v) = %v, want %v", tt.date, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMaxRunDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "90m", want: 90 * time.Minute},
+		{value: " 2h ", want: 2 * time.Hour},
+		{value: "0s", want: 0},
+		{value: "2 hours", wantErr: true},
+		{value: "90", wantErr: true},
+		{value: "-1h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Loading.MaxRunDuration = tt.value
+			got, err := cfg.GetMaxRunDuration()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetMaxRunDuration(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetMaxRunDuration(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetInstrumentStatus(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    pb.InstrumentStatus
+		wantErr bool
+	}{
+		{value: "", want: pb.InstrumentStatus_INSTRUMENT_STATUS_BASE},
+		{value: "base", want: pb.InstrumentStatus_INSTRUMENT_STATUS_BASE},
+		{value: "all", want: pb.InstrumentStatus_INSTRUMENT_STATUS_ALL},
+		{value: " ALL ", want: pb.InstrumentStatus_INSTRUMENT_STATUS_ALL},
+		{value: "al", wantErr: true},
+		{value: "unspecified", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Loading.InstrumentStatus = tt.value
+			got, err := cfg.GetInstrumentStatus()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetInstrumentStatus(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("GetInstrumentStatus(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
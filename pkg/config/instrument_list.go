@@ -0,0 +1,92 @@
+// Package config содержит общие функции и константы для загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// InstrumentFilter политика отбора инструментов для загрузки по спискам FIGI и тикеров:
+// allowlist оставляет только перечисленные инструменты, denylist исключает перечисленные
+type InstrumentFilter struct {
+	allow map[string]bool // nil - без ограничения
+	deny  map[string]bool
+}
+
+// GetInstrumentFilter читает файлы loading.allowlist_file и loading.denylist_file.
+// Файлы читаются при каждом вызове, поэтому изменения применяются при следующем отборе инструментов
+// без перезапуска; без файлов возвращается nil (отбор не ограничен)
+func (c *Config) GetInstrumentFilter() (*InstrumentFilter, error) {
+	reloadMu.RLock()
+	allowPath, denyPath := c.Loading.AllowlistFile, c.Loading.DenylistFile
+	reloadMu.RUnlock()
+
+	if allowPath == "" && denyPath == "" {
+		return nil, nil
+	}
+
+	filter := &InstrumentFilter{}
+	if allowPath != "" {
+		allow, err := readInstrumentList(allowPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading.allowlist_file: %w", err)
+		}
+		filter.allow = allow
+	}
+	if denyPath != "" {
+		deny, err := readInstrumentList(denyPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading.denylist_file: %w", err)
+		}
+		filter.deny = deny
+	}
+	return filter, nil
+}
+
+// Allows проверяет, что инструмент (по FIGI или тикеру) проходит allowlist и не входит в denylist
+func (f *InstrumentFilter) Allows(figi, ticker string) bool {
+	if f == nil {
+		return true
+	}
+	figi, ticker = strings.ToUpper(figi), strings.ToUpper(ticker)
+	if f.deny[figi] || f.deny[ticker] {
+		return false
+	}
+	if f.allow != nil {
+		return f.allow[figi] || f.allow[ticker]
+	}
+	return true
+}
+
+// readInstrumentList читает список FIGI или тикеров: по одному в строке, после # - комментарий
+func readInstrumentList(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть список инструментов: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	items := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if item := strings.TrimSpace(line); item != "" {
+			items[strings.ToUpper(item)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения списка инструментов %q: %w", path, err)
+	}
+	return items, nil
+}
@@ -0,0 +1,53 @@
+// Package config содержит общие функции и константы для загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InstrumentType тип инструмента (значение instruments.instrument_type)
+type InstrumentType string
+
+// Типы инструментов в каноническом виде (нижний регистр, единственное число, как в API)
+const (
+	InstrumentTypeShare    InstrumentType = "share"
+	InstrumentTypeBond     InstrumentType = "bond"
+	InstrumentTypeEtf      InstrumentType = "etf"
+	InstrumentTypeCurrency InstrumentType = "currency"
+	InstrumentTypeFuture   InstrumentType = "future"
+)
+
+// instrumentTypeAliases допустимые написания типов инструментов (в нижнем регистре)
+var instrumentTypeAliases = map[string]InstrumentType{
+	"share":      InstrumentTypeShare,
+	"shares":     InstrumentTypeShare,
+	"bond":       InstrumentTypeBond,
+	"bonds":      InstrumentTypeBond,
+	"etf":        InstrumentTypeEtf,
+	"etfs":       InstrumentTypeEtf,
+	"currency":   InstrumentTypeCurrency,
+	"currencies": InstrumentTypeCurrency,
+	"future":     InstrumentTypeFuture,
+	"futures":    InstrumentTypeFuture,
+}
+
+// ParseInstrumentType приводит тип инструмента к каноническому виду: "Share", "SHARE", "shares" -> share
+func ParseInstrumentType(value string) (InstrumentType, error) {
+	if instrumentType, ok := instrumentTypeAliases[strings.ToLower(strings.TrimSpace(value))]; ok {
+		return instrumentType, nil
+	}
+	return "", fmt.Errorf("неизвестный тип инструмента: %q (допустимо: share, bond, etf, currency, future)", value)
+}
+
+// String возвращает тип инструмента строкой
+func (t InstrumentType) String() string {
+	return string(t)
+}
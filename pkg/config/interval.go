@@ -10,12 +10,15 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
 )
 
 // ParseInterval 1min->CANDLE_INTERVAL_1_MIN
+// Принимает также имя интервала из API (CANDLE_INTERVAL_15_MIN, без учёта регистра) -
+// обратное к GetCandleIntervalString
 func ParseInterval(intervalStr string) (string, error) {
 	// Маппинг интервалов
 	intervalMap := map[string]string{
@@ -38,6 +41,11 @@ func ParseInterval(intervalStr string) (string, error) {
 		return intervalType, nil
 	}
 
+	// Имя интервала как в SDK (скопированное из ответа API)
+	if intervalType := strings.ToUpper(intervalStr); Interval2text(intervalType) != "" {
+		return intervalType, nil
+	}
+
 	return "", fmt.Errorf("неподдерживаемый интервал: %s", intervalStr)
 }
 
@@ -64,87 +72,128 @@ func Interval2text(interval string) string {
 	return text
 }
 
-// GetCandleInterval конвертирует строковый интервал в protobuf тип
-func GetCandleInterval(intervalType string) pb.CandleInterval {
+// GetCandleIntervalChecked конвертирует строковый интервал в protobuf тип,
+// для неизвестного или пустого интервала возвращает ошибку
+func GetCandleIntervalChecked(intervalType string) (pb.CandleInterval, error) {
 	switch intervalType {
 	case CandleInterval1Min:
-		return pb.CandleInterval_CANDLE_INTERVAL_1_MIN
+		return pb.CandleInterval_CANDLE_INTERVAL_1_MIN, nil
 	case CandleInterval2Min:
-		return pb.CandleInterval_CANDLE_INTERVAL_2_MIN
+		return pb.CandleInterval_CANDLE_INTERVAL_2_MIN, nil
 	case CandleInterval3Min:
-		return pb.CandleInterval_CANDLE_INTERVAL_3_MIN
+		return pb.CandleInterval_CANDLE_INTERVAL_3_MIN, nil
 	case CandleInterval5Min:
-		return pb.CandleInterval_CANDLE_INTERVAL_5_MIN
+		return pb.CandleInterval_CANDLE_INTERVAL_5_MIN, nil
 	case CandleInterval10Min:
-		return pb.CandleInterval_CANDLE_INTERVAL_10_MIN
+		return pb.CandleInterval_CANDLE_INTERVAL_10_MIN, nil
 	case CandleInterval15Min:
-		return pb.CandleInterval_CANDLE_INTERVAL_15_MIN
+		return pb.CandleInterval_CANDLE_INTERVAL_15_MIN, nil
 	case CandleInterval30Min:
-		return pb.CandleInterval_CANDLE_INTERVAL_30_MIN
+		return pb.CandleInterval_CANDLE_INTERVAL_30_MIN, nil
 	case CandleIntervalHour:
-		return pb.CandleInterval_CANDLE_INTERVAL_HOUR
+		return pb.CandleInterval_CANDLE_INTERVAL_HOUR, nil
 	case CandleInterval2Hour:
-		return pb.CandleInterval_CANDLE_INTERVAL_2_HOUR
+		return pb.CandleInterval_CANDLE_INTERVAL_2_HOUR, nil
 	case CandleInterval4Hour:
-		return pb.CandleInterval_CANDLE_INTERVAL_4_HOUR
+		return pb.CandleInterval_CANDLE_INTERVAL_4_HOUR, nil
 	case CandleIntervalDay:
-		return pb.CandleInterval_CANDLE_INTERVAL_DAY
+		return pb.CandleInterval_CANDLE_INTERVAL_DAY, nil
 	case CandleIntervalWeek:
-		return pb.CandleInterval_CANDLE_INTERVAL_WEEK
+		return pb.CandleInterval_CANDLE_INTERVAL_WEEK, nil
 	case CandleIntervalMonth:
-		return pb.CandleInterval_CANDLE_INTERVAL_MONTH
-	case "":
-		return pb.CandleInterval_CANDLE_INTERVAL_1_MIN
+		return pb.CandleInterval_CANDLE_INTERVAL_MONTH, nil
 	default:
+		return pb.CandleInterval_CANDLE_INTERVAL_UNSPECIFIED, fmt.Errorf("неизвестный интервал свечей: %q", intervalType)
+	}
+}
+
+// GetCandleInterval конвертирует строковый интервал в protobuf тип
+// неизвестный или пустой интервал молча заменяется на 1 минуту
+//
+// Deprecated: скрывает ошибки в интервале, используйте GetCandleIntervalChecked
+func GetCandleInterval(intervalType string) pb.CandleInterval {
+	interval, err := GetCandleIntervalChecked(intervalType)
+	if err != nil {
 		return pb.CandleInterval_CANDLE_INTERVAL_1_MIN
 	}
+	return interval
 }
 
-// GetCandleIntervalString конвертирует protobuf тип в строковый интервал
+// GetCandleIntervalStringChecked конвертирует protobuf тип в строковый интервал,
+// для неизвестного интервала возвращает ошибку
 //
 //nolint:exhaustive
-func GetCandleIntervalString(interval pb.CandleInterval) string {
+func GetCandleIntervalStringChecked(interval pb.CandleInterval) (string, error) {
 	switch interval {
 	case pb.CandleInterval_CANDLE_INTERVAL_1_MIN:
-		return CandleInterval1Min
+		return CandleInterval1Min, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_2_MIN:
-		return CandleInterval2Min
+		return CandleInterval2Min, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_3_MIN:
-		return CandleInterval3Min
+		return CandleInterval3Min, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_5_MIN:
-		return CandleInterval5Min
+		return CandleInterval5Min, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_10_MIN:
-		return CandleInterval10Min
+		return CandleInterval10Min, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_15_MIN:
-		return CandleInterval15Min
+		return CandleInterval15Min, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_30_MIN:
-		return CandleInterval30Min
+		return CandleInterval30Min, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_HOUR:
-		return CandleIntervalHour
+		return CandleIntervalHour, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_2_HOUR:
-		return CandleInterval2Hour
+		return CandleInterval2Hour, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_4_HOUR:
-		return CandleInterval4Hour
+		return CandleInterval4Hour, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_DAY:
-		return CandleIntervalDay
+		return CandleIntervalDay, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_WEEK:
-		return CandleIntervalWeek
+		return CandleIntervalWeek, nil
 	case pb.CandleInterval_CANDLE_INTERVAL_MONTH:
-		return CandleIntervalMonth
+		return CandleIntervalMonth, nil
 	default:
+		return "", fmt.Errorf("неизвестный интервал свечей: %s", interval.String())
+	}
+}
+
+// GetCandleIntervalString конвертирует protobuf тип в строковый интервал
+// неизвестный интервал молча заменяется на 1 минуту
+//
+// Deprecated: скрывает ошибки в интервале, используйте GetCandleIntervalStringChecked
+func GetCandleIntervalString(interval pb.CandleInterval) string {
+	intervalType, err := GetCandleIntervalStringChecked(interval)
+	if err != nil {
 		return CandleInterval1Min
 	}
+	return intervalType
 }
 
-// CalculateChunkSize вычисляет размер чанка
+// IntervalLimitCaps максимальные лимиты за один запрос по ключам loading.limits
+// (в единицах GetTimeUnitAndConfigKey) согласно документации API Т-Инвестиции.
+// Запрос за больший период API обрезает без ошибки.
+var IntervalLimitCaps = map[string]int{
+	CandleIntervalText1Min:  MinutesInDay,                    // 1 день минут
+	CandleIntervalTextHour:  QuarterDays * HoursInDay,        // 3 месяца часов
+	CandleIntervalTextDay:   DaysInYear * MaxYearsPerDay,     // 6 лет дней
+	CandleIntervalTextWeek:  WeeksInYear * MaxYearsPerWeek,   // 5 лет недель
+	CandleIntervalTextMonth: MonthsInYear * MaxYearsPerMonth, // 10 лет месяцев
+}
+
+// CalculateChunkSize вычисляет размер чанка, ограничивая лимит известным максимумом API
 func CalculateChunkSize(intervalType string, apiLimit int) time.Duration {
-	return GetThreshold(intervalType) * time.Duration(apiLimit)
+	timeUnit, configKey := GetTimeUnitAndConfigKey(intervalType)
+	if limitCap, exists := IntervalLimitCaps[configKey]; exists {
+		if apiLimit <= 0 || apiLimit > limitCap {
+			apiLimit = limitCap
+		}
+	}
+	return timeUnit * time.Duration(apiLimit)
 }
 
 // ShouldUpdateData проверяет, нужно ли обновлять данные для заданного интервала
 func ShouldUpdateData(lastLoadedTime time.Time, intervalType string) bool {
 	// Определяем порог обновления в зависимости от интервала
-	return time.Since(lastLoadedTime) > GetThreshold(intervalType)
+	return Now().Sub(lastLoadedTime) > GetThreshold(intervalType)
 }
 
 // GetDateFormat определяет формат даты для логирования в зависимости от интервала
@@ -209,6 +258,41 @@ func GetTimeUnitAndConfigKey(intervalType string) (time.Duration, string) {
 	}
 }
 
+// IntervalPeriodEnd возвращает время закрытия свечи интервала, начавшейся в start
+// (дневные, недельные и месячные - по календарю)
+func IntervalPeriodEnd(intervalType string, start time.Time) time.Time {
+	switch intervalType {
+	case CandleInterval1Min:
+		return start.Add(time.Minute)
+	case CandleInterval2Min:
+		return start.Add(2 * time.Minute)
+	case CandleInterval3Min:
+		return start.Add(3 * time.Minute)
+	case CandleInterval5Min:
+		return start.Add(5 * time.Minute)
+	case CandleInterval10Min:
+		return start.Add(10 * time.Minute)
+	case CandleInterval15Min:
+		return start.Add(15 * time.Minute)
+	case CandleInterval30Min:
+		return start.Add(30 * time.Minute)
+	case CandleIntervalHour:
+		return start.Add(time.Hour)
+	case CandleInterval2Hour:
+		return start.Add(2 * time.Hour)
+	case CandleInterval4Hour:
+		return start.Add(4 * time.Hour)
+	case CandleIntervalDay:
+		return start.AddDate(0, 0, 1)
+	case CandleIntervalWeek:
+		return start.AddDate(0, 0, DaysInWeek)
+	case CandleIntervalMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.Add(DefaultUpdateThreshold)
+	}
+}
+
 // GetThreshold получает порог обновления для конкретного интервала
 func GetThreshold(intervalType string) time.Duration {
 	duration, _ := GetTimeUnitAndConfigKey(intervalType)
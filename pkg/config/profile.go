@@ -0,0 +1,116 @@
+// Package config содержит общие функции и константы для загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ProfileEnv переменная окружения с именем профиля конфигурации (если не задан --profile)
+	ProfileEnv = "MARKET_LOADER_PROFILE"
+	// DefaultProfile профиль, применяемый, если профиль не выбран
+	DefaultProfile = "default"
+	// profilesKey ключ верхнего уровня YAML с профилями
+	profilesKey = "profiles"
+)
+
+// resolveProfile возвращает имя профиля: явно заданное, из MARKET_LOADER_PROFILE или пустое (default)
+func resolveProfile(profile string) string {
+	if profile = strings.TrimSpace(profile); profile != "" {
+		return profile
+	}
+	return strings.TrimSpace(os.Getenv(ProfileEnv))
+}
+
+// applyProfile накладывает профиль из секции profiles на общие настройки документа и удаляет секцию profiles.
+// Пустое имя профиля - профиль default, если он есть. Явно выбранный, но отсутствующий профиль - ошибка.
+// Возвращает имя применённого профиля (пусто - профиль не применялся)
+func applyProfile(doc *yaml.Node, profile string) (string, error) {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		if profile != "" {
+			return "", fmt.Errorf("профиль %q не найден: в конфигурации нет секции %s", profile, profilesKey)
+		}
+		return "", nil
+	}
+
+	// Секция profiles не относится к настройкам и удаляется в любом случае
+	var profiles *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == profilesKey {
+			profiles = root.Content[i+1]
+			root.Content = append(root.Content[:i], root.Content[i+2:]...)
+			break
+		}
+	}
+
+	name := profile
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	var selected *yaml.Node
+	var available []string
+	if profiles != nil && profiles.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(profiles.Content); i += 2 {
+			available = append(available, profiles.Content[i].Value)
+			if profiles.Content[i].Value == name {
+				selected = profiles.Content[i+1]
+			}
+		}
+	}
+
+	if selected == nil {
+		if profile == "" {
+			return "", nil
+		}
+		sort.Strings(available)
+		return "", fmt.Errorf("профиль %q не найден в секции %s (доступны: %s)", profile, profilesKey, strings.Join(available, ", "))
+	}
+	if selected.Kind != yaml.MappingNode {
+		return "", fmt.Errorf("профиль %q должен быть секцией настроек", name)
+	}
+
+	mergeNodes(root, selected)
+	return name, nil
+}
+
+// mergeNodes накладывает override на base: секции объединяются рекурсивно,
+// остальные значения (скаляры, списки) заменяются целиком
+func mergeNodes(base, override *yaml.Node) {
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+
+		found := false
+		for j := 0; j+1 < len(base.Content); j += 2 {
+			if base.Content[j].Value != key.Value {
+				continue
+			}
+			found = true
+			if base.Content[j+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+				mergeNodes(base.Content[j+1], value)
+			} else {
+				base.Content[j+1] = value
+			}
+			break
+		}
+		if !found {
+			base.Content = append(base.Content, key, value)
+		}
+	}
+}
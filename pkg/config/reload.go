@@ -0,0 +1,105 @@
+// Package config содержит общие функции и константы для загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// reloadMu защищает настройки, изменяемые при перечитывании конфигурации (Reload)
+var reloadMu sync.RWMutex
+
+// GetRateLimitPause возвращает паузу между запросами к API
+func (c *Config) GetRateLimitPause() time.Duration {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+	return time.Duration(c.Loading.RateLimitPause) * time.Second
+}
+
+// GetLogLevel возвращает уровень логирования из конфигурации
+func (c *Config) GetLogLevel() string {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+	return c.Logging.Level
+}
+
+// Reload перечитывает файл конфигурации и применяет настройки, которые можно менять на лету:
+// logging.level, loading.rate_limit_pause, loading.limits, loading.allowlist_file, loading.denylist_file.
+// Новая конфигурация проверяется так же, как при запуске; при ошибке ничего не применяется.
+// Число воркеров (loading.interval_workers, loading.instrument_workers) задаётся при запуске
+// и меняется только перезапуском.
+// Возвращает применённые изменения, изменения, требующие перезапуска (они не применяются),
+// и предупреждения о лимитах
+func (c *Config) Reload(path string) (changed, ignored, warnings []string, err error) {
+	// stdin уже прочитан при запуске
+	if path == ConfigStdin {
+		return nil, nil, nil, fmt.Errorf("конфигурация из stdin не перечитывается")
+	}
+
+	fresh, err := LoadConfigProfile(path, c.profile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := fresh.Validate(); err != nil {
+		return nil, nil, nil, fmt.Errorf("новая конфигурация не применена: %w", err)
+	}
+	if _, err := fresh.GetInstrumentFilter(); err != nil {
+		return nil, nil, nil, fmt.Errorf("новая конфигурация не применена: %w", err)
+	}
+	warnings = fresh.ValidateLimits()
+
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if fresh.Logging.Level != c.Logging.Level {
+		changed = append(changed, fmt.Sprintf("logging.level: %q -> %q", c.Logging.Level, fresh.Logging.Level))
+		c.Logging.Level = fresh.Logging.Level
+	}
+	if fresh.Loading.RateLimitPause != c.Loading.RateLimitPause {
+		changed = append(changed, fmt.Sprintf("loading.rate_limit_pause: %d -> %d", c.Loading.RateLimitPause, fresh.Loading.RateLimitPause))
+		c.Loading.RateLimitPause = fresh.Loading.RateLimitPause
+	}
+	if !maps.Equal(fresh.Loading.Limits, c.Loading.Limits) {
+		changed = append(changed, fmt.Sprintf("loading.limits: %v -> %v", c.Loading.Limits, fresh.Loading.Limits))
+		c.Loading.Limits = fresh.Loading.Limits
+	}
+
+	if fresh.Loading.AllowlistFile != c.Loading.AllowlistFile {
+		changed = append(changed, fmt.Sprintf("loading.allowlist_file: %q -> %q", c.Loading.AllowlistFile, fresh.Loading.AllowlistFile))
+		c.Loading.AllowlistFile = fresh.Loading.AllowlistFile
+	}
+	if fresh.Loading.DenylistFile != c.Loading.DenylistFile {
+		changed = append(changed, fmt.Sprintf("loading.denylist_file: %q -> %q", c.Loading.DenylistFile, fresh.Loading.DenylistFile))
+		c.Loading.DenylistFile = fresh.Loading.DenylistFile
+	}
+
+	// Подключения к БД и API создаются при запуске
+	if fresh.Database.Host != c.Database.Host || fresh.Database.Port != c.Database.Port ||
+		fresh.Database.DBName != c.Database.DBName || fresh.Database.User != c.Database.User ||
+		fresh.Database.Password != c.Database.Password || fresh.Database.Schema != c.Database.Schema {
+		ignored = append(ignored, "database")
+	}
+	if fresh.Tinvest.Endpoint != c.Tinvest.Endpoint || fresh.Tinvest.Token != c.Tinvest.Token ||
+		!slices.Equal(fresh.Tinvest.Tokens, c.Tinvest.Tokens) {
+		ignored = append(ignored, "tinvest")
+	}
+	// Пулы воркеров и общий лимит запросов настраиваются при запуске
+	if fresh.Loading.IntervalWorkers != c.Loading.IntervalWorkers {
+		ignored = append(ignored, "loading.interval_workers")
+	}
+	if fresh.Loading.InstrumentWorkers != c.Loading.InstrumentWorkers {
+		ignored = append(ignored, "loading.instrument_workers")
+	}
+
+	return changed, ignored, warnings, nil
+}
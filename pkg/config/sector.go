@@ -0,0 +1,92 @@
+// Package config содержит общие функции и константы для загрузчиков
+// Market Loader
+//
+// # Copyright (C) 2025 Maxim Motylkov
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+package config
+
+import "strings"
+
+// Канонические секторы (по мотивам GICS) для instruments.sector_normalized
+const (
+	SectorEnergy                = "energy"
+	SectorMaterials             = "materials"
+	SectorIndustrials           = "industrials"
+	SectorConsumerDiscretionary = "consumer_discretionary"
+	SectorConsumerStaples       = "consumer_staples"
+	SectorHealthCare            = "health_care"
+	SectorFinancials            = "financials"
+	SectorIT                    = "information_technology"
+	SectorCommunication         = "communication_services"
+	SectorUtilities             = "utilities"
+	SectorRealEstate            = "real_estate"
+	SectorGovernment            = "government"
+	SectorOther                 = "other"
+)
+
+// DefaultSectorMapping соответствие сектора из API (без учёта регистра) каноническому сектору
+var DefaultSectorMapping = map[string]string{
+	"energy":                 SectorEnergy,
+	"oil_and_gas":            SectorEnergy,
+	"green_energy":           SectorEnergy,
+	"materials":              SectorMaterials,
+	"ecomaterials":           SectorMaterials,
+	"industrials":            SectorIndustrials,
+	"industrial":             SectorIndustrials,
+	"electrocars":            SectorConsumerDiscretionary,
+	"consumer":               SectorConsumerDiscretionary,
+	"consumer_discretionary": SectorConsumerDiscretionary,
+	"consumer_staples":       SectorConsumerStaples,
+	"health_care":            SectorHealthCare,
+	"healthcare":             SectorHealthCare,
+	"financial":              SectorFinancials,
+	"financials":             SectorFinancials,
+	"finance":                SectorFinancials,
+	"it":                     SectorIT,
+	"tech":                   SectorIT,
+	"technology":             SectorIT,
+	"information_technology": SectorIT,
+	"telecom":                SectorCommunication,
+	"telecommunication":      SectorCommunication,
+	"communication_services": SectorCommunication,
+	"utilities":              SectorUtilities,
+	"real_estate":            SectorRealEstate,
+	"green_buildings":        SectorRealEstate,
+	"government":             SectorGovernment,
+	"municipal":              SectorGovernment,
+	"other":                  SectorOther,
+}
+
+// GetSectorMapping возвращает соответствие секторов: DefaultSectorMapping с переопределениями из loading.sector_mapping
+func (c *Config) GetSectorMapping() map[string]string {
+	mapping := make(map[string]string, len(DefaultSectorMapping)+len(c.Loading.SectorMapping))
+	for raw, sector := range DefaultSectorMapping {
+		mapping[raw] = sector
+	}
+	for raw, sector := range c.Loading.SectorMapping {
+		mapping[sectorKey(raw)] = strings.TrimSpace(sector)
+	}
+	return mapping
+}
+
+// NormalizeSector приводит сектор из API к каноническому по mapping:
+// пустой сектор остаётся пустым, неизвестный - SectorOther
+func NormalizeSector(raw string, mapping map[string]string) string {
+	key := sectorKey(raw)
+	if key == "" {
+		return ""
+	}
+	if sector, exists := mapping[key]; exists {
+		return sector
+	}
+	return SectorOther
+}
+
+// sectorKey ключ сектора для поиска в соответствии: нижний регистр, пробелы и дефисы заменены на "_"
+func sectorKey(raw string) string {
+	key := strings.ToLower(strings.TrimSpace(raw))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+}
@@ -14,6 +14,7 @@ import (
 
 	"market-loader/pkg/config"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -22,7 +23,15 @@ func Connect(ctx context.Context, dbConfig *config.DatabaseConfig) (*pgxpool.Poo
 	dbURL := fmt.Sprintf("postgresql://%s:%s@%s:%d/%s?sslmode=%s",
 		dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.DBName, dbConfig.SSLMode)
 
-	dbpool, err := pgxpool.New(ctx, dbURL)
+	poolConfig, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора параметров подключения: %w", err)
+	}
+
+	// Все запросы без явной схемы выполняются в схеме из конфигурации
+	poolConfig.ConnConfig.RuntimeParams["search_path"] = pgx.Identifier{dbConfig.GetSchema()}.Sanitize()
+
+	dbpool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания пула подключений: %w", err)
 	}
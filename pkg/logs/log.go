@@ -19,18 +19,7 @@ func SetupLogger(cfg *config.Config) *logrus.Logger {
 	logger := logrus.New()
 
 	// Устанавливаем уровень логирования
-	switch cfg.Logging.Level {
-	case "debug":
-		logger.SetLevel(logrus.DebugLevel)
-	case "info":
-		logger.SetLevel(logrus.InfoLevel)
-	case "warn":
-		logger.SetLevel(logrus.WarnLevel)
-	case "error":
-		logger.SetLevel(logrus.ErrorLevel)
-	default:
-		logger.SetLevel(logrus.InfoLevel)
-	}
+	logger.SetLevel(ParseLevel(cfg.Logging.Level))
 
 	// Устанавливаем формат логирования
 	if cfg.Logging.Format == "json" {
@@ -43,3 +32,19 @@ func SetupLogger(cfg *config.Config) *logrus.Logger {
 
 	return logger
 }
+
+// ParseLevel возвращает уровень логирования по имени из конфигурации (по умолчанию info)
+func ParseLevel(level string) logrus.Level {
+	switch level {
+	case "debug":
+		return logrus.DebugLevel
+	case "info":
+		return logrus.InfoLevel
+	case "warn":
+		return logrus.WarnLevel
+	case "error":
+		return logrus.ErrorLevel
+	default:
+		return logrus.InfoLevel
+	}
+}